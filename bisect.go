@@ -0,0 +1,715 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/object"
+	"github.com/goabstract/go-git/v5/plumbing/storer"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+const (
+	bisectStartFile = "BISECT_START"
+	bisectTermsFile = "BISECT_TERMS"
+	bisectLogFile   = "BISECT_LOG"
+	bisectNamesFile = "BISECT_NAMES"
+
+	bisectStart       plumbing.ReferenceName = "BISECT_START"
+	bisectHead        plumbing.ReferenceName = "BISECT_HEAD"
+	bisectExpectedRev plumbing.ReferenceName = "BISECT_EXPECTED_REV"
+	bisectBad         plumbing.ReferenceName = "refs/bisect/bad"
+
+	bisectRefPrefix  = "refs/bisect/"
+	bisectGoodPrefix = bisectRefPrefix + "good-"
+	bisectSkipPrefix = bisectRefPrefix + "skip-"
+)
+
+var (
+	ErrBisectNotStarted       = errors.New("bisect not started")
+	ErrBisectInProgress       = errors.New("bisect already in progress")
+	ErrBisectNeedsGoodAndBad  = errors.New("bisect needs a good and a bad commit")
+	ErrBisectNotFinished      = errors.New("bisect not finished")
+	ErrBisectOnlySkippedLeft  = errors.New("only skipped commits left to test")
+	ErrBisectBadBeforeGood    = errors.New("the bad commit is an ancestor of a good commit")
+	ErrInvalidBisectResult    = errors.New("invalid bisect result")
+	ErrBisectUnknownStartHead = errors.New("unable to restore the original HEAD of the bisect")
+)
+
+// BisectResult is the outcome of testing a commit during a bisection.
+type BisectResult int
+
+const (
+	// BisectGood marks the commit as not containing the searched change.
+	BisectGood BisectResult = iota + 1
+	// BisectBad marks the commit as containing the searched change.
+	BisectBad
+	// BisectSkip marks the commit as not testable.
+	BisectSkip
+)
+
+func (r BisectResult) String() string {
+	switch r {
+	case BisectGood:
+		return "good"
+	case BisectBad:
+		return "bad"
+	case BisectSkip:
+		return "skip"
+	}
+
+	return "unknown"
+}
+
+// Bisect is a binary search, over the history of a repository, of the commit
+// that introduced a change. Its state is stored in the repository using the
+// same files and references as `git bisect`, so a bisection can be started
+// with go-git and continued with git, or the other way around.
+type Bisect struct {
+	r          *Repository
+	noCheckout bool
+
+	bad  plumbing.Hash
+	good []plumbing.Hash
+	skip []plumbing.Hash
+}
+
+// BisectStart starts a new bisection, as `git bisect start`. If the options
+// contain the bad and good commits the first commit to test is selected. In
+// repositories with a worktree, every commit to test is checked out, unless
+// NoCheckout is set; otherwise the commit to test is stored at BISECT_HEAD.
+func (r *Repository) BisectStart(o *BisectOptions) (*Bisect, error) {
+	if _, err := r.Bisect(); err != ErrBisectNotStarted {
+		if err == nil {
+			err = ErrBisectInProgress
+		}
+
+		return nil, err
+	}
+
+	b := &Bisect{r: r, noCheckout: o.NoCheckout || r.wt == nil}
+	if err := b.writeStart(); err != nil {
+		return nil, err
+	}
+
+	// BISECT_HEAD is written at the start, as git does, so the mode is kept
+	// when the bisection is loaded again before the first commit to test.
+	if b.noCheckout {
+		head, err := storer.ResolveReference(r.Storer, plumbing.HEAD)
+		if err != nil {
+			return nil, err
+		}
+
+		ref := plumbing.NewHashReference(bisectHead, head.Hash())
+		if err := r.Storer.SetReference(ref); err != nil {
+			return nil, err
+		}
+	}
+
+	if !o.Bad.IsZero() {
+		if err := b.mark(BisectBad, o.Bad); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, h := range o.Good {
+		if err := b.mark(BisectGood, h); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, b.next()
+}
+
+// Bisect returns the bisection in progress, ErrBisectNotStarted is returned
+// if there is none.
+func (r *Repository) Bisect() (*Bisect, error) {
+	b := &Bisect{r: r}
+	if _, err := b.readStart(); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.Storer.Reference(bisectHead); err == nil {
+		b.noCheckout = true
+	} else if err != plumbing.ErrReferenceNotFound {
+		return nil, err
+	}
+
+	refs, err := r.Storer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().String()
+		switch {
+		case ref.Name() == bisectBad:
+			b.bad = ref.Hash()
+		case strings.HasPrefix(name, bisectGoodPrefix):
+			b.good = append(b.good, ref.Hash())
+		case strings.HasPrefix(name, bisectSkipPrefix):
+			b.skip = append(b.skip, ref.Hash())
+		}
+
+		return nil
+	})
+
+	return b, err
+}
+
+// Bad marks the given commit as bad, and selects the next commit to test.
+func (b *Bisect) Bad(h plumbing.Hash) error {
+	if err := b.mark(BisectBad, h); err != nil {
+		return err
+	}
+
+	return b.next()
+}
+
+// Good marks the given commits as good, and selects the next commit to test.
+func (b *Bisect) Good(hs ...plumbing.Hash) error {
+	for _, h := range hs {
+		if err := b.mark(BisectGood, h); err != nil {
+			return err
+		}
+	}
+
+	return b.next()
+}
+
+// Skip marks the given commits as not testable, and selects the next commit
+// to test.
+func (b *Bisect) Skip(hs ...plumbing.Hash) error {
+	for _, h := range hs {
+		if err := b.mark(BisectSkip, h); err != nil {
+			return err
+		}
+	}
+
+	return b.next()
+}
+
+// Current returns the commit to be tested: BISECT_HEAD when the commits are
+// not checked out, HEAD otherwise.
+func (b *Bisect) Current() (*object.Commit, error) {
+	name := plumbing.HEAD
+	if b.noCheckout {
+		name = bisectHead
+	}
+
+	ref, err := storer.ResolveReference(b.r.Storer, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.r.CommitObject(ref.Hash())
+}
+
+// FirstBad returns the first bad commit, if the bisection has finished.
+// Otherwise ErrBisectNotFinished is returned, or ErrBisectOnlySkippedLeft if
+// the only commits left to test were skipped.
+func (b *Bisect) FirstBad() (*object.Commit, error) {
+	next, err := b.bisect()
+	if err != nil {
+		return nil, err
+	}
+
+	if next != b.bad {
+		return nil, ErrBisectNotFinished
+	}
+
+	return b.r.CommitObject(b.bad)
+}
+
+// Run bisects automatically, calling fn for every commit to test until the
+// first bad commit is found, as `git bisect run`. Both a good and a bad
+// commit are required to be marked before running it.
+func (b *Bisect) Run(fn func(*object.Commit) (BisectResult, error)) (*object.Commit, error) {
+	for {
+		c, err := b.FirstBad()
+		if err != ErrBisectNotFinished {
+			return c, err
+		}
+
+		if c, err = b.Current(); err != nil {
+			return nil, err
+		}
+
+		res, err := fn(c)
+		if err != nil {
+			return nil, err
+		}
+
+		switch res {
+		case BisectGood:
+			err = b.Good(c.Hash)
+		case BisectBad:
+			err = b.Bad(c.Hash)
+		case BisectSkip:
+			err = b.Skip(c.Hash)
+		default:
+			err = ErrInvalidBisectResult
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Reset finishes the bisection, removing its state and checking out back the
+// branch or commit where it was started, as `git bisect reset`.
+func (b *Bisect) Reset() error {
+	start, err := b.readStart()
+	if err != nil {
+		return err
+	}
+
+	if !b.noCheckout {
+		if err := b.checkoutStart(start); err != nil {
+			return err
+		}
+	}
+
+	return b.removeState()
+}
+
+func (b *Bisect) checkoutStart(start string) error {
+	w, err := b.r.Worktree()
+	if err != nil {
+		return err
+	}
+
+	if plumbing.IsHash(start) {
+		return w.Checkout(&CheckoutOptions{Hash: plumbing.NewHash(start)})
+	}
+
+	return w.Checkout(&CheckoutOptions{Branch: plumbing.NewBranchReferenceName(start)})
+}
+
+func (b *Bisect) removeState() error {
+	refs, err := b.r.Storer.IterReferences()
+	if err != nil {
+		return err
+	}
+
+	var names []plumbing.ReferenceName
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().String(), bisectRefPrefix) {
+			names = append(names, ref.Name())
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	names = append(names, bisectHead, bisectExpectedRev)
+	if _, ok := b.dotGit(); !ok {
+		names = append(names, bisectStart)
+	}
+
+	for _, name := range names {
+		if err := b.r.Storer.RemoveReference(name); err != nil {
+			return err
+		}
+	}
+
+	fs, ok := b.dotGit()
+	if !ok {
+		return nil
+	}
+
+	for _, name := range []string{bisectStartFile, bisectTermsFile, bisectLogFile, bisectNamesFile} {
+		if err := fs.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mark stores the result of testing a commit as a reference and logs it.
+func (b *Bisect) mark(res BisectResult, h plumbing.Hash) error {
+	c, err := b.r.CommitObject(h)
+	if err != nil {
+		return err
+	}
+
+	var name plumbing.ReferenceName
+	switch res {
+	case BisectBad:
+		name = bisectBad
+		b.bad = h
+	case BisectGood:
+		name = plumbing.ReferenceName(bisectGoodPrefix + h.String())
+		b.good = append(b.good, h)
+	case BisectSkip:
+		name = plumbing.ReferenceName(bisectSkipPrefix + h.String())
+		b.skip = append(b.skip, h)
+	default:
+		return ErrInvalidBisectResult
+	}
+
+	if err := b.r.Storer.SetReference(plumbing.NewHashReference(name, h)); err != nil {
+		return err
+	}
+
+	return b.log(fmt.Sprintf("# %s: [%s] %s\ngit bisect %s %s\n",
+		res, h, commitSubject(c.Message), res, h,
+	))
+}
+
+// next selects the next commit to test and checks it out, if enough
+// information is available.
+func (b *Bisect) next() error {
+	if b.bad.IsZero() || len(b.good) == 0 {
+		return nil
+	}
+
+	next, err := b.bisect()
+	if err == ErrBisectOnlySkippedLeft {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if next == b.bad {
+		c, err := b.r.CommitObject(next)
+		if err != nil {
+			return err
+		}
+
+		return b.log(fmt.Sprintf("# first bad commit: [%s] %s\n", next, commitSubject(c.Message)))
+	}
+
+	ref := plumbing.NewHashReference(bisectExpectedRev, next)
+	if err := b.r.Storer.SetReference(ref); err != nil {
+		return err
+	}
+
+	if b.noCheckout {
+		return b.r.Storer.SetReference(plumbing.NewHashReference(bisectHead, next))
+	}
+
+	w, err := b.r.Worktree()
+	if err != nil {
+		return err
+	}
+
+	return w.Checkout(&CheckoutOptions{Hash: next})
+}
+
+// bisect returns the next commit to test, or the bad commit if it is known to
+// be the first bad one.
+func (b *Bisect) bisect() (plumbing.Hash, error) {
+	if b.bad.IsZero() || len(b.good) == 0 {
+		return plumbing.ZeroHash, ErrBisectNeedsGoodAndBad
+	}
+
+	candidates, err := b.candidates()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	skipped := make(map[plumbing.Hash]bool, len(b.skip))
+	for _, h := range b.skip {
+		skipped[h] = true
+	}
+
+	weights, err := bisectWeights(candidates)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	total := len(candidates)
+	best, bestDistance := plumbing.ZeroHash, -1
+	for h, w := range weights {
+		if h == b.bad || skipped[h] {
+			continue
+		}
+
+		distance := w
+		if total-w < distance {
+			distance = total - w
+		}
+
+		// ties are broken by hash to make the selection stable
+		if distance > bestDistance || distance == bestDistance && h.String() < best.String() {
+			best, bestDistance = h, distance
+		}
+	}
+
+	if best.IsZero() {
+		if total > 1 {
+			return plumbing.ZeroHash, ErrBisectOnlySkippedLeft
+		}
+
+		return b.bad, nil
+	}
+
+	return best, nil
+}
+
+// candidates returns the commits reachable from the bad commit and not
+// reachable from any good commit.
+func (b *Bisect) candidates() (map[plumbing.Hash]*object.Commit, error) {
+	excluded := make(map[plumbing.Hash]bool)
+	if err := b.walk(b.good, func(c *object.Commit) bool {
+		excluded[c.Hash] = true
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	if excluded[b.bad] {
+		return nil, ErrBisectBadBeforeGood
+	}
+
+	candidates := make(map[plumbing.Hash]*object.Commit)
+	err := b.walk([]plumbing.Hash{b.bad}, func(c *object.Commit) bool {
+		if excluded[c.Hash] {
+			return false
+		}
+
+		candidates[c.Hash] = c
+		return true
+	})
+
+	return candidates, err
+}
+
+// walk visits the commits reachable from the given ones, the parents of a
+// commit are only visited if fn returns true.
+func (b *Bisect) walk(from []plumbing.Hash, fn func(*object.Commit) bool) error {
+	seen := make(map[plumbing.Hash]bool)
+	pending := append([]plumbing.Hash{}, from...)
+	for len(pending) > 0 {
+		h := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if seen[h] {
+			continue
+		}
+
+		seen[h] = true
+		c, err := b.r.CommitObject(h)
+		if err != nil {
+			return err
+		}
+
+		if fn(c) {
+			pending = append(pending, c.ParentHashes...)
+		}
+	}
+
+	return nil
+}
+
+// bisectWeights returns, for every candidate, the number of candidates
+// reachable from it, including itself. Commits with a single candidate parent
+// are computed incrementally, the others by walking their ancestors.
+func bisectWeights(candidates map[plumbing.Hash]*object.Commit) (map[plumbing.Hash]int, error) {
+	weights := make(map[plumbing.Hash]int, len(candidates))
+	for _, h := range topoOrder(candidates) {
+		var parents []plumbing.Hash
+		for _, p := range candidates[h].ParentHashes {
+			if _, ok := candidates[p]; ok {
+				parents = append(parents, p)
+			}
+		}
+
+		switch len(parents) {
+		case 0:
+			weights[h] = 1
+		case 1:
+			weights[h] = weights[parents[0]] + 1
+		default:
+			weights[h] = countReachable(candidates, h)
+		}
+	}
+
+	return weights, nil
+}
+
+// topoOrder returns the candidates sorted so every commit comes after its
+// parents.
+func topoOrder(candidates map[plumbing.Hash]*object.Commit) []plumbing.Hash {
+	order := make([]plumbing.Hash, 0, len(candidates))
+	visited := make(map[plumbing.Hash]bool, len(candidates))
+
+	type frame struct {
+		h    plumbing.Hash
+		next int
+	}
+
+	for h := range candidates {
+		if visited[h] {
+			continue
+		}
+
+		visited[h] = true
+		stack := []*frame{{h: h}}
+		for len(stack) > 0 {
+			f := stack[len(stack)-1]
+			parents := candidates[f.h].ParentHashes
+			if f.next == len(parents) {
+				order = append(order, f.h)
+				stack = stack[:len(stack)-1]
+				continue
+			}
+
+			p := parents[f.next]
+			f.next++
+			if _, ok := candidates[p]; ok && !visited[p] {
+				visited[p] = true
+				stack = append(stack, &frame{h: p})
+			}
+		}
+	}
+
+	return order
+}
+
+func countReachable(candidates map[plumbing.Hash]*object.Commit, from plumbing.Hash) int {
+	seen := map[plumbing.Hash]bool{from: true}
+	pending := []plumbing.Hash{from}
+	for len(pending) > 0 {
+		h := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for _, p := range candidates[h].ParentHashes {
+			if _, ok := candidates[p]; ok && !seen[p] {
+				seen[p] = true
+				pending = append(pending, p)
+			}
+		}
+	}
+
+	return len(seen)
+}
+
+// dotGit returns the filesystem of the repository storage, if it is file
+// based.
+func (b *Bisect) dotGit() (billy.Filesystem, bool) {
+	type fsBased interface {
+		Filesystem() billy.Filesystem
+	}
+
+	fs, ok := b.r.Storer.(fsBased)
+	if !ok {
+		return nil, false
+	}
+
+	return fs.Filesystem(), true
+}
+
+// writeStart stores the branch, or the commit if detached, where HEAD points
+// when the bisection starts. File based storages use the BISECT_* files as
+// git does, others store it at the BISECT_START reference.
+func (b *Bisect) writeStart() error {
+	head, err := b.r.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return err
+	}
+
+	fs, ok := b.dotGit()
+	if !ok {
+		if head.Type() == plumbing.SymbolicReference {
+			return b.r.Storer.SetReference(plumbing.NewSymbolicReference(bisectStart, head.Target()))
+		}
+
+		return b.r.Storer.SetReference(plumbing.NewHashReference(bisectStart, head.Hash()))
+	}
+
+	start := head.Hash().String()
+	if head.Type() == plumbing.SymbolicReference {
+		start = head.Target().Short()
+	}
+
+	files := []struct{ name, content string }{
+		{bisectStartFile, start + "\n"},
+		{bisectTermsFile, "bad\ngood\n"},
+		{bisectNamesFile, "\n"},
+		{bisectLogFile, "git bisect start\n"},
+	}
+
+	for _, f := range files {
+		if err := util.WriteFile(fs, f.name, []byte(f.content), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readStart returns the branch short name or commit hash where the bisection
+// was started.
+func (b *Bisect) readStart() (string, error) {
+	fs, ok := b.dotGit()
+	if !ok {
+		ref, err := b.r.Storer.Reference(bisectStart)
+		if err == plumbing.ErrReferenceNotFound {
+			return "", ErrBisectNotStarted
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		if ref.Type() == plumbing.SymbolicReference {
+			return ref.Target().Short(), nil
+		}
+
+		return ref.Hash().String(), nil
+	}
+
+	f, err := fs.Open(bisectStartFile)
+	if os.IsNotExist(err) {
+		return "", ErrBisectNotStarted
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+
+	start := strings.TrimSpace(string(data))
+	if start == "" {
+		return "", ErrBisectUnknownStartHead
+	}
+
+	return start, nil
+}
+
+func (b *Bisect) log(msg string) error {
+	fs, ok := b.dotGit()
+	if !ok {
+		return nil
+	}
+
+	f, err := fs.OpenFile(bisectLogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write([]byte(msg)); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
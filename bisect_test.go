@@ -0,0 +1,269 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/object"
+	"github.com/goabstract/go-git/v5/storage/memory"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	. "gopkg.in/check.v1"
+)
+
+type BisectSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&BisectSuite{})
+
+// commitHistory commits n versions of the file "version", the content of the
+// bug-th version and the following ones contain "bug".
+func (s *BisectSuite) commitHistory(c *C, r *Repository, n, bug int) []plumbing.Hash {
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	sig := defaultSignature()
+	var hashes []plumbing.Hash
+	for i := 0; i < n; i++ {
+		content := fmt.Sprintf("version %d\n", i)
+		if i >= bug {
+			content += "bug\n"
+		}
+
+		c.Assert(util.WriteFile(w.Filesystem, "version", []byte(content), 0644), IsNil)
+		_, err = w.Add("version")
+		c.Assert(err, IsNil)
+
+		sig.When = sig.When.Add(time.Hour)
+		h, err := w.Commit(fmt.Sprintf("commit %d", i), &CommitOptions{Author: sig})
+		c.Assert(err, IsNil)
+		hashes = append(hashes, h)
+	}
+
+	return hashes
+}
+
+func hasBug(c *object.Commit) (BisectResult, error) {
+	f, err := c.File("version")
+	if err != nil {
+		return 0, err
+	}
+
+	content, err := f.Contents()
+	if err != nil {
+		return 0, err
+	}
+
+	if strings.Contains(content, "bug") {
+		return BisectBad, nil
+	}
+
+	return BisectGood, nil
+}
+
+func (s *BisectSuite) TestBisectRun(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+	hashes := s.commitHistory(c, r, 20, 13)
+
+	b, err := r.BisectStart(&BisectOptions{
+		Bad:  hashes[19],
+		Good: []plumbing.Hash{hashes[0]},
+	})
+	c.Assert(err, IsNil)
+
+	var tested int
+	first, err := b.Run(func(commit *object.Commit) (BisectResult, error) {
+		tested++
+		head, err := r.Head()
+		c.Assert(err, IsNil)
+		c.Assert(head.Hash(), Equals, commit.Hash)
+		return hasBug(commit)
+	})
+	c.Assert(err, IsNil)
+	c.Assert(first.Hash, Equals, hashes[13])
+	c.Assert(tested <= 5, Equals, true)
+
+	c.Assert(b.Reset(), IsNil)
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.Master)
+
+	_, err = r.Bisect()
+	c.Assert(err, Equals, ErrBisectNotStarted)
+}
+
+func (s *BisectSuite) TestBisectManual(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+	hashes := s.commitHistory(c, r, 4, 2)
+
+	b, err := r.BisectStart(&BisectOptions{})
+	c.Assert(err, IsNil)
+
+	_, err = r.BisectStart(&BisectOptions{})
+	c.Assert(err, Equals, ErrBisectInProgress)
+
+	_, err = b.FirstBad()
+	c.Assert(err, Equals, ErrBisectNeedsGoodAndBad)
+
+	c.Assert(b.Bad(hashes[3]), IsNil)
+	c.Assert(b.Good(hashes[0]), IsNil)
+
+	b, err = r.Bisect()
+	c.Assert(err, IsNil)
+
+	current, err := b.Current()
+	c.Assert(err, IsNil)
+	c.Assert(current.Hash == hashes[1] || current.Hash == hashes[2], Equals, true)
+
+	_, err = b.FirstBad()
+	c.Assert(err, Equals, ErrBisectNotFinished)
+
+	c.Assert(b.Good(hashes[1]), IsNil)
+	current, err = b.Current()
+	c.Assert(err, IsNil)
+	c.Assert(current.Hash, Equals, hashes[2])
+
+	c.Assert(b.Bad(hashes[2]), IsNil)
+	first, err := b.FirstBad()
+	c.Assert(err, IsNil)
+	c.Assert(first.Hash, Equals, hashes[2])
+}
+
+func (s *BisectSuite) TestBisectSkip(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+	hashes := s.commitHistory(c, r, 3, 2)
+
+	b, err := r.BisectStart(&BisectOptions{Bad: hashes[2], Good: []plumbing.Hash{hashes[0]}})
+	c.Assert(err, IsNil)
+
+	current, err := b.Current()
+	c.Assert(err, IsNil)
+	c.Assert(current.Hash, Equals, hashes[1])
+
+	c.Assert(b.Skip(hashes[1]), IsNil)
+	_, err = b.FirstBad()
+	c.Assert(err, Equals, ErrBisectOnlySkippedLeft)
+}
+
+func (s *BisectSuite) TestBisectBare(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+	hashes := s.commitHistory(c, r, 10, 7)
+
+	bare, err := Open(r.Storer, nil)
+	c.Assert(err, IsNil)
+
+	b, err := bare.BisectStart(&BisectOptions{Bad: hashes[9], Good: []plumbing.Hash{hashes[0]}})
+	c.Assert(err, IsNil)
+
+	first, err := b.Run(hasBug)
+	c.Assert(err, IsNil)
+	c.Assert(first.Hash, Equals, hashes[7])
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, hashes[9])
+
+	c.Assert(b.Reset(), IsNil)
+	_, err = r.Storer.Reference(bisectHead)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *BisectSuite) TestBisectStateFiles(c *C) {
+	dir, err := ioutil.TempDir("", "bisect")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	r, err := PlainInit(dir, false)
+	c.Assert(err, IsNil)
+	hashes := s.commitHistory(c, r, 4, 2)
+
+	_, err = r.BisectStart(&BisectOptions{Bad: hashes[3], Good: []plumbing.Hash{hashes[0]}})
+	c.Assert(err, IsNil)
+
+	start, err := ioutil.ReadFile(filepath.Join(dir, ".git", "BISECT_START"))
+	c.Assert(err, IsNil)
+	c.Assert(string(start), Equals, "master\n")
+
+	log, err := ioutil.ReadFile(filepath.Join(dir, ".git", "BISECT_LOG"))
+	c.Assert(err, IsNil)
+	c.Assert(string(log), Equals, fmt.Sprintf(""+
+		"git bisect start\n"+
+		"# bad: [%[1]s] commit 3\n"+
+		"git bisect bad %[1]s\n"+
+		"# good: [%[2]s] commit 0\n"+
+		"git bisect good %[2]s\n",
+		hashes[3], hashes[0],
+	))
+
+	bad, err := ioutil.ReadFile(filepath.Join(dir, ".git", "refs", "bisect", "bad"))
+	c.Assert(err, IsNil)
+	c.Assert(string(bad), Equals, hashes[3].String()+"\n")
+
+	r, err = PlainOpen(dir)
+	c.Assert(err, IsNil)
+	b, err := r.Bisect()
+	c.Assert(err, IsNil)
+	c.Assert(b.Reset(), IsNil)
+
+	_, err = os.Stat(filepath.Join(dir, ".git", "BISECT_START"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+	_, err = os.Stat(filepath.Join(dir, ".git", "refs", "bisect", "bad"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *BisectSuite) TestBisectWeights(c *C) {
+	// a - b - d - e
+	//  \- c -/
+	a := &object.Commit{Hash: plumbing.NewHash("a1")}
+	b := &object.Commit{Hash: plumbing.NewHash("b1"), ParentHashes: []plumbing.Hash{a.Hash}}
+	cc := &object.Commit{Hash: plumbing.NewHash("c1"), ParentHashes: []plumbing.Hash{a.Hash}}
+	d := &object.Commit{Hash: plumbing.NewHash("d1"), ParentHashes: []plumbing.Hash{b.Hash, cc.Hash}}
+	e := &object.Commit{Hash: plumbing.NewHash("e1"), ParentHashes: []plumbing.Hash{d.Hash}}
+
+	candidates := make(map[plumbing.Hash]*object.Commit)
+	for _, commit := range []*object.Commit{a, b, cc, d, e} {
+		candidates[commit.Hash] = commit
+	}
+
+	weights, err := bisectWeights(candidates)
+	c.Assert(err, IsNil)
+	c.Assert(weights, DeepEquals, map[plumbing.Hash]int{
+		a.Hash: 1, b.Hash: 2, cc.Hash: 2, d.Hash: 4, e.Hash: 5,
+	})
+}
+
+func (s *BisectSuite) TestBisectNoCheckoutReload(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+	hashes := s.commitHistory(c, r, 4, 2)
+
+	_, err = r.BisectStart(&BisectOptions{Bad: hashes[3], NoCheckout: true})
+	c.Assert(err, IsNil)
+
+	ref, err := r.Storer.Reference(bisectHead)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, hashes[3])
+
+	b, err := r.Bisect()
+	c.Assert(err, IsNil)
+	c.Assert(b.Good(hashes[0]), IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, hashes[3])
+
+	current, err := b.Current()
+	c.Assert(err, IsNil)
+	c.Assert(current.Hash == hashes[1] || current.Hash == hashes[2], Equals, true)
+}
@@ -1,17 +1,36 @@
 package git
 
 import (
-	"bytes"
+	"bufio"
+	"container/heap"
 	"errors"
 	"fmt"
-	"strconv"
+	"io/ioutil"
+	"path/filepath"
 	"strings"
 	"time"
-	"unicode/utf8"
 
 	"github.com/goabstract/go-git/v5/plumbing"
 	"github.com/goabstract/go-git/v5/plumbing/object"
 	"github.com/goabstract/go-git/v5/utils/diff"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const (
+	blameSection           = "blame"
+	blameIgnoreRevsFileKey = "ignoreRevsFile"
+
+	notCommittedYetName  = "Not Committed Yet"
+	notCommittedYetEmail = "not.committed.yet"
+)
+
+var (
+	// ErrInvalidLineRange is returned when a line range is out of the
+	// bounds of the blamed file.
+	ErrInvalidLineRange = errors.New("invalid line range")
 )
 
 // BlameResult represents the result of a Blame operation.
@@ -19,284 +38,696 @@ type BlameResult struct {
 	// Path is the path of the File that we're blaming.
 	Path string
 	// Rev (Revision) is the hash of the specified Commit used to generate this result.
+	// It is the zero hash when blaming the worktree version of the file.
 	Rev plumbing.Hash
-	// Lines contains every line with its authorship.
+	// Lines contains every line with its authorship. If line ranges were
+	// requested, it only contains the lines within the ranges.
 	Lines []*Line
+
+	// commits indexed by hash, used to encode the result
+	commits map[plumbing.Hash]*object.Commit
+	// previous holds, for every blamed commit and path, the parent commit
+	// and path where the file was found, as reported by git in porcelain mode
+	previous map[blameKey]blameKey
+}
+
+// Line values represent the contents and author of a line in BlamedResult values.
+type Line struct {
+	// Author is the email address of the last author that modified the line.
+	Author string
+	// AuthorName is the name of the last author that modified the line.
+	AuthorName string
+	// Text is the original text of the line.
+	Text string
+	// Date is when the original text of the line was introduced
+	Date time.Time
+	// Hash is the commit hash that introduced the original line, the zero
+	// hash is used for lines not committed yet.
+	Hash plumbing.Hash
+	// LineNo is the number of the line, starting at 1, in the blamed file.
+	LineNo int
+	// OrigLineNo is the number of the line, starting at 1, in the file of the
+	// commit that introduced it.
+	OrigLineNo int
+	// OrigPath is the path of the file in the commit that introduced the
+	// line, it differs from BlameResult.Path if the file was renamed.
+	OrigPath string
+}
+
+// LineRange is a range of lines, both ends included and starting at 1, as
+// given to `git blame -L <start>,<end>`. An End of 0 means the end of the
+// file.
+type LineRange struct {
+	Start int
+	End   int
 }
 
 // Blame returns a BlameResult with the information about the last author of
 // each line from file `path` at commit `c`.
 func Blame(c *object.Commit, path string) (*BlameResult, error) {
-	// The file to blame is identified by the input arguments:
-	// commit and path. commit is a Commit object obtained from a Repository. Path
-	// represents a path to a specific file contained into the repository.
-	//
-	// Blaming a file is a two step process:
-	//
-	// 1. Create a linear history of the commits affecting a file. We use
-	// revlist.New for that.
-	//
-	// 2. Then build a graph with a node for every line in every file in
-	// the history of the file.
-	//
-	// Each node is assigned a commit: Start by the nodes in the first
-	// commit. Assign that commit as the creator of all its lines.
-	//
-	// Then jump to the nodes in the next commit, and calculate the diff
-	// between the two files. Newly created lines get
-	// assigned the new commit as its origin. Modified lines also get
-	// this new commit. Untouched lines retain the old commit.
-	//
-	// All this work is done in the assignOrigin function which holds all
-	// the internal relevant data in a "blame" struct, that is not
-	// exported.
-	//
-	// TODO: ways to improve the efficiency of this function:
-	// 1. Improve revlist
-	// 2. Improve how to traverse the history (example a backward traversal will
-	// be much more efficient)
-	//
-	// TODO: ways to improve the function in general:
-	// 1. Add memoization between revlist and assign.
-	// 2. It is using much more memory than needed, see the TODOs below.
-
-	b := new(blame)
-	b.fRev = c
-	b.path = path
-
-	// get all the file revisions
-	if err := b.fillRevs(); err != nil {
+	return BlameWithOptions(c, path, &BlameOptions{})
+}
+
+// BlameWithOptions returns a BlameResult with the information about the last
+// author of each line from file `path` at commit `c`, using the given options.
+//
+// The history is walked backwards from `c`, passing the blame of every line
+// to the parents of each commit until the line is found to be introduced by
+// a commit. The walk stops as soon as every line has been attributed.
+func BlameWithOptions(c *object.Commit, path string, o *BlameOptions) (*BlameResult, error) {
+	f, err := c.File(path)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := f.Contents()
+	if err != nil {
+		return nil, err
+	}
+
+	ignore := make(map[plumbing.Hash]bool, len(o.IgnoreRevs))
+	for _, h := range o.IgnoreRevs {
+		ignore[h] = true
+	}
+
+	b := newBlamer(o, ignore)
+	lines, err := b.selectLines(contents)
+	if err != nil {
 		return nil, err
 	}
 
-	// calculate the line tracking graph and fill in
-	// file contents in data.
-	if err := b.fillGraphAndData(); err != nil {
+	b.push(&blameSuspect{commit: c, path: path, file: f, lines: lines})
+	if err := b.run(); err != nil {
 		return nil, err
 	}
 
-	file, err := b.fRev.File(b.path)
+	return b.result(c.Hash, path, contents), nil
+}
+
+// Blame returns a BlameResult for the file `path` at commit `c`, using the
+// given options. Unlike BlameWithOptions, the revisions listed in the file
+// configured at `blame.ignoreRevsFile` are ignored, unless IgnoreRevsFile is
+// set in the options.
+func (r *Repository) Blame(c *object.Commit, path string, o *BlameOptions) (*BlameResult, error) {
+	o, err := r.blameOptions(o)
 	if err != nil {
 		return nil, err
 	}
-	finalLines, err := file.Lines()
+
+	return BlameWithOptions(c, path, o)
+}
+
+// blameOptions returns a copy of the given options with the revisions from
+// the ignore revs file appended.
+func (r *Repository) blameOptions(o *BlameOptions) (*BlameOptions, error) {
+	n := *o
+	file := o.IgnoreRevsFile
+	if file == "" {
+		cfg, err := r.MergedConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		file = cfg.Raw.Section(blameSection).Options.Get(blameIgnoreRevsFileKey)
+	}
+
+	if file == "" {
+		return &n, nil
+	}
+
+	fs, err := r.ignoreRevsFilesystem(file)
 	if err != nil {
 		return nil, err
 	}
 
-	// Each node (line) holds the commit where it was introduced or
-	// last modified. To achieve that we use the FORWARD algorithm
-	// described in Zimmermann, et al. "Mining Version Archives for
-	// Co-changed Lines", in proceedings of the Mining Software
-	// Repositories workshop, Shanghai, May 22-23, 2006.
-	lines, err := newLines(finalLines, b.sliceGraph(len(b.graph)-1))
+	revs, err := readIgnoreRevsFile(fs, file)
 	if err != nil {
 		return nil, err
 	}
 
-	return &BlameResult{
-		Path:  path,
-		Rev:   c.Hash,
-		Lines: lines,
-	}, nil
+	n.IgnoreRevs = append(append([]plumbing.Hash{}, o.IgnoreRevs...), revs...)
+	return &n, nil
 }
 
-// Line values represent the contents and author of a line in BlamedResult values.
-type Line struct {
-	// Author is the email address of the last author that modified the line.
-	Author string
-	// Text is the original text of the line.
-	Text string
-	// Date is when the original text of the line was introduced
-	Date time.Time
-	// Hash is the commit hash that introduced the original line
-	Hash plumbing.Hash
+// ignoreRevsFilesystem returns the filesystem a blame ignore-revs file is
+// read from: the root for absolute paths, the worktree for relative ones or,
+// in bare repositories, the git directory.
+func (r *Repository) ignoreRevsFilesystem(path string) (billy.Filesystem, error) {
+	if filepath.IsAbs(path) {
+		return osfs.New(""), nil
+	}
+
+	if r.wt != nil {
+		return r.wt, nil
+	}
+
+	s, ok := r.Storer.(interface{ Filesystem() billy.Filesystem })
+	if !ok {
+		return nil, ErrIsBareRepository
+	}
+
+	return s.Filesystem(), nil
 }
 
-func newLine(author, text string, date time.Time, hash plumbing.Hash) *Line {
-	return &Line{
-		Author: author,
-		Text:   text,
-		Hash:   hash,
-		Date:   date,
+// readIgnoreRevsFile reads a file with one commit hash per line, as used by
+// `git blame --ignore-revs-file`. Comments and empty lines are skipped.
+func readIgnoreRevsFile(fs billy.Filesystem, path string) ([]plumbing.Hash, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return nil, err
 	}
+
+	defer f.Close()
+
+	var revs []plumbing.Hash
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if !plumbing.IsHash(line) {
+			return nil, fmt.Errorf("invalid object name in %s: %s", path, line)
+		}
+
+		revs = append(revs, plumbing.NewHash(line))
+	}
+
+	return revs, s.Err()
 }
 
-func newLines(contents []string, commits []*object.Commit) ([]*Line, error) {
-	lcontents := len(contents)
-	lcommits := len(commits)
+// Blame returns a BlameResult for the file `path` as found in the worktree,
+// including its uncommitted changes. Lines not committed yet are attributed
+// to the zero hash, with "Not Committed Yet" as author.
+func (w *Worktree) Blame(path string, o *BlameOptions) (*BlameResult, error) {
+	o, err := w.r.blameOptions(o)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := w.Filesystem.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	contents := string(data)
+
+	ignore := make(map[plumbing.Hash]bool, len(o.IgnoreRevs))
+	for _, h := range o.IgnoreRevs {
+		ignore[h] = true
+	}
+
+	b := newBlamer(o, ignore)
+	lines, err := b.selectLines(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	wt := &blameSuspect{
+		commit: notCommittedYet(time.Now()),
+		path:   path,
+		lines:  lines,
+		data:   contents,
+		loaded: true,
+	}
+
+	head, err := w.r.Head()
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return nil, err
+	}
 
-	if lcontents != lcommits {
-		if lcontents == lcommits-1 && contents[lcontents-1] != "\n" {
-			contents = append(contents, "\n")
-		} else {
-			return nil, errors.New("contents and commits have different length")
+	if head != nil {
+		c, err := w.r.CommitObject(head.Hash())
+		if err != nil {
+			return nil, err
 		}
+
+		wt.commit.ParentHashes = []plumbing.Hash{c.Hash}
+		if err := b.passToParent(wt, c); err != nil {
+			return nil, err
+		}
+	}
+
+	b.blameRemaining(wt)
+	if err := b.run(); err != nil {
+		return nil, err
 	}
 
-	result := make([]*Line, 0, lcontents)
-	for i := range contents {
-		result = append(result, newLine(
-			commits[i].Author.Email, contents[i],
-			commits[i].Author.When, commits[i].Hash,
-		))
+	return b.result(plumbing.ZeroHash, path, contents), nil
+}
+
+// notCommittedYet returns the pseudo commit used to attribute the lines of
+// the worktree that were not committed yet.
+func notCommittedYet(when time.Time) *object.Commit {
+	sig := object.Signature{
+		Name:  notCommittedYetName,
+		Email: notCommittedYetEmail,
+		When:  when,
 	}
 
-	return result, nil
+	return &object.Commit{
+		Author:    sig,
+		Committer: sig,
+		Message:   "Version of the file in the worktree",
+	}
 }
 
-// this struct is internally used by the blame function to hold its
-// inputs, outputs and state.
-type blame struct {
-	// the path of the file to blame
+type blameKey struct {
+	hash plumbing.Hash
 	path string
-	// the commit of the final revision of the file to blame
-	fRev *object.Commit
-	// the chain of revisions affecting the the file to blame
-	revs []*object.Commit
-	// the contents of the file across all its revisions
-	data []string
-	// the graph of the lines in the file across all the revisions
-	graph [][]*object.Commit
-}
-
-// calculate the history of a file "path", starting from commit "from", sorted by commit date.
-func (b *blame) fillRevs() error {
-	var err error
-
-	b.revs, err = references(b.fRev, b.path)
-	return err
-}
-
-// build graph of a file from its revision history
-func (b *blame) fillGraphAndData() error {
-	//TODO: not all commits are needed, only the current rev and the prev
-	b.graph = make([][]*object.Commit, len(b.revs))
-	b.data = make([]string, len(b.revs)) // file contents in all the revisions
-	// for every revision of the file, starting with the first
-	// one...
-	for i, rev := range b.revs {
-		// get the contents of the file
-		file, err := rev.File(b.path)
-		if err != nil {
-			return nil
+}
+
+// blameLine is a line of the blamed file, at index final, whose blame is
+// being tracked in a suspect where the line is found at index orig.
+type blameLine struct {
+	final int
+	orig  int
+}
+
+// blameSuspect is a version of the file, at a given commit and path, that
+// may have introduced the lines it holds.
+type blameSuspect struct {
+	commit *object.Commit
+	path   string
+	file   *object.File
+	lines  []blameLine
+
+	data   string
+	loaded bool
+	seq    int
+}
+
+func (s *blameSuspect) key() blameKey {
+	return blameKey{s.commit.Hash, s.path}
+}
+
+func (s *blameSuspect) contents() (string, error) {
+	if s.loaded {
+		return s.data, nil
+	}
+
+	data, err := s.file.Contents()
+	if err != nil {
+		return "", err
+	}
+
+	s.data, s.loaded = data, true
+	return s.data, nil
+}
+
+// blameOrigin is the final attribution of a line.
+type blameOrigin struct {
+	commit *object.Commit
+	path   string
+	orig   int
+}
+
+// blamer holds the state of a blame operation: a queue of suspects, sorted
+// by commit date, and the attribution of every line found so far.
+type blamer struct {
+	o      *BlameOptions
+	ignore map[plumbing.Hash]bool
+
+	queue    blameQueue
+	pushed   int
+	suspects map[blameKey]*blameSuspect
+	origins  []*blameOrigin
+	selected []int
+	previous map[blameKey]blameKey
+}
+
+func newBlamer(o *BlameOptions, ignore map[plumbing.Hash]bool) *blamer {
+	return &blamer{
+		o:        o,
+		ignore:   ignore,
+		suspects: make(map[blameKey]*blameSuspect),
+		previous: make(map[blameKey]blameKey),
+	}
+}
+
+// selectLines returns the lines within the requested ranges, or all of them
+// if no range was requested.
+func (b *blamer) selectLines(contents string) ([]blameLine, error) {
+	n := countLines(contents)
+	b.origins = make([]*blameOrigin, n)
+
+	selected := make([]bool, n)
+	if len(b.o.LineRanges) == 0 {
+		for i := range selected {
+			selected[i] = true
 		}
-		b.data[i], err = file.Contents()
-		if err != nil {
-			return err
+	}
+
+	for _, r := range b.o.LineRanges {
+		end := r.End
+		if end == 0 {
+			end = n
 		}
-		nLines := countLines(b.data[i])
-		// create a node for each line
-		b.graph[i] = make([]*object.Commit, nLines)
-		// assign a commit to each node
-		// if this is the first revision, then the node is assigned to
-		// this first commit.
-		if i == 0 {
-			for j := 0; j < nLines; j++ {
-				b.graph[i][j] = b.revs[i]
-			}
-		} else {
-			// if this is not the first commit, then assign to the old
-			// commit or to the new one, depending on what the diff
-			// says.
-			b.assignOrigin(i, i-1)
+
+		if r.Start < 1 || r.Start > end || end > n {
+			return nil, ErrInvalidLineRange
 		}
+
+		for i := r.Start - 1; i < end; i++ {
+			selected[i] = true
+		}
+	}
+
+	var lines []blameLine
+	for i, ok := range selected {
+		if ok {
+			lines = append(lines, blameLine{final: i, orig: i})
+			b.selected = append(b.selected, i)
+		}
+	}
+
+	return lines, nil
+}
+
+// push adds the lines of the suspect to the queue, merging them with the
+// ones of an already queued suspect for the same commit and path.
+func (b *blamer) push(s *blameSuspect) {
+	if len(s.lines) == 0 {
+		return
+	}
+
+	if prev, ok := b.suspects[s.key()]; ok {
+		prev.lines = append(prev.lines, s.lines...)
+		return
+	}
+
+	b.suspects[s.key()] = s
+	s.seq = b.pushed
+	b.pushed++
+	heap.Push(&b.queue, s)
+}
+
+// pop returns the most recent suspect of the queue.
+func (b *blamer) pop() *blameSuspect {
+	s := heap.Pop(&b.queue).(*blameSuspect)
+	delete(b.suspects, s.key())
+	return s
+}
+
+// blameQueue is a priority queue of suspects, the most recent commit first
+// and, for the same date, the first pushed.
+type blameQueue []*blameSuspect
+
+func (q blameQueue) Len() int { return len(q) }
+
+func (q blameQueue) Less(i, j int) bool {
+	a, b := q[i].commit.Committer.When, q[j].commit.Committer.When
+	if !a.Equal(b) {
+		return a.After(b)
 	}
+
+	return q[i].seq < q[j].seq
+}
+
+func (q blameQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *blameQueue) Push(x interface{}) { *q = append(*q, x.(*blameSuspect)) }
+
+func (q *blameQueue) Pop() interface{} {
+	old := *q
+	s := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return s
+}
+
+func (b *blamer) run() error {
+	for len(b.queue) > 0 {
+		s := b.pop()
+		if err := b.process(s); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// sliceGraph returns a slice of commits (one per line) for a particular
-// revision of a file (0=first revision).
-func (b *blame) sliceGraph(i int) []*object.Commit {
-	fVs := b.graph[i]
-	result := make([]*object.Commit, 0, len(fVs))
-	for _, v := range fVs {
-		c := *v
-		result = append(result, &c)
-	}
-	return result
-}
-
-// Assigns origin to vertexes in current (c) rev from data in its previous (p)
-// revision
-func (b *blame) assignOrigin(c, p int) {
-	// assign origin based on diff info
-	hunks := diff.Do(b.data[p], b.data[c])
-	sl := -1 // source line
-	dl := -1 // destination line
-	for h := range hunks {
-		hLines := countLines(hunks[h].Text)
-		for hl := 0; hl < hLines; hl++ {
-			switch {
-			case hunks[h].Type == 0:
-				sl++
-				dl++
-				b.graph[c][dl] = b.graph[p][sl]
-			case hunks[h].Type == 1:
-				dl++
-				b.graph[c][dl] = b.revs[c]
-			case hunks[h].Type == -1:
-				sl++
-			default:
-				panic("unreachable")
-			}
+// process passes the blame of the lines of the suspect to its parents, the
+// lines not found in any parent are attributed to the suspect.
+func (b *blamer) process(s *blameSuspect) error {
+	parents := make([]*object.Commit, 0, s.commit.NumParents())
+	err := s.commit.Parents().ForEach(func(p *object.Commit) error {
+		parents = append(parents, p)
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// if any parent holds the very same file, it takes all the blame
+	for _, p := range parents {
+		f, err := p.File(s.path)
+		if err != nil || f.Hash != s.file.Hash {
+			continue
 		}
+
+		b.push(&blameSuspect{commit: p, path: s.path, file: f, lines: s.lines})
+		return nil
 	}
+
+	for _, p := range parents {
+		if len(s.lines) == 0 {
+			break
+		}
+
+		if err := b.passToParent(s, p); err != nil {
+			return err
+		}
+	}
+
+	b.blameRemaining(s)
+	return nil
 }
 
-// GoString prints the results of a Blame using git-blame's style.
-func (b *blame) GoString() string {
-	var buf bytes.Buffer
+// passToParent passes to the parent the blame of the lines of the suspect
+// that are not changed between them. The lines that remain in the suspect
+// are the ones changed by it.
+func (b *blamer) passToParent(s *blameSuspect, p *object.Commit) error {
+	f, err := b.findInParent(s, p)
+	if err != nil || f == nil {
+		return err
+	}
+
+	if _, ok := b.previous[s.key()]; !ok {
+		b.previous[s.key()] = blameKey{p.Hash, f.Name}
+	}
 
-	file, err := b.fRev.File(b.path)
+	ps := &blameSuspect{commit: p, path: f.Name, file: f}
+	dst, err := s.contents()
 	if err != nil {
-		panic("PrettyPrint: internal error in repo.Data")
+		return err
 	}
-	contents, err := file.Contents()
+
+	src, err := ps.contents()
 	if err != nil {
-		panic("PrettyPrint: internal error in repo.Data")
+		return err
 	}
 
-	lines := strings.Split(contents, "\n")
-	// max line number length
-	mlnl := len(strconv.Itoa(len(lines)))
-	// max author length
-	mal := b.maxAuthorLength()
-	format := fmt.Sprintf("%%s (%%-%ds %%%dd) %%s\n",
-		mal, mlnl)
+	mapping := mapLines(diff.Do(src, dst), countLines(dst), b.ignore[s.commit.Hash])
+
+	var remaining []blameLine
+	for _, l := range s.lines {
+		if orig := mapping[l.orig]; orig != -1 {
+			ps.lines = append(ps.lines, blameLine{final: l.final, orig: orig})
+			continue
+		}
 
-	fVs := b.graph[len(b.graph)-1]
-	for ln, v := range fVs {
-		fmt.Fprintf(&buf, format, v.Hash.String()[:8],
-			prettyPrintAuthor(fVs[ln]), ln+1, lines[ln])
+		remaining = append(remaining, l)
 	}
-	return buf.String()
+
+	s.lines = remaining
+	b.push(ps)
+	return nil
 }
 
-// utility function to pretty print the author.
-func prettyPrintAuthor(c *object.Commit) string {
-	return fmt.Sprintf("%s %s", c.Author.Name, c.Author.When.Format("2006-01-02"))
+// findInParent returns the suspect file in the parent commit, following
+// renames unless disabled, or nil if the file is not found.
+func (b *blamer) findInParent(s *blameSuspect, p *object.Commit) (*object.File, error) {
+	f, err := p.File(s.path)
+	if err == nil {
+		return f, nil
+	}
+
+	if err != object.ErrFileNotFound {
+		return nil, err
+	}
+
+	if b.o.NoFollowRenames || s.commit.Hash.IsZero() {
+		return nil, nil
+	}
+
+	return findRenameSource(s, p)
 }
 
-// utility function to calculate the number of runes needed
-// to print the longest author name in the blame of a file.
-func (b *blame) maxAuthorLength() int {
-	memo := make(map[plumbing.Hash]struct{}, len(b.graph)-1)
-	fVs := b.graph[len(b.graph)-1]
-	m := 0
-	for ln := range fVs {
-		if _, ok := memo[fVs[ln].Hash]; ok {
+// findRenameSource looks for a file removed between the parent and the
+// suspect commit that is the source of the suspect file. Exact renames are
+// preferred, otherwise the removed file sharing the most lines, at least half
+// of them, is returned.
+func findRenameSource(s *blameSuspect, p *object.Commit) (*object.File, error) {
+	from, err := p.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := s.commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTree(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	for _, ch := range changes {
+		if ch.To.Name != "" || ch.From.Name == "" {
 			continue
 		}
-		memo[fVs[ln].Hash] = struct{}{}
-		m = max(m, utf8.RuneCountInString(prettyPrintAuthor(fVs[ln])))
+
+		if ch.From.TreeEntry.Hash == s.file.Hash {
+			return p.File(ch.From.Name)
+		}
+
+		candidates = append(candidates, ch.From.Name)
+	}
+
+	dst, err := s.contents()
+	if err != nil {
+		return nil, err
 	}
-	return m
+
+	var (
+		best      *object.File
+		bestScore int
+	)
+
+	for _, name := range candidates {
+		f, err := p.File(name)
+		if err != nil {
+			return nil, err
+		}
+
+		src, err := f.Contents()
+		if err != nil {
+			return nil, err
+		}
+
+		if score := commonLines(diff.Do(src, dst)); score > bestScore {
+			best, bestScore = f, score
+		}
+	}
+
+	if bestScore*2 < countLines(dst) {
+		return nil, nil
+	}
+
+	return best, nil
+}
+
+func commonLines(diffs []diffmatchpatch.Diff) int {
+	var n int
+	for _, d := range diffs {
+		if d.Type == diffmatchpatch.DiffEqual {
+			n += countLines(d.Text)
+		}
+	}
+
+	return n
 }
 
-func max(a, b int) int {
-	if a > b {
-		return a
+// mapLines returns, for every line of the destination of the diff, the index
+// of the same line in the source, or -1 if the line was changed. If fuzzy is
+// true, changed lines are mapped to the line at the same offset of the
+// replaced chunk, as done for ignored revisions.
+func mapLines(diffs []diffmatchpatch.Diff, n int, fuzzy bool) []int {
+	mapping := make([]int, n)
+	var src, dst, deleted, deletedAt int
+	for _, d := range diffs {
+		lines := countLines(d.Text)
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			for i := 0; i < lines; i++ {
+				mapping[dst+i] = src + i
+			}
+
+			src += lines
+			dst += lines
+			deleted = 0
+		case diffmatchpatch.DiffDelete:
+			deleted, deletedAt = lines, src
+			src += lines
+		case diffmatchpatch.DiffInsert:
+			for i := 0; i < lines; i++ {
+				mapping[dst+i] = -1
+				if fuzzy && i < deleted {
+					mapping[dst+i] = deletedAt + i
+				}
+			}
+
+			dst += lines
+			deleted = 0
+		}
 	}
-	return b
+
+	return mapping
+}
+
+// blameRemaining attributes the remaining lines of the suspect to it.
+func (b *blamer) blameRemaining(s *blameSuspect) {
+	for _, l := range s.lines {
+		b.origins[l.final] = &blameOrigin{commit: s.commit, path: s.path, orig: l.orig}
+	}
+
+	s.lines = nil
+}
+
+func (b *blamer) result(rev plumbing.Hash, path, contents string) *BlameResult {
+	text := strings.Split(contents, "\n")
+	r := &BlameResult{
+		Path:     path,
+		Rev:      rev,
+		Lines:    make([]*Line, 0, len(b.selected)),
+		commits:  make(map[plumbing.Hash]*object.Commit),
+		previous: b.previous,
+	}
+
+	for _, i := range b.selected {
+		o := b.origins[i]
+		c, ok := r.commits[o.commit.Hash]
+		if !ok {
+			c = o.commit
+			if b.o.Mailmap != nil {
+				c = mailmapCommit(b.o.Mailmap, c)
+			}
+
+			r.commits[c.Hash] = c
+		}
+
+		r.Lines = append(r.Lines, &Line{
+			Author:     c.Author.Email,
+			AuthorName: c.Author.Name,
+			Text:       text[i],
+			Date:       c.Author.When,
+			Hash:       c.Hash,
+			LineNo:     i + 1,
+			OrigLineNo: o.orig + 1,
+			OrigPath:   o.path,
+		})
+	}
+
+	return r
 }
@@ -0,0 +1,133 @@
+package git
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/object"
+)
+
+// BlamePorcelainEncoder writes a BlameResult in the porcelain format of
+// `git blame --porcelain`, meant to be consumed by other programs.
+// https://git-scm.com/docs/git-blame#_the_porcelain_format
+type BlamePorcelainEncoder struct {
+	w io.Writer
+	// LinePorcelain writes the commit information for every line, instead of
+	// only the first time a commit is seen, as `git blame --line-porcelain`.
+	LinePorcelain bool
+}
+
+// NewBlamePorcelainEncoder returns a new encoder that writes to w.
+func NewBlamePorcelainEncoder(w io.Writer) *BlamePorcelainEncoder {
+	return &BlamePorcelainEncoder{w: w}
+}
+
+// Encode writes the given BlameResult.
+func (e *BlamePorcelainEncoder) Encode(r *BlameResult) error {
+	shown := make(map[plumbing.Hash]string)
+	lines := r.Lines
+	for len(lines) > 0 {
+		n := blameGroupSize(lines)
+		for i, l := range lines[:n] {
+			if err := e.encodeHeader(l, i, n); err != nil {
+				return err
+			}
+
+			path, seen := shown[l.Hash]
+			if !seen || e.LinePorcelain {
+				if err := e.encodeCommit(r, l.Hash); err != nil {
+					return err
+				}
+			}
+
+			if !seen || e.LinePorcelain || path != l.OrigPath {
+				if err := e.encodeFilename(r, l); err != nil {
+					return err
+				}
+			}
+
+			shown[l.Hash] = l.OrigPath
+			if _, err := fmt.Fprintf(e.w, "\t%s\n", l.Text); err != nil {
+				return err
+			}
+		}
+
+		lines = lines[n:]
+	}
+
+	return nil
+}
+
+// blameGroupSize returns the number of lines, from the beginning, that are
+// consecutive in both the blamed file and the original file of their commit.
+func blameGroupSize(lines []*Line) int {
+	n := 1
+	for n < len(lines) {
+		prev, l := lines[n-1], lines[n]
+		if l.Hash != prev.Hash || l.OrigPath != prev.OrigPath ||
+			l.LineNo != prev.LineNo+1 || l.OrigLineNo != prev.OrigLineNo+1 {
+			break
+		}
+
+		n++
+	}
+
+	return n
+}
+
+func (e *BlamePorcelainEncoder) encodeHeader(l *Line, i, n int) error {
+	if i == 0 {
+		_, err := fmt.Fprintf(e.w, "%s %d %d %d\n", l.Hash, l.OrigLineNo, l.LineNo, n)
+		return err
+	}
+
+	_, err := fmt.Fprintf(e.w, "%s %d %d\n", l.Hash, l.OrigLineNo, l.LineNo)
+	return err
+}
+
+func (e *BlamePorcelainEncoder) encodeCommit(r *BlameResult, h plumbing.Hash) error {
+	c, ok := r.commits[h]
+	if !ok {
+		return nil
+	}
+
+	if err := e.encodeSignature("author", c.Author); err != nil {
+		return err
+	}
+
+	if err := e.encodeSignature("committer", c.Committer); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(e.w, "summary %s\n", commitSubject(c.Message)); err != nil {
+		return err
+	}
+
+	if c.NumParents() == 0 {
+		if _, err := fmt.Fprintln(e.w, "boundary"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *BlamePorcelainEncoder) encodeSignature(kind string, s object.Signature) error {
+	_, err := fmt.Fprintf(e.w, "%s %s\n%s-mail <%s>\n%s-time %d\n%s-tz %s\n",
+		kind, s.Name, kind, s.Email, kind, s.When.Unix(), kind, s.When.Format("-0700"),
+	)
+
+	return err
+}
+
+func (e *BlamePorcelainEncoder) encodeFilename(r *BlameResult, l *Line) error {
+	if p, ok := r.previous[blameKey{l.Hash, l.OrigPath}]; ok {
+		if _, err := fmt.Fprintf(e.w, "previous %s %s\n", p.hash, p.path); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(e.w, "filename %s\n", l.OrigPath)
+	return err
+}
@@ -1,10 +1,20 @@
 package git
 
 import (
-	fixtures "github.com/go-git/go-git-fixtures/v4"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
 	"github.com/goabstract/go-git/v5/plumbing"
-	"github.com/goabstract/go-git/v5/plumbing/object"
+	"github.com/goabstract/go-git/v5/plumbing/format/mailmap"
+	"github.com/goabstract/go-git/v5/storage/memory"
 
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	fixtures "github.com/go-git/go-git-fixtures/v4"
 	. "gopkg.in/check.v1"
 )
 
@@ -14,31 +24,6 @@ type BlameSuite struct {
 
 var _ = Suite(&BlameSuite{})
 
-func (s *BlameSuite) TestNewLines(c *C) {
-	h := plumbing.NewHash("ce9f123d790717599aaeb76bc62510de437761be")
-	lines, err := newLines([]string{"foo"}, []*object.Commit{{
-		Hash:    h,
-		Message: "foo",
-	}})
-
-	c.Assert(err, IsNil)
-	c.Assert(lines, HasLen, 1)
-	c.Assert(lines[0].Text, Equals, "foo")
-	c.Assert(lines[0].Hash, Equals, h)
-}
-
-func (s *BlameSuite) TestNewLinesWithNewLine(c *C) {
-	lines, err := newLines([]string{"foo"}, []*object.Commit{
-		{Message: "foo"},
-		{Message: "bar"},
-	})
-
-	c.Assert(err, IsNil)
-	c.Assert(lines, HasLen, 2)
-	c.Assert(lines[0].Text, Equals, "foo")
-	c.Assert(lines[1].Text, Equals, "\n")
-}
-
 type blameTest struct {
 	repo   string
 	rev    string
@@ -57,14 +42,43 @@ func (s *BlameSuite) TestBlame(c *C) {
 
 		obt, err := Blame(commit, t.path)
 		c.Assert(err, IsNil)
-		c.Assert(obt, DeepEquals, exp)
+		c.Assert(obt.Path, Equals, exp.Path)
+		c.Assert(obt.Rev, Equals, exp.Rev)
+		c.Assert(obt.Lines, HasLen, len(exp.Lines))
 
+		origs := make(map[string][]string)
 		for i, l := range obt.Lines {
 			c.Assert(l.Hash.String(), Equals, t.blames[i])
+			c.Assert(l.LineNo, Equals, i+1)
+			s.assertLine(c, r, origs, l, exp.Lines[i])
 		}
 	}
 }
 
+// assertLine checks the line against the expected one, and that the original
+// line number and path point to the same text in the blamed commit.
+func (s *BlameSuite) assertLine(c *C, r *Repository, origs map[string][]string, obt, exp *Line) {
+	c.Assert(obt.Author, Equals, exp.Author)
+	c.Assert(obt.AuthorName, Equals, exp.AuthorName)
+	c.Assert(obt.Text, Equals, exp.Text)
+	c.Assert(obt.Date.Equal(exp.Date), Equals, true)
+	c.Assert(obt.Hash, Equals, exp.Hash)
+
+	key := obt.Hash.String() + ":" + obt.OrigPath
+	lines, ok := origs[key]
+	if !ok {
+		commit, err := r.CommitObject(obt.Hash)
+		c.Assert(err, IsNil)
+		f, err := commit.File(obt.OrigPath)
+		c.Assert(err, IsNil)
+		lines, err = f.Lines()
+		c.Assert(err, IsNil)
+		origs[key] = lines
+	}
+
+	c.Assert(lines[obt.OrigLineNo-1], Equals, obt.Text)
+}
+
 func (s *BlameSuite) mockBlame(c *C, t blameTest, r *Repository) (blame *BlameResult) {
 	commit, err := r.CommitObject(plumbing.NewHash(t.rev))
 	c.Assert(err, IsNil, Commentf("%v: repo=%s, rev=%s", err, t.repo, t.rev))
@@ -81,10 +95,11 @@ func (s *BlameSuite) mockBlame(c *C, t blameTest, r *Repository) (blame *BlameRe
 		commit, err := r.CommitObject(plumbing.NewHash(t.blames[i]))
 		c.Assert(err, IsNil)
 		l := &Line{
-			Author: commit.Author.Email,
-			Text:   lines[i],
-			Date:   commit.Author.When,
-			Hash:   commit.Hash,
+			Author:     commit.Author.Email,
+			AuthorName: commit.Author.Name,
+			Text:       lines[i],
+			Date:       commit.Author.When,
+			Hash:       commit.Hash,
 		}
 		blamedLines = append(blamedLines, l)
 	}
@@ -549,3 +564,224 @@ var blameTests = [...]blameTest{
 		)},
 	*/
 }
+
+func (s *BlameSuite) TestBlameWithMailmap(c *C) {
+	m, err := mailmap.Parse(strings.NewReader(
+		"Daniel <daniel@example.com> <daniel@lordran.local>\n",
+	))
+	c.Assert(err, IsNil)
+
+	r := s.NewRepositoryFromPackfile(fixtures.Basic().One())
+	commit, err := r.CommitObject(plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	c.Assert(err, IsNil)
+
+	result, err := BlameWithOptions(commit, "CHANGELOG", &BlameOptions{Mailmap: m})
+	c.Assert(err, IsNil)
+	c.Assert(result.Lines, HasLen, 1)
+	c.Assert(result.Lines[0].Author, Equals, "daniel@example.com")
+	c.Assert(result.Lines[0].AuthorName, Equals, "Daniel")
+}
+
+// newBlameRepository returns a repository where "foo" is created with three
+// lines, renamed to "bar" and then modified.
+func (s *BlameSuite) newBlameRepository(c *C) (*Repository, []plumbing.Hash) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	sig := defaultSignature()
+	commit := func(msg string) plumbing.Hash {
+		sig.When = sig.When.Add(time.Hour)
+		_, err := w.Add(".")
+		c.Assert(err, IsNil)
+		h, err := w.Commit(msg, &CommitOptions{All: true, Author: sig})
+		c.Assert(err, IsNil)
+		return h
+	}
+
+	var hashes []plumbing.Hash
+	c.Assert(util.WriteFile(w.Filesystem, "foo", []byte("a\nb\nc\n"), 0644), IsNil)
+	hashes = append(hashes, commit("first"))
+	_, err = w.Move("foo", "bar")
+	c.Assert(err, IsNil)
+	hashes = append(hashes, commit("rename"))
+	c.Assert(util.WriteFile(w.Filesystem, "bar", []byte("a\nB\nc\nd\n"), 0644), IsNil)
+	hashes = append(hashes, commit("modify"))
+
+	return r, hashes
+}
+
+func (s *BlameSuite) TestBlameFollowRenames(c *C) {
+	r, hashes := s.newBlameRepository(c)
+	commit, err := r.CommitObject(hashes[2])
+	c.Assert(err, IsNil)
+
+	result, err := BlameWithOptions(commit, "bar", &BlameOptions{NoFollowRenames: true})
+	c.Assert(err, IsNil)
+	c.Assert(result.Lines, HasLen, 4)
+	c.Assert(result.Lines[0].Hash, Equals, hashes[1])
+
+	result, err = BlameWithOptions(commit, "bar", &BlameOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(result.Lines, HasLen, 4)
+
+	expected := []struct {
+		hash plumbing.Hash
+		path string
+		orig int
+	}{
+		{hashes[0], "foo", 1},
+		{hashes[2], "bar", 2},
+		{hashes[0], "foo", 3},
+		{hashes[2], "bar", 4},
+	}
+
+	for i, e := range expected {
+		l := result.Lines[i]
+		c.Assert(l.Hash, Equals, e.hash)
+		c.Assert(l.OrigPath, Equals, e.path)
+		c.Assert(l.OrigLineNo, Equals, e.orig)
+		c.Assert(l.LineNo, Equals, i+1)
+	}
+}
+
+func (s *BlameSuite) TestBlameLineRanges(c *C) {
+	r, hashes := s.newBlameRepository(c)
+	commit, err := r.CommitObject(hashes[2])
+	c.Assert(err, IsNil)
+
+	result, err := BlameWithOptions(commit, "bar", &BlameOptions{
+		LineRanges: []LineRange{{Start: 2, End: 2}, {Start: 4}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(result.Lines, HasLen, 2)
+	c.Assert(result.Lines[0].LineNo, Equals, 2)
+	c.Assert(result.Lines[0].Text, Equals, "B")
+	c.Assert(result.Lines[1].LineNo, Equals, 4)
+	c.Assert(result.Lines[1].Text, Equals, "d")
+
+	_, err = BlameWithOptions(commit, "bar", &BlameOptions{
+		LineRanges: []LineRange{{Start: 3, End: 5}},
+	})
+	c.Assert(err, Equals, ErrInvalidLineRange)
+}
+
+func (s *BlameSuite) TestBlameIgnoreRevs(c *C) {
+	r, hashes := s.newBlameRepository(c)
+	commit, err := r.CommitObject(hashes[2])
+	c.Assert(err, IsNil)
+
+	result, err := BlameWithOptions(commit, "bar", &BlameOptions{
+		IgnoreRevs: []plumbing.Hash{hashes[2]},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(result.Lines[1].Hash, Equals, hashes[0])
+	c.Assert(result.Lines[3].Hash, Equals, hashes[2])
+}
+
+func (s *BlameSuite) TestRepositoryBlameIgnoreRevsFile(c *C) {
+	r, hashes := s.newBlameRepository(c)
+	commit, err := r.CommitObject(hashes[2])
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(r.wt, ".git-blame-ignore-revs", []byte(
+		"# formatting\n"+hashes[2].String()+"\n",
+	), 0644)
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("blame").SetOption("ignoreRevsFile", ".git-blame-ignore-revs")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	result, err := r.Blame(commit, "bar", &BlameOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(result.Lines[1].Hash, Equals, hashes[0])
+}
+
+func (s *BlameSuite) TestWorktreeBlame(c *C) {
+	r, hashes := s.newBlameRepository(c)
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(w.Filesystem, "bar", []byte("a\nB\nx\nc\nd\n"), 0644), IsNil)
+
+	result, err := w.Blame("bar", &BlameOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(result.Rev, Equals, plumbing.ZeroHash)
+	c.Assert(result.Lines, HasLen, 5)
+	c.Assert(result.Lines[1].Hash, Equals, hashes[2])
+	c.Assert(result.Lines[2].Hash, Equals, plumbing.ZeroHash)
+	c.Assert(result.Lines[2].AuthorName, Equals, "Not Committed Yet")
+	c.Assert(result.Lines[3].Hash, Equals, hashes[0])
+	c.Assert(result.Lines[3].OrigPath, Equals, "foo")
+	c.Assert(result.Lines[3].OrigLineNo, Equals, 3)
+}
+
+func (s *BlameSuite) TestBlamePorcelainEncoder(c *C) {
+	r, hashes := s.newBlameRepository(c)
+	commit, err := r.CommitObject(hashes[2])
+	c.Assert(err, IsNil)
+
+	result, err := BlameWithOptions(commit, "bar", &BlameOptions{
+		LineRanges: []LineRange{{Start: 1, End: 3}},
+	})
+	c.Assert(err, IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewBlamePorcelainEncoder(buf).Encode(result), IsNil)
+
+	c.Assert(buf.String(), Equals, fmt.Sprintf(""+
+		"%[1]s 1 1 1\n"+
+		"author foo\n"+
+		"author-mail <foo@foo.foo>\n"+
+		"author-time 1493852623\n"+
+		"author-tz +0200\n"+
+		"committer foo\n"+
+		"committer-mail <foo@foo.foo>\n"+
+		"committer-time 1493852623\n"+
+		"committer-tz +0200\n"+
+		"summary first\n"+
+		"boundary\n"+
+		"filename foo\n"+
+		"\ta\n"+
+		"%[2]s 2 2 1\n"+
+		"author foo\n"+
+		"author-mail <foo@foo.foo>\n"+
+		"author-time 1493859823\n"+
+		"author-tz +0200\n"+
+		"committer foo\n"+
+		"committer-mail <foo@foo.foo>\n"+
+		"committer-time 1493859823\n"+
+		"committer-tz +0200\n"+
+		"summary modify\n"+
+		"previous %[3]s bar\n"+
+		"filename bar\n"+
+		"\tB\n"+
+		"%[1]s 3 3 1\n"+
+		"\tc\n",
+		hashes[0], hashes[2], hashes[1],
+	))
+}
+
+func (s *BlameSuite) TestRepositoryBlameIgnoreRevsFileBare(c *C) {
+	r, hashes := s.newBlameRepository(c)
+	commit, err := r.CommitObject(hashes[2])
+	c.Assert(err, IsNil)
+
+	dir := c.MkDir()
+	path := filepath.Join(dir, "ignore-revs")
+	err = ioutil.WriteFile(path, []byte(hashes[2].String()+"\n"), 0644)
+	c.Assert(err, IsNil)
+
+	bare, err := Open(r.Storer, nil)
+	c.Assert(err, IsNil)
+
+	result, err := bare.Blame(commit, "bar", &BlameOptions{IgnoreRevsFile: path})
+	c.Assert(err, IsNil)
+	c.Assert(result.Lines[1].Hash, Equals, hashes[0])
+
+	_, err = bare.Blame(commit, "bar", &BlameOptions{IgnoreRevsFile: "ignore-revs"})
+	c.Assert(err, Equals, ErrIsBareRepository)
+}
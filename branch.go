@@ -0,0 +1,216 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/goabstract/go-git/v5/config"
+	"github.com/goabstract/go-git/v5/plumbing"
+	format "github.com/goabstract/go-git/v5/plumbing/format/config"
+	"github.com/goabstract/go-git/v5/plumbing/format/reflog"
+	"github.com/goabstract/go-git/v5/plumbing/storer"
+)
+
+var (
+	// ErrForceUpdateCurrentBranch is returned renaming or copying a branch
+	// over the current branch.
+	ErrForceUpdateCurrentBranch = errors.New("cannot force update the current branch")
+	// ErrSymbolicBranch is returned renaming or copying a branch which is a
+	// symbolic reference, as git doesn't support it.
+	ErrSymbolicBranch = errors.New("cannot rename or copy a symbolic branch")
+)
+
+// RenameBranch renames a local branch, moving its reflog and its
+// branch.<name> config section, as `git branch -m` does. HEAD is updated if
+// it points to the branch. ErrBranchExists is returned if the new branch
+// already exists, unless force is set, as `git branch -M`.
+func (r *Repository) RenameBranch(old, new string, force bool) error {
+	return r.copyBranch(old, new, force, true)
+}
+
+// CopyBranch copies a local branch, with its reflog and its branch.<name>
+// config section, as `git branch -c` does. ErrBranchExists is returned if the
+// new branch already exists, unless force is set, as `git branch -C`.
+func (r *Repository) CopyBranch(old, new string, force bool) error {
+	return r.copyBranch(old, new, force, false)
+}
+
+func (r *Repository) copyBranch(old, new string, force, rename bool) error {
+	from := plumbing.NewBranchReferenceName(old)
+	to := plumbing.NewBranchReferenceName(new)
+
+	ref, err := r.Storer.Reference(from)
+	if err == plumbing.ErrReferenceNotFound {
+		return ErrBranchNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if ref.Type() == plumbing.SymbolicReference {
+		return ErrSymbolicBranch
+	}
+
+	existing, err := r.Storer.Reference(to)
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+
+	if existing != nil && !force {
+		return ErrBranchExists
+	}
+
+	if from == to {
+		return nil
+	}
+
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+
+	isHead := func(name plumbing.ReferenceName) bool {
+		return head != nil && head.Type() == plumbing.SymbolicReference && head.Target() == name
+	}
+
+	if existing != nil && isHead(to) {
+		return ErrForceUpdateCurrentBranch
+	}
+
+	rs, hasReflog := r.Storer.(storer.ReflogStorer)
+	var entries []*reflog.Entry
+	if hasReflog {
+		if entries, err = rs.Reflog(from); err != nil {
+			return err
+		}
+	}
+
+	merged, err := reflogConfig(r.Storer)
+	if err != nil {
+		return err
+	}
+
+	if err := r.moveBranchReference(ref, existing, to, rename); err != nil {
+		return err
+	}
+
+	if rename && isHead(from) {
+		if err := r.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, to)); err != nil {
+			return err
+		}
+	}
+
+	if hasReflog {
+		msg := "Branch: copied " + from.String() + " to " + to.String()
+		if rename {
+			msg = "Branch: renamed " + from.String() + " to " + to.String()
+		}
+
+		if err := rs.SetReflog(to, entries); err != nil {
+			return err
+		}
+
+		if rename {
+			if err := rs.DeleteReflog(from); err != nil {
+				return err
+			}
+		}
+
+		if err := appendReflog(merged, rs, to, &reflog.Entry{
+			Old:       ref.Hash(),
+			New:       ref.Hash(),
+			Committer: reflogSignature(merged, nil),
+			Message:   msg,
+		}); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return err
+	}
+
+	cfg, err = copyBranchConfig(cfg, old, new, rename)
+	if err != nil {
+		return err
+	}
+
+	return r.Storer.SetConfig(cfg)
+}
+
+// moveBranchReference sets the reference to to the hash of ref, removing
+// ref if rename is set, at once if the storer supports transactions.
+func (r *Repository) moveBranchReference(ref, existing *plumbing.Reference,
+	to plumbing.ReferenceName, rename bool) error {
+
+	moved := plumbing.NewHashReference(to, ref.Hash())
+
+	t := r.ReferenceTransaction()
+	if existing == nil {
+		t.Create(moved)
+	} else {
+		t.Update(moved, existing)
+	}
+
+	if rename {
+		t.Delete(ref.Name(), ref)
+	}
+
+	err := t.Commit("")
+	if err != ErrReferenceTransactionNotSupported {
+		return err
+	}
+
+	if err := r.Storer.CheckAndSetReference(moved, existing); err != nil {
+		return err
+	}
+
+	if rename {
+		return r.Storer.RemoveReference(ref.Name())
+	}
+
+	return nil
+}
+
+// copyBranchConfig returns the config with the branch.<old> section copied,
+// or moved if rename is set, to branch.<new>, with all its options.
+func copyBranchConfig(cfg *config.Config, old, new string, rename bool) (*config.Config, error) {
+	section := cfg.Raw.Section(branchSection)
+	if !section.HasSubsection(old) {
+		return cfg, nil
+	}
+
+	// the raw config is updated from the branches first
+	if _, err := cfg.Marshal(); err != nil {
+		return nil, err
+	}
+
+	var subsections format.Subsections
+	for _, ss := range section.Subsections {
+		if ss.IsName(new) || rename && ss.IsName(old) {
+			continue
+		}
+
+		subsections = append(subsections, ss)
+	}
+
+	src := section.Subsection(old)
+	section.Subsections = append(subsections, &format.Subsection{
+		Name:    new,
+		Options: append(format.Options(nil), src.Options...),
+	})
+
+	var buf bytes.Buffer
+	if err := format.NewEncoder(&buf).Encode(cfg.Raw); err != nil {
+		return nil, err
+	}
+
+	result := config.NewConfig()
+	if err := result.Unmarshal(buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,141 @@
+package git
+
+import (
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/storage/memory"
+
+	fixtures "github.com/go-git/go-git-fixtures/v4"
+	. "gopkg.in/check.v1"
+)
+
+type BranchSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&BranchSuite{})
+
+func (s *BranchSuite) TestRenameBranch(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("branch").Subsection("master").SetOption("description", "the main branch")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	before, err := r.Reflog(plumbing.Master)
+	c.Assert(err, IsNil)
+
+	c.Assert(r.RenameBranch("master", "main", false), IsNil)
+
+	_, err = r.Reference(plumbing.Master, false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	AssertReferences(c, r, map[string]string{
+		"refs/heads/main": "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+	})
+
+	head, err := r.Reference(plumbing.HEAD, false)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.ReferenceName("refs/heads/main"))
+
+	entries, err := r.Reflog(plumbing.Master)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+
+	entries, err = r.Reflog("refs/heads/main")
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, len(before)+1)
+	c.Assert(entries[0].Message, Equals, "Branch: renamed refs/heads/master to refs/heads/main")
+	c.Assert(entries[1].Message, Equals, before[0].Message)
+
+	_, err = r.Branch("master")
+	c.Assert(err, Equals, ErrBranchNotFound)
+
+	b, err := r.Branch("main")
+	c.Assert(err, IsNil)
+	c.Assert(b.Remote, Equals, "origin")
+	c.Assert(b.Merge, Equals, plumbing.Master)
+
+	cfg, err = r.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Raw.Section("branch").Subsection("main").Option("description"), Equals, "the main branch")
+	c.Assert(cfg.Raw.Section("branch").HasSubsection("master"), Equals, false)
+}
+
+func (s *BranchSuite) TestRenameBranchExisting(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	c.Assert(r.RenameBranch("branch", "master", false), Equals, ErrBranchExists)
+	c.Assert(r.RenameBranch("branch", "master", true), Equals, ErrForceUpdateCurrentBranch)
+	c.Assert(r.RenameBranch("foo", "bar", false), Equals, ErrBranchNotFound)
+
+	c.Assert(r.RenameBranch("master", "branch", true), IsNil)
+	AssertReferences(c, r, map[string]string{
+		"refs/heads/branch": "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+	})
+
+	b, err := r.Branch("branch")
+	c.Assert(err, IsNil)
+	c.Assert(b.Merge, Equals, plumbing.Master)
+}
+
+func (s *BranchSuite) TestRenameBranchSymbolic(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+	alias := plumbing.NewSymbolicReference("refs/heads/alias", plumbing.Master)
+	c.Assert(r.Storer.SetReference(alias), IsNil)
+
+	c.Assert(r.RenameBranch("alias", "foo", false), Equals, ErrSymbolicBranch)
+	c.Assert(r.CopyBranch("alias", "foo", false), Equals, ErrSymbolicBranch)
+
+	ref, err := r.Reference("refs/heads/alias", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Target(), Equals, plumbing.Master)
+
+	_, err = r.Reference("refs/heads/foo", false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *BranchSuite) TestCopyBranch(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	c.Assert(r.CopyBranch("master", "branch", false), Equals, ErrBranchExists)
+	c.Assert(r.CopyBranch("master", "copy", false), IsNil)
+
+	AssertReferences(c, r, map[string]string{
+		"refs/heads/master": "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"refs/heads/copy":   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+	})
+
+	head, err := r.Reference(plumbing.HEAD, false)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.Master)
+
+	master, err := r.Reflog(plumbing.Master)
+	c.Assert(err, IsNil)
+
+	entries, err := r.Reflog("refs/heads/copy")
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, len(master)+1)
+	c.Assert(entries[0].Message, Equals, "Branch: copied refs/heads/master to refs/heads/copy")
+
+	for _, name := range []string{"master", "copy"} {
+		b, err := r.Branch(name)
+		c.Assert(err, IsNil)
+		c.Assert(b.Remote, Equals, "origin")
+		c.Assert(b.Merge, Equals, plumbing.Master)
+	}
+}
+
+func (s *BranchSuite) TestRenameBranchMemory(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	hash := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	c.Assert(r.Storer.SetReference(plumbing.NewHashReference(plumbing.Master, hash)), IsNil)
+	c.Assert(r.RenameBranch("master", "main", false), IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.ReferenceName("refs/heads/main"))
+	c.Assert(head.Hash(), Equals, hash)
+}
@@ -1,6 +1,7 @@
 package git
 
 import (
+	"os"
 	"testing"
 
 	"github.com/goabstract/go-git/v5/plumbing"
@@ -28,6 +29,11 @@ type BaseSuite struct {
 }
 
 func (s *BaseSuite) SetUpSuite(c *C) {
+	// the config of the user and of the system is never read, so the tests
+	// don't depend on the environment they run in
+	os.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	os.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
 	s.buildBasicRepository(c)
 
 	s.cache = make(map[string]*Repository)
@@ -0,0 +1,112 @@
+package git
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/goabstract/go-git/v5/config"
+	"github.com/goabstract/go-git/v5/plumbing"
+	format "github.com/goabstract/go-git/v5/plumbing/format/config"
+	"github.com/goabstract/go-git/v5/storage"
+)
+
+const (
+	extensionsSection  = "extensions"
+	worktreeConfigKey  = "worktreeConfig"
+	worktreeConfigFile = "config.worktree"
+)
+
+// mergedConfig returns the system, global, local and worktree configs of the
+// repository stored in s merged, the latter taking precedence.
+func mergedConfig(s storage.Storer) (*config.Config, error) {
+	local, err := s.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	// the raw config is updated from the fields, as storing it does
+	b, err := local.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	localRaw := format.New()
+	if err := format.NewDecoder(bytes.NewReader(b)).Decode(localRaw); err != nil {
+		return nil, err
+	}
+
+	o := includeOptions(s)
+	var raws []*format.Config
+	for _, scope := range []config.Scope{config.SystemScope, config.GlobalScope} {
+		raw, err := config.ReadScope(scope, o)
+		if err != nil {
+			return nil, err
+		}
+
+		raws = append(raws, raw)
+	}
+
+	raw, err := config.ResolveIncludes(localRaw, config.LocalScope, o.GitDir, o)
+	if err != nil {
+		return nil, err
+	}
+
+	raws = append(raws, raw)
+	if enabled, _ := localRaw.Section(extensionsSection).Options.Bool(worktreeConfigKey); enabled {
+		raw, err := worktreeConfig(s, o)
+		if err != nil {
+			return nil, err
+		}
+
+		raws = append(raws, raw)
+	}
+
+	return config.Merge(raws...)
+}
+
+// worktreeConfig reads the config of the worktree, $GIT_DIR/config.worktree,
+// if the storer is in a filesystem.
+func worktreeConfig(s storage.Storer, o *config.IncludeOptions) (*format.Config, error) {
+	raw := format.New()
+	fs, ok := s.(interface{ Filesystem() billy.Filesystem })
+	if !ok {
+		return raw, nil
+	}
+
+	f, err := fs.Filesystem().Open(worktreeConfigFile)
+	if os.IsNotExist(err) {
+		return raw, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	if err := format.NewDecoder(f).Decode(raw); err != nil {
+		return nil, err
+	}
+
+	return config.ResolveIncludes(raw, config.WorktreeScope, o.GitDir, o)
+}
+
+// includeOptions returns the git directory, if the storer is in a
+// filesystem, and the current branch the includeIf sections are evaluated
+// against.
+func includeOptions(s storage.Storer) *config.IncludeOptions {
+	o := &config.IncludeOptions{}
+	if fs, ok := s.(interface{ Filesystem() billy.Filesystem }); ok {
+		if dir, err := filepath.Abs(fs.Filesystem().Root()); err == nil {
+			o.GitDir = dir
+		}
+	}
+
+	head, err := s.Reference(plumbing.HEAD)
+	if err == nil && head.Type() == plumbing.SymbolicReference && head.Target().IsBranch() {
+		o.Branch = head.Target().Short()
+	}
+
+	return o
+}
@@ -46,6 +46,40 @@ type Config struct {
 		CommentChar string
 	}
 
+	User struct {
+		// Name is the name recorded as author, committer or tagger of the
+		// commits and tags created.
+		Name string
+		// Email is the email recorded as author, committer or tagger of the
+		// commits and tags created.
+		Email string
+		// SigningKey identifies the key used to sign the commits and tags, by
+		// its key ID, its fingerprint or one of its identities.
+		SigningKey string
+	}
+
+	Init struct {
+		// DefaultBranch is the name of the branch HEAD points to in the new
+		// repositories, master if empty.
+		DefaultBranch string
+	}
+
+	Commit struct {
+		// GpgSign if true, the commits are signed.
+		GpgSign bool
+		// Template is the path of a file used as message of the commits
+		// created without one.
+		Template string
+		// Cleanup is how the message of the commits is cleaned up, one of
+		// strip, whitespace, verbatim, scissors or default.
+		Cleanup string
+	}
+
+	Tag struct {
+		// GpgSign if true, the annotated tags are signed.
+		GpgSign bool
+	}
+
 	Pack struct {
 		// Window controls the size of the sliding window for delta
 		// compression.  The default is 10.  A value of 0 turns off
@@ -62,6 +96,9 @@ type Config struct {
 	// Branches list of branches, the key is the branch name and should
 	// equal Branch.Name
 	Branches map[string]*Branch
+	// URLs list of the URL rewriting rules, the key is the base the URLs are
+	// rewritten to and should equal URL.Name.
+	URLs map[string]*URL
 	// Raw contains the raw information of a config file. The main goal is
 	// preserve the parsed information from the original format, to avoid
 	// dropping unsupported fields.
@@ -74,6 +111,7 @@ func NewConfig() *Config {
 		Remotes:    make(map[string]*RemoteConfig),
 		Submodules: make(map[string]*Submodule),
 		Branches:   make(map[string]*Branch),
+		URLs:       make(map[string]*URL),
 		Raw:        format.New(),
 	}
 
@@ -104,23 +142,59 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	for name, u := range c.URLs {
+		if u.Name != name {
+			return ErrInvalid
+		}
+
+		if err := u.Validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 const (
-	remoteSection    = "remote"
-	submoduleSection = "submodule"
-	branchSection    = "branch"
-	coreSection      = "core"
-	packSection      = "pack"
-	fetchKey         = "fetch"
-	urlKey           = "url"
-	bareKey          = "bare"
-	worktreeKey      = "worktree"
-	commentCharKey   = "commentChar"
-	windowKey        = "window"
-	mergeKey         = "merge"
-	rebaseKey        = "rebase"
+	remoteSection     = "remote"
+	submoduleSection  = "submodule"
+	branchSection     = "branch"
+	urlSection        = "url"
+	coreSection       = "core"
+	packSection       = "pack"
+	userSection       = "user"
+	initSection       = "init"
+	commitSection     = "commit"
+	tagSection        = "tag"
+	httpSection       = "http"
+	credentialSection = "credential"
+	fetchKey          = "fetch"
+	urlKey            = "url"
+	pushurlKey        = "pushurl"
+	bareKey           = "bare"
+	worktreeKey       = "worktree"
+	commentCharKey    = "commentChar"
+	windowKey         = "window"
+	mergeKey          = "merge"
+	rebaseKey         = "rebase"
+	nameKey           = "name"
+	emailKey          = "email"
+	signingKeyKey     = "signingKey"
+	defaultBranchKey  = "defaultBranch"
+	gpgSignKey        = "gpgSign"
+	templateKey       = "template"
+	cleanupKey        = "cleanup"
+	insteadOfKey      = "insteadOf"
+	pushInsteadOfKey  = "pushInsteadOf"
+	proxyKey          = "proxy"
+	sslVerifyKey      = "sslVerify"
+	sslCAInfoKey      = "sslCAInfo"
+	extraHeaderKey    = "extraHeader"
+	cookieFileKey     = "cookieFile"
+	userAgentKey      = "userAgent"
+	helperKey         = "helper"
+	usernameKey       = "username"
+	useHTTPPathKey    = "useHttpPath"
 
 	// DefaultPackWindow holds the number of previous objects used to
 	// generate deltas. The value 10 is the same used by git command.
@@ -137,7 +211,16 @@ func (c *Config) Unmarshal(b []byte) error {
 		return err
 	}
 
+	return c.unmarshal()
+}
+
+// unmarshal fills the fields of the config from its raw representation.
+func (c *Config) unmarshal() error {
 	c.unmarshalCore()
+	c.unmarshalUser()
+	c.unmarshalInit()
+	c.unmarshalCommit()
+	c.unmarshalTag()
 	if err := c.unmarshalPack(); err != nil {
 		return err
 	}
@@ -147,6 +230,8 @@ func (c *Config) Unmarshal(b []byte) error {
 		return err
 	}
 
+	c.unmarshalURLs()
+
 	return c.unmarshalRemotes()
 }
 
@@ -160,6 +245,30 @@ func (c *Config) unmarshalCore() {
 	c.Core.CommentChar = s.Options.Get(commentCharKey)
 }
 
+func (c *Config) unmarshalUser() {
+	s := c.Raw.Section(userSection)
+	c.User.Name = s.Options.Get(nameKey)
+	c.User.Email = s.Options.Get(emailKey)
+	c.User.SigningKey = s.Options.Get(signingKeyKey)
+}
+
+func (c *Config) unmarshalInit() {
+	s := c.Raw.Section(initSection)
+	c.Init.DefaultBranch = s.Options.Get(defaultBranchKey)
+}
+
+func (c *Config) unmarshalCommit() {
+	s := c.Raw.Section(commitSection)
+	c.Commit.GpgSign, _ = s.Options.Bool(gpgSignKey)
+	c.Commit.Template = s.Options.Get(templateKey)
+	c.Commit.Cleanup = s.Options.Get(cleanupKey)
+}
+
+func (c *Config) unmarshalTag() {
+	s := c.Raw.Section(tagSection)
+	c.Tag.GpgSign, _ = s.Options.Bool(gpgSignKey)
+}
+
 func (c *Config) unmarshalPack() error {
 	s := c.Raw.Section(packSection)
 	window := s.Options.Get(windowKey)
@@ -203,6 +312,16 @@ func unmarshalSubmodules(fc *format.Config, submodules map[string]*Submodule) {
 	}
 }
 
+func (c *Config) unmarshalURLs() {
+	s := c.Raw.Section(urlSection)
+	for _, sub := range s.Subsections {
+		u := &URL{}
+		u.unmarshal(sub)
+
+		c.URLs[u.Name] = u
+	}
+}
+
 func (c *Config) unmarshalBranches() error {
 	bs := c.Raw.Section(branchSection)
 	for _, sub := range bs.Subsections {
@@ -220,10 +339,15 @@ func (c *Config) unmarshalBranches() error {
 // Marshal returns Config encoded as a git-config file.
 func (c *Config) Marshal() ([]byte, error) {
 	c.marshalCore()
+	c.marshalUser()
+	c.marshalInit()
+	c.marshalCommit()
+	c.marshalTag()
 	c.marshalPack()
 	c.marshalRemotes()
 	c.marshalSubmodules()
 	c.marshalBranches()
+	c.marshalURLs()
 
 	buf := bytes.NewBuffer(nil)
 	if err := format.NewEncoder(buf).Encode(c.Raw); err != nil {
@@ -242,6 +366,43 @@ func (c *Config) marshalCore() {
 	}
 }
 
+func (c *Config) marshalUser() {
+	s := c.Raw.Section(userSection)
+	setOption(s, nameKey, c.User.Name)
+	setOption(s, emailKey, c.User.Email)
+	setOption(s, signingKeyKey, c.User.SigningKey)
+}
+
+func (c *Config) marshalInit() {
+	s := c.Raw.Section(initSection)
+	setOption(s, defaultBranchKey, c.Init.DefaultBranch)
+}
+
+func (c *Config) marshalCommit() {
+	s := c.Raw.Section(commitSection)
+	if c.Commit.GpgSign {
+		s.SetOption(gpgSignKey, "true")
+	}
+
+	setOption(s, templateKey, c.Commit.Template)
+	setOption(s, cleanupKey, c.Commit.Cleanup)
+}
+
+func (c *Config) marshalTag() {
+	s := c.Raw.Section(tagSection)
+	if c.Tag.GpgSign {
+		s.SetOption(gpgSignKey, "true")
+	}
+}
+
+// setOption sets the option of the section if the value isn't empty, the
+// options set only in the raw config are kept.
+func setOption(s *format.Section, key, value string) {
+	if value != "" {
+		s.SetOption(key, value)
+	}
+}
+
 func (c *Config) marshalPack() {
 	s := c.Raw.Section(packSection)
 	if c.Pack.Window != DefaultPackWindow {
@@ -318,6 +479,33 @@ func (c *Config) marshalBranches() {
 	s.Subsections = newSubsections
 }
 
+func (c *Config) marshalURLs() {
+	s := c.Raw.Section(urlSection)
+	newSubsections := make(format.Subsections, 0, len(c.URLs))
+	added := make(map[string]bool)
+	for _, subsection := range s.Subsections {
+		if u, ok := c.URLs[subsection.Name]; ok {
+			newSubsections = append(newSubsections, u.marshal())
+			added[subsection.Name] = true
+		}
+	}
+
+	names := make([]string, 0, len(c.URLs))
+	for name := range c.URLs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !added[name] {
+			newSubsections = append(newSubsections, c.URLs[name].marshal())
+		}
+	}
+
+	s.Subsections = newSubsections
+}
+
 // RemoteConfig contains the configuration for a given remote repository.
 type RemoteConfig struct {
 	// Name of the remote
@@ -325,6 +513,9 @@ type RemoteConfig struct {
 	// URLs the URLs of a remote repository. It must be non-empty. Fetch will
 	// always use the first URL, while push will use all of them.
 	URLs []string
+	// PushURLs the URLs used by push instead of URLs, if any. Push will use
+	// all of them.
+	PushURLs []string
 	// Fetch the default set of "refspec" for fetch operation
 	Fetch []RefSpec
 
@@ -371,6 +562,7 @@ func (c *RemoteConfig) unmarshal(s *format.Subsection) error {
 
 	c.Name = c.raw.Name
 	c.URLs = append([]string(nil), c.raw.Options.GetAll(urlKey)...)
+	c.PushURLs = append([]string(nil), c.raw.Options.GetAll(pushurlKey)...)
 	c.Fetch = fetch
 
 	return nil
@@ -388,6 +580,12 @@ func (c *RemoteConfig) marshal() *format.Subsection {
 		c.raw.SetOption(urlKey, c.URLs...)
 	}
 
+	if len(c.PushURLs) == 0 {
+		c.raw.RemoveOption(pushurlKey)
+	} else {
+		c.raw.SetOption(pushurlKey, c.PushURLs...)
+	}
+
 	if len(c.Fetch) == 0 {
 		c.raw.RemoveOption(fetchKey)
 	} else {
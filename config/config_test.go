@@ -146,6 +146,45 @@ func (s *ConfigSuite) TestUnmarshalMarshal(c *C) {
 	c.Assert(string(output), DeepEquals, string(input))
 }
 
+func (s *ConfigSuite) TestUnmarshalMarshalLayout(c *C) {
+	input := []byte(`# repository config
+[core]
+	bare = false ; not bare
+
+[remote "origin"]
+	# the upstream repository
+	url = git@github.com:go-git/go-git.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+[remote "fork"]
+	url = git@github.com:foo/go-git.git
+[branch "master"]
+	remote = origin
+	merge = refs/heads/master
+`)
+
+	cfg := NewConfig()
+	c.Assert(cfg.Unmarshal(input), IsNil)
+
+	cfg.Remotes["origin"].URLs = []string{"https://github.com/go-git/go-git.git"}
+	delete(cfg.Remotes, "fork")
+	cfg.Branches["master"].Remote = "upstream"
+
+	output, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, `# repository config
+[core]
+	bare = false ; not bare
+
+[remote "origin"]
+	# the upstream repository
+	url = https://github.com/go-git/go-git.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+[branch "master"]
+	remote = upstream
+	merge = refs/heads/master
+`)
+}
+
 func (s *ConfigSuite) TestValidateConfig(c *C) {
 	config := &Config{
 		Remotes: map[string]*RemoteConfig{
@@ -208,6 +247,90 @@ func (s *ConfigSuite) TestRemoteConfigValidateDefault(c *C) {
 	c.Assert(fetch[0].String(), Equals, "+refs/heads/*:refs/remotes/foo/*")
 }
 
+func (s *ConfigSuite) TestRemoteConfigPushURLs(c *C) {
+	input := []byte(`[core]
+	bare = false
+[remote "origin"]
+	url = git@github.com:mcuadros/go-git.git
+	pushurl = git@github.com:src-d/go-git.git
+	pushurl = git@gitlab.com:src-d/go-git.git
+`)
+
+	cfg := NewConfig()
+	c.Assert(cfg.Unmarshal(input), IsNil)
+	c.Assert(cfg.Remotes["origin"].PushURLs, DeepEquals, []string{
+		"git@github.com:src-d/go-git.git",
+		"git@gitlab.com:src-d/go-git.git",
+	})
+
+	output, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, string(input))
+
+	cfg.Remotes["origin"].PushURLs = nil
+	output, err = cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, `[core]
+	bare = false
+[remote "origin"]
+	url = git@github.com:mcuadros/go-git.git
+`)
+}
+
+func (s *ConfigSuite) TestUserInitCommitTag(c *C) {
+	input := []byte(`[core]
+	bare = false
+[user]
+	name = John Doe
+	email = john@example.com
+	signingKey = 0xDEADBEEF
+[init]
+	defaultBranch = main
+[commit]
+	gpgSign = yes
+	template = ~/.gitmessage
+	cleanup = strip
+[tag]
+	gpgSign = false
+`)
+
+	cfg := NewConfig()
+	c.Assert(cfg.Unmarshal(input), IsNil)
+	c.Assert(cfg.User.Name, Equals, "John Doe")
+	c.Assert(cfg.User.Email, Equals, "john@example.com")
+	c.Assert(cfg.User.SigningKey, Equals, "0xDEADBEEF")
+	c.Assert(cfg.Init.DefaultBranch, Equals, "main")
+	c.Assert(cfg.Commit.GpgSign, Equals, true)
+	c.Assert(cfg.Commit.Template, Equals, "~/.gitmessage")
+	c.Assert(cfg.Commit.Cleanup, Equals, "strip")
+	c.Assert(cfg.Tag.GpgSign, Equals, false)
+
+	cfg.Init.DefaultBranch = "trunk"
+	cfg.Tag.GpgSign = true
+
+	output, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, `[core]
+	bare = false
+[user]
+	name = John Doe
+	email = john@example.com
+	signingKey = 0xDEADBEEF
+[init]
+	defaultBranch = trunk
+[commit]
+	gpgSign = true
+	template = ~/.gitmessage
+	cleanup = strip
+[tag]
+	gpgSign = true
+`)
+
+	output, err = NewConfig().Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "[core]\n\tbare = false\n")
+}
+
 func (s *ConfigSuite) TestValidateInvalidBranchKey(c *C) {
 	config := &Config{
 		Branches: map[string]*Branch{
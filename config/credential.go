@@ -0,0 +1,42 @@
+package config
+
+import (
+	format "github.com/goabstract/go-git/v5/plumbing/format/config"
+)
+
+// Credential are the settings of the credential section applying to a URL.
+type Credential struct {
+	// Helpers are the credential helpers, from credential.helper. An empty
+	// value resets the helpers set before.
+	Helpers []string
+	// Username is the default username, from credential.username.
+	Username string
+	// UseHTTPPath is true if the path of the HTTP URLs tells apart the
+	// credentials of a host, from credential.useHttpPath.
+	UseHTTPPath bool
+}
+
+// Credential returns the settings of the credential section applying to the
+// given URL: the ones of the credential section, then the ones of every
+// credential.<url> subsection matching it, as git does.
+func (c *Config) Credential(url string) *Credential {
+	cred := &Credential{}
+	for _, st := range matchingSettings(c.Raw.Section(credentialSection), url) {
+		for _, o := range st.options {
+			switch {
+			case o.IsKey(helperKey):
+				if o.Value == "" {
+					cred.Helpers = nil
+				} else {
+					cred.Helpers = append(cred.Helpers, o.Value)
+				}
+			case o.IsKey(usernameKey):
+				cred.Username = o.Value
+			case o.IsKey(useHTTPPathKey):
+				cred.UseHTTPPath = o.Blank || format.IsTrue(o.Value)
+			}
+		}
+	}
+
+	return cred
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type CredentialSuite struct{}
+
+var _ = Suite(&CredentialSuite{})
+
+func (s *CredentialSuite) TestCredential(c *C) {
+	input := []byte(`[credential]
+	helper = cache --timeout=300
+[credential "https://example.com"]
+	helper =
+	helper = store
+	username = foo
+[credential "https://*.example.com/foo"]
+	helper = /usr/bin/helper
+	useHttpPath = true
+`)
+
+	cfg := NewConfig()
+	c.Assert(cfg.Unmarshal(input), IsNil)
+
+	c.Assert(cfg.Credential("https://github.com/foo.git"), DeepEquals, &Credential{
+		Helpers: []string{"cache --timeout=300"},
+	})
+
+	c.Assert(cfg.Credential("https://example.com/foo.git"), DeepEquals, &Credential{
+		Helpers:  []string{"store"},
+		Username: "foo",
+	})
+
+	c.Assert(cfg.Credential("https://git.example.com/foo/bar.git"), DeepEquals, &Credential{
+		Helpers:     []string{"cache --timeout=300", "/usr/bin/helper"},
+		UseHTTPPath: true,
+	})
+
+	c.Assert(cfg.Credential("https://git.example.com/bar.git"), DeepEquals, &Credential{
+		Helpers: []string{"cache --timeout=300"},
+	})
+}
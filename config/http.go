@@ -0,0 +1,87 @@
+package config
+
+import (
+	"strings"
+)
+
+// HTTP are the settings of the http section applying to a URL.
+type HTTP struct {
+	// Proxy is the proxy the requests are sent through, from http.proxy.
+	Proxy string
+	// SSLVerify is false if the TLS certificate of the server is not
+	// verified, from http.sslVerify.
+	SSLVerify bool
+	// SSLCAInfo is the path of the file with the certificates of the
+	// certificate authorities to verify the server with, from http.sslCAInfo.
+	SSLCAInfo string
+	// ExtraHeaders are the headers sent with every request, from
+	// http.extraHeader. An empty value resets the headers of the less
+	// specific settings.
+	ExtraHeaders []string
+	// CookieFile is the path of the file the cookies are read from, from
+	// http.cookieFile.
+	CookieFile string
+	// UserAgent is the user agent of the requests, from http.userAgent.
+	UserAgent string
+}
+
+// HTTP returns the settings of the http section applying to the given URL:
+// the ones of the http.<url> subsection with the most specific URL matching
+// it, as git does, or else the ones of the http section. The more specific
+// URL is the one with the longest host, then the longest path, then a user.
+func (c *Config) HTTP(url string) *HTTP {
+	h := &HTTP{SSLVerify: true}
+	settings := matchingSettings(c.Raw.Section(httpSection), url)
+
+	var matches [len(httpKeys)]*urlMatch
+	for i := range settings {
+		st := &settings[i]
+		for _, o := range st.options {
+			for k, key := range httpKeys {
+				if !o.IsKey(key) || (matches[k] != nil && st.match.less(*matches[k])) {
+					continue
+				}
+
+				matches[k] = &st.match
+				h.set(key, o.Value)
+			}
+		}
+	}
+
+	return h
+}
+
+var httpKeys = [...]string{
+	proxyKey, sslVerifyKey, sslCAInfoKey, extraHeaderKey, cookieFileKey, userAgentKey,
+}
+
+func (h *HTTP) set(key, value string) {
+	switch key {
+	case proxyKey:
+		h.Proxy = value
+	case sslVerifyKey:
+		h.SSLVerify = !isFalse(value)
+	case sslCAInfoKey:
+		h.SSLCAInfo = value
+	case extraHeaderKey:
+		if value == "" {
+			h.ExtraHeaders = nil
+		} else {
+			h.ExtraHeaders = append(h.ExtraHeaders, value)
+		}
+	case cookieFileKey:
+		h.CookieFile = value
+	case userAgentKey:
+		h.UserAgent = value
+	}
+}
+
+// isFalse returns true if the value is a false boolean, as git parses it.
+func isFalse(value string) bool {
+	switch strings.ToLower(value) {
+	case "false", "no", "off", "0":
+		return true
+	default:
+		return false
+	}
+}
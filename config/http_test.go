@@ -0,0 +1,69 @@
+package config
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type HTTPSuite struct{}
+
+var _ = Suite(&HTTPSuite{})
+
+func (s *HTTPSuite) TestHTTP(c *C) {
+	input := []byte(`[http]
+	proxy = proxy.example.com:3128
+	extraHeader = X-Global: foo
+	userAgent = git/2.30.0
+[http "https://example.com"]
+	sslVerify = false
+	extraHeader = X-Host: foo
+[http "https://*.example.com/"]
+	proxy =
+	cookieFile = ~/.cookies
+[http "https://git.example.com/foo"]
+	sslCAInfo = /etc/ca.pem
+	extraHeader =
+	extraHeader = X-Path: foo
+[http "https://bar@git.example.com/foo"]
+	sslVerify = no
+[http "https://git.example.com:8443/"]
+	userAgent = git/2.31.0
+`)
+
+	cfg := NewConfig()
+	c.Assert(cfg.Unmarshal(input), IsNil)
+
+	c.Assert(cfg.HTTP("http://example.com/foo.git"), DeepEquals, &HTTP{
+		Proxy:        "proxy.example.com:3128",
+		SSLVerify:    true,
+		ExtraHeaders: []string{"X-Global: foo"},
+		UserAgent:    "git/2.30.0",
+	})
+
+	c.Assert(cfg.HTTP("https://EXAMPLE.com:443/foo.git"), DeepEquals, &HTTP{
+		Proxy:        "proxy.example.com:3128",
+		SSLVerify:    false,
+		ExtraHeaders: []string{"X-Global: foo", "X-Host: foo"},
+		UserAgent:    "git/2.30.0",
+	})
+
+	c.Assert(cfg.HTTP("https://git.example.com/bar.git"), DeepEquals, &HTTP{
+		SSLVerify:    true,
+		ExtraHeaders: []string{"X-Global: foo"},
+		CookieFile:   "~/.cookies",
+		UserAgent:    "git/2.30.0",
+	})
+
+	c.Assert(cfg.HTTP("https://git.example.com/foo/bar.git"), DeepEquals, &HTTP{
+		SSLVerify:    true,
+		SSLCAInfo:    "/etc/ca.pem",
+		ExtraHeaders: []string{"X-Path: foo"},
+		CookieFile:   "~/.cookies",
+		UserAgent:    "git/2.30.0",
+	})
+
+	c.Assert(cfg.HTTP("https://bar@git.example.com/foo").SSLVerify, Equals, false)
+	c.Assert(cfg.HTTP("https://git.example.com/foobar.git").SSLCAInfo, Equals, "")
+	c.Assert(cfg.HTTP("https://git.example.com:8443/foo.git").UserAgent, Equals, "git/2.31.0")
+	c.Assert(cfg.HTTP("https://git.example.com:8443/foo.git").SSLCAInfo, Equals, "")
+	c.Assert(cfg.HTTP("https://a.git.example.com/foo.git").CookieFile, Equals, "")
+}
@@ -30,6 +30,10 @@ type RefSpec string
 
 // Validate validates the RefSpec
 func (s RefSpec) Validate() error {
+	if s.IsMatching() {
+		return nil
+	}
+
 	spec := string(s)
 	if strings.Count(spec, refSpecSeparator) != 1 {
 		return ErrRefSpecMalformedSeparator
@@ -56,7 +60,13 @@ func (s RefSpec) IsForceUpdate() bool {
 
 // IsDelete returns true if the refspec indicates a delete (empty src).
 func (s RefSpec) IsDelete() bool {
-	return s[0] == refSpecSeparator[0]
+	return s[0] == refSpecSeparator[0] && !s.IsMatching()
+}
+
+// IsMatching returns true if the refspec is ":", or "+:", pushing the
+// branches existing both locally and in the remote with the same name.
+func (s RefSpec) IsMatching() bool {
+	return strings.TrimPrefix(string(s), refSpecForce) == refSpecSeparator
 }
 
 // Src return the src side.
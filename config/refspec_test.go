@@ -56,6 +56,21 @@ func (s *RefSpecSuite) TestRefSpecIsDelete(c *C) {
 
 	spec = RefSpec("refs/heads/*:refs/remotes/origin/*")
 	c.Assert(spec.IsDelete(), Equals, false)
+
+	spec = RefSpec(":")
+	c.Assert(spec.IsDelete(), Equals, false)
+}
+
+func (s *RefSpecSuite) TestRefSpecIsMatching(c *C) {
+	for spec, matching := range map[RefSpec]bool{
+		":":                         true,
+		"+:":                        true,
+		":refs/heads/master":        false,
+		"refs/heads/*:refs/heads/*": false,
+	} {
+		c.Assert(spec.IsMatching(), Equals, matching, Commentf("%s", spec))
+		c.Assert(spec.Validate(), IsNil, Commentf("%s", spec))
+	}
 }
 
 func (s *RefSpecSuite) TestRefSpecSrc(c *C) {
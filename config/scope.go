@@ -0,0 +1,406 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	format "github.com/goabstract/go-git/v5/plumbing/format/config"
+)
+
+// Scope is the scope of a config file.
+type Scope = format.Scope
+
+const (
+	// LocalScope is the scope of the config file of the repository.
+	LocalScope = format.LocalScope
+	// GlobalScope is the scope of the config files of the user.
+	GlobalScope = format.GlobalScope
+	// SystemScope is the scope of the config file of the system.
+	SystemScope = format.SystemScope
+	// WorktreeScope is the scope of the config file of the worktree.
+	WorktreeScope = format.WorktreeScope
+)
+
+var (
+	// ErrUnsupportedScope is returned reading the files of a scope that
+	// isn't global nor system.
+	ErrUnsupportedScope = errors.New("unsupported config scope")
+	// ErrIncludeDepthExceeded is returned when the include directives are
+	// nested too deep, as with cyclic includes.
+	ErrIncludeDepthExceeded = errors.New("exceeded maximum include depth")
+)
+
+const (
+	includeSection   = "include"
+	includeIfSection = "includeIf"
+
+	// maxIncludeDepth is the maximum depth of the nested includes, the same
+	// used by git.
+	maxIncludeDepth = 10
+
+	gitDirCondition     = "gitdir:"
+	gitDirFoldCondition = "gitdir/i:"
+	onBranchCondition   = "onbranch:"
+)
+
+// SystemConfigPath is the path of the config file of the system scope.
+var SystemConfigPath = "/etc/gitconfig"
+
+// IncludeOptions describes the repository the conditional includes, the
+// includeIf sections, are evaluated against.
+type IncludeOptions struct {
+	// GitDir is the path of the git directory of the repository, matched by
+	// the "gitdir:" and "gitdir/i:" conditions.
+	GitDir string
+	// Branch is the short name of the current branch of the repository,
+	// matched by the "onbranch:" conditions.
+	Branch string
+}
+
+// Paths returns the paths of the config files of the given scope, global or
+// system, in the order git reads them, the last one taking precedence. The
+// GIT_CONFIG_GLOBAL, GIT_CONFIG_SYSTEM and GIT_CONFIG_NOSYSTEM environment
+// variables are honoured.
+func Paths(scope Scope) ([]string, error) {
+	switch scope {
+	case GlobalScope:
+		if p, ok := os.LookupEnv("GIT_CONFIG_GLOBAL"); ok {
+			return nonEmptyPaths(p), nil
+		}
+
+		home, _ := os.UserHomeDir()
+		xdg := os.Getenv("XDG_CONFIG_HOME")
+		if xdg == "" && home != "" {
+			xdg = filepath.Join(home, ".config")
+		}
+
+		var paths []string
+		if xdg != "" {
+			paths = append(paths, filepath.Join(xdg, "git", "config"))
+		}
+
+		if home != "" {
+			paths = append(paths, filepath.Join(home, ".gitconfig"))
+		}
+
+		return paths, nil
+	case SystemScope:
+		if format.IsTrue(os.Getenv("GIT_CONFIG_NOSYSTEM")) {
+			return nil, nil
+		}
+
+		if p, ok := os.LookupEnv("GIT_CONFIG_SYSTEM"); ok {
+			return nonEmptyPaths(p), nil
+		}
+
+		return []string{SystemConfigPath}, nil
+	default:
+		return nil, ErrUnsupportedScope
+	}
+}
+
+func nonEmptyPaths(p string) []string {
+	if p == "" {
+		return nil
+	}
+
+	return []string{p}
+}
+
+// LoadConfig loads the config of the given scope, global or system, from
+// the files returned by Paths, following their include directives. The
+// conditional includes are ignored.
+func LoadConfig(scope Scope) (*Config, error) {
+	raw, err := ReadScope(scope, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return Merge(raw)
+}
+
+// ReadScope reads the config files of the given scope, global or system,
+// following their include directives, and the conditional ones matching the
+// given options. The missing files are ignored.
+func ReadScope(scope Scope, o *IncludeOptions) (*format.Config, error) {
+	paths, err := Paths(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	result := format.New()
+	for _, p := range paths {
+		raw, err := readConfigFile(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := resolveIncludes(result, raw, scope, filepath.Dir(p), o, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// ResolveIncludes returns a copy of the given raw config, of the given scope,
+// with the options of the files it includes, and of the conditional includes
+// matching the given options, inserted. The relative paths are relative to
+// dir, the relative includes are ignored if it is empty.
+func ResolveIncludes(raw *format.Config, scope Scope, dir string, o *IncludeOptions) (*format.Config, error) {
+	result := format.New()
+	if err := resolveIncludes(result, raw, scope, dir, o, 0); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func resolveIncludes(dst, src *format.Config, scope Scope, dir string, o *IncludeOptions, depth int) error {
+	if depth > maxIncludeDepth {
+		return ErrIncludeDepthExceeded
+	}
+
+	include := func(opts format.Options) error {
+		for _, opt := range opts {
+			if !opt.IsKey(pathKey) {
+				continue
+			}
+
+			p := expandPath(opt.Value, dir)
+			if p == "" {
+				continue
+			}
+
+			raw, err := readConfigFile(p)
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			dst.Includes = append(dst.Includes, &format.Include{Path: p, Config: raw})
+			if err := resolveIncludes(dst, raw, scope, filepath.Dir(p), o, depth+1); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	for _, s := range src.Sections {
+		section := dst.Section(s.Name)
+		section.Options = appendOptions(section.Options, s.Options, scope)
+		if s.IsName(includeSection) {
+			if err := include(s.Options); err != nil {
+				return err
+			}
+		}
+
+		for _, ss := range s.Subsections {
+			subsection := section.Subsection(ss.Name)
+			subsection.Options = appendOptions(subsection.Options, ss.Options, scope)
+			if s.IsName(includeIfSection) && o.match(ss.Name, dir) {
+				if err := include(ss.Options); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func appendOptions(dst, src format.Options, scope Scope) format.Options {
+	for _, o := range src {
+		dst = append(dst, &format.Option{Key: o.Key, Value: o.Value, Blank: o.Blank, Scope: scope})
+	}
+
+	return dst
+}
+
+func readConfigFile(p string) (*format.Config, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	raw := format.New()
+	if err := format.NewDecoder(f).Decode(raw); err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// expandPath returns the given path of an include, expanding a leading "~/"
+// to the home directory of the user and making it relative to dir. An empty
+// path is returned if it is relative and dir is empty.
+func expandPath(p, dir string) string {
+	if strings.HasPrefix(p, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+
+		return filepath.Join(home, p[2:])
+	}
+
+	if filepath.IsAbs(p) {
+		return p
+	}
+
+	if dir == "" {
+		return ""
+	}
+
+	return filepath.Join(dir, p)
+}
+
+// match returns true if the given includeIf condition matches the
+// repository.
+func (o *IncludeOptions) match(condition, dir string) bool {
+	if o == nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(condition, gitDirCondition):
+		return o.matchGitDir(strings.TrimPrefix(condition, gitDirCondition), dir, false)
+	case strings.HasPrefix(condition, gitDirFoldCondition):
+		return o.matchGitDir(strings.TrimPrefix(condition, gitDirFoldCondition), dir, true)
+	case strings.HasPrefix(condition, onBranchCondition):
+		pattern := strings.TrimPrefix(condition, onBranchCondition)
+		if strings.HasSuffix(pattern, "/") {
+			pattern += "**"
+		}
+
+		return o.Branch != "" && matchPattern(pattern, o.Branch, false)
+	default:
+		return false
+	}
+}
+
+// matchGitDir matches the git directory against a "gitdir:" pattern, as git
+// does: "~/" is the home directory, "./" the directory of the config file,
+// a relative pattern matches at any depth and a trailing "/" matches
+// everything inside the directory.
+func (o *IncludeOptions) matchGitDir(pattern, dir string, fold bool) bool {
+	if o.GitDir == "" {
+		return false
+	}
+
+	prefix := strings.HasSuffix(pattern, "/")
+	switch {
+	case strings.HasPrefix(pattern, "~/"):
+		if pattern = expandPath(pattern, dir); pattern == "" {
+			return false
+		}
+	case strings.HasPrefix(pattern, "./"):
+		if dir == "" {
+			return false
+		}
+
+		pattern = filepath.Join(dir, pattern[2:])
+	case !filepath.IsAbs(pattern) && !strings.HasPrefix(pattern, "**/"):
+		pattern = "**/" + pattern
+	}
+
+	pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+	if prefix {
+		pattern += "/**"
+	}
+
+	return matchPattern(pattern, filepath.ToSlash(filepath.Clean(o.GitDir)), fold)
+}
+
+// matchPattern matches the given slash separated name against a glob
+// pattern, where "**" matches any number of path components.
+func matchPattern(pattern, name string, fold bool) bool {
+	if fold {
+		pattern, name = strings.ToLower(pattern), strings.ToLower(name)
+	}
+
+	return matchComponents(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchComponents(pattern, name []string) bool {
+	for len(pattern) != 0 {
+		if pattern[0] == "**" {
+			// a trailing "**" matches everything inside, but not the
+			// directory itself
+			start := 0
+			if len(pattern) == 1 {
+				start = 1
+			}
+
+			for i := start; i <= len(name); i++ {
+				if matchComponents(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+// Merge returns the config merging the given raw configs, the last one
+// taking precedence, as git merges the config files of each scope. The
+// options keep their scope, and the merged config should be only read.
+func Merge(raws ...*format.Config) (*Config, error) {
+	merged := format.New()
+	for _, raw := range raws {
+		for _, s := range raw.Sections {
+			section := merged.Section(s.Name)
+			section.Options = append(section.Options, copyOptions(s.Options)...)
+			for _, ss := range s.Subsections {
+				subsection := section.Subsection(ss.Name)
+				subsection.Options = append(subsection.Options, copyOptions(ss.Options)...)
+			}
+		}
+
+		merged.Includes = append(merged.Includes, raw.Includes...)
+	}
+
+	c := NewConfig()
+	c.Raw = merged
+	if err := c.unmarshal(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func copyOptions(opts format.Options) format.Options {
+	result := make(format.Options, 0, len(opts))
+	for _, o := range opts {
+		copied := *o
+		result = append(result, &copied)
+	}
+
+	return result
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	format "github.com/goabstract/go-git/v5/plumbing/format/config"
+	. "gopkg.in/check.v1"
+)
+
+type ScopeSuite struct {
+	env map[string]*string
+}
+
+var _ = Suite(&ScopeSuite{})
+
+func (s *ScopeSuite) SetUpTest(c *C) {
+	s.env = make(map[string]*string)
+	for _, name := range []string{
+		"HOME", "XDG_CONFIG_HOME", "GIT_CONFIG_GLOBAL",
+		"GIT_CONFIG_SYSTEM", "GIT_CONFIG_NOSYSTEM",
+	} {
+		if value, ok := os.LookupEnv(name); ok {
+			s.env[name] = &value
+		} else {
+			s.env[name] = nil
+		}
+	}
+}
+
+func (s *ScopeSuite) TearDownTest(c *C) {
+	for name, value := range s.env {
+		if value == nil {
+			os.Unsetenv(name)
+		} else {
+			os.Setenv(name, *value)
+		}
+	}
+}
+
+func (s *ScopeSuite) writeFile(c *C, path, content string) {
+	c.Assert(os.MkdirAll(filepath.Dir(path), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+}
+
+func (s *ScopeSuite) TestPaths(c *C) {
+	home := c.MkDir()
+	os.Setenv("HOME", home)
+	os.Unsetenv("XDG_CONFIG_HOME")
+	os.Unsetenv("GIT_CONFIG_GLOBAL")
+	os.Unsetenv("GIT_CONFIG_SYSTEM")
+	os.Unsetenv("GIT_CONFIG_NOSYSTEM")
+
+	paths, err := Paths(GlobalScope)
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{
+		filepath.Join(home, ".config", "git", "config"),
+		filepath.Join(home, ".gitconfig"),
+	})
+
+	os.Setenv("XDG_CONFIG_HOME", "/xdg")
+	paths, err = Paths(GlobalScope)
+	c.Assert(err, IsNil)
+	c.Assert(paths[0], Equals, filepath.Join("/xdg", "git", "config"))
+
+	os.Setenv("GIT_CONFIG_GLOBAL", "/foo/config")
+	paths, err = Paths(GlobalScope)
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"/foo/config"})
+
+	paths, err = Paths(SystemScope)
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{SystemConfigPath})
+
+	os.Setenv("GIT_CONFIG_SYSTEM", "/bar/config")
+	paths, err = Paths(SystemScope)
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"/bar/config"})
+
+	os.Setenv("GIT_CONFIG_NOSYSTEM", "true")
+	paths, err = Paths(SystemScope)
+	c.Assert(err, IsNil)
+	c.Assert(paths, HasLen, 0)
+
+	_, err = Paths(LocalScope)
+	c.Assert(err, Equals, ErrUnsupportedScope)
+}
+
+func (s *ScopeSuite) TestLoadConfig(c *C) {
+	home := c.MkDir()
+	os.Setenv("HOME", home)
+	os.Unsetenv("XDG_CONFIG_HOME")
+	os.Unsetenv("GIT_CONFIG_GLOBAL")
+
+	s.writeFile(c, filepath.Join(home, ".config", "git", "config"), `[user]
+	name = xdg
+	email = foo@example.com
+`)
+	s.writeFile(c, filepath.Join(home, ".gitconfig"), `[user]
+	name = home
+[include]
+	path = conf/included
+	path = ~/missing
+[includeIf "gitdir:~/work/"]
+	path = ~/work.config
+`)
+	s.writeFile(c, filepath.Join(home, "conf", "included"), `[core]
+	commentChar = %
+`)
+	s.writeFile(c, filepath.Join(home, "work.config"), `[user]
+	name = work
+`)
+
+	cfg, err := LoadConfig(GlobalScope)
+	c.Assert(err, IsNil)
+
+	user := cfg.Raw.Section("user")
+	c.Assert(user.Option("name"), Equals, "home")
+	c.Assert(user.Option("email"), Equals, "foo@example.com")
+	c.Assert(cfg.Core.CommentChar, Equals, "%")
+	c.Assert(cfg.Raw.Includes, HasLen, 1)
+	c.Assert(cfg.Raw.Includes[0].Path, Equals, filepath.Join(home, "conf", "included"))
+
+	for _, o := range user.Options {
+		c.Assert(o.Scope, Equals, GlobalScope)
+	}
+
+	raw, err := ReadScope(GlobalScope, &IncludeOptions{GitDir: filepath.Join(home, "work", "foo", ".git")})
+	c.Assert(err, IsNil)
+	c.Assert(raw.Section("user").Option("name"), Equals, "work")
+}
+
+func (s *ScopeSuite) TestResolveIncludesConditions(c *C) {
+	dir := c.MkDir()
+	s.writeFile(c, filepath.Join(dir, "master"), "[user]\n\tname = master\n")
+	s.writeFile(c, filepath.Join(dir, "feature"), "[user]\n\tname = feature\n")
+	s.writeFile(c, filepath.Join(dir, "foo"), "[user]\n\tname = foo\n")
+	s.writeFile(c, filepath.Join(dir, "local"), "[user]\n\tname = local\n")
+
+	raw := format.New()
+	raw.Section("user").SetOption("name", "default")
+	raw.Section("includeIf").Subsection("onbranch:master").SetOption("path", "master")
+	raw.Section("includeIf").Subsection("onbranch:feature/").SetOption("path", "feature")
+	raw.Section("includeIf").Subsection("gitdir/i:FOO/").SetOption("path", "foo")
+	raw.Section("includeIf").Subsection("gitdir:./repo/.git").SetOption("path", "local")
+
+	for _, t := range []struct {
+		o    *IncludeOptions
+		name string
+	}{
+		{nil, "default"},
+		{&IncludeOptions{Branch: "master"}, "master"},
+		{&IncludeOptions{Branch: "feature/bar"}, "feature"},
+		{&IncludeOptions{Branch: "feature"}, "default"},
+		{&IncludeOptions{GitDir: "/src/foo/.git"}, "foo"},
+		{&IncludeOptions{GitDir: "/src/foobar/.git"}, "default"},
+		{&IncludeOptions{GitDir: filepath.Join(dir, "repo", ".git")}, "local"},
+	} {
+		result, err := ResolveIncludes(raw, LocalScope, dir, t.o)
+		c.Assert(err, IsNil)
+		c.Assert(result.Section("user").Option("name"), Equals, t.name, Commentf("%#v", t.o))
+	}
+
+	result, err := ResolveIncludes(raw, LocalScope, "", &IncludeOptions{Branch: "master"})
+	c.Assert(err, IsNil)
+	c.Assert(result.Section("user").Option("name"), Equals, "default")
+}
+
+func (s *ScopeSuite) TestResolveIncludesCycle(c *C) {
+	dir := c.MkDir()
+	s.writeFile(c, filepath.Join(dir, "a"), "[include]\n\tpath = b\n")
+	s.writeFile(c, filepath.Join(dir, "b"), "[include]\n\tpath = a\n")
+
+	raw := format.New()
+	raw.Section("include").SetOption("path", "a")
+
+	_, err := ResolveIncludes(raw, LocalScope, dir, nil)
+	c.Assert(err, Equals, ErrIncludeDepthExceeded)
+}
+
+func (s *ScopeSuite) TestMerge(c *C) {
+	system := format.New()
+	system.Section("core").SetOption("commentChar", "#")
+	system.Section("user").SetOption("name", "system")
+
+	global := format.New()
+	global.Section("user").SetOption("name", "global")
+	global.Section("remote").Subsection("origin").SetOption("url", "https://example.com/foo.git")
+
+	local := format.New()
+	local.Section("remote").Subsection("origin").SetOption("fetch", "+refs/heads/*:refs/remotes/origin/*")
+
+	for _, raw := range []struct {
+		c     *format.Config
+		scope Scope
+	}{{system, SystemScope}, {global, GlobalScope}, {local, LocalScope}} {
+		resolved, err := ResolveIncludes(raw.c, raw.scope, "", nil)
+		c.Assert(err, IsNil)
+		*raw.c = *resolved
+	}
+
+	cfg, err := Merge(system, global, local)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.CommentChar, Equals, "#")
+	c.Assert(cfg.Raw.Section("user").Option("name"), Equals, "global")
+	c.Assert(cfg.Remotes["origin"].URLs, DeepEquals, []string{"https://example.com/foo.git"})
+	c.Assert(cfg.Remotes["origin"].Fetch, HasLen, 1)
+
+	var scopes []Scope
+	for _, o := range cfg.Raw.Section("user").Options {
+		scopes = append(scopes, o.Scope)
+	}
+
+	c.Assert(scopes, DeepEquals, []Scope{SystemScope, GlobalScope})
+	c.Assert(GlobalScope.String(), Equals, "global")
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	format "github.com/goabstract/go-git/v5/plumbing/format/config"
+)
+
+var (
+	errURLEmptyName      = errors.New("url config: empty name")
+	errURLEmptyInsteadOf = errors.New("url config: empty insteadOf")
+)
+
+// URL defines the rules, from the url.<base> section, rewriting the URLs
+// starting with a given prefix to start with the base instead.
+type URL struct {
+	// Name is the base the matching URLs are rewritten to.
+	Name string
+	// InsteadOf are the prefixes of the URLs rewritten to Name, to fetch
+	// and push.
+	InsteadOf []string
+	// PushInsteadOf are the prefixes of the URLs rewritten to Name, only to
+	// push.
+	PushInsteadOf []string
+
+	raw *format.Subsection
+}
+
+// Validate validates fields of the URL rules.
+func (u *URL) Validate() error {
+	if u.Name == "" {
+		return errURLEmptyName
+	}
+
+	for _, prefix := range append(u.InsteadOf, u.PushInsteadOf...) {
+		if prefix == "" {
+			return errURLEmptyInsteadOf
+		}
+	}
+
+	return nil
+}
+
+func (u *URL) marshal() *format.Subsection {
+	if u.raw == nil {
+		u.raw = &format.Subsection{}
+	}
+
+	u.raw.Name = u.Name
+	if len(u.InsteadOf) == 0 {
+		u.raw.RemoveOption(insteadOfKey)
+	} else {
+		u.raw.SetOption(insteadOfKey, u.InsteadOf...)
+	}
+
+	if len(u.PushInsteadOf) == 0 {
+		u.raw.RemoveOption(pushInsteadOfKey)
+	} else {
+		u.raw.SetOption(pushInsteadOfKey, u.PushInsteadOf...)
+	}
+
+	return u.raw
+}
+
+func (u *URL) unmarshal(s *format.Subsection) {
+	u.raw = s
+
+	u.Name = s.Name
+	u.InsteadOf = append([]string(nil), s.Options.GetAll(insteadOfKey)...)
+	u.PushInsteadOf = append([]string(nil), s.Options.GetAll(pushInsteadOfKey)...)
+}
+
+// RewriteURL returns the given URL rewritten by the url.<base>.insteadOf rule
+// with the longest matching prefix, as git does, or the URL unchanged if no
+// rule matches.
+func (c *Config) RewriteURL(url string) string {
+	rewritten, _ := c.rewriteURL(url, func(u *URL) []string { return u.InsteadOf })
+	return rewritten
+}
+
+// RewritePushURL returns the given URL of a remote, to push to, rewritten by
+// the url.<base>.pushInsteadOf rule with the longest matching prefix, or else
+// by the url.<base>.insteadOf rules. The push URLs of a remote are only
+// rewritten by RewriteURL.
+func (c *Config) RewritePushURL(url string) string {
+	rewritten, ok := c.rewriteURL(url, func(u *URL) []string { return u.PushInsteadOf })
+	if ok {
+		return rewritten
+	}
+
+	return c.RewriteURL(url)
+}
+
+// rewriteURL rewrites the URL with the rule with the longest prefix among the
+// ones returned by prefixes, the first in the order of the bases in case of a
+// tie.
+func (c *Config) rewriteURL(url string, prefixes func(u *URL) []string) (string, bool) {
+	names := make([]string, 0, len(c.URLs))
+	for name := range c.URLs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var base, match string
+	for _, name := range names {
+		for _, prefix := range prefixes(c.URLs[name]) {
+			if len(prefix) > len(match) && strings.HasPrefix(url, prefix) {
+				base, match = name, prefix
+			}
+		}
+	}
+
+	if match == "" {
+		return url, false
+	}
+
+	return base + strings.TrimPrefix(url, match), true
+}
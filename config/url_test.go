@@ -0,0 +1,95 @@
+package config
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type URLSuite struct{}
+
+var _ = Suite(&URLSuite{})
+
+func (s *URLSuite) TestValidate(c *C) {
+	u := URL{Name: "https://mirror.example.com/", InsteadOf: []string{"https://github.com/"}}
+	c.Assert(u.Validate(), IsNil)
+
+	u = URL{InsteadOf: []string{"https://github.com/"}}
+	c.Assert(u.Validate(), Equals, errURLEmptyName)
+
+	u = URL{Name: "https://mirror.example.com/", PushInsteadOf: []string{""}}
+	c.Assert(u.Validate(), Equals, errURLEmptyInsteadOf)
+}
+
+func (s *URLSuite) TestUnmarshalMarshal(c *C) {
+	input := []byte(`[core]
+	bare = false
+[url "https://mirror.example.com/"]
+	insteadOf = https://github.com/
+	insteadOf = gh:
+[url "git@github.com:"]
+	pushInsteadOf = https://github.com/
+`)
+
+	cfg := NewConfig()
+	c.Assert(cfg.Unmarshal(input), IsNil)
+	c.Assert(cfg.URLs, HasLen, 2)
+	c.Assert(cfg.URLs["https://mirror.example.com/"].InsteadOf, DeepEquals, []string{
+		"https://github.com/", "gh:",
+	})
+	c.Assert(cfg.URLs["git@github.com:"].PushInsteadOf, DeepEquals, []string{"https://github.com/"})
+
+	output, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, string(input))
+
+	delete(cfg.URLs, "git@github.com:")
+	cfg.URLs["https://a.example.com/"] = &URL{
+		Name:      "https://a.example.com/",
+		InsteadOf: []string{"a:"},
+	}
+
+	output, err = cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, `[core]
+	bare = false
+[url "https://mirror.example.com/"]
+	insteadOf = https://github.com/
+	insteadOf = gh:
+[url "https://a.example.com/"]
+	insteadOf = a:
+`)
+}
+
+func (s *URLSuite) TestRewriteURL(c *C) {
+	cfg := NewConfig()
+	cfg.URLs["https://mirror.example.com/"] = &URL{
+		Name:      "https://mirror.example.com/",
+		InsteadOf: []string{"https://github.com/"},
+	}
+	cfg.URLs["https://mirror.example.com/go-git/"] = &URL{
+		Name:      "https://mirror.example.com/go-git/",
+		InsteadOf: []string{"https://github.com/go-git/"},
+	}
+	cfg.URLs["git@github.com:"] = &URL{
+		Name:          "git@github.com:",
+		PushInsteadOf: []string{"https://github.com/"},
+	}
+
+	for _, t := range []struct {
+		url, fetch, push string
+	}{{
+		"https://github.com/src-d/go-git",
+		"https://mirror.example.com/src-d/go-git",
+		"git@github.com:src-d/go-git",
+	}, {
+		"https://github.com/go-git/go-git",
+		"https://mirror.example.com/go-git/go-git",
+		"git@github.com:go-git/go-git",
+	}, {
+		"https://gitlab.com/go-git/go-git",
+		"https://gitlab.com/go-git/go-git",
+		"https://gitlab.com/go-git/go-git",
+	}} {
+		c.Assert(cfg.RewriteURL(t.url), Equals, t.fetch)
+		c.Assert(cfg.RewritePushURL(t.url), Equals, t.push)
+	}
+}
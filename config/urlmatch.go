@@ -0,0 +1,114 @@
+package config
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	format "github.com/goabstract/go-git/v5/plumbing/format/config"
+)
+
+// urlSettings are the options of a section, or of one of its subsections
+// with a URL matching a given one, with how specific the URL is.
+type urlSettings struct {
+	options format.Options
+	match   urlMatch
+}
+
+// matchingSettings returns the options of the section, then the ones of its
+// subsections with a URL matching the given one, in order.
+//
+// A URL of a subsection matches the ones with the same scheme, host and port,
+// with the same user if it has one, and with a path starting with its path.
+// The labels of its host may be * to match any label.
+func matchingSettings(s *format.Section, url string) []urlSettings {
+	settings := []urlSettings{{s.Options, urlMatch{host: -1}}}
+
+	target, err := parseMatchURL(url)
+	if err != nil {
+		return settings
+	}
+
+	for _, ss := range s.Subsections {
+		if m, ok := matchURL(ss.Name, target); ok {
+			settings = append(settings, urlSettings{ss.Options, m})
+		}
+	}
+
+	return settings
+}
+
+// urlMatch is how specific the URL of a subsection matching a URL is.
+type urlMatch struct {
+	host, path int
+	user       bool
+}
+
+// less returns true if the match is less specific than the given one.
+func (m urlMatch) less(o urlMatch) bool {
+	if m.host != o.host {
+		return m.host < o.host
+	}
+
+	if m.path != o.path {
+		return m.path < o.path
+	}
+
+	return !m.user && o.user
+}
+
+func parseMatchURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if u.Port() == "" {
+		switch u.Scheme {
+		case "http":
+			u.Host += ":80"
+		case "https":
+			u.Host += ":443"
+		}
+	}
+
+	return u, nil
+}
+
+// matchURL returns how specific the URL pattern is if it matches the URL.
+func matchURL(pattern string, u *url.URL) (urlMatch, bool) {
+	p, err := parseMatchURL(pattern)
+	if err != nil || p.Scheme != u.Scheme || p.Port() != u.Port() {
+		return urlMatch{}, false
+	}
+
+	m := urlMatch{host: len(p.Hostname())}
+	if p.User != nil {
+		if u.User == nil || p.User.Username() != u.User.Username() {
+			return urlMatch{}, false
+		}
+
+		m.user = true
+	}
+
+	labels, hostLabels := strings.Split(p.Hostname(), "."), strings.Split(u.Hostname(), ".")
+	if len(labels) != len(hostLabels) {
+		return urlMatch{}, false
+	}
+
+	for i, label := range labels {
+		if ok, _ := path.Match(label, hostLabels[i]); !ok {
+			return urlMatch{}, false
+		}
+	}
+
+	prefix := strings.TrimSuffix(p.Path, "/")
+	if prefix != "" && u.Path != prefix && !strings.HasPrefix(u.Path, prefix+"/") {
+		return urlMatch{}, false
+	}
+
+	m.path = len(prefix)
+	return m, true
+}
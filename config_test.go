@@ -0,0 +1,139 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/goabstract/go-git/v5/config"
+	"github.com/goabstract/go-git/v5/plumbing"
+
+	. "gopkg.in/check.v1"
+)
+
+type ConfigSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&ConfigSuite{})
+
+func (s *ConfigSuite) writeFile(c *C, path, content string) {
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+}
+
+func (s *ConfigSuite) TestConfigMerged(c *C) {
+	dir := c.MkDir()
+	global := filepath.Join(dir, "global")
+	os.Setenv("GIT_CONFIG_GLOBAL", global)
+	defer os.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	s.writeFile(c, global, `[user]
+	name = Global
+	email = global@example.com
+[includeIf "onbranch:feature"]
+	path = feature
+`)
+	s.writeFile(c, filepath.Join(dir, "feature"), `[user]
+	email = feature@example.com
+`)
+
+	r, err := PlainInit(filepath.Join(dir, "repo"), false)
+	c.Assert(err, IsNil)
+
+	local, err := r.Config()
+	c.Assert(err, IsNil)
+	local.Raw.Section("user").SetOption("name", "Local")
+	c.Assert(r.Storer.SetConfig(local), IsNil)
+
+	cfg, err := r.MergedConfig()
+	c.Assert(err, IsNil)
+
+	user := cfg.Raw.Section("user")
+	c.Assert(user.Option("name"), Equals, "Local")
+	c.Assert(user.Option("email"), Equals, "global@example.com")
+	c.Assert(user.Options[0].Scope, Equals, config.GlobalScope)
+	c.Assert(user.Options[len(user.Options)-1].Scope, Equals, config.LocalScope)
+
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("feature"))
+	c.Assert(r.Storer.SetReference(head), IsNil)
+
+	cfg, err = r.MergedConfig()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Raw.Section("user").Option("email"), Equals, "feature@example.com")
+
+	local, err = r.Config()
+	c.Assert(err, IsNil)
+	c.Assert(local.Raw.Section("user").Option("email"), Equals, "")
+}
+
+func (s *ConfigSuite) TestConfigWorktree(c *C) {
+	dir := c.MkDir()
+	r, err := PlainInit(dir, false)
+	c.Assert(err, IsNil)
+	s.writeFile(c, filepath.Join(dir, ".git", "config.worktree"), `[core]
+	commentChar = %
+`)
+
+	cfg, err := r.MergedConfig()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.CommentChar, Equals, "")
+
+	local, err := r.Config()
+	c.Assert(err, IsNil)
+	local.Raw.Section("extensions").SetOption("worktreeConfig", "true")
+	c.Assert(r.Storer.SetConfig(local), IsNil)
+
+	cfg, err = r.MergedConfig()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.CommentChar, Equals, "%")
+
+	options := cfg.Raw.Section("core").Options
+	c.Assert(options[len(options)-1].Scope, Equals, config.WorktreeScope)
+}
+
+func (s *ConfigSuite) TestConfigLocalInclude(c *C) {
+	dir := c.MkDir()
+	r, err := PlainInit(dir, false)
+	c.Assert(err, IsNil)
+	s.writeFile(c, filepath.Join(dir, ".git", "included"), `[remote "origin"]
+	url = https://example.com/foo.git
+`)
+
+	local, err := r.Config()
+	c.Assert(err, IsNil)
+	local.Raw.Section("include").SetOption("path", "included")
+	c.Assert(r.Storer.SetConfig(local), IsNil)
+
+	cfg, err := r.MergedConfig()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Remotes["origin"].URLs, DeepEquals, []string{"https://example.com/foo.git"})
+}
+
+func (s *ConfigSuite) TestConfigGlobalReaders(c *C) {
+	dir := c.MkDir()
+	global := filepath.Join(dir, "global")
+	os.Setenv("GIT_CONFIG_GLOBAL", global)
+	defer os.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	s.writeFile(c, global, `[push]
+	default = nothing
+[core]
+	logAllRefUpdates = always
+`)
+
+	r, err := PlainInit(filepath.Join(dir, "repo"), true)
+	c.Assert(err, IsNil)
+	c.Assert(r.Push(&PushOptions{}), Equals, ErrPushNothing)
+
+	hash := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	tx := r.ReferenceTransaction()
+	tx.Create(plumbing.NewHashReference("refs/tags/v1.0.0", hash))
+	c.Assert(tx.Commit("tag: v1.0.0"), IsNil)
+
+	entries, err := r.Reflog("refs/tags/v1.0.0")
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+
+	// the local config has none of the global options
+	local, err := r.Config()
+	c.Assert(err, IsNil)
+	c.Assert(local.Raw.Section("push").Option("default"), Equals, "")
+}
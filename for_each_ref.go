@@ -0,0 +1,971 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goabstract/go-git/v5/config"
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/object"
+	"github.com/goabstract/go-git/v5/plumbing/object/commitgraph"
+	"github.com/goabstract/go-git/v5/plumbing/storer"
+	"github.com/goabstract/go-git/v5/storage"
+)
+
+var (
+	// ErrInvalidRefFormat is returned by ForEachRef when the format contains
+	// an unknown field, or a field with an unknown modifier.
+	ErrInvalidRefFormat = errors.New("invalid reference format")
+	// ErrInvalidRefSortKey is returned by ForEachRef when a sort key is not
+	// a known field.
+	ErrInvalidRefSortKey = errors.New("invalid reference sort key")
+)
+
+const defaultRefFormat = "%(objectname) %(objecttype)\t%(refname)"
+
+// FormattedReference is a reference listed by Repository.ForEachRef.
+type FormattedReference struct {
+	// Reference is the listed reference, as stored.
+	Reference *plumbing.Reference
+	// Output is the reference formatted with ForEachRefOptions.Format.
+	Output string
+}
+
+// ForEachRef returns the references matching the filters of the options,
+// sorted and formatted, as `git for-each-ref` does. HEAD and the references
+// which can't be resolved are not listed.
+func (r *Repository) ForEachRef(o *ForEachRefOptions) ([]*FormattedReference, error) {
+	if o == nil {
+		o = &ForEachRefOptions{}
+	}
+
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	format, err := parseRefFormat(o.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := parseRefSortKeys(o.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := r.matchingReferences(o)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := r.newRefFormatter()
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	items := make([]*refItem, 0, len(refs))
+	for _, ref := range refs {
+		items = append(items, &refItem{ref: ref, values: make(map[refAtom]refValue)})
+	}
+
+	var sortErr error
+	sort.SliceStable(items, func(i, j int) bool {
+		if sortErr != nil {
+			return false
+		}
+
+		var cmp int
+		cmp, sortErr = f.compare(keys, items[i], items[j])
+		return cmp < 0
+	})
+
+	if sortErr != nil {
+		return nil, sortErr
+	}
+
+	if o.Count > 0 && len(items) > o.Count {
+		items = items[:o.Count]
+	}
+
+	result := make([]*FormattedReference, 0, len(items))
+	for _, item := range items {
+		var b strings.Builder
+		for _, part := range format {
+			if part.atom == nil {
+				b.WriteString(part.literal)
+				continue
+			}
+
+			v, err := f.value(item, *part.atom)
+			if err != nil {
+				return nil, err
+			}
+
+			b.WriteString(v.s)
+		}
+
+		result = append(result, &FormattedReference{Reference: item.ref, Output: b.String()})
+	}
+
+	return result, nil
+}
+
+// matchingReferences returns the references matching the patterns and the
+// filters of the options.
+func (r *Repository) matchingReferences(o *ForEachRefOptions) ([]*plumbing.Reference, error) {
+	iter, err := r.Storer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []*plumbing.Reference
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if !strings.HasPrefix(ref.Name().String(), "refs/") {
+			return nil
+		}
+
+		ok, err := matchRefPatterns(o.Patterns, ref.Name())
+		if err != nil || !ok {
+			return err
+		}
+
+		resolved, err := storer.ResolveReference(r.Storer, ref.Name())
+		if err == plumbing.ErrReferenceNotFound {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if len(o.PointsAt) != 0 {
+			ok, err := r.pointsAt(resolved.Hash(), o.PointsAt)
+			if err != nil || !ok {
+				return err
+			}
+		}
+
+		refs = append(refs, ref)
+		return nil
+	})
+
+	if err != nil || o.ReachabilityOptions.isEmpty() {
+		return refs, err
+	}
+
+	filtered, err := r.FilterReferences(storer.NewReferenceSliceIter(refs), &o.ReachabilityOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	refs = refs[:0]
+	err = filtered.ForEach(func(ref *plumbing.Reference) error {
+		refs = append(refs, ref)
+		return nil
+	})
+
+	return refs, err
+}
+
+// matchRefPatterns returns true if the name is below any of the patterns, or
+// matches any of them as a glob. All the names match if there is no pattern.
+func matchRefPatterns(patterns []string, name plumbing.ReferenceName) (bool, error) {
+	if len(patterns) == 0 {
+		return true, nil
+	}
+
+	n := name.String()
+	for _, p := range patterns {
+		if strings.HasPrefix(n, p) &&
+			(len(n) == len(p) || n[len(p)] == '/' || strings.HasSuffix(p, "/")) {
+			return true, nil
+		}
+
+		ok, err := path.Match(p, n)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+
+	return false, nil
+}
+
+// pointsAt returns true if h, or any of the objects pointed by the tags it
+// is the hash of, is one of the given hashes.
+func (r *Repository) pointsAt(h plumbing.Hash, hashes []plumbing.Hash) (bool, error) {
+	for {
+		for _, target := range hashes {
+			if h == target {
+				return true, nil
+			}
+		}
+
+		tag, err := r.TagObject(h)
+		if err == plumbing.ErrObjectNotFound {
+			return false, nil
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		h = tag.Target
+	}
+}
+
+// refAtom is a field of a reference, as "refname:short" or "*objectname".
+type refAtom struct {
+	name     string
+	modifier string
+	// deref is set for the fields of the object pointed by a tag, written
+	// with a "*" prefix.
+	deref bool
+}
+
+type refFormatPart struct {
+	literal string
+	atom    *refAtom
+}
+
+type refSortKey struct {
+	atom    refAtom
+	reverse bool
+	version bool
+}
+
+var (
+	refNameModifiers  = []string{"", "short", "lstrip=", "rstrip=", "strip="}
+	trackingModifiers = append(refNameModifiers, "track", "trackshort", "remotename", "remoteref")
+	hashModifiers     = []string{"", "short", "short="}
+	emailModifiers    = []string{"", "trim", "localpart"}
+	dateModifiers     = []string{"", "default", "iso", "iso8601", "iso-strict", "iso8601-strict", "rfc", "rfc2822", "short", "unix", "raw"}
+	noModifiers       = []string{""}
+)
+
+// refAtomModifiers are the modifiers accepted by every field, the empty one
+// included. The ones ending with "=" take a number.
+var refAtomModifiers = map[string][]string{
+	"refname":        refNameModifiers,
+	"symref":         refNameModifiers,
+	"upstream":       trackingModifiers,
+	"push":           trackingModifiers,
+	"HEAD":           noModifiers,
+	"objecttype":     noModifiers,
+	"objectsize":     noModifiers,
+	"objectname":     hashModifiers,
+	"tree":           hashModifiers,
+	"parent":         hashModifiers,
+	"numparent":      noModifiers,
+	"object":         hashModifiers,
+	"type":           noModifiers,
+	"author":         noModifiers,
+	"authorname":     noModifiers,
+	"authoremail":    emailModifiers,
+	"authordate":     dateModifiers,
+	"committer":      noModifiers,
+	"committername":  noModifiers,
+	"committeremail": emailModifiers,
+	"committerdate":  dateModifiers,
+	"tagger":         noModifiers,
+	"taggername":     noModifiers,
+	"taggeremail":    emailModifiers,
+	"taggerdate":     dateModifiers,
+	"creator":        noModifiers,
+	"creatordate":    dateModifiers,
+	"subject":        noModifiers,
+	"body":           noModifiers,
+	"contents":       {"", "subject", "body", "signature"},
+}
+
+func parseRefAtom(s string) (refAtom, error) {
+	var a refAtom
+	if strings.HasPrefix(s, "*") {
+		a.deref = true
+		s = s[1:]
+	}
+
+	a.name = s
+	if i := strings.IndexByte(s, ':'); i != -1 {
+		a.name, a.modifier = s[:i], s[i+1:]
+	}
+
+	modifiers, ok := refAtomModifiers[a.name]
+	if !ok {
+		return a, ErrInvalidRefFormat
+	}
+
+	modifier := a.modifier
+	if a.name == "upstream" || a.name == "push" {
+		modifier = strings.TrimSuffix(modifier, ",nobracket")
+	}
+
+	for _, m := range modifiers {
+		if modifier == m {
+			return a, nil
+		}
+
+		if strings.HasSuffix(m, "=") && strings.HasPrefix(modifier, m) {
+			if _, err := strconv.Atoi(modifier[len(m):]); err == nil {
+				return a, nil
+			}
+		}
+	}
+
+	return a, ErrInvalidRefFormat
+}
+
+// parseRefFormat splits a format in literals and fields. "%%" is a literal
+// "%" and "%xx" the byte with the hexadecimal code xx.
+func parseRefFormat(format string) ([]refFormatPart, error) {
+	var parts []refFormatPart
+	var literal strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' || i+1 == len(format) {
+			literal.WriteByte(c)
+			continue
+		}
+
+		switch next := format[i+1]; {
+		case next == '%':
+			literal.WriteByte('%')
+			i++
+		case next == '(':
+			end := strings.IndexByte(format[i:], ')')
+			if end == -1 {
+				return nil, ErrInvalidRefFormat
+			}
+
+			atom, err := parseRefAtom(format[i+2 : i+end])
+			if err != nil {
+				return nil, err
+			}
+
+			if literal.Len() != 0 {
+				parts = append(parts, refFormatPart{literal: literal.String()})
+				literal.Reset()
+			}
+
+			parts = append(parts, refFormatPart{atom: &atom})
+			i += end
+		default:
+			if i+2 < len(format) {
+				if b, err := strconv.ParseUint(format[i+1:i+3], 16, 8); err == nil {
+					literal.WriteByte(byte(b))
+					i += 2
+					continue
+				}
+			}
+
+			literal.WriteByte(c)
+		}
+	}
+
+	if literal.Len() != 0 {
+		parts = append(parts, refFormatPart{literal: literal.String()})
+	}
+
+	return parts, nil
+}
+
+// parseRefSortKeys parses the sort keys, returning them from the most
+// significant, the last one given, as git does.
+func parseRefSortKeys(keys []string) ([]refSortKey, error) {
+	result := make([]refSortKey, 0, len(keys))
+	for i := len(keys) - 1; i >= 0; i-- {
+		k := keys[i]
+		var key refSortKey
+		if strings.HasPrefix(k, "-") {
+			key.reverse = true
+			k = k[1:]
+		}
+
+		for _, prefix := range []string{"version:", "v:"} {
+			if strings.HasPrefix(k, prefix) {
+				key.version = true
+				k = k[len(prefix):]
+			}
+		}
+
+		atom, err := parseRefAtom(k)
+		if err != nil {
+			return nil, ErrInvalidRefSortKey
+		}
+
+		key.atom = atom
+		result = append(result, key)
+	}
+
+	return result, nil
+}
+
+// refItem is a listed reference, with the values of its fields computed so
+// far.
+type refItem struct {
+	ref    *plumbing.Reference
+	values map[refAtom]refValue
+
+	loaded       bool
+	obj          object.Object
+	objType      plumbing.ObjectType
+	objSize      int64
+	derefLoaded  bool
+	derefObj     object.Object
+	derefObjType plumbing.ObjectType
+	derefObjSize int64
+}
+
+// refValue is the value of a field, numeric fields as dates and sizes are
+// sorted by number.
+type refValue struct {
+	s       string
+	n       int64
+	numeric bool
+}
+
+type refFormatter struct {
+	r       *Repository
+	objects storage.Storer
+	cfg     *config.Config
+	head    *plumbing.Reference
+
+	reach  *commitgraph.Reachability
+	closer io.Closer
+}
+
+func (r *Repository) newRefFormatter() (*refFormatter, error) {
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := r.MergedConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	head, _ := r.Storer.Reference(plumbing.HEAD)
+	return &refFormatter{r: r, objects: s, cfg: cfg, head: head}, nil
+}
+
+// Close releases the resources used to compute the tracking fields.
+func (f *refFormatter) Close() error {
+	if f.closer == nil {
+		return nil
+	}
+
+	return f.closer.Close()
+}
+
+// compare compares two references by the given keys, and by refname if they
+// are equal.
+func (f *refFormatter) compare(keys []refSortKey, a, b *refItem) (int, error) {
+	for _, k := range keys {
+		va, err := f.value(a, k.atom)
+		if err != nil {
+			return 0, err
+		}
+
+		vb, err := f.value(b, k.atom)
+		if err != nil {
+			return 0, err
+		}
+
+		var cmp int
+		switch {
+		case k.version:
+			cmp = versionCompare(va.s, vb.s)
+		case va.numeric && vb.numeric:
+			cmp = compareInt64(va.n, vb.n)
+		default:
+			cmp = strings.Compare(va.s, vb.s)
+		}
+
+		if k.reverse {
+			cmp = -cmp
+		}
+
+		if cmp != 0 {
+			return cmp, nil
+		}
+	}
+
+	return strings.Compare(a.ref.Name().String(), b.ref.Name().String()), nil
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// versionCompare compares two strings treating the runs of digits as
+// numbers, so "v1.10" is greater than "v1.9".
+func versionCompare(a, b string) int {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			na, nb := digitsPrefix(a), digitsPrefix(b)
+			ta, tb := strings.TrimLeft(na, "0"), strings.TrimLeft(nb, "0")
+			if cmp := compareInt64(int64(len(ta)), int64(len(tb))); cmp != 0 {
+				return cmp
+			}
+
+			if cmp := strings.Compare(ta, tb); cmp != 0 {
+				return cmp
+			}
+
+			a, b = a[len(na):], b[len(nb):]
+			continue
+		}
+
+		if a[0] != b[0] {
+			return compareInt64(int64(a[0]), int64(b[0]))
+		}
+
+		a, b = a[1:], b[1:]
+	}
+
+	return compareInt64(int64(len(a)), int64(len(b)))
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func digitsPrefix(s string) string {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+
+	return s[:i]
+}
+
+// value returns the value of the field of the reference.
+func (f *refFormatter) value(item *refItem, a refAtom) (refValue, error) {
+	if v, ok := item.values[a]; ok {
+		return v, nil
+	}
+
+	v, err := f.computeValue(item, a)
+	if err != nil {
+		return v, err
+	}
+
+	item.values[a] = v
+	return v, nil
+}
+
+func (f *refFormatter) computeValue(item *refItem, a refAtom) (refValue, error) {
+	switch a.name {
+	case "refname":
+		return refValue{s: formatRefName(item.ref.Name(), a.modifier)}, nil
+	case "symref":
+		if item.ref.Type() != plumbing.SymbolicReference {
+			return refValue{}, nil
+		}
+
+		return refValue{s: formatRefName(item.ref.Target(), a.modifier)}, nil
+	case "HEAD":
+		if f.head != nil && f.head.Type() == plumbing.SymbolicReference &&
+			f.head.Target() == item.ref.Name() {
+			return refValue{s: "*"}, nil
+		}
+
+		return refValue{s: " "}, nil
+	case "upstream", "push":
+		s, err := f.trackingValue(item.ref.Name(), a)
+		return refValue{s: s}, err
+	}
+
+	obj, t, size, err := f.object(item, a.deref)
+	if err != nil || obj == nil {
+		return refValue{}, err
+	}
+
+	switch a.name {
+	case "objecttype":
+		return refValue{s: t.String()}, nil
+	case "objectsize":
+		return refValue{s: strconv.FormatInt(size, 10), n: size, numeric: true}, nil
+	case "objectname":
+		return refValue{s: formatHash(obj.ID(), a.modifier)}, nil
+	}
+
+	switch o := obj.(type) {
+	case *object.Commit:
+		return commitRefValue(o, a), nil
+	case *object.Tag:
+		return tagRefValue(o, a), nil
+	}
+
+	return refValue{}, nil
+}
+
+// object returns the object the reference points to, or the one pointed by
+// it if deref is set and it is a tag.
+func (f *refFormatter) object(item *refItem, deref bool) (object.Object, plumbing.ObjectType, int64, error) {
+	if !item.loaded {
+		resolved, err := storer.ResolveReference(f.r.Storer, item.ref.Name())
+		if err != nil {
+			return nil, plumbing.InvalidObject, 0, err
+		}
+
+		item.obj, item.objType, item.objSize, err = f.decodeObject(resolved.Hash())
+		if err != nil {
+			return nil, plumbing.InvalidObject, 0, err
+		}
+
+		item.loaded = true
+	}
+
+	if !deref {
+		return item.obj, item.objType, item.objSize, nil
+	}
+
+	if !item.derefLoaded {
+		if tag, ok := item.obj.(*object.Tag); ok {
+			var err error
+			item.derefObj, item.derefObjType, item.derefObjSize, err = f.decodeObject(tag.Target)
+			if err != nil {
+				return nil, plumbing.InvalidObject, 0, err
+			}
+		}
+
+		item.derefLoaded = true
+	}
+
+	return item.derefObj, item.derefObjType, item.derefObjSize, nil
+}
+
+func (f *refFormatter) decodeObject(h plumbing.Hash) (object.Object, plumbing.ObjectType, int64, error) {
+	eo, err := f.objects.EncodedObject(plumbing.AnyObject, h)
+	if err != nil {
+		return nil, plumbing.InvalidObject, 0, err
+	}
+
+	obj, err := object.DecodeObject(f.objects, eo)
+	if err != nil {
+		return nil, plumbing.InvalidObject, 0, err
+	}
+
+	return obj, eo.Type(), eo.Size(), nil
+}
+
+func commitRefValue(c *object.Commit, a refAtom) refValue {
+	switch a.name {
+	case "tree":
+		return refValue{s: formatHash(c.TreeHash, a.modifier)}
+	case "parent":
+		parents := make([]string, 0, len(c.ParentHashes))
+		for _, p := range c.ParentHashes {
+			parents = append(parents, formatHash(p, a.modifier))
+		}
+
+		return refValue{s: strings.Join(parents, " ")}
+	case "numparent":
+		n := int64(len(c.ParentHashes))
+		return refValue{s: strconv.FormatInt(n, 10), n: n, numeric: true}
+	case "author", "authorname", "authoremail", "authordate":
+		return signatureRefValue(&c.Author, strings.TrimPrefix(a.name, "author"), a.modifier)
+	case "committer", "committername", "committeremail", "committerdate":
+		return signatureRefValue(&c.Committer, strings.TrimPrefix(a.name, "committer"), a.modifier)
+	case "creator", "creatordate":
+		return signatureRefValue(&c.Committer, strings.TrimPrefix(a.name, "creator"), a.modifier)
+	}
+
+	return messageRefValue(c.Message, "", a)
+}
+
+func tagRefValue(t *object.Tag, a refAtom) refValue {
+	switch a.name {
+	case "object":
+		return refValue{s: formatHash(t.Target, a.modifier)}
+	case "type":
+		return refValue{s: t.TargetType.String()}
+	case "tagger", "taggername", "taggeremail", "taggerdate":
+		return signatureRefValue(&t.Tagger, strings.TrimPrefix(a.name, "tagger"), a.modifier)
+	case "creator", "creatordate":
+		return signatureRefValue(&t.Tagger, strings.TrimPrefix(a.name, "creator"), a.modifier)
+	}
+
+	return messageRefValue(t.Message, t.PGPSignature, a)
+}
+
+// signatureRefValue returns the given part of the signature: the whole of it
+// if empty, "name", "email" or "date".
+func signatureRefValue(sig *object.Signature, part, modifier string) refValue {
+	switch part {
+	case "name":
+		return refValue{s: sig.Name}
+	case "email":
+		switch modifier {
+		case "trim":
+			return refValue{s: sig.Email}
+		case "localpart":
+			return refValue{s: strings.SplitN(sig.Email, "@", 2)[0]}
+		}
+
+		return refValue{s: "<" + sig.Email + ">"}
+	case "date":
+		return refValue{s: formatRefDate(sig, modifier), n: sig.When.Unix(), numeric: true}
+	}
+
+	return refValue{
+		s: fmt.Sprintf("%s <%s> %d %s", sig.Name, sig.Email, sig.When.Unix(), sig.When.Format("-0700")),
+		n: sig.When.Unix(),
+	}
+}
+
+func formatRefDate(sig *object.Signature, modifier string) string {
+	when := sig.When
+	switch modifier {
+	case "iso", "iso8601":
+		return when.Format("2006-01-02 15:04:05 -0700")
+	case "iso-strict", "iso8601-strict":
+		return when.Format("2006-01-02T15:04:05-07:00")
+	case "rfc", "rfc2822":
+		return when.Format("Mon, 2 Jan 2006 15:04:05 -0700")
+	case "short":
+		return when.Format("2006-01-02")
+	case "unix":
+		return strconv.FormatInt(when.Unix(), 10)
+	case "raw":
+		return fmt.Sprintf("%d %s", when.Unix(), when.Format("-0700"))
+	}
+
+	return when.Format("Mon Jan 2 15:04:05 2006 -0700")
+}
+
+// messageRefValue returns the subject, body or the whole contents of the
+// message of a commit or a tag.
+func messageRefValue(msg, signature string, a refAtom) refValue {
+	subject, body := splitMessage(msg)
+	switch {
+	case a.name == "subject" || a.name == "contents" && a.modifier == "subject":
+		return refValue{s: subject}
+	case a.name == "body" || a.name == "contents" && a.modifier == "body":
+		return refValue{s: body}
+	case a.name == "contents" && a.modifier == "signature":
+		return refValue{s: signature}
+	case a.name == "contents":
+		return refValue{s: msg + signature}
+	}
+
+	return refValue{}
+}
+
+// splitMessage splits a message in its subject, the first paragraph joined
+// in a single line, and its body, the rest of it.
+func splitMessage(msg string) (subject, body string) {
+	msg = strings.TrimLeft(msg, "\n")
+	paragraph := msg
+	if i := strings.Index(msg, "\n\n"); i != -1 {
+		paragraph, body = msg[:i], strings.TrimLeft(msg[i:], "\n")
+	}
+
+	lines := strings.Split(strings.TrimSpace(paragraph), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+
+	return strings.Join(lines, " "), body
+}
+
+// formatHash returns the hash, abbreviated to 7 characters with the "short"
+// modifier, or to n with "short=n".
+func formatHash(h plumbing.Hash, modifier string) string {
+	s := h.String()
+	switch {
+	case modifier == "short":
+		return s[:7]
+	case strings.HasPrefix(modifier, "short="):
+		n, _ := strconv.Atoi(strings.TrimPrefix(modifier, "short="))
+		if n < 4 {
+			n = 4
+		}
+
+		if n < len(s) {
+			return s[:n]
+		}
+	}
+
+	return s
+}
+
+// formatRefName returns the name of a reference with the "short", "lstrip=n",
+// "strip=n" or "rstrip=n" modifiers applied. A negative n keeps the given
+// number of components.
+func formatRefName(name plumbing.ReferenceName, modifier string) string {
+	switch {
+	case modifier == "short":
+		return name.Short()
+	case strings.HasPrefix(modifier, "lstrip="), strings.HasPrefix(modifier, "strip="):
+		n, _ := strconv.Atoi(modifier[strings.IndexByte(modifier, '=')+1:])
+		parts := strings.Split(name.String(), "/")
+		if n < 0 {
+			n += len(parts)
+		}
+
+		if n < 0 {
+			n = 0
+		}
+
+		if n > len(parts) {
+			n = len(parts)
+		}
+
+		return strings.Join(parts[n:], "/")
+	case strings.HasPrefix(modifier, "rstrip="):
+		n, _ := strconv.Atoi(strings.TrimPrefix(modifier, "rstrip="))
+		parts := strings.Split(name.String(), "/")
+		if n < 0 {
+			n += len(parts)
+		}
+
+		if n < 0 {
+			n = 0
+		}
+
+		if n > len(parts) {
+			n = len(parts)
+		}
+
+		return strings.Join(parts[:len(parts)-n], "/")
+	}
+
+	return name.String()
+}
+
+// trackingValue returns the upstream or push field of a branch, empty for
+// the other references or if it has no upstream.
+func (f *refFormatter) trackingValue(name plumbing.ReferenceName, a refAtom) (string, error) {
+	if !name.IsBranch() {
+		return "", nil
+	}
+
+	branch := name.Short()
+	modifier := a.modifier
+	nobracket := strings.HasSuffix(modifier, ",nobracket")
+	modifier = strings.TrimSuffix(modifier, ",nobracket")
+
+	b := f.cfg.Branches[branch]
+	switch modifier {
+	case "remotename":
+		if a.name == "push" {
+			return pushRemoteName(f.cfg, branch), nil
+		}
+
+		if b == nil {
+			return "", nil
+		}
+
+		return b.Remote, nil
+	case "remoteref":
+		if a.name == "push" || b == nil {
+			return "", nil
+		}
+
+		return b.Merge.String(), nil
+	}
+
+	var tracking plumbing.ReferenceName
+	var err error
+	if a.name == "push" {
+		tracking, err = f.r.pushRefName(f.cfg, branch)
+	} else {
+		tracking, err = f.r.upstreamRefName(f.cfg, branch)
+	}
+
+	if err == ErrNoUpstream || err == ErrRemoteNotFound {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if modifier != "track" && modifier != "trackshort" {
+		return formatRefName(tracking, modifier), nil
+	}
+
+	ahead, behind, gone, err := f.aheadBehind(name, tracking)
+	if err != nil {
+		return "", err
+	}
+
+	if modifier == "trackshort" {
+		switch {
+		case gone:
+			return "", nil
+		case ahead != 0 && behind != 0:
+			return "<>", nil
+		case ahead != 0:
+			return ">", nil
+		case behind != 0:
+			return "<", nil
+		}
+
+		return "=", nil
+	}
+
+	var s string
+	switch {
+	case gone:
+		s = "gone"
+	case ahead != 0 && behind != 0:
+		s = fmt.Sprintf("ahead %d, behind %d", ahead, behind)
+	case ahead != 0:
+		s = fmt.Sprintf("ahead %d", ahead)
+	case behind != 0:
+		s = fmt.Sprintf("behind %d", behind)
+	default:
+		return "", nil
+	}
+
+	if nobracket {
+		return s, nil
+	}
+
+	return "[" + s + "]", nil
+}
+
+// aheadBehind counts the commits of a branch not in its tracking reference,
+// and the other way around, gone is set if the tracking reference doesn't
+// exist.
+func (f *refFormatter) aheadBehind(name, tracking plumbing.ReferenceName) (ahead, behind int, gone bool, err error) {
+	remote, err := storer.ResolveReference(f.r.Storer, tracking)
+	if err == plumbing.ErrReferenceNotFound {
+		return 0, 0, true, nil
+	}
+
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	local, err := storer.ResolveReference(f.r.Storer, name)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	if f.reach == nil {
+		f.reach, f.closer, err = f.r.reachability()
+		if err != nil {
+			return 0, 0, false, err
+		}
+	}
+
+	ahead, behind, err = f.reach.AheadBehind(local.Hash(), remote.Hash())
+	return ahead, behind, false, err
+}
@@ -0,0 +1,200 @@
+package git
+
+import (
+	"time"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/object"
+
+	fixtures "github.com/go-git/go-git-fixtures/v4"
+	. "gopkg.in/check.v1"
+)
+
+type ForEachRefSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&ForEachRefSuite{})
+
+func (s *ForEachRefSuite) outputs(c *C, r *Repository, o *ForEachRefOptions) []string {
+	refs, err := r.ForEachRef(o)
+	c.Assert(err, IsNil)
+
+	result := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		result = append(result, ref.Output)
+	}
+
+	return result
+}
+
+func (s *ForEachRefSuite) TestDefaultFormat(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	c.Assert(s.outputs(c, r, &ForEachRefOptions{Patterns: []string{"refs/heads"}}), DeepEquals, []string{
+		"e8d3ffab552895c19b9fcf7aa264d277cde33881 commit\trefs/heads/branch",
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5 commit\trefs/heads/master",
+	})
+}
+
+func (s *ForEachRefSuite) TestPatterns(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	for _, t := range []struct {
+		patterns []string
+		expected []string
+	}{
+		{nil, []string{"branch", "master", "origin/HEAD", "origin/branch", "origin/master", "v1.0.0"}},
+		{[]string{"refs/remotes/"}, []string{"origin/HEAD", "origin/branch", "origin/master"}},
+		{[]string{"refs/remotes/origin/m*", "refs/tags"}, []string{"origin/master", "v1.0.0"}},
+		{[]string{"refs/*/master"}, []string{"master"}},
+		{[]string{"refs/head"}, []string{}},
+	} {
+		c.Assert(s.outputs(c, r, &ForEachRefOptions{
+			Patterns: t.patterns,
+			Format:   "%(refname:short)",
+		}), DeepEquals, t.expected, Commentf("%v", t.patterns))
+	}
+}
+
+func (s *ForEachRefSuite) TestFilters(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+	master := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	branch := plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")
+
+	tag, err := r.CreateTag("v2.0.0", branch, &CreateTagOptions{
+		Tagger:  defaultSignature(),
+		Message: "v2.0.0",
+	})
+	c.Assert(err, IsNil)
+
+	o := &ForEachRefOptions{Format: "%(refname)", PointsAt: []plumbing.Hash{branch}}
+	c.Assert(s.outputs(c, r, o), DeepEquals, []string{
+		"refs/heads/branch",
+		"refs/remotes/origin/branch",
+		"refs/tags/v2.0.0",
+	})
+
+	o = &ForEachRefOptions{Format: "%(refname)", PointsAt: []plumbing.Hash{tag.Hash()}}
+	c.Assert(s.outputs(c, r, o), DeepEquals, []string{"refs/tags/v2.0.0"})
+
+	o = &ForEachRefOptions{Format: "%(refname)", Patterns: []string{"refs/heads"}}
+	o.Merged = []plumbing.Hash{master}
+	c.Assert(s.outputs(c, r, o), DeepEquals, []string{"refs/heads/master"})
+
+	o = &ForEachRefOptions{Format: "%(refname)", Patterns: []string{"refs/tags"}}
+	o.Contains = []plumbing.Hash{branch}
+	c.Assert(s.outputs(c, r, o), DeepEquals, []string{"refs/tags/v2.0.0"})
+}
+
+func (s *ForEachRefSuite) TestSort(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+	head := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	for _, name := range []string{"v1.10.0", "v1.9.0", "v1.2.0"} {
+		c.Assert(r.Storer.SetReference(plumbing.NewHashReference(plumbing.NewTagReferenceName(name), head)), IsNil)
+	}
+
+	o := &ForEachRefOptions{
+		Patterns: []string{"refs/tags"},
+		Sort:     []string{"-version:refname"},
+		Format:   "%(refname:lstrip=2)",
+	}
+	c.Assert(s.outputs(c, r, o), DeepEquals, []string{"v1.10.0", "v1.9.0", "v1.2.0", "v1.0.0"})
+
+	o.Sort = []string{"refname"}
+	c.Assert(s.outputs(c, r, o), DeepEquals, []string{"v1.0.0", "v1.10.0", "v1.2.0", "v1.9.0"})
+
+	o = &ForEachRefOptions{
+		Patterns: []string{"refs/heads"},
+		Sort:     []string{"-committerdate"},
+		Format:   "%(refname:short) %(committerdate:short)",
+		Count:    1,
+	}
+	c.Assert(s.outputs(c, r, o), DeepEquals, []string{"master 2015-04-05"})
+
+	o.Sort = []string{"committerdate"}
+	c.Assert(s.outputs(c, r, o), DeepEquals, []string{"branch 2015-03-31"})
+
+	// the last key is the most significant, as git does
+	for _, name := range []string{"a", "z"} {
+		c.Assert(r.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(name), head)), IsNil)
+	}
+
+	o = &ForEachRefOptions{
+		Patterns: []string{"refs/heads"},
+		Sort:     []string{"refname", "-committerdate"},
+		Format:   "%(refname:short)",
+	}
+	c.Assert(s.outputs(c, r, o), DeepEquals, []string{"a", "master", "z", "branch"})
+}
+
+func (s *ForEachRefSuite) TestFormat(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+	branch := plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")
+
+	tagger := &object.Signature{
+		Name:  "foo",
+		Email: "foo@foo.foo",
+		When:  time.Unix(1494108223, 0).In(time.FixedZone("", 2*60*60)),
+	}
+
+	_, err := r.CreateTag("v2.0.0", branch, &CreateTagOptions{
+		Tagger:  tagger,
+		Message: "release\nv2.0.0\n\nthe body\n",
+	})
+	c.Assert(err, IsNil)
+
+	o := &ForEachRefOptions{
+		Patterns: []string{"refs/tags/v2.0.0"},
+		Format: "%(objecttype) %(type) %(object:short) %(*objectname:short) " +
+			"%(*objecttype)%0a%(subject)|%(body)|%(taggername) %(taggeremail) " +
+			"%(taggerdate:iso-strict) %(creatordate:unix)|%(*subject)|%(*authoremail:localpart) %%",
+	}
+	c.Assert(s.outputs(c, r, o), DeepEquals, []string{
+		"tag commit e8d3ffa e8d3ffa commit\n" +
+			"release v2.0.0|the body\n|foo <foo@foo.foo> 2017-05-07T00:03:43+02:00 1494108223|" +
+			"some code in a branch|mcuadros %",
+	})
+
+	o = &ForEachRefOptions{
+		Patterns: []string{"refs/heads", "refs/remotes/origin/HEAD"},
+		Format:   "%(HEAD) %(refname:short) %(objectname:short=10) %(parent:short) %(symref:short)",
+	}
+	c.Assert(s.outputs(c, r, o), DeepEquals, []string{
+		"  branch e8d3ffab55 918c48b ",
+		"* master 6ecf0ef2c2 918c48b ",
+		"  origin/HEAD 6ecf0ef2c2 918c48b origin/master",
+	})
+}
+
+func (s *ForEachRefSuite) TestUpstream(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	// master is one commit behind its upstream
+	parent := plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+	c.Assert(r.Storer.SetReference(plumbing.NewHashReference(plumbing.Master, parent)), IsNil)
+	c.Assert(r.Storer.RemoveReference("refs/remotes/origin/branch"), IsNil)
+
+	o := &ForEachRefOptions{
+		Patterns: []string{"refs/heads"},
+		Format: "%(refname:short) %(upstream) %(upstream:short) %(upstream:remotename) " +
+			"%(upstream:track) %(upstream:trackshort) %(upstream:track,nobracket)",
+	}
+	c.Assert(s.outputs(c, r, o), DeepEquals, []string{
+		"branch refs/remotes/origin/branch origin/branch origin [gone]  gone",
+		"master refs/remotes/origin/master origin/master origin [behind 1] < behind 1",
+	})
+}
+
+func (s *ForEachRefSuite) TestInvalidOptions(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	for _, format := range []string{"%(foo)", "%(refname:foo)", "%(objectname:short=a)", "%(refname"} {
+		_, err := r.ForEachRef(&ForEachRefOptions{Format: format})
+		c.Assert(err, Equals, ErrInvalidRefFormat, Commentf(format))
+	}
+
+	_, err := r.ForEachRef(&ForEachRefOptions{Sort: []string{"version:foo"}})
+	c.Assert(err, Equals, ErrInvalidRefSortKey)
+}
@@ -0,0 +1,165 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goabstract/go-git/v5/config"
+	"github.com/goabstract/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/openpgp"
+)
+
+var (
+	// ErrMissingSignKey is returned when signing is enabled in the config, by
+	// commit.gpgSign or tag.gpgSign, and a key ring is given, but no sign key
+	// is found in it.
+	ErrMissingSignKey = errors.New("signing is enabled but no sign key was found")
+	// ErrInvalidDate is returned when the date of an identity given by the
+	// environment, as GIT_AUTHOR_DATE, can't be parsed.
+	ErrInvalidDate = errors.New("invalid date format")
+)
+
+const (
+	authorEnvPrefix    = "GIT_AUTHOR_"
+	committerEnvPrefix = "GIT_COMMITTER_"
+)
+
+// identityDateLayouts are the layouts of the dates accepted in the
+// environment, besides the raw "<unix timestamp> <time zone>" format.
+var identityDateLayouts = []string{
+	time.RFC1123Z,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+}
+
+// identitySignature returns the signature of the user in the config, at the
+// given time. If env is true, it is overridden by the environment variables
+// with the given prefix, <prefix>NAME, <prefix>EMAIL and <prefix>DATE, as git
+// does. nil is returned if the name or the email are missing.
+func identitySignature(cfg *config.Config, prefix string, env bool, when time.Time) (*object.Signature, error) {
+	sig := &object.Signature{
+		Name:  cfg.User.Name,
+		Email: cfg.User.Email,
+		When:  when,
+	}
+
+	if env {
+		if name, ok := os.LookupEnv(prefix + "NAME"); ok {
+			sig.Name = name
+		}
+
+		if email, ok := os.LookupEnv(prefix + "EMAIL"); ok {
+			sig.Email = email
+		}
+
+		if date := os.Getenv(prefix + "DATE"); date != "" {
+			when, err := parseIdentityDate(date)
+			if err != nil {
+				return nil, err
+			}
+
+			sig.When = when
+		}
+	}
+
+	if sig.Name == "" || sig.Email == "" {
+		return nil, nil
+	}
+
+	return sig, nil
+}
+
+// parseIdentityDate parses a date in the raw format of git,
+// "[@]<unix timestamp> [<time zone>]", or in the RFC 2822 and ISO 8601
+// formats.
+func parseIdentityDate(date string) (time.Time, error) {
+	date = strings.TrimSpace(date)
+	fields := strings.Fields(strings.TrimPrefix(date, "@"))
+	if len(fields) == 1 || len(fields) == 2 {
+		if sec, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			when := time.Unix(sec, 0)
+			if len(fields) == 1 {
+				return when, nil
+			}
+
+			tz, err := time.Parse("-0700", fields[1])
+			if err != nil {
+				return time.Time{}, ErrInvalidDate
+			}
+
+			return when.In(tz.Location()), nil
+		}
+	}
+
+	for _, layout := range identityDateLayouts {
+		if when, err := time.ParseInLocation(layout, date, time.Local); err == nil {
+			return when, nil
+		}
+	}
+
+	return time.Time{}, ErrInvalidDate
+}
+
+// configSignKey returns the key of the key ring to sign with when signing is
+// enabled in the config, or nil if there is no key ring, or if the config asks
+// for a signature format other than OpenPGP, as gpg.format=ssh.
+func configSignKey(cfg *config.Config, keyring openpgp.EntityList, email string) (*openpgp.Entity, error) {
+	if len(keyring) == 0 {
+		return nil, nil
+	}
+
+	format := cfg.Raw.Section("gpg").Option("format")
+	if format != "" && !strings.EqualFold(format, "openpgp") {
+		return nil, nil
+	}
+
+	return findSignKey(keyring, cfg.User.SigningKey, email)
+}
+
+// findSignKey returns the key of the key ring identified by id, the value of
+// user.signingKey, by the suffix of its fingerprint, as a key ID, or by one of
+// its identities. Without id, the first key with an identity with the given
+// email is returned.
+func findSignKey(keyring openpgp.EntityList, id, email string) (*openpgp.Entity, error) {
+	keyID := strings.ToUpper(strings.TrimSuffix(id, "!"))
+	keyID = strings.TrimPrefix(keyID, "0X")
+	for _, e := range keyring {
+		if id == "" {
+			for _, identity := range e.Identities {
+				if identity.UserId != nil && identity.UserId.Email == email {
+					return e, nil
+				}
+			}
+
+			continue
+		}
+
+		if len(keyID) >= 8 {
+			fingerprints := []string{fmt.Sprintf("%X", e.PrimaryKey.Fingerprint)}
+			for _, subkey := range e.Subkeys {
+				fingerprints = append(fingerprints, fmt.Sprintf("%X", subkey.PublicKey.Fingerprint))
+			}
+
+			for _, fingerprint := range fingerprints {
+				if strings.HasSuffix(fingerprint, keyID) {
+					return e, nil
+				}
+			}
+		}
+
+		for name := range e.Identities {
+			if strings.Contains(name, id) {
+				return e, nil
+			}
+		}
+	}
+
+	return nil, ErrMissingSignKey
+}
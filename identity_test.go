@@ -0,0 +1,64 @@
+package git
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/openpgp"
+	. "gopkg.in/check.v1"
+)
+
+type IdentitySuite struct{}
+
+var _ = Suite(&IdentitySuite{})
+
+func (s *IdentitySuite) TestParseIdentityDate(c *C) {
+	for _, date := range []string{
+		"1234567890 +0200",
+		"@1234567890 +0200",
+		"Sat, 14 Feb 2009 01:31:30 +0200",
+		"2009-02-14T01:31:30+02:00",
+		"2009-02-14 01:31:30 +0200",
+	} {
+		when, err := parseIdentityDate(date)
+		c.Assert(err, IsNil, Commentf(date))
+		c.Assert(when.Unix(), Equals, int64(1234567890), Commentf(date))
+		c.Assert(when.Format("-0700"), Equals, "+0200", Commentf(date))
+	}
+
+	when, err := parseIdentityDate("1234567890")
+	c.Assert(err, IsNil)
+	c.Assert(when.Unix(), Equals, int64(1234567890))
+
+	for _, date := range []string{"yesterday", "1234567890 CET", ""} {
+		_, err := parseIdentityDate(date)
+		c.Assert(err, Equals, ErrInvalidDate, Commentf(date))
+	}
+}
+
+func (s *IdentitySuite) TestFindSignKey(c *C) {
+	key := commitSignKey(c, false)
+	keyring := openpgp.EntityList{key}
+	fingerprint := fmt.Sprintf("%X", key.PrimaryKey.Fingerprint)
+
+	for _, id := range []string{
+		fingerprint,
+		"0x" + fingerprint[len(fingerprint)-16:],
+		fingerprint[len(fingerprint)-8:] + "!",
+		"foo bar",
+	} {
+		found, err := findSignKey(keyring, id, "")
+		c.Assert(err, IsNil, Commentf(id))
+		c.Assert(found, Equals, key)
+	}
+
+	found, err := findSignKey(keyring, "", "foo@foo.foo")
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, key)
+
+	_, err = findSignKey(keyring, "", "bar@foo.foo")
+	c.Assert(err, Equals, ErrMissingSignKey)
+	_, err = findSignKey(keyring, "DEADBEEF", "foo@foo.foo")
+	c.Assert(err, Equals, ErrMissingSignKey)
+	_, err = findSignKey(nil, "", "foo@foo.foo")
+	c.Assert(err, Equals, ErrMissingSignKey)
+}
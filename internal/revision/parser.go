@@ -8,6 +8,7 @@ import (
 	"io"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -47,7 +48,7 @@ type CaretReg struct {
 	Negate bool
 }
 
-// CaretType represents ^{commit}
+// CaretType represents ^{commit}, an empty ObjectType represents ^{}
 type CaretType struct {
 	ObjectType string
 }
@@ -72,7 +73,7 @@ type AtPush struct {
 	BranchName string
 }
 
-// AtDate represents @{"2006-01-02T15:04:05Z"}
+// AtDate represents @{"2006-01-02T15:04:05Z"}, @{yesterday}, @{2.weeks.ago}
 type AtDate struct {
 	Date time.Time
 }
@@ -191,37 +192,37 @@ func (p *Parser) validateFullRevision(chunks *[]Revisioner) error {
 				return &ErrInvalidRevision{`reference must be defined once at the beginning`}
 			}
 		case AtDate:
-			if len(*chunks) == 1 || hasReference && len(*chunks) == 2 {
-				return nil
+			if i == 0 || hasReference && i == 1 {
+				continue
 			}
 
 			return &ErrInvalidRevision{`"@" statement is not valid, could be : <refname>@{<ISO-8601 date>}, @{<ISO-8601 date>}`}
 		case AtReflog:
-			if len(*chunks) == 1 || hasReference && len(*chunks) == 2 {
-				return nil
+			if i == 0 || hasReference && i == 1 {
+				continue
 			}
 
 			return &ErrInvalidRevision{`"@" statement is not valid, could be : <refname>@{<n>}, @{<n>}`}
 		case AtCheckout:
-			if len(*chunks) == 1 {
-				return nil
+			if i == 0 {
+				continue
 			}
 
 			return &ErrInvalidRevision{`"@" statement is not valid, could be : @{-<n>}`}
 		case AtUpstream:
-			if len(*chunks) == 1 || hasReference && len(*chunks) == 2 {
-				return nil
+			if i == 0 || hasReference && i == 1 {
+				continue
 			}
 
 			return &ErrInvalidRevision{`"@" statement is not valid, could be : <refname>@{upstream}, @{upstream}, <refname>@{u}, @{u}`}
 		case AtPush:
-			if len(*chunks) == 1 || hasReference && len(*chunks) == 2 {
-				return nil
+			if i == 0 || hasReference && i == 1 {
+				continue
 			}
 
 			return &ErrInvalidRevision{`"@" statement is not valid, could be : <refname>@{push}, @{push}`}
 		case TildePath, CaretPath, CaretReg:
-			if !hasReference {
+			if i == 0 {
 				return &ErrInvalidRevision{`"~" or "^" statement must have a reference defined at the beginning`}
 			}
 		case ColonReg:
@@ -231,7 +232,7 @@ func (p *Parser) validateFullRevision(chunks *[]Revisioner) error {
 
 			return &ErrInvalidRevision{`":" statement is not valid, could be : :/<regexp>`}
 		case ColonPath:
-			if i == len(*chunks)-1 && hasReference || len(*chunks) == 1 {
+			if i == len(*chunks)-1 {
 				return nil
 			}
 
@@ -315,7 +316,7 @@ func (p *Parser) parseAt() (Revisioner, error) {
 
 			switch {
 			case tok == cbrace:
-				t, err := time.Parse("2006-01-02T15:04:05Z", date)
+				t, err := parseDate(date)
 
 				if err != nil {
 					return nil, &ErrInvalidRevision{fmt.Sprintf(`wrong date "%s" must fit ISO-8601 format : 2006-01-02T15:04:05Z`, date)}
@@ -329,6 +330,62 @@ func (p *Parser) parseAt() (Revisioner, error) {
 	}
 }
 
+// dateLayouts are the absolute date formats accepted in @{<date>}
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02.15:04:05",
+	"2006-01-02",
+}
+
+// dateUnits are the units accepted in relative dates, such as 2.weeks.ago
+var dateUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+	"month":  30 * 24 * time.Hour,
+	"year":   365 * 24 * time.Hour,
+}
+
+// parseDate parses the date of a @{<date>} statement, either an absolute
+// date or a relative one as "yesterday", "now" or "<n> <unit>s ago", whose
+// words can be separated by dots or spaces
+func parseDate(date string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02T15:04:05Z", date); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, date, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	now := time.Now()
+	words := strings.FieldsFunc(date, func(r rune) bool { return r == '.' || r == ' ' })
+
+	switch {
+	case len(words) == 1 && words[0] == "now":
+		return now, nil
+	case len(words) == 1 && words[0] == "yesterday":
+		return now.Add(-24 * time.Hour), nil
+	case len(words) == 3 && words[2] == "ago":
+		n, err := strconv.Atoi(words[0])
+		unit, ok := dateUnits[strings.TrimSuffix(words[1], "s")]
+
+		if err != nil || !ok {
+			break
+		}
+
+		return now.Add(-time.Duration(n) * unit), nil
+	}
+
+	return time.Time{}, fmt.Errorf("unknown date format %q", date)
+}
+
 // parseTilde extract ~ statements
 func (p *Parser) parseTilde() (Revisioner, error) {
 	var tok token
@@ -413,7 +470,7 @@ func (p *Parser) parseCaretBraces() (Revisioner, error) {
 		case tok == word && nextTok == cbrace && (lit == "commit" || lit == "tree" || lit == "blob" || lit == "tag" || lit == "object"):
 			return CaretType{lit}, nil
 		case re == "" && tok == cbrace:
-			return CaretType{"tag"}, nil
+			return CaretType{""}, nil
 		case re == "" && tok == emark && nextTok == emark:
 			re += lit
 		case re == "" && tok == emark && nextTok == minus:
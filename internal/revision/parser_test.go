@@ -116,6 +116,19 @@ func (s *ParserSuite) TestParseWithValidExpression(c *C) {
 			Ref("master"),
 			AtDate{tim},
 		},
+		"master@{1}~2": []Revisioner{
+			Ref("master"),
+			AtReflog{1},
+			TildePath{2},
+		},
+		"@{-1}^": []Revisioner{
+			AtCheckout{1},
+			CaretPath{1},
+		},
+		"@{u}:README": []Revisioner{
+			AtUpstream{},
+			ColonPath{"README"},
+		},
 		"HEAD^": []Revisioner{
 			Ref("HEAD"),
 			CaretPath{1},
@@ -130,7 +143,7 @@ func (s *ParserSuite) TestParseWithValidExpression(c *C) {
 		},
 		"v0.99.8^{}": []Revisioner{
 			Ref("v0.99.8"),
-			CaretType{"tag"},
+			CaretType{""},
 		},
 		"HEAD^{/fix nasty bug}": []Revisioner{
 			Ref("HEAD"),
@@ -230,6 +243,45 @@ func (s *ParserSuite) TestParseAtWithValidExpression(c *C) {
 	}
 }
 
+func (s *ParserSuite) TestParseDate(c *C) {
+	local := time.Date(2016, 12, 16, 21, 42, 47, 0, time.Local)
+
+	datas := map[string]time.Time{
+		"2016-12-16T21:42:47Z":      time.Date(2016, 12, 16, 21, 42, 47, 0, time.UTC),
+		"2016-12-16T21:42:47+02:00": time.Date(2016, 12, 16, 19, 42, 47, 0, time.UTC),
+		"2016-12-16 21:42:47":       local,
+		"2016-12-16.21:42:47":       local,
+		"2016-12-16":                time.Date(2016, 12, 16, 0, 0, 0, 0, time.Local),
+	}
+
+	for d, expected := range datas {
+		t, err := parseDate(d)
+		c.Assert(err, IsNil, Commentf("%s", d))
+		c.Assert(t.Equal(expected), Equals, true, Commentf("%s", d))
+	}
+
+	now := time.Now()
+	relative := map[string]time.Duration{
+		"now":          0,
+		"yesterday":    24 * time.Hour,
+		"2.weeks.ago":  14 * 24 * time.Hour,
+		"1 hour ago":   time.Hour,
+		"3.minute.ago": 3 * time.Minute,
+	}
+
+	for d, ago := range relative {
+		t, err := parseDate(d)
+		c.Assert(err, IsNil, Commentf("%s", d))
+		diff := now.Add(-ago).Sub(t)
+		c.Assert(diff < time.Minute && diff > -time.Minute, Equals, true, Commentf("%s", d))
+	}
+
+	for _, d := range []string{"test", "2.fortnights.ago", "two.weeks.ago"} {
+		_, err := parseDate(d)
+		c.Assert(err, NotNil, Commentf("%s", d))
+	}
+}
+
 func (s *ParserSuite) TestParseAtWithUnValidExpression(c *C) {
 	datas := map[string]error{
 		"{test}": &ErrInvalidRevision{`wrong date "test" must fit ISO-8601 format : 2006-01-02T15:04:05Z`},
@@ -249,7 +301,7 @@ func (s *ParserSuite) TestParseCaretWithValidExpression(c *C) {
 	datas := map[string]Revisioner{
 		"":                    CaretPath{1},
 		"2":                   CaretPath{2},
-		"{}":                  CaretType{"tag"},
+		"{}":                  CaretType{""},
 		"{commit}":            CaretType{"commit"},
 		"{tree}":              CaretType{"tree"},
 		"{blob}":              CaretType{"blob"},
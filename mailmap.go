@@ -0,0 +1,144 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/format/mailmap"
+	"github.com/goabstract/go-git/v5/plumbing/object"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/mitchellh/go-homedir"
+)
+
+const (
+	mailmapFile    = ".mailmap"
+	mailmapSection = "mailmap"
+	mailmapFileKey = "file"
+	mailmapBlobKey = "blob"
+)
+
+// Mailmap returns the mailmap of the repository, built in the same order as
+// git does: the .mailmap file at the top of the worktree, the blob referenced
+// by `mailmap.blob` (HEAD:.mailmap by default in bare repositories) and the
+// file referenced by `mailmap.file`. Later sources take precedence. An empty
+// Mailmap is returned if none of them exist.
+func (r *Repository) Mailmap() (*mailmap.Mailmap, error) {
+	m := mailmap.New()
+
+	cfg, err := r.MergedConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	s := cfg.Raw.Section(mailmapSection)
+
+	if r.wt != nil {
+		if err := readMailmapFile(m, r.wt, mailmapFile); err != nil {
+			return nil, err
+		}
+	}
+
+	blob := s.Options.Get(mailmapBlobKey)
+	if blob == "" && r.wt == nil {
+		blob = "HEAD:" + mailmapFile
+	}
+
+	if blob != "" {
+		if err := r.readMailmapBlob(m, blob); err != nil {
+			return nil, err
+		}
+	}
+
+	if file := s.Options.Get(mailmapFileKey); file != "" {
+		file, err = homedir.Expand(file)
+		if err != nil {
+			return nil, err
+		}
+
+		fs := osfs.New(filepath.Dir(file))
+		if err := readMailmapFile(m, fs, filepath.Base(file)); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func readMailmapFile(m *mailmap.Mailmap, fs billy.Basic, path string) error {
+	f, err := fs.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	return m.Read(f)
+}
+
+// readMailmapBlob reads a mailmap from a blob revision such as HEAD:.mailmap,
+// missing revisions or paths are ignored as git does.
+func (r *Repository) readMailmapBlob(m *mailmap.Mailmap, rev string) error {
+	o, err := r.ResolveRevisionObject(plumbing.Revision(rev))
+	if err != nil {
+		return nil
+	}
+
+	b, ok := o.(*object.Blob)
+	if !ok {
+		return nil
+	}
+
+	rd, err := b.Reader()
+	if err != nil {
+		return err
+	}
+
+	defer rd.Close()
+	return m.Read(rd)
+}
+
+// mailmapSignature returns a copy of the signature with its name and email
+// replaced by the canonical ones.
+func mailmapSignature(m *mailmap.Mailmap, s object.Signature) object.Signature {
+	s.Name, s.Email = m.Lookup(s.Name, s.Email)
+	return s
+}
+
+// mailmapCommit returns a copy of the commit with the author and committer
+// mapped through the given mailmap.
+func mailmapCommit(m *mailmap.Mailmap, c *object.Commit) *object.Commit {
+	n := *c
+	n.Author = mailmapSignature(m, c.Author)
+	n.Committer = mailmapSignature(m, c.Committer)
+	return &n
+}
+
+type commitMailmapIter struct {
+	object.CommitIter
+	m *mailmap.Mailmap
+}
+
+func newCommitMailmapIter(iter object.CommitIter, m *mailmap.Mailmap) object.CommitIter {
+	return &commitMailmapIter{CommitIter: iter, m: m}
+}
+
+func (i *commitMailmapIter) Next() (*object.Commit, error) {
+	c, err := i.CommitIter.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	return mailmapCommit(i.m, c), nil
+}
+
+func (i *commitMailmapIter) ForEach(cb func(*object.Commit) error) error {
+	return i.CommitIter.ForEach(func(c *object.Commit) error {
+		return cb(mailmapCommit(i.m, c))
+	})
+}
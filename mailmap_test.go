@@ -0,0 +1,66 @@
+package git
+
+import (
+	"github.com/goabstract/go-git/v5/plumbing/object"
+
+	"github.com/go-git/go-billy/v5/util"
+	fixtures "github.com/go-git/go-git-fixtures/v4"
+	. "gopkg.in/check.v1"
+)
+
+type MailmapSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&MailmapSuite{})
+
+func (s *MailmapSuite) TestMailmapEmpty(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	m, err := r.Mailmap()
+	c.Assert(err, IsNil)
+	c.Assert(m.Entries(), Equals, 0)
+}
+
+func (s *MailmapSuite) TestMailmapFromWorktree(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+	err := util.WriteFile(r.wt, ".mailmap", []byte(
+		"Máximo Cuadros Ortiz <mcuadros@gmail.com>\n",
+	), 0644)
+	c.Assert(err, IsNil)
+
+	m, err := r.Mailmap()
+	c.Assert(err, IsNil)
+	c.Assert(m.Entries(), Equals, 1)
+
+	name, _ := m.Lookup("Máximo Cuadros", "mcuadros@gmail.com")
+	c.Assert(name, Equals, "Máximo Cuadros Ortiz")
+}
+
+func (s *MailmapSuite) TestLogWithMailmap(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+	err := util.WriteFile(r.wt, ".mailmap", []byte(
+		"Daniel <daniel@example.com> <daniel@lordran.local>\n",
+	), 0644)
+	c.Assert(err, IsNil)
+
+	m, err := r.Mailmap()
+	c.Assert(err, IsNil)
+
+	iter, err := r.Log(&LogOptions{Mailmap: m})
+	c.Assert(err, IsNil)
+
+	var found bool
+	err = iter.ForEach(func(commit *object.Commit) error {
+		c.Assert(commit.Author.Email, Not(Equals), "daniel@lordran.local")
+		c.Assert(commit.Committer.Email, Not(Equals), "daniel@lordran.local")
+		if commit.Author.Email == "daniel@example.com" {
+			c.Assert(commit.Author.Name, Equals, "Daniel")
+			found = true
+		}
+
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, true)
+}
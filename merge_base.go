@@ -0,0 +1,54 @@
+package git
+
+import (
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/object"
+	"github.com/goabstract/go-git/v5/plumbing/storer"
+)
+
+// ForkPoint returns the commit where the given one forked from the upstream
+// reference, as `git merge-base --fork-point <upstream> <commit>` does.
+// Besides the tip of upstream, all the commits recorded by its reflog are
+// considered, so the fork point is found even if upstream was rebased after
+// the fork. object.ErrNoForkPoint is returned if it is not found.
+func (r *Repository) ForkPoint(upstream plumbing.ReferenceName, h plumbing.Hash) (*object.Commit, error) {
+	commit, err := r.CommitObject(h)
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := storer.ResolveReference(r.Storer, upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := []plumbing.Hash{ref.Hash()}
+	entries, err := r.Reflog(ref.Name())
+	if err != nil && err != ErrReflogNotSupported {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		hashes = append(hashes, e.New, e.Old)
+	}
+
+	var commits []*object.Commit
+	for _, h := range hashes {
+		if h.IsZero() {
+			continue
+		}
+
+		c, err := r.CommitObject(h)
+		if err == plumbing.ErrObjectNotFound {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		commits = append(commits, c)
+	}
+
+	return commit.ForkPoint(commits)
+}
@@ -0,0 +1,83 @@
+package git
+
+import (
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/object"
+	"github.com/goabstract/go-git/v5/plumbing/storer"
+
+	fixtures "github.com/go-git/go-git-fixtures/v4"
+	. "gopkg.in/check.v1"
+)
+
+type MergeBaseSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&MergeBaseSuite{})
+
+func (s *MergeBaseSuite) TestForkPoint(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	upstream := plumbing.NewBranchReferenceName("upstream")
+	forked := plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+	topic := plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")
+
+	ref := plumbing.NewHashReference(upstream, forked)
+	err := updateReference(r.Storer, nil, ref, nil, defaultSignature(), "branch: Created from "+forked.String())
+	c.Assert(err, IsNil)
+
+	commit, err := r.ForkPoint(upstream, topic)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Hash, Equals, forked)
+
+	// rewrite the upstream, as a rebase would do
+	rewritten := s.rewriteCommit(c, r, forked)
+	err = updateReference(r.Storer, nil, plumbing.NewHashReference(upstream, rewritten), ref,
+		defaultSignature(), "rebase (finish): "+upstream.String())
+	c.Assert(err, IsNil)
+
+	commit, err = r.ForkPoint(upstream, topic)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Hash, Equals, forked)
+
+	bases, err := s.commit(c, r, topic).MergeBase(s.commit(c, r, rewritten))
+	c.Assert(err, IsNil)
+	c.Assert(bases, HasLen, 1)
+	c.Assert(bases[0].Hash, Not(Equals), forked)
+
+	// without the reflog, only the merge base with the tip is considered
+	err = r.Storer.(storer.ReflogStorer).DeleteReflog(upstream)
+	c.Assert(err, IsNil)
+
+	commit, err = r.ForkPoint(upstream, topic)
+	c.Assert(err, Equals, object.ErrNoForkPoint)
+	c.Assert(commit, IsNil)
+}
+
+func (s *MergeBaseSuite) TestForkPointUnknownReference(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	_, err := r.ForkPoint(plumbing.NewBranchReferenceName("unknown"),
+		plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881"))
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+// rewriteCommit writes a copy of the given commit with another message,
+// returning its hash.
+func (s *MergeBaseSuite) rewriteCommit(c *C, r *Repository, h plumbing.Hash) plumbing.Hash {
+	commit := s.commit(c, r, h)
+	commit.Message = "rewritten: " + commit.Message
+
+	obj := r.Storer.NewEncodedObject()
+	c.Assert(commit.Encode(obj), IsNil)
+
+	rewritten, err := r.Storer.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+	return rewritten
+}
+
+func (s *MergeBaseSuite) commit(c *C, r *Repository, h plumbing.Hash) *object.Commit {
+	commit, err := r.CommitObject(h)
+	c.Assert(err, IsNil)
+	return commit
+}
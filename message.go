@@ -0,0 +1,101 @@
+package git
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrInvalidCleanupMode is returned when commit.cleanup isn't one of the
+	// cleanup modes supported by git.
+	ErrInvalidCleanupMode = errors.New("invalid cleanup mode")
+)
+
+const (
+	cleanupStrip      = "strip"
+	cleanupWhitespace = "whitespace"
+	cleanupVerbatim   = "verbatim"
+	cleanupScissors   = "scissors"
+	cleanupDefault    = "default"
+
+	defaultCommentChar = "#"
+	scissorsLine       = " ------------------------ >8 ------------------------"
+)
+
+// cleanupMessage cleans up a commit message following the given mode, as
+// `git commit --cleanup` does. edited tells if the message comes from an
+// editor, or a template, where the default mode strips the comments and
+// scissors cuts the message at the scissors line.
+func cleanupMessage(msg, mode, commentChar string, edited bool) (string, error) {
+	if commentChar == "" || commentChar == "auto" {
+		commentChar = defaultCommentChar
+	}
+
+	if mode == cleanupDefault {
+		mode = cleanupWhitespace
+		if edited {
+			mode = cleanupStrip
+		}
+	}
+
+	switch mode {
+	case "", cleanupVerbatim:
+		return msg, nil
+	case cleanupWhitespace:
+		return stripSpace(msg, ""), nil
+	case cleanupStrip:
+		return stripSpace(msg, commentChar), nil
+	case cleanupScissors:
+		if edited {
+			msg = cutScissors(msg, commentChar)
+		}
+
+		return stripSpace(msg, ""), nil
+	default:
+		return "", ErrInvalidCleanupMode
+	}
+}
+
+// stripSpace removes the trailing whitespace of the lines of the message, the
+// consecutive blank lines and the blank lines at its beginning and end, as
+// `git stripspace` does. If commentChar isn't empty, the lines starting with
+// it are removed too.
+func stripSpace(msg, commentChar string) string {
+	var b strings.Builder
+	blank := false
+	for _, line := range strings.Split(msg, "\n") {
+		if commentChar != "" && strings.HasPrefix(line, commentChar) {
+			continue
+		}
+
+		line = strings.TrimRight(line, " \t\r\v\f")
+		if line == "" {
+			blank = b.Len() != 0
+			continue
+		}
+
+		if blank {
+			b.WriteString("\n")
+			blank = false
+		}
+
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// cutScissors returns the message up to the scissors line, if any.
+func cutScissors(msg, commentChar string) string {
+	line := commentChar + scissorsLine + "\n"
+	if strings.HasPrefix(msg, line) {
+		return ""
+	}
+
+	if i := strings.Index(msg, "\n"+line); i != -1 {
+		return msg[:i+1]
+	}
+
+	return msg
+}
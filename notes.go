@@ -0,0 +1,972 @@
+package git
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goabstract/go-git/v5/config"
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/filemode"
+	"github.com/goabstract/go-git/v5/plumbing/object"
+	"github.com/goabstract/go-git/v5/plumbing/storer"
+)
+
+var (
+	// ErrNoteNotFound is returned when an object has no note in the notes
+	// reference.
+	ErrNoteNotFound = errors.New("note not found")
+	// ErrNoteExists is returned adding or copying a note to an object that
+	// already has one, without forcing it.
+	ErrNoteExists = errors.New("note already exists")
+	// ErrNotesMergeConflict is returned merging notes references when both
+	// sides changed the note of the same object and the merge strategy is
+	// manual.
+	ErrNotesMergeConflict = errors.New("conflicting notes, a merge strategy is required")
+	// ErrMissingNotesRef is returned merging notes without the reference to
+	// merge from.
+	ErrMissingNotesRef = errors.New("notes reference to merge from is required")
+)
+
+const (
+	// DefaultNotesRef is the notes reference used when core.notesRef is not
+	// set.
+	DefaultNotesRef plumbing.ReferenceName = "refs/notes/commits"
+
+	notesSection            = "notes"
+	notesRewriteSubsection  = "rewrite"
+	notesRefKey             = "notesRef"
+	notesDisplayRefKey      = "displayRef"
+	notesRewriteRefKey      = "rewriteRef"
+	notesRewriteModeKey     = "rewriteMode"
+	notesMergeStrategyKey   = "mergeStrategy"
+	notesHashFanoutDivision = 256
+)
+
+// Note returns the note attached to the given object in the notes reference,
+// or in the default notes reference if empty, as `git notes show`. Notes in
+// fanout trees, such as 'ab/cdef...', are found as well.
+func (r *Repository) Note(ref plumbing.ReferenceName, h plumbing.Hash) (*object.Note, error) {
+	if ref == "" {
+		var err error
+		if ref, err = r.defaultNotesRef(); err != nil {
+			return nil, err
+		}
+	}
+
+	_, commit, err := r.notesCommit(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if commit == nil {
+		return nil, ErrNoteNotFound
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	blob, err := r.lookupNote(tree, h.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return r.note(ref, h, blob)
+}
+
+// ListNotes returns all the notes of the notes reference, or of the default
+// notes reference if empty, sorted by annotated object, as `git notes list`.
+func (r *Repository) ListNotes(ref plumbing.ReferenceName) ([]*object.Note, error) {
+	if ref == "" {
+		var err error
+		if ref, err = r.defaultNotesRef(); err != nil {
+			return nil, err
+		}
+	}
+
+	_, commit, err := r.notesCommit(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	nt, err := r.readNotesTree(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []*object.Note
+	for obj, blob := range nt.notes {
+		n, err := r.note(ref, obj, blob)
+		if err != nil {
+			return nil, err
+		}
+
+		notes = append(notes, n)
+	}
+
+	sort.Slice(notes, func(i, j int) bool {
+		return notes[i].Object.String() < notes[j].Object.String()
+	})
+
+	return notes, nil
+}
+
+// DisplayNotes returns the notes attached to the given object that `git log`
+// shows: the ones in core.notesRef, or DefaultNotesRef, followed by the ones
+// in the references matching notes.displayRef.
+func (r *Repository) DisplayNotes(h plumbing.Hash) ([]*object.Note, error) {
+	refs, err := r.displayNotesRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []*object.Note
+	for _, ref := range refs {
+		n, err := r.Note(ref, h)
+		if err == ErrNoteNotFound {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		notes = append(notes, n)
+	}
+
+	return notes, nil
+}
+
+// AddNote attaches a note with the given message to an object, committing the
+// change on the notes reference, as `git notes add`. If the object already
+// has a note ErrNoteExists is returned, unless the Force option is set. An
+// empty message removes the note.
+func (r *Repository) AddNote(h plumbing.Hash, msg string, o *NoteOptions) error {
+	if err := o.Validate(r); err != nil {
+		return err
+	}
+
+	if err := r.checkNoteObject(h); err != nil {
+		return err
+	}
+
+	return r.editNotes(o.Ref, o.Author, o.Committer, "Notes added by 'git notes add'",
+		func(nt *notesTree) error {
+			if _, ok := nt.notes[h]; ok && !o.Force {
+				return ErrNoteExists
+			}
+
+			return r.setNote(nt, h, msg)
+		})
+}
+
+// AppendNote appends the given message to the note of an object, separated
+// by a blank line, or adds a new note if it has none, as `git notes append`.
+func (r *Repository) AppendNote(h plumbing.Hash, msg string, o *NoteOptions) error {
+	if err := o.Validate(r); err != nil {
+		return err
+	}
+
+	if err := r.checkNoteObject(h); err != nil {
+		return err
+	}
+
+	return r.editNotes(o.Ref, o.Author, o.Committer, "Notes added by 'git notes append'",
+		func(nt *notesTree) error {
+			if strings.TrimSpace(msg) == "" {
+				return nil
+			}
+
+			if blob, ok := nt.notes[h]; ok {
+				current, err := r.noteMessage(blob)
+				if err != nil {
+					return err
+				}
+
+				msg = current + "\n" + msg
+			}
+
+			return r.setNote(nt, h, msg)
+		})
+}
+
+// RemoveNote removes the note attached to an object, as `git notes remove`.
+// ErrNoteNotFound is returned if the object has no note.
+func (r *Repository) RemoveNote(h plumbing.Hash, o *NoteOptions) error {
+	if err := o.Validate(r); err != nil {
+		return err
+	}
+
+	return r.editNotes(o.Ref, o.Author, o.Committer, "Notes removed by 'git notes remove'",
+		func(nt *notesTree) error {
+			if _, ok := nt.notes[h]; !ok {
+				return ErrNoteNotFound
+			}
+
+			delete(nt.notes, h)
+			return nil
+		})
+}
+
+// CopyNote copies the note attached to an object to another one, as `git
+// notes copy`. If the target object already has a note ErrNoteExists is
+// returned, unless the Force option is set.
+func (r *Repository) CopyNote(from, to plumbing.Hash, o *NoteOptions) error {
+	if err := o.Validate(r); err != nil {
+		return err
+	}
+
+	if err := r.checkNoteObject(to); err != nil {
+		return err
+	}
+
+	return r.editNotes(o.Ref, o.Author, o.Committer, "Notes added by 'git notes copy'",
+		func(nt *notesTree) error {
+			blob, ok := nt.notes[from]
+			if !ok {
+				return ErrNoteNotFound
+			}
+
+			if _, ok := nt.notes[to]; ok && !o.Force {
+				return ErrNoteExists
+			}
+
+			nt.notes[to] = blob
+			return nil
+		})
+}
+
+// RewriteNotes copies the notes of rewritten objects, given as a map from the
+// old object to the new one, as git does after amending or rebasing commits.
+// The notes.rewrite.<command>, notes.rewriteRef and notes.rewriteMode
+// configs are honoured; as in git, no note is copied when no notes reference
+// is given, neither in the options nor in the config.
+func (r *Repository) RewriteNotes(rewritten map[plumbing.Hash]plumbing.Hash, o *RewriteNotesOptions) error {
+	if err := o.Validate(r); err != nil {
+		return err
+	}
+
+	if len(rewritten) == 0 {
+		return nil
+	}
+
+	if o.Command != "" {
+		enabled, err := r.notesRewriteEnabled(o.Command)
+		if err != nil || !enabled {
+			return err
+		}
+	}
+
+	for _, ref := range o.Refs {
+		err := r.editNotes(ref, o.Author, o.Committer, "Notes added by 'git notes copy'",
+			func(nt *notesTree) error {
+				return r.rewriteNotes(nt, rewritten, o.Mode)
+			})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) rewriteNotes(nt *notesTree, rewritten map[plumbing.Hash]plumbing.Hash,
+	mode NotesRewriteMode) error {
+
+	for from, to := range rewritten {
+		blob, ok := nt.notes[from]
+		if !ok {
+			continue
+		}
+
+		current, ok := nt.notes[to]
+		if !ok || mode == NotesRewriteOverwrite {
+			nt.notes[to] = blob
+			continue
+		}
+
+		if mode == NotesRewriteIgnore {
+			continue
+		}
+
+		ours, err := r.noteMessage(current)
+		if err != nil {
+			return err
+		}
+
+		theirs, err := r.noteMessage(blob)
+		if err != nil {
+			return err
+		}
+
+		msg := ours + "\n" + theirs
+		if mode == NotesRewriteCatSortUniq {
+			msg = catSortUniqNotes(ours, theirs)
+		}
+
+		if err := r.setNote(nt, to, msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MergeNotes merges the notes reference given in the From option into the
+// local one, as `git notes merge`. The notes changed on both sides are
+// resolved with the merge strategy, or ErrNotesMergeConflict is returned if
+// it is manual. NoErrAlreadyUpToDate is returned if there is nothing to merge.
+func (r *Repository) MergeNotes(o *MergeNotesOptions) error {
+	if err := o.Validate(r); err != nil {
+		return err
+	}
+
+	localRef, local, err := r.notesCommit(o.Ref)
+	if err != nil {
+		return err
+	}
+
+	remoteRef, remote, err := r.notesCommit(o.From)
+	if err != nil {
+		return err
+	}
+
+	if remote == nil {
+		return plumbing.ErrReferenceNotFound
+	}
+
+	msg := fmt.Sprintf("Merged notes from %s into %s", o.From, o.Ref)
+	if local == nil {
+		ref := plumbing.NewHashReference(o.Ref, remote.Hash)
+		return updateReference(r.Storer, nil, ref, nil, o.Committer, "notes: "+msg)
+	}
+
+	if local.Hash == remote.Hash {
+		return NoErrAlreadyUpToDate
+	}
+
+	if ok, err := remote.IsAncestor(local); err != nil || ok {
+		if err == nil {
+			err = NoErrAlreadyUpToDate
+		}
+
+		return err
+	}
+
+	if ok, err := local.IsAncestor(remote); err != nil || ok {
+		if err != nil {
+			return err
+		}
+
+		ref := plumbing.NewHashReference(o.Ref, remoteRef.Hash())
+		return updateReference(r.Storer, nil, ref, localRef, o.Committer, "notes: Fast-forward")
+	}
+
+	bases, err := local.MergeBase(remote)
+	if err != nil {
+		return err
+	}
+
+	var base *object.Commit
+	if len(bases) > 0 {
+		base = bases[0]
+	}
+
+	trees := make([]*notesTree, 3)
+	for i, c := range []*object.Commit{base, local, remote} {
+		if trees[i], err = r.readNotesTree(c); err != nil {
+			return err
+		}
+	}
+
+	merged, err := r.mergeNotesTrees(trees[0], trees[1], trees[2], o.Strategy)
+	if err != nil {
+		return err
+	}
+
+	tree, err := r.writeNotesTree(merged)
+	if err != nil {
+		return err
+	}
+
+	return r.commitNotes(o.Ref, localRef, []plumbing.Hash{local.Hash, remote.Hash},
+		tree, o.Author, o.Committer, msg)
+}
+
+func (r *Repository) mergeNotesTrees(base, local, remote *notesTree,
+	strategy NotesMergeStrategy) (*notesTree, error) {
+
+	objects := make(map[plumbing.Hash]bool)
+	for h := range local.notes {
+		objects[h] = true
+	}
+
+	for h := range remote.notes {
+		objects[h] = true
+	}
+
+	for h := range objects {
+		b, l, rm := base.notes[h], local.notes[h], remote.notes[h]
+		switch {
+		case l == rm, rm == b:
+			continue
+		case l == b:
+			local.set(h, rm)
+			continue
+		}
+
+		switch strategy {
+		case NotesMergeOurs:
+		case NotesMergeTheirs:
+			local.set(h, rm)
+		case NotesMergeUnion, NotesMergeCatSortUniq:
+			if l.IsZero() || rm.IsZero() {
+				if l.IsZero() {
+					local.set(h, rm)
+				}
+
+				continue
+			}
+
+			ours, err := r.noteMessage(l)
+			if err != nil {
+				return nil, err
+			}
+
+			theirs, err := r.noteMessage(rm)
+			if err != nil {
+				return nil, err
+			}
+
+			msg := ours + "\n" + theirs
+			if strategy == NotesMergeCatSortUniq {
+				msg = catSortUniqNotes(ours, theirs)
+			}
+
+			if err := r.setNote(local, h, msg); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, ErrNotesMergeConflict
+		}
+	}
+
+	return local, nil
+}
+
+// catSortUniqNotes combines two notes in one with their lines sorted and
+// without duplicated nor empty lines.
+func catSortUniqNotes(notes ...string) string {
+	seen := make(map[string]bool)
+	var lines []string
+	for _, n := range notes {
+		for _, line := range strings.Split(n, "\n") {
+			if line == "" || seen[line] {
+				continue
+			}
+
+			seen[line] = true
+			lines = append(lines, line)
+		}
+	}
+
+	sort.Strings(lines)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// notesTree is the content of the tree of a notes commit: the blobs of the
+// notes by annotated object, and any other entry by path.
+type notesTree struct {
+	notes  map[plumbing.Hash]plumbing.Hash
+	others map[string]object.TreeEntry
+}
+
+func (nt *notesTree) set(obj, blob plumbing.Hash) {
+	if blob.IsZero() {
+		delete(nt.notes, obj)
+		return
+	}
+
+	nt.notes[obj] = blob
+}
+
+func (r *Repository) readNotesTree(commit *object.Commit) (*notesTree, error) {
+	nt := &notesTree{
+		notes:  make(map[plumbing.Hash]plumbing.Hash),
+		others: make(map[string]object.TreeEntry),
+	}
+
+	if commit == nil {
+		return nt, nil
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	return nt, r.readNotesSubtree(nt, tree, "", "")
+}
+
+func (r *Repository) readNotesSubtree(nt *notesTree, t *object.Tree, dir, prefix string) error {
+	for _, e := range t.Entries {
+		name := prefix + e.Name
+		switch {
+		case e.Mode.IsFile() && plumbing.IsHash(name):
+			nt.notes[plumbing.NewHash(name)] = e.Hash
+		case e.Mode == filemode.Dir && len(e.Name) == 2 && len(name) < 40 && isHexString(e.Name):
+			sub, err := r.TreeObject(e.Hash)
+			if err != nil {
+				return err
+			}
+
+			if err := r.readNotesSubtree(nt, sub, dir+e.Name+"/", name); err != nil {
+				return err
+			}
+		default:
+			nt.others[dir+e.Name] = e
+		}
+	}
+
+	return nil
+}
+
+// lookupNote finds the blob of the note of the object with the given
+// hexadecimal hash, following the fanout subtrees.
+func (r *Repository) lookupNote(t *object.Tree, name string) (plumbing.Hash, error) {
+	for {
+		var next *object.TreeEntry
+		for i, e := range t.Entries {
+			if e.Name == name && e.Mode.IsFile() {
+				return e.Hash, nil
+			}
+
+			if len(name) > 2 && e.Name == name[:2] && e.Mode == filemode.Dir {
+				next = &t.Entries[i]
+			}
+		}
+
+		if next == nil {
+			return plumbing.ZeroHash, ErrNoteNotFound
+		}
+
+		var err error
+		if t, err = r.TreeObject(next.Hash); err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		name = name[2:]
+	}
+}
+
+// writeNotesTree stores the tree of the given notes, with one level of
+// fanout for every 256 notes, as git does.
+func (r *Repository) writeNotesTree(nt *notesTree) (plumbing.Hash, error) {
+	fanout := 0
+	for n := len(nt.notes); n > notesHashFanoutDivision-1; n /= notesHashFanoutDivision {
+		fanout++
+	}
+
+	files := make(map[string]object.TreeEntry, len(nt.notes)+len(nt.others))
+	for p, e := range nt.others {
+		files[p] = e
+	}
+
+	for obj, blob := range nt.notes {
+		name := obj.String()
+		var p string
+		for i := 0; i < fanout; i++ {
+			p += name[i*2:i*2+2] + "/"
+		}
+
+		files[p+name[fanout*2:]] = object.TreeEntry{Mode: filemode.Regular, Hash: blob}
+	}
+
+	return writeNotesSubtree(r.Storer, files)
+}
+
+func writeNotesSubtree(s storer.EncodedObjectStorer, files map[string]object.TreeEntry) (plumbing.Hash, error) {
+	t := &object.Tree{}
+	dirs := make(map[string]map[string]object.TreeEntry)
+	for p, e := range files {
+		i := strings.IndexByte(p, '/')
+		if i == -1 {
+			e.Name = p
+			t.Entries = append(t.Entries, e)
+			continue
+		}
+
+		name := p[:i]
+		if dirs[name] == nil {
+			dirs[name] = make(map[string]object.TreeEntry)
+		}
+
+		dirs[name][p[i+1:]] = e
+	}
+
+	for name, sub := range dirs {
+		h, err := writeNotesSubtree(s, sub)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		t.Entries = append(t.Entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: h})
+	}
+
+	sort.Sort(sortableEntries(t.Entries))
+
+	o := s.NewEncodedObject()
+	if err := t.Encode(o); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return s.SetEncodedObject(o)
+}
+
+// editNotes applies fn to the notes of the given reference and commits the
+// result on it with the given message.
+func (r *Repository) editNotes(ref plumbing.ReferenceName, author, committer *object.Signature,
+	msg string, fn func(*notesTree) error) error {
+
+	old, commit, err := r.notesCommit(ref)
+	if err != nil {
+		return err
+	}
+
+	nt, err := r.readNotesTree(commit)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(nt); err != nil {
+		return err
+	}
+
+	tree, err := r.writeNotesTree(nt)
+	if err != nil {
+		return err
+	}
+
+	if commit == nil && len(nt.notes) == 0 && len(nt.others) == 0 {
+		return nil
+	}
+
+	var parents []plumbing.Hash
+	if commit != nil {
+		if commit.TreeHash == tree {
+			return nil
+		}
+
+		parents = append(parents, commit.Hash)
+	}
+
+	return r.commitNotes(ref, old, parents, tree, author, committer, msg)
+}
+
+func (r *Repository) commitNotes(ref plumbing.ReferenceName, old *plumbing.Reference,
+	parents []plumbing.Hash, tree plumbing.Hash, author, committer *object.Signature, msg string) error {
+
+	commit := &object.Commit{
+		Author:       *author,
+		Committer:    *committer,
+		Message:      msg + "\n",
+		TreeHash:     tree,
+		ParentHashes: parents,
+	}
+
+	obj := r.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return err
+	}
+
+	h, err := r.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return err
+	}
+
+	return updateReference(r.Storer, nil, plumbing.NewHashReference(ref, h), old, committer, "notes: "+msg)
+}
+
+// notesCommit returns the reference and the commit at the tip of the notes
+// reference, both nil if the reference doesn't exist.
+func (r *Repository) notesCommit(name plumbing.ReferenceName) (*plumbing.Reference, *object.Commit, error) {
+	ref, err := r.Storer.Reference(name)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil, nil
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	commit, err := r.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ref, commit, nil
+}
+
+func (r *Repository) setNote(nt *notesTree, h plumbing.Hash, msg string) error {
+	if strings.TrimSpace(msg) == "" {
+		delete(nt.notes, h)
+		return nil
+	}
+
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+
+	obj := r.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write([]byte(msg)); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	blob, err := r.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return err
+	}
+
+	nt.notes[h] = blob
+	return nil
+}
+
+func (r *Repository) note(ref plumbing.ReferenceName, obj, blob plumbing.Hash) (*object.Note, error) {
+	msg, err := r.noteMessage(blob)
+	if err != nil {
+		return nil, err
+	}
+
+	return &object.Note{Ref: ref, Object: obj, Hash: blob, Message: msg}, nil
+}
+
+func (r *Repository) noteMessage(blob plumbing.Hash) (string, error) {
+	b, err := r.BlobObject(blob)
+	if err != nil {
+		return "", err
+	}
+
+	rd, err := b.Reader()
+	if err != nil {
+		return "", err
+	}
+
+	defer rd.Close()
+	content, err := ioutil.ReadAll(rd)
+	return string(content), err
+}
+
+func (r *Repository) checkNoteObject(h plumbing.Hash) error {
+	_, err := r.Storer.EncodedObject(plumbing.AnyObject, h)
+	return err
+}
+
+// defaultNotesRef returns the notes reference from core.notesRef, or
+// DefaultNotesRef if not set.
+func (r *Repository) defaultNotesRef() (plumbing.ReferenceName, error) {
+	cfg, err := r.MergedConfig()
+	if err != nil {
+		return "", err
+	}
+
+	return notesRefFromConfig(cfg), nil
+}
+
+func notesRefFromConfig(cfg *config.Config) plumbing.ReferenceName {
+	if ref := cfg.Raw.Section("core").Option(notesRefKey); ref != "" {
+		return plumbing.ReferenceName(ref)
+	}
+
+	return DefaultNotesRef
+}
+
+// displayNotesRefs returns the notes references shown by `git log`, the
+// default one followed by the ones matching notes.displayRef.
+func (r *Repository) displayNotesRefs() ([]plumbing.ReferenceName, error) {
+	cfg, err := r.MergedConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	def := notesRefFromConfig(cfg)
+	patterns := cfg.Raw.Section(notesSection).Options.GetAll(notesDisplayRefKey)
+	refs, err := r.notesRefsMatching(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []plumbing.ReferenceName{def}
+	for _, ref := range refs {
+		if ref != def {
+			result = append(result, ref)
+		}
+	}
+
+	return result, nil
+}
+
+// notesRefsMatching returns the existing notes references matching any of
+// the given glob patterns, sorted by name.
+func (r *Repository) notesRefsMatching(patterns []string) ([]plumbing.ReferenceName, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	iter, err := r.Notes()
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []plumbing.ReferenceName
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, ref.Name().String()); ok {
+				refs = append(refs, ref.Name())
+				break
+			}
+		}
+
+		return nil
+	})
+
+	sort.Slice(refs, func(i, j int) bool { return refs[i] < refs[j] })
+	return refs, err
+}
+
+func isHexString(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+type commitNotesIter struct {
+	object.CommitIter
+	r     *Repository
+	refs  []plumbing.ReferenceName
+	trees []*notesTree
+}
+
+// newCommitNotesIter returns an iterator filling the notes of the commits
+// from the given notes references.
+func newCommitNotesIter(r *Repository, iter object.CommitIter,
+	refs []plumbing.ReferenceName) (object.CommitIter, error) {
+
+	i := &commitNotesIter{CommitIter: iter, r: r, refs: refs}
+	for _, ref := range refs {
+		_, commit, err := r.notesCommit(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		nt, err := r.readNotesTree(commit)
+		if err != nil {
+			return nil, err
+		}
+
+		i.trees = append(i.trees, nt)
+	}
+
+	return i, nil
+}
+
+func (i *commitNotesIter) Next() (*object.Commit, error) {
+	c, err := i.CommitIter.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	return i.withNotes(c)
+}
+
+func (i *commitNotesIter) ForEach(cb func(*object.Commit) error) error {
+	return i.CommitIter.ForEach(func(c *object.Commit) error {
+		c, err := i.withNotes(c)
+		if err != nil {
+			return err
+		}
+
+		return cb(c)
+	})
+}
+
+func (i *commitNotesIter) withNotes(c *object.Commit) (*object.Commit, error) {
+	n := *c
+	n.Notes = nil
+	for j, nt := range i.trees {
+		blob, ok := nt.notes[c.Hash]
+		if !ok {
+			continue
+		}
+
+		note, err := i.r.note(i.refs[j], c.Hash, blob)
+		if err != nil {
+			return nil, err
+		}
+
+		n.Notes = append(n.Notes, note)
+	}
+
+	return &n, nil
+}
+
+// notesRewriteEnabled reports whether the notes are copied when rewriting
+// objects with the given command, following notes.rewrite.<command>.
+func (r *Repository) notesRewriteEnabled(command string) (bool, error) {
+	cfg, err := r.MergedConfig()
+	if err != nil {
+		return false, err
+	}
+
+	section := cfg.Raw.Section(notesSection)
+	if !section.HasSubsection(notesRewriteSubsection) {
+		return true, nil
+	}
+
+	value := section.Subsection(notesRewriteSubsection).Option(command)
+	return !strings.EqualFold(value, "false"), nil
+}
+
+// notesSignatures returns the author and committer of a notes commit, taking
+// the author from the user in the config if not given.
+func notesSignatures(r *Repository, author, committer *object.Signature) (
+	*object.Signature, *object.Signature, error) {
+
+	if author == nil {
+		cfg, err := r.MergedConfig()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		author, err = identitySignature(cfg, authorEnvPrefix, false, time.Now())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if author == nil {
+			return nil, nil, ErrMissingAuthor
+		}
+	}
+
+	if committer == nil {
+		committer = author
+	}
+
+	return author, committer, nil
+}
@@ -0,0 +1,360 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/filemode"
+	"github.com/goabstract/go-git/v5/plumbing/object"
+
+	fixtures "github.com/go-git/go-git-fixtures/v4"
+	. "gopkg.in/check.v1"
+)
+
+type NotesSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&NotesSuite{})
+
+var (
+	notesHead   = plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	notesBranch = plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	notesParent = plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+)
+
+func (s *NotesSuite) options(ref plumbing.ReferenceName) *NoteOptions {
+	return &NoteOptions{Ref: ref, Author: defaultSignature()}
+}
+
+func (s *NotesSuite) TestAddNote(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	_, err := r.Note("", notesHead)
+	c.Assert(err, Equals, ErrNoteNotFound)
+
+	err = r.AddNote(notesHead, "build passed", s.options(""))
+	c.Assert(err, IsNil)
+
+	n, err := r.Note("", notesHead)
+	c.Assert(err, IsNil)
+	c.Assert(n.Ref, Equals, DefaultNotesRef)
+	c.Assert(n.Object, Equals, notesHead)
+	c.Assert(n.Message, Equals, "build passed\n")
+
+	err = r.AddNote(notesHead, "build failed", s.options(""))
+	c.Assert(err, Equals, ErrNoteExists)
+
+	o := s.options("")
+	o.Force = true
+	err = r.AddNote(notesHead, "build failed", o)
+	c.Assert(err, IsNil)
+
+	n, err = r.Note(DefaultNotesRef, notesHead)
+	c.Assert(err, IsNil)
+	c.Assert(n.Message, Equals, "build failed\n")
+
+	ref, err := r.Reference(DefaultNotesRef, false)
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(ref.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "Notes added by 'git notes add'\n")
+	c.Assert(commit.ParentHashes, HasLen, 1)
+	c.Assert(commit.Author.Name, Equals, "foo")
+
+	entries, err := r.Reflog(DefaultNotesRef)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Assert(entries[0].Message, Equals, "notes: Notes added by 'git notes add'")
+
+	err = r.AddNote(plumbing.NewHash("0000000000000000000000000000000000000001"), "foo", s.options(""))
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+}
+
+func (s *NotesSuite) TestAddNoteAuthorFromConfig(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	err := r.AddNote(notesHead, "foo", &NoteOptions{})
+	c.Assert(err, Equals, ErrMissingAuthor)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("user").SetOption("name", "John Doe")
+	cfg.Raw.Section("user").SetOption("email", "john@example.com")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	err = r.AddNote(notesHead, "foo", &NoteOptions{})
+	c.Assert(err, IsNil)
+
+	ref, err := r.Reference(DefaultNotesRef, false)
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(ref.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(commit.Author.Name, Equals, "John Doe")
+	c.Assert(commit.Committer.Email, Equals, "john@example.com")
+}
+
+func (s *NotesSuite) TestAppendAndRemoveNote(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	ref := plumbing.ReferenceName("refs/notes/ci")
+	c.Assert(r.AppendNote(notesHead, "build passed\n", s.options(ref)), IsNil)
+	c.Assert(r.AppendNote(notesHead, "coverage: 80%", s.options(ref)), IsNil)
+
+	n, err := r.Note(ref, notesHead)
+	c.Assert(err, IsNil)
+	c.Assert(n.Message, Equals, "build passed\n\ncoverage: 80%\n")
+
+	_, err = r.Note("", notesHead)
+	c.Assert(err, Equals, ErrNoteNotFound)
+
+	c.Assert(r.RemoveNote(notesHead, s.options(ref)), IsNil)
+	_, err = r.Note(ref, notesHead)
+	c.Assert(err, Equals, ErrNoteNotFound)
+
+	c.Assert(r.RemoveNote(notesHead, s.options(ref)), Equals, ErrNoteNotFound)
+}
+
+func (s *NotesSuite) TestCopyNote(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	c.Assert(r.AddNote(notesHead, "foo", s.options("")), IsNil)
+	c.Assert(r.AddNote(notesBranch, "bar", s.options("")), IsNil)
+
+	c.Assert(r.CopyNote(notesParent, notesHead, s.options("")), Equals, ErrNoteNotFound)
+	c.Assert(r.CopyNote(notesHead, notesBranch, s.options("")), Equals, ErrNoteExists)
+	c.Assert(r.CopyNote(notesHead, notesParent, s.options("")), IsNil)
+
+	notes, err := r.ListNotes("")
+	c.Assert(err, IsNil)
+	c.Assert(notes, HasLen, 3)
+	c.Assert(notes[0].Object, Equals, notesHead)
+	c.Assert(notes[1].Object, Equals, notesParent)
+	c.Assert(notes[1].Message, Equals, "foo\n")
+	c.Assert(notes[2].Object, Equals, notesBranch)
+}
+
+func (s *NotesSuite) TestRewriteNotes(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	c.Assert(r.AddNote(notesHead, "b\na", s.options("")), IsNil)
+	c.Assert(r.AddNote(notesBranch, "a\nc", s.options("")), IsNil)
+
+	rewritten := map[plumbing.Hash]plumbing.Hash{notesHead: notesBranch}
+	o := &RewriteNotesOptions{Command: "amend", Author: defaultSignature()}
+	c.Assert(r.RewriteNotes(rewritten, o), IsNil)
+
+	n, err := r.Note("", notesBranch)
+	c.Assert(err, IsNil)
+	c.Assert(n.Message, Equals, "a\nc\n")
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section(notesSection).AddOption(notesRewriteRefKey, "refs/notes/*")
+	cfg.Raw.Section(notesSection).SetOption(notesRewriteModeKey, "cat_sort_uniq")
+	cfg.Raw.Section(notesSection).Subsection(notesRewriteSubsection).SetOption("rebase", "false")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	o = &RewriteNotesOptions{Command: "rebase", Author: defaultSignature()}
+	c.Assert(r.RewriteNotes(rewritten, o), IsNil)
+
+	n, err = r.Note("", notesBranch)
+	c.Assert(err, IsNil)
+	c.Assert(n.Message, Equals, "a\nc\n")
+
+	o = &RewriteNotesOptions{Command: "amend", Author: defaultSignature()}
+	c.Assert(r.RewriteNotes(rewritten, o), IsNil)
+
+	n, err = r.Note("", notesBranch)
+	c.Assert(err, IsNil)
+	c.Assert(n.Message, Equals, "a\nb\nc\n")
+
+	o = &RewriteNotesOptions{
+		Refs:   []plumbing.ReferenceName{DefaultNotesRef},
+		Mode:   NotesRewriteOverwrite,
+		Author: defaultSignature(),
+	}
+	c.Assert(r.RewriteNotes(map[plumbing.Hash]plumbing.Hash{notesHead: notesParent}, o), IsNil)
+
+	n, err = r.Note("", notesParent)
+	c.Assert(err, IsNil)
+	c.Assert(n.Message, Equals, "b\na\n")
+}
+
+func (s *NotesSuite) TestNoteFanout(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+	c.Assert(r.AddNote(notesHead, "foo", s.options("")), IsNil)
+
+	n, err := r.Note("", notesHead)
+	c.Assert(err, IsNil)
+
+	name := notesHead.String()
+	tree, err := writeNotesSubtree(r.Storer, map[string]object.TreeEntry{
+		name[:2] + "/" + name[2:4] + "/" + name[4:]: {Mode: filemode.Regular, Hash: n.Hash},
+		"README": {Mode: filemode.Regular, Hash: n.Hash},
+	})
+	c.Assert(err, IsNil)
+
+	ref := plumbing.ReferenceName("refs/notes/fanout")
+	err = r.commitNotes(ref, nil, nil, tree, defaultSignature(), defaultSignature(), "fanout")
+	c.Assert(err, IsNil)
+
+	n, err = r.Note(ref, notesHead)
+	c.Assert(err, IsNil)
+	c.Assert(n.Message, Equals, "foo\n")
+
+	c.Assert(r.AddNote(notesBranch, "bar", s.options(ref)), IsNil)
+	notes, err := r.ListNotes(ref)
+	c.Assert(err, IsNil)
+	c.Assert(notes, HasLen, 2)
+
+	commit, err := r.Reference(ref, false)
+	c.Assert(err, IsNil)
+	_, c2, err := r.notesCommit(commit.Name())
+	c.Assert(err, IsNil)
+	t, err := c2.Tree()
+	c.Assert(err, IsNil)
+
+	_, err = t.File("README")
+	c.Assert(err, IsNil)
+	_, err = t.File(name)
+	c.Assert(err, IsNil)
+}
+
+func (s *NotesSuite) TestWriteNotesTreeFanout(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	nt, err := r.readNotesTree(nil)
+	c.Assert(err, IsNil)
+	for i := 0; i < 256; i++ {
+		nt.notes[plumbing.NewHash(fmt.Sprintf("%040x", i))] = notesHead
+	}
+
+	h, err := r.writeNotesTree(nt)
+	c.Assert(err, IsNil)
+
+	tree, err := r.TreeObject(h)
+	c.Assert(err, IsNil)
+	c.Assert(tree.Entries, HasLen, 1)
+	c.Assert(tree.Entries[0].Name, Equals, "00")
+
+	blob, err := r.lookupNote(tree, fmt.Sprintf("%040x", 255))
+	c.Assert(err, IsNil)
+	c.Assert(blob, Equals, notesHead)
+}
+
+func (s *NotesSuite) TestDisplayNotesAndLog(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	c.Assert(r.AddNote(notesHead, "foo", s.options("")), IsNil)
+	c.Assert(r.AddNote(notesHead, "bar", s.options("refs/notes/ci")), IsNil)
+	c.Assert(r.AddNote(notesParent, "qux", s.options("refs/notes/ci")), IsNil)
+
+	notes, err := r.DisplayNotes(notesHead)
+	c.Assert(err, IsNil)
+	c.Assert(notes, HasLen, 1)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section(notesSection).AddOption(notesDisplayRefKey, "refs/notes/*")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	notes, err = r.DisplayNotes(notesHead)
+	c.Assert(err, IsNil)
+	c.Assert(notes, HasLen, 2)
+	c.Assert(notes[0].Ref, Equals, DefaultNotesRef)
+	c.Assert(notes[1].Ref, Equals, plumbing.ReferenceName("refs/notes/ci"))
+
+	iter, err := r.Log(&LogOptions{From: notesHead, ShowNotes: true})
+	c.Assert(err, IsNil)
+
+	commit, err := iter.Next()
+	c.Assert(err, IsNil)
+	c.Assert(commit.Notes, HasLen, 2)
+	c.Assert(strings.HasSuffix(commit.String(), "Notes:\n    foo\n\nNotes (ci):\n    bar\n\n"), Equals, true)
+
+	iter, err = r.Log(&LogOptions{
+		From:      notesHead,
+		ShowNotes: true,
+		NotesRefs: []plumbing.ReferenceName{"refs/notes/ci"},
+	})
+	c.Assert(err, IsNil)
+
+	var messages []string
+	err = iter.ForEach(func(commit *object.Commit) error {
+		for _, n := range commit.Notes {
+			messages = append(messages, n.Message)
+		}
+
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(messages, DeepEquals, []string{"bar\n", "qux\n"})
+}
+
+func (s *NotesSuite) TestMergeNotes(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	remote := plumbing.ReferenceName("refs/notes/remote")
+	c.Assert(r.AddNote(notesHead, "base", s.options("")), IsNil)
+
+	err := r.MergeNotes(&MergeNotesOptions{From: remote, Author: defaultSignature()})
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	err = r.MergeNotes(&MergeNotesOptions{Ref: remote, From: DefaultNotesRef, Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	local, err := r.Reference(DefaultNotesRef, false)
+	c.Assert(err, IsNil)
+	ref, err := r.Reference(remote, false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, local.Hash())
+
+	c.Assert(r.AddNote(notesBranch, "remote only", s.options(remote)), IsNil)
+	err = r.MergeNotes(&MergeNotesOptions{From: remote, Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	n, err := r.Note("", notesBranch)
+	c.Assert(err, IsNil)
+	c.Assert(n.Message, Equals, "remote only\n")
+
+	err = r.MergeNotes(&MergeNotesOptions{From: remote, Author: defaultSignature()})
+	c.Assert(err, Equals, NoErrAlreadyUpToDate)
+
+	forced := s.options(remote)
+	forced.Force = true
+	c.Assert(r.AddNote(notesHead, "theirs", forced), IsNil)
+	c.Assert(r.AddNote(notesParent, "new theirs", s.options(remote)), IsNil)
+
+	forced = s.options("")
+	forced.Force = true
+	c.Assert(r.AddNote(notesHead, "ours", forced), IsNil)
+
+	err = r.MergeNotes(&MergeNotesOptions{From: remote, Author: defaultSignature()})
+	c.Assert(err, Equals, ErrNotesMergeConflict)
+
+	err = r.MergeNotes(&MergeNotesOptions{
+		From:     remote,
+		Strategy: NotesMergeUnion,
+		Author:   defaultSignature(),
+	})
+	c.Assert(err, IsNil)
+
+	n, err = r.Note("", notesHead)
+	c.Assert(err, IsNil)
+	c.Assert(n.Message, Equals, "ours\n\ntheirs\n")
+
+	n, err = r.Note("", notesParent)
+	c.Assert(err, IsNil)
+	c.Assert(n.Message, Equals, "new theirs\n")
+
+	ref, err = r.Reference(DefaultNotesRef, false)
+	c.Assert(err, IsNil)
+	commit, err := r.CommitObject(ref.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, HasLen, 2)
+	c.Assert(commit.Message, Equals, "Merged notes from refs/notes/remote into refs/notes/commits\n")
+}
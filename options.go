@@ -8,6 +8,7 @@ import (
 
 	"github.com/goabstract/go-git/v5/config"
 	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/format/mailmap"
 	"github.com/goabstract/go-git/v5/plumbing/object"
 	"github.com/goabstract/go-git/v5/plumbing/progress"
 	"github.com/goabstract/go-git/v5/plumbing/protocol/packp/sideband"
@@ -31,6 +32,11 @@ const (
 
 var (
 	ErrMissingURL = errors.New("URL field is required")
+	// ErrInvalidDepth is returned when a negative depth is given.
+	ErrInvalidDepth = errors.New("depth must be a positive number")
+	// ErrShallowOptionsConflict is returned when more than one of Depth,
+	// Deepen, Unshallow and ShallowSince or ShallowExclude are given.
+	ErrShallowOptionsConflict = errors.New("Depth, Deepen, Unshallow and ShallowSince or ShallowExclude are mutually exclusive")
 )
 
 // CloneOptions describes how a clone should be performed.
@@ -39,6 +45,9 @@ type CloneOptions struct {
 	URL string
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
+	// HTTP overrides the settings of the http section of the config for the
+	// HTTP and HTTPS remotes.
+	HTTP HTTPOptions
 	// Name of the remote to be added, by default `origin`.
 	RemoteName string
 	// Remote branch to clone.
@@ -49,6 +58,12 @@ type CloneOptions struct {
 	NoCheckout bool
 	// Limit fetching to the specified number of commits.
 	Depth int
+	// ShallowSince limits fetching to the commits more recent than the given
+	// time, as `git clone --shallow-since`.
+	ShallowSince time.Time
+	// ShallowExclude limits fetching to the commits not reachable from the
+	// given remote branches or tags, as `git clone --shallow-exclude`.
+	ShallowExclude []string
 	// RecurseSubmodules after the clone is created, initialize all submodules
 	// within, using their default settings. This option is ignored if the
 	// cloned repository does not have a worktree.
@@ -74,6 +89,14 @@ func (o *CloneOptions) Validate() error {
 		o.RemoteName = DefaultRemoteName
 	}
 
+	if o.Depth < 0 {
+		return ErrInvalidDepth
+	}
+
+	if o.Depth != 0 && (!o.ShallowSince.IsZero() || len(o.ShallowExclude) != 0) {
+		return ErrShallowOptionsConflict
+	}
+
 	if o.ReferenceName == "" {
 		o.ReferenceName = plumbing.HEAD
 	}
@@ -82,7 +105,7 @@ func (o *CloneOptions) Validate() error {
 		o.Tags = AllTags
 	}
 
-	return nil
+	return o.HTTP.Validate()
 }
 
 // PullOptions describes how a pull should be performed.
@@ -97,6 +120,9 @@ type PullOptions struct {
 	Depth int
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
+	// HTTP overrides the settings of the http section of the config for the
+	// HTTP and HTTPS remotes.
+	HTTP HTTPOptions
 	// RecurseSubmodules controls if new commits of all populated submodules
 	// should be fetched too.
 	RecurseSubmodules SubmoduleRescursivity
@@ -121,7 +147,7 @@ func (o *PullOptions) Validate() error {
 		o.ReferenceName = plumbing.HEAD
 	}
 
-	return nil
+	return o.HTTP.Validate()
 }
 
 type TagMode int
@@ -139,6 +165,19 @@ const (
 	NoTags
 )
 
+// PruneMode describes whether a fetch prunes, overriding the config.
+type PruneMode int
+
+const (
+	// PruneFromConfig follows the config, it is the default.
+	PruneFromConfig PruneMode = iota
+	// PruneEnabled prunes, whatever the config says, as `git fetch --prune`.
+	PruneEnabled
+	// PruneDisabled doesn't prune, whatever the config says, as
+	// `git fetch --no-prune`.
+	PruneDisabled
+)
+
 // FetchOptions describes how a fetch should be performed
 type FetchOptions struct {
 	// Name of the remote to fetch from. Defaults to origin.
@@ -149,8 +188,23 @@ type FetchOptions struct {
 	// Depth limit fetching to the specified number of commits from the tip of
 	// each remote branch history.
 	Depth int
+	// ShallowSince limits fetching to the commits more recent than the given
+	// time, as `git fetch --shallow-since`.
+	ShallowSince time.Time
+	// ShallowExclude limits fetching to the commits not reachable from the
+	// given remote branches or tags, as `git fetch --shallow-exclude`.
+	ShallowExclude []string
+	// Deepen deepens the history of a shallow repository by the given number
+	// of commits from its current shallow boundary, as `git fetch --deepen`.
+	Deepen int
+	// Unshallow fetches the complete history of a shallow repository, as
+	// `git fetch --unshallow`.
+	Unshallow bool
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
+	// HTTP overrides the settings of the http section of the config for the
+	// HTTP and HTTPS remotes.
+	HTTP HTTPOptions
 	// Progress is where the human readable information sent by the server is
 	// stored, if nil nothing is stored and the capability (if supported)
 	// no-progress, is sent to the server to avoid send this information.
@@ -163,6 +217,14 @@ type FetchOptions struct {
 	// Force allows the fetch to update a local branch even when the remote
 	// branch does not descend from it.
 	Force bool
+	// Prune deletes the local references matched by the refspecs that no
+	// longer exist in the remote repository, as `git fetch --prune`. By
+	// default, remote.<name>.prune and fetch.prune are honoured.
+	Prune PruneMode
+	// PruneTags also deletes, when pruning, the local tags that no longer
+	// exist in the remote repository, as `git fetch --prune-tags`. By
+	// default, remote.<name>.pruneTags and fetch.pruneTags are honoured.
+	PruneTags PruneMode
 }
 
 // Validate validates the fields and sets the default values.
@@ -181,7 +243,34 @@ func (o *FetchOptions) Validate() error {
 		}
 	}
 
-	return nil
+	if o.Depth < 0 || o.Deepen < 0 {
+		return ErrInvalidDepth
+	}
+
+	var shallowOptions int
+	for _, set := range []bool{
+		o.Depth != 0,
+		o.Deepen != 0,
+		o.Unshallow,
+		!o.ShallowSince.IsZero() || len(o.ShallowExclude) != 0,
+	} {
+		if set {
+			shallowOptions++
+		}
+	}
+
+	if shallowOptions > 1 {
+		return ErrShallowOptionsConflict
+	}
+
+	return o.HTTP.Validate()
+}
+
+// isShallow returns true if any of the options limiting the history fetched
+// is set.
+func (o *FetchOptions) isShallow() bool {
+	return o.Depth != 0 || o.Deepen != 0 || o.Unshallow ||
+		!o.ShallowSince.IsZero() || len(o.ShallowExclude) != 0
 }
 
 // PushOptions describes how a push should be performed.
@@ -193,6 +282,9 @@ type PushOptions struct {
 	RefSpecs []config.RefSpec
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
+	// HTTP overrides the settings of the http section of the config for the
+	// HTTP and HTTPS remotes.
+	HTTP HTTPOptions
 	// Progress is where the human readable information sent by the server is
 	// stored, if nil nothing is stored.
 	Progress sideband.Progress
@@ -219,7 +311,36 @@ func (o *PushOptions) Validate() error {
 		}
 	}
 
-	return nil
+	return o.HTTP.Validate()
+}
+
+// HTTPOptions describes the settings of the HTTP transport of an operation,
+// overriding the ones of the http section of the config when set.
+type HTTPOptions struct {
+	// InsecureSkipTLS skips the verification of the TLS certificate of the
+	// server, as http.sslVerify = false.
+	InsecureSkipTLS bool
+	// CABundle are the PEM encoded certificates of the certificate
+	// authorities trusted to verify the TLS certificate of the server,
+	// instead of the file of http.sslCAInfo.
+	CABundle []byte
+	// Proxy is the proxy the requests are sent through, instead of the one
+	// of http.proxy.
+	Proxy transport.ProxyOptions
+	// ExtraHeaders are headers, in the "Name: value" form, sent with every
+	// request besides the ones of http.extraHeader.
+	ExtraHeaders []string
+	// CookieFile is the file the cookies are read from, instead of the one
+	// of http.cookieFile.
+	CookieFile string
+	// UserAgent is the user agent of the requests, instead of the one of
+	// http.userAgent.
+	UserAgent string
+}
+
+// Validate validates the fields and sets the default values.
+func (o *HTTPOptions) Validate() error {
+	return o.Proxy.Validate()
 }
 
 // SubmoduleUpdateOptions describes how a submodule update should be performed.
@@ -240,6 +361,12 @@ type SubmoduleUpdateOptions struct {
 var (
 	ErrBranchHashExclusive  = errors.New("Branch and Hash are mutually exclusive")
 	ErrCreateRequiresBranch = errors.New("Branch is mandatory when Create is used")
+	// ErrStartPointHashExclusive is returned when both StartPoint and Hash
+	// are given.
+	ErrStartPointHashExclusive = errors.New("StartPoint and Hash are mutually exclusive")
+	// ErrStartPointRequiresCreate is returned when StartPoint is given
+	// without Create.
+	ErrStartPointRequiresCreate = errors.New("StartPoint can only be used with Create")
 )
 
 // CheckoutOptions describes how a checkout operation should be performed.
@@ -252,6 +379,14 @@ type CheckoutOptions struct {
 	Branch plumbing.ReferenceName
 	// Create a new branch named Branch and start it at Hash.
 	Create bool
+	// StartPoint is the reference the branch created by Create starts at,
+	// instead of Hash. If it is a remote-tracking branch, the new branch
+	// tracks it, following branch.autoSetupMerge, as
+	// `git checkout -b <branch> <start-point>` does.
+	StartPoint plumbing.ReferenceName
+	// NoTrack doesn't set the upstream of the branch created from
+	// StartPoint, as `git checkout --no-track`.
+	NoTrack bool
 	// Force, if true when switching branches, proceed even if the index or the
 	// working tree differs from HEAD. This is used to throw away local changes
 	Force bool
@@ -272,6 +407,14 @@ func (o *CheckoutOptions) Validate() error {
 		return ErrCreateRequiresBranch
 	}
 
+	if o.StartPoint != "" && !o.Create {
+		return ErrStartPointRequiresCreate
+	}
+
+	if o.StartPoint != "" && !o.Hash.IsZero() {
+		return ErrStartPointHashExclusive
+	}
+
 	if o.Branch == "" {
 		o.Branch = plumbing.Master
 	}
@@ -373,6 +516,260 @@ type LogOptions struct {
 	// Show commits older than a specific date.
 	// It is equivalent to running `git log --until <date>` or `git log --before <date>`.
 	Until *time.Time
+
+	// Mailmap, if set, is used to replace the author and committer of the
+	// returned commits by their canonical identities. See Repository.Mailmap.
+	// It is equivalent to running `git log --use-mailmap`.
+	Mailmap *mailmap.Mailmap
+
+	// ShowNotes fills the notes of the returned commits, shown by
+	// Commit.String, from the references given by NotesRefs or, if empty,
+	// the references used by Repository.DisplayNotes.
+	// It is equivalent to running `git log --notes`.
+	ShowNotes bool
+
+	// NotesRefs are the notes references used by ShowNotes.
+	// It is equivalent to running `git log --notes=<ref>`.
+	NotesRefs []plumbing.ReferenceName
+}
+
+// BlameOptions describes how a blame operation should be performed.
+type BlameOptions struct {
+	// Mailmap, if set, is used to replace the author of every line by its
+	// canonical identity. See Repository.Mailmap.
+	Mailmap *mailmap.Mailmap
+	// LineRanges limits the blame to the given ranges of lines, as
+	// `git blame -L`. If empty, every line is blamed.
+	LineRanges []LineRange
+	// NoFollowRenames stops the blame at the commits where the file is not
+	// found in the parent, instead of following it across renames, looking
+	// for the source of the file, as git does by default.
+	NoFollowRenames bool
+	// IgnoreRevs are commits whose changes are ignored, the blame of the
+	// lines they changed is passed to their parents, as
+	// `git blame --ignore-rev`.
+	IgnoreRevs []plumbing.Hash
+	// IgnoreRevsFile is a path, relative to the worktree, or to the git
+	// directory in bare repositories, of a file with the commits to ignore,
+	// as `git blame --ignore-revs-file`. If empty, the
+	// `blame.ignoreRevsFile` config is used. Only honoured by
+	// Repository.Blame and Worktree.Blame.
+	IgnoreRevsFile string
+}
+
+// ShortlogOptions describes how a shortlog operation should be performed.
+type ShortlogOptions struct {
+	// From is the commit where the history walk starts, if not set HEAD is
+	// used.
+	From plumbing.Hash
+	// All walks the history of all the references, as `git shortlog --all`.
+	// If set on true, the From option will be ignored.
+	All bool
+	// Committer groups the commits by committer instead of by author.
+	Committer bool
+	// Email groups the commits by name and email, instead of by name only.
+	Email bool
+	// Stats computes the number of added and deleted lines, and changed
+	// files, of every entry. Merge commits do not contribute to the stats.
+	Stats bool
+	// Numbered sorts the entries by number of commits instead of by name.
+	Numbered bool
+	// Mailmap used to find the canonical identities, if nil the mailmap
+	// returned by Repository.Mailmap is used.
+	Mailmap *mailmap.Mailmap
+}
+
+// BisectOptions describes how a bisect operation should be started.
+type BisectOptions struct {
+	// Bad is the commit known to contain the searched change, if any.
+	Bad plumbing.Hash
+	// Good are the commits known to not contain the searched change.
+	Good []plumbing.Hash
+	// NoCheckout stores the commit to test at BISECT_HEAD instead of
+	// checking it out, as `git bisect start --no-checkout`. It is implied in
+	// bare repositories.
+	NoCheckout bool
+}
+
+// ReflogExpireOptions describes how the entries of a reflog are pruned.
+type ReflogExpireOptions struct {
+	// Expire prunes the entries older than the given time. If zero, the
+	// entries older than 90 days are pruned, as `git reflog expire`.
+	Expire time.Time
+	// ExpireUnreachable prunes the entries older than the given time whose
+	// commit is not reachable from the current value of the reference. If
+	// zero, the unreachable entries older than 30 days are pruned.
+	ExpireUnreachable time.Time
+	// Rewrite sets the old value of every entry to the new value of the
+	// previous one, as `git reflog expire --rewrite`.
+	Rewrite bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *ReflogExpireOptions) Validate() error {
+	now := time.Now()
+	if o.Expire.IsZero() {
+		o.Expire = now.Add(-defaultReflogExpire)
+	}
+
+	if o.ExpireUnreachable.IsZero() {
+		o.ExpireUnreachable = now.Add(-defaultReflogExpireUnreachable)
+	}
+
+	return nil
+}
+
+// NoteOptions describes how a note is written.
+type NoteOptions struct {
+	// Ref is the notes reference, if empty core.notesRef is used, or
+	// DefaultNotesRef if not set.
+	Ref plumbing.ReferenceName
+	// Author is the author of the notes commit, if nil the user from the
+	// config is used.
+	Author *object.Signature
+	// Committer is the committer of the notes commit, if nil Author is used.
+	Committer *object.Signature
+	// Force overwrites an existing note, as `git notes add --force`.
+	Force bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *NoteOptions) Validate(r *Repository) error {
+	var err error
+	if o.Ref == "" {
+		if o.Ref, err = r.defaultNotesRef(); err != nil {
+			return err
+		}
+	}
+
+	o.Author, o.Committer, err = notesSignatures(r, o.Author, o.Committer)
+	return err
+}
+
+// NotesRewriteMode defines how the note of a rewritten object is combined
+// with the note of the new object, if it already has one.
+type NotesRewriteMode string
+
+const (
+	// NotesRewriteConcatenate appends the copied note to the existing one.
+	NotesRewriteConcatenate NotesRewriteMode = "concatenate"
+	// NotesRewriteOverwrite replaces the existing note.
+	NotesRewriteOverwrite NotesRewriteMode = "overwrite"
+	// NotesRewriteCatSortUniq combines both notes sorting their lines and
+	// removing the duplicated ones.
+	NotesRewriteCatSortUniq NotesRewriteMode = "cat_sort_uniq"
+	// NotesRewriteIgnore keeps the existing note, the note of the rewritten
+	// object is only copied if the new one has none.
+	NotesRewriteIgnore NotesRewriteMode = "ignore"
+)
+
+// RewriteNotesOptions describes how the notes of rewritten objects are
+// copied.
+type RewriteNotesOptions struct {
+	// Command is the command rewriting the objects, as "amend" or "rebase".
+	// Nothing is copied if notes.rewrite.<command> is false.
+	Command string
+	// Refs are the notes references whose notes are copied, if empty the
+	// references matching notes.rewriteRef are used.
+	Refs []plumbing.ReferenceName
+	// Mode defines how the notes are combined when the new object already
+	// has one, if empty notes.rewriteMode is used, or
+	// NotesRewriteConcatenate if not set.
+	Mode NotesRewriteMode
+	// Author is the author of the notes commits, if nil the user from the
+	// config is used.
+	Author *object.Signature
+	// Committer is the committer of the notes commits, if nil Author is used.
+	Committer *object.Signature
+}
+
+// Validate validates the fields and sets the default values.
+func (o *RewriteNotesOptions) Validate(r *Repository) error {
+	cfg, err := r.MergedConfig()
+	if err != nil {
+		return err
+	}
+
+	section := cfg.Raw.Section(notesSection)
+	if o.Mode == "" {
+		o.Mode = NotesRewriteMode(section.Option(notesRewriteModeKey))
+	}
+
+	if o.Mode == "" {
+		o.Mode = NotesRewriteConcatenate
+	}
+
+	if len(o.Refs) == 0 {
+		o.Refs, err = r.notesRefsMatching(section.Options.GetAll(notesRewriteRefKey))
+		if err != nil {
+			return err
+		}
+	}
+
+	o.Author, o.Committer, err = notesSignatures(r, o.Author, o.Committer)
+	return err
+}
+
+// NotesMergeStrategy defines how the notes changed on both sides of a notes
+// merge are resolved.
+type NotesMergeStrategy string
+
+const (
+	// NotesMergeManual fails the merge on conflicting notes.
+	NotesMergeManual NotesMergeStrategy = "manual"
+	// NotesMergeOurs keeps the local note.
+	NotesMergeOurs NotesMergeStrategy = "ours"
+	// NotesMergeTheirs takes the merged note.
+	NotesMergeTheirs NotesMergeStrategy = "theirs"
+	// NotesMergeUnion concatenates both notes.
+	NotesMergeUnion NotesMergeStrategy = "union"
+	// NotesMergeCatSortUniq combines both notes sorting their lines and
+	// removing the duplicated ones.
+	NotesMergeCatSortUniq NotesMergeStrategy = "cat_sort_uniq"
+)
+
+// MergeNotesOptions describes how a notes merge should be performed.
+type MergeNotesOptions struct {
+	// Ref is the local notes reference, if empty core.notesRef is used, or
+	// DefaultNotesRef if not set.
+	Ref plumbing.ReferenceName
+	// From is the notes reference merged into Ref, it is required.
+	From plumbing.ReferenceName
+	// Strategy resolves the conflicting notes, if empty notes.mergeStrategy
+	// is used, or NotesMergeManual if not set.
+	Strategy NotesMergeStrategy
+	// Author is the author of the merge commit, if nil the user from the
+	// config is used.
+	Author *object.Signature
+	// Committer is the committer of the merge commit, if nil Author is used.
+	Committer *object.Signature
+}
+
+// Validate validates the fields and sets the default values.
+func (o *MergeNotesOptions) Validate(r *Repository) error {
+	if o.From == "" {
+		return ErrMissingNotesRef
+	}
+
+	cfg, err := r.MergedConfig()
+	if err != nil {
+		return err
+	}
+
+	if o.Ref == "" {
+		o.Ref = notesRefFromConfig(cfg)
+	}
+
+	if o.Strategy == "" {
+		o.Strategy = NotesMergeStrategy(cfg.Raw.Section(notesSection).Option(notesMergeStrategyKey))
+	}
+
+	if o.Strategy == "" {
+		o.Strategy = NotesMergeManual
+	}
+
+	o.Author, o.Committer, err = notesSignatures(r, o.Author, o.Committer)
+	return err
 }
 
 var (
@@ -384,30 +781,72 @@ type CommitOptions struct {
 	// All automatically stage files that have been modified and deleted, but
 	// new files you have not told Git about are not affected.
 	All bool
-	// Author is the author's signature of the commit.
+	// Author is the author's signature of the commit. If Author is nil the
+	// user from the config is used.
 	Author *object.Signature
 	// Committer is the committer's signature of the commit. If Committer is
-	// nil the Author signature is used.
+	// nil the Author signature is used, or the user from the config if Author
+	// is also nil.
 	Committer *object.Signature
 	// Parents are the parents commits for the new commit, by default when
 	// len(Parents) is zero, the hash of HEAD reference is used.
 	Parents []plumbing.Hash
 	// SignKey denotes a key to sign the commit with. A nil value here means the
-	// commit will not be signed. The private key must be present and already
-	// decrypted.
+	// commit will not be signed, unless commit.gpgSign is set and a KeyRing is
+	// given. The private key must be present and already decrypted.
 	SignKey *openpgp.Entity
+	// KeyRing contains the keys the sign key is chosen from, when SignKey is
+	// nil and commit.gpgSign is set: the key identified by user.signingKey, or
+	// else the one with the email of the committer. Without KeyRing, or if
+	// gpg.format is not openpgp, commit.gpgSign is ignored.
+	KeyRing openpgp.EntityList
+	// IdentityFromEnv if true, the GIT_AUTHOR_NAME, GIT_AUTHOR_EMAIL and
+	// GIT_AUTHOR_DATE environment variables, and the GIT_COMMITTER_* ones,
+	// override the user from the config, as git does.
+	IdentityFromEnv bool
 }
 
 // Validate validates the fields and sets the default values.
 func (o *CommitOptions) Validate(r *Repository) error {
+	var cfg *config.Config
+	var err error
+	if o.Author == nil || (o.SignKey == nil && len(o.KeyRing) != 0) {
+		cfg, err = r.MergedConfig()
+		if err != nil {
+			return err
+		}
+	}
+
 	if o.Author == nil {
-		return ErrMissingAuthor
+		now := time.Now()
+		o.Author, err = identitySignature(cfg, authorEnvPrefix, o.IdentityFromEnv, now)
+		if err != nil {
+			return err
+		}
+
+		if o.Author == nil {
+			return ErrMissingAuthor
+		}
+
+		if o.Committer == nil {
+			o.Committer, err = identitySignature(cfg, committerEnvPrefix, o.IdentityFromEnv, now)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	if o.Committer == nil {
 		o.Committer = o.Author
 	}
 
+	if o.SignKey == nil && cfg != nil && cfg.Commit.GpgSign {
+		o.SignKey, err = configSignKey(cfg, o.KeyRing, o.Committer.Email)
+		if err != nil {
+			return err
+		}
+	}
+
 	if len(o.Parents) == 0 {
 		head, err := r.Head()
 		if err != nil && err != plumbing.ErrReferenceNotFound {
@@ -430,21 +869,48 @@ var (
 
 // CreateTagOptions describes how a tag object should be created.
 type CreateTagOptions struct {
-	// Tagger defines the signature of the tag creator.
+	// Tagger defines the signature of the tag creator. If Tagger is nil the
+	// user from the config is used.
 	Tagger *object.Signature
 	// Message defines the annotation of the tag. It is canonicalized during
 	// validation into the format expected by git - no leading whitespace and
 	// ending in a newline.
 	Message string
 	// SignKey denotes a key to sign the tag with. A nil value here means the tag
-	// will not be signed. The private key must be present and already decrypted.
+	// will not be signed, unless tag.gpgSign is set and a KeyRing is given.
+	// The private key must be present and already decrypted.
 	SignKey *openpgp.Entity
+	// KeyRing contains the keys the sign key is chosen from, when SignKey is
+	// nil and tag.gpgSign is set: the key identified by user.signingKey, or
+	// else the one with the email of the tagger. Without KeyRing, or if
+	// gpg.format is not openpgp, tag.gpgSign is ignored.
+	KeyRing openpgp.EntityList
+	// IdentityFromEnv if true, the GIT_COMMITTER_NAME, GIT_COMMITTER_EMAIL and
+	// GIT_COMMITTER_DATE environment variables override the user from the
+	// config, as git does.
+	IdentityFromEnv bool
 }
 
 // Validate validates the fields and sets the default values.
 func (o *CreateTagOptions) Validate(r *Repository, hash plumbing.Hash) error {
+	var cfg *config.Config
+	var err error
+	if o.Tagger == nil || (o.SignKey == nil && len(o.KeyRing) != 0) {
+		cfg, err = r.MergedConfig()
+		if err != nil {
+			return err
+		}
+	}
+
 	if o.Tagger == nil {
-		return ErrMissingTagger
+		o.Tagger, err = identitySignature(cfg, committerEnvPrefix, o.IdentityFromEnv, time.Now())
+		if err != nil {
+			return err
+		}
+
+		if o.Tagger == nil {
+			return ErrMissingTagger
+		}
 	}
 
 	if o.Message == "" {
@@ -454,6 +920,13 @@ func (o *CreateTagOptions) Validate(r *Repository, hash plumbing.Hash) error {
 	// Canonicalize the message into the expected message format.
 	o.Message = strings.TrimSpace(o.Message) + "\n"
 
+	if o.SignKey == nil && cfg != nil && cfg.Tag.GpgSign {
+		o.SignKey, err = configSignKey(cfg, o.KeyRing, o.Tagger.Email)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -461,6 +934,45 @@ func (o *CreateTagOptions) Validate(r *Repository, hash plumbing.Hash) error {
 type ListOptions struct {
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
+	// HTTP overrides the settings of the http section of the config for the
+	// HTTP and HTTPS remotes.
+	HTTP HTTPOptions
+}
+
+// SetHeadOptions describes how the HEAD of a remote should be set.
+type SetHeadOptions struct {
+	// Branch is the branch of the remote HEAD points to. If empty, the branch
+	// HEAD points to in the remote repository is used.
+	Branch string
+	// Delete removes the HEAD of the remote instead, Branch must be empty.
+	Delete bool
+	// Auth credentials, if required, to use with the remote repository.
+	Auth transport.AuthMethod
+}
+
+var (
+	// ErrBranchDeleteExclusive is returned when both Branch and Delete are
+	// given setting the HEAD of a remote.
+	ErrBranchDeleteExclusive = errors.New("Branch and Delete are mutually exclusive")
+)
+
+// Validate validates the fields and sets the default values.
+func (o *SetHeadOptions) Validate() error {
+	if o.Delete && o.Branch != "" {
+		return ErrBranchDeleteExclusive
+	}
+
+	return nil
+}
+
+// RemotePruneOptions describes how the remote-tracking references of a remote
+// should be pruned.
+type RemotePruneOptions struct {
+	// DryRun reports the references that would be deleted, without deleting
+	// them.
+	DryRun bool
+	// Auth credentials, if required, to use with the remote repository.
+	Auth transport.AuthMethod
 }
 
 // CleanOptions describes how a clean should be performed.
@@ -515,3 +1027,68 @@ type PlainOpenOptions struct {
 
 // Validate validates the fields and sets the default values.
 func (o *PlainOpenOptions) Validate() error { return nil }
+
+// ReachabilityOptions describes the reachability filters applied by
+// Repository.FilterReferences. A reference is kept if it matches all the
+// given filters.
+type ReachabilityOptions struct {
+	// Contains keeps the references containing any of the commits, as
+	// `git branch --contains`.
+	Contains []plumbing.Hash
+	// NoContains keeps the references not containing any of the commits, as
+	// `git branch --no-contains`.
+	NoContains []plumbing.Hash
+	// Merged keeps the references reachable from any of the commits, as
+	// `git branch --merged`.
+	Merged []plumbing.Hash
+	// NoMerged keeps the references not reachable from any of the commits,
+	// as `git branch --no-merged`.
+	NoMerged []plumbing.Hash
+}
+
+func (o *ReachabilityOptions) isEmpty() bool {
+	return len(o.Contains)+len(o.NoContains)+len(o.Merged)+len(o.NoMerged) == 0
+}
+
+// ForEachRefOptions describes how the references are listed by
+// Repository.ForEachRef.
+type ForEachRefOptions struct {
+	// Patterns keeps the references matching any of the patterns, as
+	// `git for-each-ref <pattern>...`. A pattern matches the references
+	// below it, as "refs/heads", or the ones matching it as a glob, as
+	// "refs/tags/v1.*". All the references are kept if empty.
+	Patterns []string
+	// PointsAt keeps the references pointing to any of the objects, directly
+	// or through tags, as `git for-each-ref --points-at`.
+	PointsAt []plumbing.Hash
+	// ReachabilityOptions keeps the references matching the `--contains`,
+	// `--no-contains`, `--merged` and `--no-merged` filters.
+	ReachabilityOptions
+	// Sort are the keys the references are sorted by, the last the most
+	// significant, as with several `git for-each-ref --sort`. A key is a field name, as
+	// "refname" or "committerdate", prefixed with "-" to reverse the order,
+	// and with "version:" to sort version numbers, as "version:refname".
+	// The references are sorted by refname if empty.
+	Sort []string
+	// Format is the format of the references, as
+	// `git for-each-ref --format`, with fields such as "%(refname:short)",
+	// "%(objectname)", "%(upstream:track)" or "%(subject)". By default
+	// "%(objectname) %(objecttype)\t%(refname)".
+	Format string
+	// Count limits the number of references, all of them are listed if it
+	// is not positive.
+	Count int
+}
+
+// Validate validates the fields and sets the default values.
+func (o *ForEachRefOptions) Validate() error {
+	if o.Format == "" {
+		o.Format = defaultRefFormat
+	}
+
+	if len(o.Sort) == 0 {
+		o.Sort = []string{"refname"}
+	}
+
+	return nil
+}
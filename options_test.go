@@ -1,6 +1,8 @@
 package git
 
 import (
+	"time"
+
 	"github.com/goabstract/go-git/v5/plumbing/object"
 	. "gopkg.in/check.v1"
 )
@@ -33,3 +35,25 @@ func (s *OptionsSuite) TestCommitOptionsCommitter(c *C) {
 
 	c.Assert(o.Committer, Equals, o.Author)
 }
+
+func (s *OptionsSuite) TestFetchOptionsShallowConflict(c *C) {
+	o := FetchOptions{Depth: 1, Deepen: 1}
+	c.Assert(o.Validate(), Equals, ErrShallowOptionsConflict)
+
+	o = FetchOptions{Unshallow: true, ShallowExclude: []string{"refs/heads/master"}}
+	c.Assert(o.Validate(), Equals, ErrShallowOptionsConflict)
+
+	o = FetchOptions{ShallowSince: time.Now(), ShallowExclude: []string{"refs/heads/master"}}
+	c.Assert(o.Validate(), IsNil)
+
+	o = FetchOptions{Deepen: -1}
+	c.Assert(o.Validate(), Equals, ErrInvalidDepth)
+}
+
+func (s *OptionsSuite) TestCloneOptionsShallowConflict(c *C) {
+	o := CloneOptions{URL: "foo", Depth: 1, ShallowSince: time.Now()}
+	c.Assert(o.Validate(), Equals, ErrShallowOptionsConflict)
+
+	o = CloneOptions{URL: "foo", Depth: -1}
+	c.Assert(o.Validate(), Equals, ErrInvalidDepth)
+}
@@ -10,6 +10,10 @@ type Config struct {
 	Comment  *Comment
 	Sections Sections
 	Includes Includes
+
+	// lines are the lines of the decoded config file, kept to encode it back
+	// preserving its layout.
+	lines []*line
 }
 
 // Includes is a list of Includes in a config file.
@@ -68,6 +72,127 @@ func (c *Config) SetOption(section string, subsection string, key string, value
 	return c
 }
 
+// GetAll returns the values of the options with the given key, of a given
+// section and subsection, matching the value regex, as
+// `git config --get-all <name> <value-regex>` does. All the values match an
+// empty value regex, and the values not matching the rest of it match one
+// starting with "!".
+func (c *Config) GetAll(section, subsection, key, valueRegex string) ([]string, error) {
+	match, err := valueMatcher(valueRegex)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	for _, o := range c.options(section, subsection, false) {
+		if o.IsKey(key) && match(o.Value) {
+			values = append(values, o.Value)
+		}
+	}
+
+	return values, nil
+}
+
+// UnsetAll removes the options with the given key, of a given section and
+// subsection, matching the value regex, as
+// `git config --unset-all <name> <value-regex>` does.
+func (c *Config) UnsetAll(section, subsection, key, valueRegex string) error {
+	match, err := valueMatcher(valueRegex)
+	if err != nil {
+		return err
+	}
+
+	opts := c.options(section, subsection, false)
+	var result Options
+	for _, o := range opts {
+		if !o.IsKey(key) || !match(o.Value) {
+			result = append(result, o)
+		}
+	}
+
+	c.setOptions(section, subsection, result)
+	return nil
+}
+
+// ReplaceAll replaces the options with the given key, of a given section and
+// subsection, matching the value regex, by a single option with the given
+// value, in place of the first one, as
+// `git config --replace-all <name> <value> <value-regex>` does. The option is
+// added if none matches.
+func (c *Config) ReplaceAll(section, subsection, key, value, valueRegex string) error {
+	match, err := valueMatcher(valueRegex)
+	if err != nil {
+		return err
+	}
+
+	var result Options
+	replaced := false
+	for _, o := range c.options(section, subsection, true) {
+		if !o.IsKey(key) || !match(o.Value) {
+			result = append(result, o)
+			continue
+		}
+
+		if !replaced {
+			o.Value = value
+			result = append(result, o)
+			replaced = true
+		}
+	}
+
+	if !replaced {
+		result = result.withAddedOption(key, value)
+	}
+
+	c.setOptions(section, subsection, result)
+	return nil
+}
+
+// options returns the options of the given section and subsection, creating
+// them if create is true.
+func (c *Config) options(section, subsection string, create bool) Options {
+	if !create && !c.hasOptions(section, subsection) {
+		return nil
+	}
+
+	s := c.Section(section)
+	if subsection == NoSubsection {
+		return s.Options
+	}
+
+	return s.Subsection(subsection).Options
+}
+
+func (c *Config) hasOptions(section, subsection string) bool {
+	for _, s := range c.Sections {
+		if !s.IsName(section) {
+			continue
+		}
+
+		if subsection == NoSubsection || s.HasSubsection(subsection) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setOptions sets the options of the given section and subsection, if they
+// exist or there are options to set.
+func (c *Config) setOptions(section, subsection string, opts Options) {
+	if len(opts) == 0 && !c.hasOptions(section, subsection) {
+		return
+	}
+
+	s := c.Section(section)
+	if subsection == NoSubsection {
+		s.Options = opts
+		return
+	}
+
+	s.Subsection(subsection).Options = opts
+}
+
 // RemoveSection removes a section from a config file.
 func (c *Config) RemoveSection(name string) *Config {
 	result := Sections{}
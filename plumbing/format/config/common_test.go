@@ -84,3 +84,70 @@ func (s *CommonSuite) TestConfig_RemoveSubsection(c *C) {
 	c.Assert(sect.RemoveSubsection("other", "other"), DeepEquals, sect)
 	c.Assert(sect.RemoveSubsection("section1", "sub2"), DeepEquals, expected)
 }
+
+func (s *CommonSuite) TestConfig_GetAll(c *C) {
+	cfg := New().
+		AddOption("remote", "origin", "fetch", "+refs/heads/*:refs/remotes/origin/*").
+		AddOption("remote", "origin", "fetch", "+refs/tags/*:refs/tags/*").
+		AddOption("remote", "origin", "url", "https://example.com/foo.git")
+
+	values, err := cfg.GetAll("remote", "origin", "fetch", "")
+	c.Assert(err, IsNil)
+	c.Assert(values, DeepEquals, []string{"+refs/heads/*:refs/remotes/origin/*", "+refs/tags/*:refs/tags/*"})
+
+	values, err = cfg.GetAll("remote", "origin", "fetch", "!tags")
+	c.Assert(err, IsNil)
+	c.Assert(values, DeepEquals, []string{"+refs/heads/*:refs/remotes/origin/*"})
+
+	values, err = cfg.GetAll("remote", "upstream", "fetch", "")
+	c.Assert(err, IsNil)
+	c.Assert(values, HasLen, 0)
+	c.Assert(cfg.Section("remote").HasSubsection("upstream"), Equals, false)
+
+	_, err = cfg.GetAll("remote", "origin", "fetch", "(")
+	c.Assert(err, NotNil)
+}
+
+func (s *CommonSuite) TestConfig_UnsetAll(c *C) {
+	cfg := New().
+		AddOption("section", NoSubsection, "key1", "value1").
+		AddOption("section", NoSubsection, "key2", "value2").
+		AddOption("section", NoSubsection, "key1", "value3")
+
+	c.Assert(cfg.UnsetAll("section", NoSubsection, "key1", "3$"), IsNil)
+	c.Assert(cfg.Section("section").Options, DeepEquals, Options{
+		{Key: "key1", Value: "value1"},
+		{Key: "key2", Value: "value2"},
+	})
+
+	c.Assert(cfg.UnsetAll("section", NoSubsection, "KEY1", ""), IsNil)
+	c.Assert(cfg.Section("section").Options, DeepEquals, Options{
+		{Key: "key2", Value: "value2"},
+	})
+
+	c.Assert(cfg.UnsetAll("other", "subsection", "key1", ""), IsNil)
+	c.Assert(cfg.Sections, HasLen, 1)
+	c.Assert(cfg.UnsetAll("section", NoSubsection, "key1", "["), NotNil)
+}
+
+func (s *CommonSuite) TestConfig_ReplaceAll(c *C) {
+	cfg := decode(c, `[remote "origin"]
+	fetch = +refs/heads/master:refs/remotes/origin/master # master
+	url = https://example.com/foo.git
+	fetch = +refs/heads/dev:refs/remotes/origin/dev
+	fetch = +refs/tags/*:refs/tags/*
+`)
+
+	err := cfg.ReplaceAll("remote", "origin", "fetch", "+refs/heads/*:refs/remotes/origin/*", "refs/heads/")
+	c.Assert(err, IsNil)
+	c.Assert(encode(c, cfg), Equals, `[remote "origin"]
+	fetch = +refs/heads/*:refs/remotes/origin/*
+	url = https://example.com/foo.git
+	fetch = +refs/tags/*:refs/tags/*
+`)
+
+	err = cfg.ReplaceAll("remote", "origin", "pushurl", "https://example.com/bar.git", "")
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Section("remote").Subsection("origin").Option("pushurl"), Equals, "https://example.com/bar.git")
+	c.Assert(cfg.ReplaceAll("remote", "origin", "fetch", "foo", "!("), NotNil)
+}
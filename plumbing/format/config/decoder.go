@@ -1,7 +1,9 @@
 package config
 
 import (
+	"bytes"
 	"io"
+	"io/ioutil"
 
 	"github.com/go-git/gcfg"
 )
@@ -17,21 +19,70 @@ func NewDecoder(r io.Reader) *Decoder {
 }
 
 // Decode reads the whole config from its input and stores it in the
-// value pointed to by config.
+// value pointed to by config. The lines of the input are kept, so the config
+// is encoded back with its comments and formatting.
 func (d *Decoder) Decode(config *Config) error {
+	src, err := ioutil.ReadAll(d)
+	if err != nil {
+		return err
+	}
+
+	lines := splitLines(string(src))
+	next := 0
+	var section *Section
+	var subsection *Subsection
+
+	// nextLine returns the next header or option line, attaching the
+	// preceding lines to the current section.
+	nextLine := func(match func(string) bool) *line {
+		for ; next < len(lines); next++ {
+			l := lines[next]
+			if match(l.text) {
+				next++
+				return l
+			}
+
+			l.section, l.subsection = section, subsection
+		}
+
+		return &line{}
+	}
+
 	cb := func(s string, ss string, k string, v string, bv bool) error {
-		if ss == "" && k == "" {
-			config.Section(s)
+		if k == "" {
+			l := nextLine(isHeader)
+			section, subsection = config.Section(s), nil
+			if ss != "" {
+				subsection = section.Subsection(ss)
+			}
+
+			l.section, l.subsection = section, subsection
+			l.header, l.name = true, section.Name
+			if subsection != nil {
+				l.name = subsection.Name
+			}
+
 			return nil
 		}
 
-		if ss != "" && k == "" {
-			config.Section(s).Subsection(ss)
-			return nil
+		o := &Option{Key: k, Value: v, Blank: bv}
+		if subsection != nil {
+			subsection.Options = append(subsection.Options, o)
+		} else {
+			section.Options = append(section.Options, o)
 		}
 
-		config.AddOption(s, ss, k, v)
+		l := nextLine(isOption)
+		l.section, l.subsection = section, subsection
+		l.option, l.key, l.value = o, k, v
 		return nil
 	}
-	return gcfg.ReadWithCallback(d, cb)
+
+	if err := gcfg.ReadWithCallback(bytes.NewReader(src), cb); err != nil {
+		return err
+	}
+
+	nextLine(func(string) bool { return false })
+	config.lines = lines
+	return nil
 }
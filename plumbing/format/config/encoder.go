@@ -9,17 +9,37 @@ import (
 // An Encoder writes config files to an output stream.
 type Encoder struct {
 	w io.Writer
+	// missingEOL is true if the last line written has no line ending, as
+	// the last line of a decoded file may have.
+	missingEOL bool
 }
 
 // NewEncoder returns a new encoder that writes to w.
 func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{w}
+	return &Encoder{w: w}
 }
 
 // Encode writes the config in git config format to the stream of the encoder.
+// The lines of a decoded config are written as they were read, comments and
+// blank lines included, except the ones of the changed or removed sections and
+// options. The new options are written after the last option of their section
+// or subsection, and the new sections at the end.
 func (e *Encoder) Encode(cfg *Config) error {
+	written, err := e.encodeLines(cfg)
+	if err != nil {
+		return err
+	}
+
 	for _, s := range cfg.Sections {
-		if err := e.encodeSection(s); err != nil {
+		if written[s] {
+			if err := e.encodeNewSubsections(s, written); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := e.encodeSection(s, written); err != nil {
 			return err
 		}
 	}
@@ -27,7 +47,123 @@ func (e *Encoder) Encode(cfg *Config) error {
 	return nil
 }
 
-func (e *Encoder) encodeSection(s *Section) error {
+// encodeLines writes the lines of a decoded config, returning the sections
+// and subsections written.
+func (e *Encoder) encodeLines(cfg *Config) (map[interface{}]bool, error) {
+	written := make(map[interface{}]bool)
+	if len(cfg.lines) == 0 {
+		return written, nil
+	}
+
+	present := make(map[interface{}]bool)
+	options := make(map[*Option]bool)
+	for _, s := range cfg.Sections {
+		present[s] = true
+		for _, o := range s.Options {
+			options[o] = true
+		}
+
+		for _, ss := range s.Subsections {
+			present[ss] = true
+			for _, o := range ss.Options {
+				options[o] = true
+			}
+		}
+	}
+
+	// the new options are inserted after the last header or option line of
+	// the last block of their section or subsection
+	known := make(map[*Option]bool)
+	last := make(map[interface{}]int)
+	for i, l := range cfg.lines {
+		if l.option != nil {
+			known[l.option] = true
+		}
+
+		if l.header || l.option != nil {
+			last[l.owner()] = i
+		}
+	}
+
+	for i, l := range cfg.lines {
+		owner := l.owner()
+		if owner != nil && !present[owner] {
+			continue
+		}
+
+		var err error
+		switch {
+		case l.header:
+			written[owner] = true
+			err = e.encodeHeader(l)
+		case l.option != nil:
+			err = e.encodeOption(l, options[l.option])
+		default:
+			err = e.write(l.text)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if owner == nil || last[owner] != i {
+			continue
+		}
+
+		var added Options
+		for _, o := range ownerOptions(owner) {
+			if !known[o] {
+				added = append(added, o)
+			}
+		}
+
+		if err := e.encodeOptions(added); err != nil {
+			return nil, err
+		}
+	}
+
+	return written, nil
+}
+
+// encodeHeader writes a section or subsection header as read, or formatted
+// if it was renamed.
+func (e *Encoder) encodeHeader(l *line) error {
+	switch {
+	case l.subsection != nil && l.subsection.Name != l.name:
+		return e.printf("[%s \"%s\"]\n", l.section.Name, l.subsection.Name)
+	case l.subsection == nil && l.section.Name != l.name:
+		return e.printf("[%s]\n", l.section.Name)
+	default:
+		return e.write(l.text)
+	}
+}
+
+// encodeOption writes an option line as read, formatted with the same
+// indentation if its key or value changed, or nothing if it was removed.
+func (e *Encoder) encodeOption(l *line, present bool) error {
+	o := l.option
+	switch {
+	case !present:
+		return nil
+	case o.Key == l.key && o.Value == l.value:
+		return e.write(l.text)
+	default:
+		return e.printf(l.indent()+optionPattern(o)[1:], o.Key, o.Value)
+	}
+}
+
+func ownerOptions(owner interface{}) Options {
+	switch o := owner.(type) {
+	case *Section:
+		return o.Options
+	case *Subsection:
+		return o.Options
+	default:
+		return nil
+	}
+}
+
+func (e *Encoder) encodeSection(s *Section, written map[interface{}]bool) error {
 	if len(s.Options) > 0 {
 		if err := e.printf("[%s]\n", s.Name); err != nil {
 			return err
@@ -38,7 +174,17 @@ func (e *Encoder) encodeSection(s *Section) error {
 		}
 	}
 
+	return e.encodeNewSubsections(s, written)
+}
+
+// encodeNewSubsections writes the subsections of the section not written
+// yet.
+func (e *Encoder) encodeNewSubsections(s *Section, written map[interface{}]bool) error {
 	for _, ss := range s.Subsections {
+		if written[ss] {
+			continue
+		}
+
 		if err := e.encodeSubsection(s.Name, ss); err != nil {
 			return err
 		}
@@ -58,12 +204,7 @@ func (e *Encoder) encodeSubsection(sectionName string, s *Subsection) error {
 
 func (e *Encoder) encodeOptions(opts Options) error {
 	for _, o := range opts {
-		pattern := "\t%s = %s\n"
-		if strings.Contains(o.Value, "\\") {
-			pattern = "\t%s = %q\n"
-		}
-
-		if err := e.printf(pattern, o.Key, o.Value); err != nil {
+		if err := e.printf(optionPattern(o), o.Key, o.Value); err != nil {
 			return err
 		}
 	}
@@ -71,7 +212,35 @@ func (e *Encoder) encodeOptions(opts Options) error {
 	return nil
 }
 
+// optionPattern returns the format of the line of the option, quoting its
+// value if needed: when it has characters starting a comment or escaped, or
+// spaces around it, which would be lost otherwise.
+func optionPattern(o *Option) string {
+	if strings.ContainsAny(o.Value, "\\\";#\n\t") || strings.TrimSpace(o.Value) != o.Value {
+		return "\t%s = %q\n"
+	}
+
+	return "\t%s = %s\n"
+}
+
+// write writes a line as read.
+func (e *Encoder) write(text string) error {
+	if err := e.printf("%s", text); err != nil {
+		return err
+	}
+
+	e.missingEOL = !strings.HasSuffix(text, "\n")
+	return nil
+}
+
 func (e *Encoder) printf(msg string, args ...interface{}) error {
+	if e.missingEOL {
+		e.missingEOL = false
+		if _, err := io.WriteString(e.w, "\n"); err != nil {
+			return err
+		}
+	}
+
 	_, err := fmt.Fprintf(e.w, msg, args...)
 	return err
 }
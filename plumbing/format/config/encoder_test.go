@@ -2,6 +2,7 @@ package config
 
 import (
 	"bytes"
+	"strings"
 
 	. "gopkg.in/check.v1"
 )
@@ -19,3 +20,81 @@ func (s *EncoderSuite) TestEncode(c *C) {
 		c.Assert(buf.String(), Equals, fixture.Text, Commentf("bad result for fixture: %d", idx))
 	}
 }
+
+// decode decodes the given config file, failing the test on errors.
+func decode(c *C, raw string) *Config {
+	cfg := New()
+	c.Assert(NewDecoder(strings.NewReader(raw)).Decode(cfg), IsNil)
+	return cfg
+}
+
+func encode(c *C, cfg *Config) string {
+	buf := &bytes.Buffer{}
+	c.Assert(NewEncoder(buf).Encode(cfg), IsNil)
+	return buf.String()
+}
+
+const layoutFixture = `# global settings
+[core]
+    bare = false   ; not bare
+	# the worktree
+	worktree = "../foo bar"
+
+[remote "origin"]
+	url = https://example.com/foo.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+	; a comment
+[branch "master"]
+	remote = origin
+	merge = refs/heads/master
+[alias]
+	lg = log --graph \
+	  --oneline
+	st`
+
+func (s *EncoderSuite) TestEncodeLayoutUnchanged(c *C) {
+	cfg := decode(c, layoutFixture)
+	c.Assert(cfg.Section("alias").Option("lg"), Equals, "log --graph \t  --oneline")
+	c.Assert(encode(c, cfg), Equals, layoutFixture)
+}
+
+func (s *EncoderSuite) TestEncodeLayoutChanged(c *C) {
+	cfg := decode(c, layoutFixture)
+	cfg.Section("core").SetOption("bare", "true")
+	cfg.Section("core").AddOption("filemode", "false")
+	cfg.Section("remote").Subsection("origin").RemoveOption("fetch")
+	cfg.Section("remote").Subsection("origin").Name = "upstream"
+	cfg.Section("alias").RemoveOption("lg")
+	cfg.RemoveSubsection("branch", "master")
+	cfg.Section("remote").SetOption("pushDefault", "upstream")
+	cfg.AddOption("user", NoSubsection, "name", "foo")
+	cfg.AddOption("branch", "develop", "remote", "upstream")
+
+	c.Assert(encode(c, cfg), Equals, `# global settings
+[core]
+    bare = true
+	# the worktree
+	worktree = "../foo bar"
+	filemode = false
+
+[remote "upstream"]
+	url = https://example.com/foo.git
+	; a comment
+[alias]
+	st
+[remote]
+	pushDefault = upstream
+[branch "develop"]
+	remote = upstream
+[user]
+	name = foo
+`)
+}
+
+func (s *EncoderSuite) TestEncodeLayoutDuplicatedSections(c *C) {
+	cfg := decode(c, "[core]\n\tbare = false\n[user]\n\tname = foo\n[core]\n\tfilemode = true\n\n")
+	cfg.Section("core").AddOption("symlinks", "false")
+	cfg.RemoveSection("user")
+
+	c.Assert(encode(c, cfg), Equals, "[core]\n\tbare = false\n[core]\n\tfilemode = true\n\tsymlinks = false\n\n")
+}
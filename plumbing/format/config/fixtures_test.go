@@ -27,6 +27,11 @@ var fixtures = []*Fixture{
 		Text:   "[core]\n\trepositoryformatversion = 0\n",
 		Config: New().AddOption("core", "", "repositoryformatversion", "0"),
 	},
+	{
+		Raw:    "[core]\n\tsshCommand = \"ssh -i a;b # c\"\n",
+		Text:   "[core]\n\tsshCommand = \"ssh -i a;b # c\"\n",
+		Config: New().AddOption("core", "", "sshCommand", "ssh -i a;b # c"),
+	},
 	{
 		Raw:    "[core]\n\trepositoryformatversion = 0\n",
 		Text:   "[core]\n\trepositoryformatversion = 0\n",
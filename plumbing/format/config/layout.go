@@ -0,0 +1,111 @@
+package config
+
+import (
+	"strings"
+)
+
+// line is a line of a decoded config file, with its continuation lines, kept
+// to encode the config back preserving the comments, the blank lines and the
+// formatting of the unchanged sections and options.
+type line struct {
+	// text is the line as read, with its line ending.
+	text string
+	// section and subsection are the section and subsection the line belongs
+	// to, nil for the lines before the first section.
+	section    *Section
+	subsection *Subsection
+	// header is true for the section and subsection headers, name is the
+	// name they had when decoded.
+	header bool
+	name   string
+	// option is the option of the line, if any, key and value are the key
+	// and value it had when decoded.
+	option     *Option
+	key, value string
+}
+
+// owner returns the section or subsection the line belongs to.
+func (l *line) owner() interface{} {
+	if l.subsection != nil {
+		return l.subsection
+	}
+
+	if l.section != nil {
+		return l.section
+	}
+
+	return nil
+}
+
+// indent returns the whitespace the line starts with.
+func (l *line) indent() string {
+	return l.text[:len(l.text)-len(strings.TrimLeft(l.text, " \t"))]
+}
+
+// splitLines splits the content of a config file in lines, joining the lines
+// of the values continued with a trailing backslash.
+func splitLines(src string) []*line {
+	var lines []*line
+	for len(src) != 0 {
+		i := strings.IndexByte(src, '\n') + 1
+		if i == 0 {
+			i = len(src)
+		}
+
+		text := src[:i]
+		for isContinued(text) && i < len(src) {
+			next := strings.IndexByte(src[i:], '\n') + 1
+			if next == 0 {
+				next = len(src) - i
+			}
+
+			i += next
+			text = src[:i]
+		}
+
+		lines = append(lines, &line{text: text})
+		src = src[i:]
+	}
+
+	return lines
+}
+
+// isContinued returns true if the last line of the text ends with a
+// backslash, out of a comment, continuing the value in the next line.
+func isContinued(text string) bool {
+	text = strings.TrimRight(text, "\r\n")
+	if i := strings.LastIndexByte(text, '\n'); i != -1 {
+		text = text[i+1:]
+	}
+
+	quoted := false
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			if i == len(text)-1 {
+				return true
+			}
+
+			i++
+		case '"':
+			quoted = !quoted
+		case '#', ';':
+			if !quoted {
+				return false
+			}
+		}
+	}
+
+	return false
+}
+
+// isHeader returns true if the line is a section or subsection header.
+func isHeader(text string) bool {
+	return strings.HasPrefix(strings.TrimSpace(text), "[")
+}
+
+// isOption returns true if the line declares an option.
+func isOption(text string) bool {
+	text = strings.TrimSpace(text)
+	return text != "" && !isHeader(text) && text[0] != '#' && text[0] != ';'
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 )
 
@@ -12,6 +13,12 @@ type Option struct {
 	Key string
 	// Original value as string, could be not normalized.
 	Value string
+	// Blank is true for an option without value, a bare key, which is a
+	// true boolean.
+	Blank bool
+	// Scope of the config file the option was read from, set when config
+	// files are merged, LocalScope otherwise.
+	Scope Scope
 }
 
 type Options []*Option
@@ -54,6 +61,30 @@ func (opts Options) Get(key string) string {
 	return ""
 }
 
+// Bool returns the last value of the key as a boolean, as git parses it:
+// true for a bare key, or for the values true, yes, on and 1. ok is false if
+// the key is not set.
+func (opts Options) Bool(key string) (value, ok bool) {
+	for i := len(opts) - 1; i >= 0; i-- {
+		o := opts[i]
+		if o.IsKey(key) {
+			return o.Blank || IsTrue(o.Value), true
+		}
+	}
+
+	return false, false
+}
+
+// IsTrue returns true if the value is a true boolean, as git parses it.
+func IsTrue(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true
+	default:
+		return false
+	}
+}
+
 // GetAll returns all possible values for the same key.
 func (opts Options) GetAll(key string) []string {
 	result := []string{}
@@ -76,30 +107,44 @@ func (opts Options) withoutOption(key string) Options {
 }
 
 func (opts Options) withAddedOption(key string, value string) Options {
-	return append(opts, &Option{key, value})
+	return append(opts, &Option{Key: key, Value: value})
 }
 
+// withSettedOption returns the options with the given values for the key.
+// The options with one of the values are kept and the others are reused, in
+// place, for the missing values, so setting an option of a decoded config
+// changes only its lines.
 func (opts Options) withSettedOption(key string, values ...string) Options {
-	var result Options
+	kept := make(map[*Option]bool)
 	var added []string
 	for _, o := range opts {
-		if !o.IsKey(key) {
-			result = append(result, o)
-			continue
-		}
-
-		if contains(values, o.Value) {
+		if o.IsKey(key) && contains(values, o.Value) && !contains(added, o.Value) {
+			kept[o] = true
 			added = append(added, o.Value)
-			result = append(result, o)
-			continue
 		}
 	}
 
+	var missing []string
 	for _, value := range values {
-		if contains(added, value) {
+		if !contains(added, value) {
+			missing = append(missing, value)
+		}
+	}
+
+	var result Options
+	for _, o := range opts {
+		if !o.IsKey(key) || kept[o] {
+			result = append(result, o)
 			continue
 		}
 
+		if len(missing) != 0 {
+			o.Value, missing = missing[0], missing[1:]
+			result = append(result, o)
+		}
+	}
+
+	for _, value := range missing {
 		result = result.withAddedOption(key, value)
 	}
 
@@ -115,3 +160,22 @@ func contains(haystack []string, needle string) bool {
 
 	return false
 }
+
+// valueMatcher returns a function matching the values against a value-regex
+// of git config: every value matches an empty one, and one starting with "!"
+// matches the values not matching the rest of it.
+func valueMatcher(valueRegex string) (func(string) bool, error) {
+	if valueRegex == "" {
+		return func(string) bool { return true }, nil
+	}
+
+	negate := strings.HasPrefix(valueRegex, "!")
+	re, err := regexp.Compile(strings.TrimPrefix(valueRegex, "!"))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(value string) bool {
+		return re.MatchString(value) != negate
+	}, nil
+}
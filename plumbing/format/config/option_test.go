@@ -1,6 +1,8 @@
 package config
 
 import (
+	"bytes"
+
 	. "gopkg.in/check.v1"
 )
 
@@ -10,9 +12,9 @@ var _ = Suite(&OptionSuite{})
 
 func (s *OptionSuite) TestOptions_GetAll(c *C) {
 	o := Options{
-		&Option{"k", "v"},
-		&Option{"ok", "v1"},
-		&Option{"K", "v2"},
+		&Option{Key: "k", Value: "v"},
+		&Option{Key: "ok", Value: "v1"},
+		&Option{Key: "K", Value: "v2"},
 	}
 	c.Assert(o.GetAll("k"), DeepEquals, []string{"v", "v2"})
 	c.Assert(o.GetAll("K"), DeepEquals, []string{"v", "v2"})
@@ -31,3 +33,26 @@ func (s *OptionSuite) TestOption_IsKey(c *C) {
 	c.Assert((&Option{Key: "key"}).IsKey(""), Equals, false)
 	c.Assert((&Option{Key: ""}).IsKey("key"), Equals, false)
 }
+
+func (s *OptionSuite) TestOptions_Bool(c *C) {
+	cfg := New()
+	c.Assert(NewDecoder(bytes.NewBufferString(
+		"[core]\n\tbare\n\tfoo = Yes\n\tfoo = off\n\tbar = on\n\tqux = 1\n\tempty =\n",
+	)).Decode(cfg), IsNil)
+
+	opts := cfg.Section("core").Options
+	for key, expected := range map[string]bool{
+		"bare":  true,
+		"foo":   false,
+		"bar":   true,
+		"qux":   true,
+		"empty": false,
+	} {
+		value, ok := opts.Bool(key)
+		c.Assert(ok, Equals, true, Commentf("%s", key))
+		c.Assert(value, Equals, expected, Commentf("%s", key))
+	}
+
+	_, ok := opts.Bool("missing")
+	c.Assert(ok, Equals, false)
+}
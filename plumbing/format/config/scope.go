@@ -0,0 +1,36 @@
+package config
+
+// Scope is the scope of a config file: the files of a repository, of the
+// user and of the system are read, and merged, in a fixed order.
+type Scope int
+
+const (
+	// LocalScope is the scope of the config file of the repository,
+	// `$GIT_DIR/config`.
+	LocalScope Scope = iota
+	// GlobalScope is the scope of the config files of the user,
+	// `$XDG_CONFIG_HOME/git/config` and `~/.gitconfig`.
+	GlobalScope
+	// SystemScope is the scope of the config file of the system,
+	// `/etc/gitconfig`.
+	SystemScope
+	// WorktreeScope is the scope of the config file of the worktree,
+	// `$GIT_DIR/config.worktree`, read if extensions.worktreeConfig is set.
+	WorktreeScope
+)
+
+// String returns the name of the scope, as `git config --show-scope` does.
+func (s Scope) String() string {
+	switch s {
+	case LocalScope:
+		return "local"
+	case GlobalScope:
+		return "global"
+	case SystemScope:
+		return "system"
+	case WorktreeScope:
+		return "worktree"
+	default:
+		return "unknown"
+	}
+}
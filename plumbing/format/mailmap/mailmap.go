@@ -0,0 +1,205 @@
+// Package mailmap implements the parsing of .mailmap files, used to map
+// author and committer names and email addresses to canonical ones.
+//
+// Each non-comment line of a mailmap file uses one of these forms:
+//
+//   Proper Name <commit@email.xx>
+//   <proper@email.xx> <commit@email.xx>
+//   Proper Name <proper@email.xx> <commit@email.xx>
+//   Proper Name <proper@email.xx> Commit Name <commit@email.xx>
+//
+// The commit email is always matched case-insensitively, the commit name,
+// when present, is matched case-insensitively too. More details can be found
+// at https://git-scm.com/docs/gitmailmap
+package mailmap
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+const commentPrefix = "#"
+
+// Entry is a single mapping from a commit identity to a proper one.
+type Entry struct {
+	// ProperName is the canonical name, empty if the name is not mapped.
+	ProperName string
+	// ProperEmail is the canonical email, empty if the email is not mapped.
+	ProperEmail string
+	// CommitName is the name to match, empty to match any name.
+	CommitName string
+	// CommitEmail is the email to match.
+	CommitEmail string
+}
+
+// Mailmap holds a set of entries and resolves identities against them. The
+// zero value is an empty mailmap that maps every identity to itself.
+type Mailmap struct {
+	// entries indexed by lowercased commit email
+	entries map[string][]*Entry
+}
+
+// New returns an empty Mailmap.
+func New() *Mailmap {
+	return &Mailmap{entries: make(map[string][]*Entry)}
+}
+
+// Parse reads a mailmap file from r.
+func Parse(r io.Reader) (*Mailmap, error) {
+	m := New()
+	if err := m.Read(r); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Read reads the entries of a mailmap file from r and adds them to m. Later
+// entries take precedence over previous ones, so several files can be read
+// in the order git does. The lines that can't be parsed are skipped, as git
+// does.
+func (m *Mailmap) Read(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, commentPrefix) {
+			continue
+		}
+
+		e, ok := parseEntry(line)
+		if !ok {
+			continue
+		}
+
+		m.Add(e)
+	}
+
+	return s.Err()
+}
+
+// Add adds the given entry to the mailmap. An entry with the same commit name
+// and email replaces the previous one.
+func (m *Mailmap) Add(e *Entry) {
+	if m.entries == nil {
+		m.entries = make(map[string][]*Entry)
+	}
+
+	key := strings.ToLower(e.CommitEmail)
+	for i, prev := range m.entries[key] {
+		if strings.EqualFold(prev.CommitName, e.CommitName) {
+			m.entries[key][i] = merge(prev, e)
+			return
+		}
+	}
+
+	m.entries[key] = append(m.entries[key], e)
+}
+
+func merge(prev, e *Entry) *Entry {
+	n := *prev
+	if e.ProperName != "" {
+		n.ProperName = e.ProperName
+	}
+
+	if e.ProperEmail != "" {
+		n.ProperEmail = e.ProperEmail
+	}
+
+	return &n
+}
+
+// Entries returns the number of entries in the mailmap.
+func (m *Mailmap) Entries() int {
+	var n int
+	for _, es := range m.entries {
+		n += len(es)
+	}
+
+	return n
+}
+
+// Lookup returns the canonical name and email for the given identity. If no
+// entry matches, the given name and email are returned unchanged. Entries
+// with a commit name take precedence over the ones matching only the email.
+func (m *Mailmap) Lookup(name, email string) (string, string) {
+	if m == nil {
+		return name, email
+	}
+
+	var match *Entry
+	for _, e := range m.entries[strings.ToLower(email)] {
+		if e.CommitName == "" {
+			if match == nil {
+				match = e
+			}
+
+			continue
+		}
+
+		if strings.EqualFold(e.CommitName, name) {
+			match = e
+			break
+		}
+	}
+
+	if match == nil {
+		return name, email
+	}
+
+	if match.ProperName != "" {
+		name = match.ProperName
+	}
+
+	if match.ProperEmail != "" {
+		email = match.ProperEmail
+	}
+
+	return name, email
+}
+
+func parseEntry(line string) (*Entry, bool) {
+	name1, email1, rest, ok := parseIdentity(line)
+	if !ok {
+		return nil, false
+	}
+
+	e := &Entry{ProperName: name1}
+	if strings.TrimSpace(rest) == "" || strings.HasPrefix(strings.TrimSpace(rest), commentPrefix) {
+		e.CommitEmail = email1
+		return e, true
+	}
+
+	name2, email2, rest, ok := parseIdentity(rest)
+	if !ok {
+		return nil, false
+	}
+
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, commentPrefix) {
+		return nil, false
+	}
+
+	e.ProperEmail = email1
+	e.CommitName = name2
+	e.CommitEmail = email2
+	return e, true
+}
+
+// parseIdentity parses a `[name] <email>` pair from the beginning of s, and
+// returns the remaining text.
+func parseIdentity(s string) (name, email, rest string, ok bool) {
+	open := strings.IndexByte(s, '<')
+	if open == -1 {
+		return "", "", "", false
+	}
+
+	end := strings.IndexByte(s[open:], '>')
+	if end == -1 {
+		return "", "", "", false
+	}
+
+	end += open
+	name = strings.TrimSpace(s[:open])
+	email = strings.TrimSpace(s[open+1 : end])
+	return name, email, s[end+1:], true
+}
@@ -0,0 +1,89 @@
+package mailmap
+
+import (
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MailmapSuite struct{}
+
+var _ = Suite(&MailmapSuite{})
+
+const fixture = `
+# comment
+Proper Name <commit@example.com>
+<proper@example.com> <other@example.com>
+Joe Developer <joe@example.com> <JOE@old.example.com>
+Jane Doe <jane@example.com> jane <jane@laptop>
+Jane Doe <jane@example.com> Jane D. <jane@laptop> # trailing comment
+`
+
+func (s *MailmapSuite) TestParse(c *C) {
+	m, err := Parse(strings.NewReader(fixture))
+	c.Assert(err, IsNil)
+	c.Assert(m.Entries(), Equals, 5)
+}
+
+func (s *MailmapSuite) TestParseInvalid(c *C) {
+	m, err := Parse(strings.NewReader(
+		"Proper Name commit@example.com\n" +
+			"Foo <foo@example.com>\n" +
+			"<a@example.com> <b@example.com> foo\n" +
+			"Bar <bar@example.com> Bar <bar@old\n" +
+			"<proper@example.com> <other@example.com>\n",
+	))
+	c.Assert(err, IsNil)
+	c.Assert(m.Entries(), Equals, 2)
+
+	name, email := m.Lookup("x", "foo@example.com")
+	c.Assert(name, Equals, "Foo")
+	c.Assert(email, Equals, "foo@example.com")
+
+	name, email = m.Lookup("x", "other@example.com")
+	c.Assert(name, Equals, "x")
+	c.Assert(email, Equals, "proper@example.com")
+}
+
+func (s *MailmapSuite) TestLookup(c *C) {
+	m, err := Parse(strings.NewReader(fixture))
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		name, email       string
+		expName, expEmail string
+	}{
+		{"foo", "commit@example.com", "Proper Name", "commit@example.com"},
+		{"foo", "other@example.com", "foo", "proper@example.com"},
+		{"joe", "joe@old.example.com", "Joe Developer", "joe@example.com"},
+		{"JANE", "jane@laptop", "Jane Doe", "jane@example.com"},
+		{"Jane D.", "jane@laptop", "Jane Doe", "jane@example.com"},
+		{"someone", "jane@laptop", "someone", "jane@laptop"},
+		{"unknown", "unknown@example.com", "unknown", "unknown@example.com"},
+	} {
+		name, email := m.Lookup(t.name, t.email)
+		c.Assert(name, Equals, t.expName, Commentf("%s <%s>", t.name, t.email))
+		c.Assert(email, Equals, t.expEmail, Commentf("%s <%s>", t.name, t.email))
+	}
+}
+
+func (s *MailmapSuite) TestLookupNil(c *C) {
+	var m *Mailmap
+	name, email := m.Lookup("foo", "foo@example.com")
+	c.Assert(name, Equals, "foo")
+	c.Assert(email, Equals, "foo@example.com")
+}
+
+func (s *MailmapSuite) TestAddMerges(c *C) {
+	m := New()
+	m.Add(&Entry{ProperName: "Foo", CommitEmail: "foo@example.com"})
+	m.Add(&Entry{ProperEmail: "bar@example.com", CommitEmail: "FOO@example.com"})
+	c.Assert(m.Entries(), Equals, 1)
+
+	name, email := m.Lookup("x", "foo@example.com")
+	c.Assert(name, Equals, "Foo")
+	c.Assert(email, Equals, "bar@example.com")
+}
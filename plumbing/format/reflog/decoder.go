@@ -0,0 +1,139 @@
+package reflog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+)
+
+// ErrMalformedEntry is returned when a reflog line can not be parsed.
+var ErrMalformedEntry = errors.New("malformed reflog entry")
+
+const hashLength = 40
+
+// A Decoder reads reflog entries from an input stream.
+type Decoder struct {
+	s *bufio.Scanner
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{s: bufio.NewScanner(r)}
+}
+
+// Decode reads the next entry, io.EOF is returned when there are no more
+// entries. Empty lines are skipped.
+func (d *Decoder) Decode() (*Entry, error) {
+	for d.s.Scan() {
+		line := d.s.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		return decodeEntry(line)
+	}
+
+	if err := d.s.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
+
+// DecodeAll reads all the remaining entries.
+func (d *Decoder) DecodeAll() ([]*Entry, error) {
+	var entries []*Entry
+	for {
+		e, err := d.Decode()
+		if err == io.EOF {
+			return entries, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, e)
+	}
+}
+
+func decodeEntry(line []byte) (*Entry, error) {
+	if len(line) < 2*hashLength+2 || line[hashLength] != ' ' || line[2*hashLength+1] != ' ' {
+		return nil, ErrMalformedEntry
+	}
+
+	old, new := string(line[:hashLength]), string(line[hashLength+1:2*hashLength+1])
+	if !plumbing.IsHash(old) || !plumbing.IsHash(new) {
+		return nil, ErrMalformedEntry
+	}
+
+	e := &Entry{Old: plumbing.NewHash(old), New: plumbing.NewHash(new)}
+
+	rest := line[2*hashLength+2:]
+	if tab := bytes.IndexByte(rest, '\t'); tab != -1 {
+		e.Message = string(rest[tab+1:])
+		rest = rest[:tab]
+	}
+
+	sig, err := decodeSignature(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	e.Committer = sig
+	return e, nil
+}
+
+func decodeSignature(b []byte) (Signature, error) {
+	var s Signature
+	open := bytes.LastIndexByte(b, '<')
+	close := bytes.LastIndexByte(b, '>')
+	if open == -1 || close == -1 || close < open {
+		return s, ErrMalformedEntry
+	}
+
+	s.Name = string(bytes.TrimSpace(b[:open]))
+	s.Email = string(b[open+1 : close])
+
+	fields := bytes.Fields(b[close+1:])
+	if len(fields) != 2 {
+		return s, ErrMalformedEntry
+	}
+
+	ts, err := strconv.ParseInt(string(fields[0]), 10, 64)
+	if err != nil {
+		return s, ErrMalformedEntry
+	}
+
+	tz, err := decodeTimeZone(fields[1])
+	if err != nil {
+		return s, err
+	}
+
+	s.When = time.Unix(ts, 0).In(tz)
+	return s, nil
+}
+
+func decodeTimeZone(b []byte) (*time.Location, error) {
+	if len(b) != 5 || (b[0] != '+' && b[0] != '-') {
+		return nil, ErrMalformedEntry
+	}
+
+	hours, err1 := strconv.Atoi(string(b[1:3]))
+	mins, err2 := strconv.Atoi(string(b[3:]))
+	if err1 != nil || err2 != nil {
+		return nil, ErrMalformedEntry
+	}
+
+	offset := hours*60*60 + mins*60
+	if b[0] == '-' {
+		offset = -offset
+	}
+
+	return time.FixedZone("", offset), nil
+}
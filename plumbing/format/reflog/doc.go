@@ -0,0 +1,10 @@
+// Package reflog implements encoding and decoding of reflog files.
+//
+// A reflog records, one line per update, the values a reference had over
+// time. Every line has the following format:
+//
+//   <old hash> SP <new hash> SP <committer> SP <timestamp> SP <tz> TAB <message> LF
+//
+// The lines are written in chronological order, the oldest first. More
+// information at https://git-scm.com/docs/git-reflog
+package reflog
@@ -0,0 +1,38 @@
+package reflog
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// An Encoder writes reflog entries to an output stream.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the entry as a single line. Line breaks in the message are
+// replaced by spaces, as git does.
+func (e *Encoder) Encode(entry *Entry) error {
+	msg := strings.TrimRight(entry.Message, "\n")
+	msg = strings.Replace(msg, "\n", " ", -1)
+
+	c := entry.Committer
+	ts := c.When.Unix()
+	if ts < 0 {
+		ts = 0
+	}
+
+	line := fmt.Sprintf("%s %s %s %d %s", entry.Old, entry.New, c, ts, c.When.Format("-0700"))
+	if msg != "" {
+		line += "\t" + msg
+	}
+
+	_, err := fmt.Fprintln(e.w, line)
+	return err
+}
@@ -0,0 +1,36 @@
+package reflog
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+)
+
+// Signature is the identity and time of the update of a reference.
+type Signature struct {
+	// Name of the committer.
+	Name string
+	// Email of the committer.
+	Email string
+	// When is the time of the update.
+	When time.Time
+}
+
+func (s Signature) String() string {
+	return fmt.Sprintf("%s <%s>", s.Name, s.Email)
+}
+
+// Entry is a single update of a reference.
+type Entry struct {
+	// Old is the value of the reference before the update, the zero hash
+	// when the reference was created.
+	Old plumbing.Hash
+	// New is the value of the reference after the update, the zero hash
+	// when the reference was deleted.
+	New plumbing.Hash
+	// Committer is who updated the reference, and when.
+	Committer Signature
+	// Message describes the update, such as "commit: fix typo".
+	Message string
+}
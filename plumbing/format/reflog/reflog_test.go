@@ -0,0 +1,81 @@
+package reflog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ReflogSuite struct{}
+
+var _ = Suite(&ReflogSuite{})
+
+const fixture = "" +
+	"0000000000000000000000000000000000000000 6ecf0ef2c2dffb796033e5a02219af86ec6584e5 John Doe <john@example.com> 1493852623 +0200\tclone: from https://example.com/repo.git\n" +
+	"6ecf0ef2c2dffb796033e5a02219af86ec6584e5 918c48b83bd081e863dbe1b80f8998f058cd8294 John Doe <john@example.com> 1493856223 -0130\tcheckout: moving from master to 918c48b\n" +
+	"\n" +
+	"918c48b83bd081e863dbe1b80f8998f058cd8294 918c48b83bd081e863dbe1b80f8998f058cd8294 John Doe <john@example.com> 1493856224 +0000\n"
+
+func (s *ReflogSuite) TestDecode(c *C) {
+	entries, err := NewDecoder(strings.NewReader(fixture)).DecodeAll()
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 3)
+
+	e := entries[0]
+	c.Assert(e.Old, Equals, plumbing.ZeroHash)
+	c.Assert(e.New, Equals, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	c.Assert(e.Committer.Name, Equals, "John Doe")
+	c.Assert(e.Committer.Email, Equals, "john@example.com")
+	c.Assert(e.Committer.When.Unix(), Equals, int64(1493852623))
+	_, offset := e.Committer.When.Zone()
+	c.Assert(offset, Equals, 2*60*60)
+	c.Assert(e.Message, Equals, "clone: from https://example.com/repo.git")
+
+	_, offset = entries[1].Committer.When.Zone()
+	c.Assert(offset, Equals, -90*60)
+	c.Assert(entries[2].Message, Equals, "")
+}
+
+func (s *ReflogSuite) TestDecodeMalformed(c *C) {
+	for _, line := range []string{
+		"foo",
+		"0000000000000000000000000000000000000000 6ecf0ef2c2dffb796033e5a02219af86ec6584e5 John Doe 1493852623 +0200\tfoo",
+		"0000000000000000000000000000000000000000 6ecf0ef2c2dffb796033e5a02219af86ec6584e5 John Doe <john@example.com> foo +0200\tfoo",
+		"0000000000000000000000000000000000000000 6ecf0ef2c2dffb796033e5a02219af86ec6584e5 John Doe <john@example.com> 1493852623 0200\tfoo",
+	} {
+		_, err := NewDecoder(strings.NewReader(line)).Decode()
+		c.Assert(err, Equals, ErrMalformedEntry, Commentf("%s", line))
+	}
+}
+
+func (s *ReflogSuite) TestEncodeDecode(c *C) {
+	entries, err := NewDecoder(strings.NewReader(fixture)).DecodeAll()
+	c.Assert(err, IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	e := NewEncoder(buf)
+	for _, entry := range entries {
+		c.Assert(e.Encode(entry), IsNil)
+	}
+
+	c.Assert(buf.String(), Equals, strings.Replace(fixture, "\n\n", "\n", 1))
+}
+
+func (s *ReflogSuite) TestEncodeMultilineMessage(c *C) {
+	buf := bytes.NewBuffer(nil)
+	err := NewEncoder(buf).Encode(&Entry{
+		Committer: Signature{Name: "foo", Email: "foo@example.com", When: time.Unix(0, 0).UTC()},
+		Message:   "commit: foo\nbar\n",
+	})
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals, ""+
+		"0000000000000000000000000000000000000000 0000000000000000000000000000000000000000 "+
+		"foo <foo@example.com> 0 +0000\tcommit: foo bar\n")
+}
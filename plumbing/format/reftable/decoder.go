@@ -0,0 +1,686 @@
+package reftable
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+)
+
+const (
+	version2      = 2
+	hashIDSize    = 4
+	hashSize      = len(plumbing.ZeroHash)
+	logKeySuffix  = 9
+	footerEntries = 5 * 8
+)
+
+// A Decoder reads tables from an input stream.
+type Decoder struct {
+	r io.Reader
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode reads a whole table into t.
+func (d *Decoder) Decode(t *Table) error {
+	data, err := ioutil.ReadAll(d.r)
+	if err != nil {
+		return err
+	}
+
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+
+	table, err := r.Table()
+	if err != nil {
+		return err
+	}
+
+	*t = *table
+	return nil
+}
+
+// Reader reads the records of a table on demand, reading only the blocks
+// needed. The references and the reflogs of a reference are looked up with
+// the indexes of the table, when it has them, and a binary search over the
+// restart points of the blocks.
+type Reader struct {
+	r                io.ReaderAt
+	end              int64
+	headerSize       int
+	blockSize        int
+	minUpdateIndex   uint64
+	maxUpdateIndex   uint64
+	refIndexPosition int64
+	logPosition      int64
+	logIndexPosition int64
+}
+
+// NewReader returns a new reader of the table of the given size read from
+// r, checking its header and its footer.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	header := make([]byte, headerSize+hashIDSize)
+	if size < int64(len(header)) {
+		header = header[:size]
+	}
+
+	if _, err := r.ReadAt(header, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if len(header) < headerSize || !bytes.Equal(header[:4], magic) {
+		return nil, ErrMalformedTable
+	}
+
+	hdrSize := headerSize
+	switch header[4] {
+	case version:
+	case version2:
+		hdrSize += hashIDSize
+		if len(header) < hdrSize || !bytes.Equal(header[8:12], sha1ID) {
+			return nil, ErrUnsupportedVersion
+		}
+	default:
+		return nil, ErrUnsupportedVersion
+	}
+
+	ftrSize := hdrSize + footerEntries + 4
+	if size < int64(hdrSize+ftrSize) {
+		return nil, ErrMalformedTable
+	}
+
+	end := size - int64(ftrSize)
+	footer := make([]byte, ftrSize)
+	if _, err := r.ReadAt(footer, end); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if !bytes.Equal(footer[:hdrSize], header[:hdrSize]) {
+		return nil, ErrMalformedTable
+	}
+
+	if crc32.ChecksumIEEE(footer[:ftrSize-4]) != binary.BigEndian.Uint32(footer[ftrSize-4:]) {
+		return nil, ErrMalformedTable
+	}
+
+	position := func(off int) (int64, error) {
+		p := binary.BigEndian.Uint64(footer[hdrSize+off:])
+		if p > uint64(end) {
+			return 0, ErrMalformedTable
+		}
+
+		return int64(p), nil
+	}
+
+	rd := &Reader{
+		r:              r,
+		end:            end,
+		headerSize:     hdrSize,
+		blockSize:      int(getUint24(header[5:])),
+		minUpdateIndex: binary.BigEndian.Uint64(header[hdrSize-16:]),
+		maxUpdateIndex: binary.BigEndian.Uint64(header[hdrSize-8:]),
+	}
+
+	var err error
+	if rd.refIndexPosition, err = position(0); err != nil {
+		return nil, err
+	}
+
+	if rd.logPosition, err = position(24); err != nil {
+		return nil, err
+	}
+
+	if rd.logIndexPosition, err = position(32); err != nil {
+		return nil, err
+	}
+
+	return rd, nil
+}
+
+// MinUpdateIndex returns the lowest update index of the records of the
+// table.
+func (r *Reader) MinUpdateIndex() uint64 {
+	return r.minUpdateIndex
+}
+
+// MaxUpdateIndex returns the highest update index of the records of the
+// table.
+func (r *Reader) MaxUpdateIndex() uint64 {
+	return r.maxUpdateIndex
+}
+
+// Ref returns the record of the reference with the given name, nil if the
+// table has none.
+func (r *Reader) Ref(name string) (*RefRecord, error) {
+	var found *RefRecord
+	err := r.scan(0, r.refIndexPosition, refBlockType, []byte(name),
+		func(key []byte, valueType uint64, value []byte) (int, bool, error) {
+			rec, n, err := r.decodeRef(key, valueType, value)
+			if err != nil {
+				return 0, false, err
+			}
+
+			if rec.RefName < name {
+				return n, false, nil
+			}
+
+			if rec.RefName == name {
+				found = rec
+			}
+
+			return n, true, nil
+		})
+
+	return found, err
+}
+
+// Refs returns all the reference records of the table, sorted by name.
+func (r *Reader) Refs() ([]*RefRecord, error) {
+	var refs []*RefRecord
+	err := r.scan(0, 0, refBlockType, nil,
+		func(key []byte, valueType uint64, value []byte) (int, bool, error) {
+			rec, n, err := r.decodeRef(key, valueType, value)
+			if err != nil {
+				return 0, false, err
+			}
+
+			refs = append(refs, rec)
+			return n, false, nil
+		})
+
+	return refs, err
+}
+
+// Logs returns the reflog records of the reference with the given name,
+// the newest first.
+func (r *Reader) Logs(name string) ([]*LogRecord, error) {
+	var logs []*LogRecord
+	err := r.scan(r.logPosition, r.logIndexPosition, logBlockType, []byte(name+"\x00"),
+		func(key []byte, valueType uint64, value []byte) (int, bool, error) {
+			rec, n, err := decodeLog(key, valueType, value)
+			if err != nil {
+				return 0, false, err
+			}
+
+			if rec.RefName < name {
+				return n, false, nil
+			}
+
+			if rec.RefName != name {
+				return n, true, nil
+			}
+
+			logs = append(logs, rec)
+			return n, false, nil
+		})
+
+	return logs, err
+}
+
+// AllLogs returns all the reflog records of the table, sorted by reference
+// name, and then by update index, the newest first.
+func (r *Reader) AllLogs() ([]*LogRecord, error) {
+	var logs []*LogRecord
+	err := r.scan(r.logPosition, 0, logBlockType, nil,
+		func(key []byte, valueType uint64, value []byte) (int, bool, error) {
+			rec, n, err := decodeLog(key, valueType, value)
+			if err != nil {
+				return 0, false, err
+			}
+
+			logs = append(logs, rec)
+			return n, false, nil
+		})
+
+	return logs, err
+}
+
+// Table reads all the records of the table.
+func (r *Reader) Table() (*Table, error) {
+	t := &Table{
+		BlockSize:      uint32(r.blockSize),
+		MinUpdateIndex: r.minUpdateIndex,
+		MaxUpdateIndex: r.maxUpdateIndex,
+	}
+
+	var err error
+	if t.Refs, err = r.Refs(); err != nil {
+		return nil, err
+	}
+
+	if t.Logs, err = r.AllLogs(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// recordFunc is called with the records of a block, returning the length of
+// the value, and true to stop reading.
+type recordFunc func(key []byte, valueType uint64, value []byte) (int, bool, error)
+
+// scan calls fn with the records of the consecutive blocks of the given
+// type starting at first, from the last restart point before key, until fn
+// returns true. The blocks are found with the index at index, unless it is
+// zero.
+func (r *Reader) scan(first, index int64, typ byte, key []byte, fn recordFunc) error {
+	b, err := r.seek(first, index, typ, key)
+	if err != nil || b == nil {
+		return err
+	}
+
+	from, err := b.seek(key)
+	if err != nil {
+		return err
+	}
+
+	for {
+		stop, err := b.records(from, fn)
+		if err != nil || stop || b.next >= r.end {
+			return err
+		}
+
+		if b, err = r.readBlock(b.next); err != nil || b == nil || b.typ != typ {
+			return err
+		}
+
+		from = b.start
+	}
+}
+
+// seek returns the first block of the given type which may hold key,
+// descending the index at index unless it is zero, or else the block at
+// first. It returns nil if there is no such block.
+func (r *Reader) seek(first, index int64, typ byte, key []byte) (*block, error) {
+	if index == 0 {
+		b, err := r.readBlock(first)
+		if err != nil || b == nil || b.typ != typ {
+			return nil, err
+		}
+
+		return b, nil
+	}
+
+	pos := index
+	for {
+		b, err := r.readBlock(pos)
+		if err != nil {
+			return nil, err
+		}
+
+		if b == nil {
+			return nil, ErrMalformedTable
+		}
+
+		if b.typ == typ {
+			return b, nil
+		}
+
+		if b.typ != indexBlockType {
+			return nil, ErrMalformedTable
+		}
+
+		from, err := b.seek(key)
+		if err != nil {
+			return nil, err
+		}
+
+		child := int64(-1)
+		_, err = b.records(from, func(k []byte, _ uint64, value []byte) (int, bool, error) {
+			p, n := readVarint(value)
+			if n == 0 || p >= uint64(r.end) {
+				return 0, false, ErrMalformedTable
+			}
+
+			if bytes.Compare(k, key) < 0 {
+				return n, false, nil
+			}
+
+			child = int64(p)
+			return n, true, nil
+		})
+
+		if err != nil || child < 0 {
+			return nil, err
+		}
+
+		pos = child
+	}
+}
+
+// block is a block of a table, uncompressed.
+type block struct {
+	typ      byte
+	data     []byte
+	start    int
+	end      int
+	restarts []int
+	next     int64
+}
+
+// readBlock reads the block at pos, nil if there is none.
+func (r *Reader) readBlock(pos int64) (*block, error) {
+	headerOff := 0
+	if pos == 0 {
+		headerOff = r.headerSize
+	}
+
+	start := pos + int64(headerOff+blockHeaderSize)
+	if start > r.end {
+		if pos == 0 || pos == r.end {
+			return nil, nil
+		}
+
+		return nil, ErrMalformedTable
+	}
+
+	hdr, err := r.readAt(pos+int64(headerOff), blockHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &block{typ: hdr[0], start: headerOff + blockHeaderSize}
+	blockLen := int(getUint24(hdr[1:]))
+	if blockLen < b.start+2 {
+		return nil, ErrMalformedTable
+	}
+
+	if b.typ != logBlockType {
+		if b.data, err = r.readAt(pos, blockLen); err != nil {
+			return nil, err
+		}
+
+		// the blocks are padded with zeros up to the block size, unless
+		// the table is unaligned
+		b.next = pos + int64(blockLen)
+		if b.next < r.end && r.blockSize > blockLen {
+			next, err := r.readAt(b.next, 1)
+			if err != nil {
+				return nil, err
+			}
+
+			if next[0] == 0 {
+				b.next = pos + int64(r.blockSize)
+			}
+		}
+	} else {
+		cr := &countingReader{r: io.NewSectionReader(r.r, start, r.end-start)}
+		br := bufio.NewReader(cr)
+		zr, err := zlib.NewReader(br)
+		if err != nil {
+			return nil, ErrMalformedTable
+		}
+
+		b.data = make([]byte, blockLen)
+		copy(b.data[headerOff:], hdr)
+		if _, err := io.ReadFull(zr, b.data[b.start:]); err != nil {
+			return nil, ErrMalformedTable
+		}
+
+		// reading up to the end of the stream checks the checksum
+		if n, err := zr.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+			return nil, ErrMalformedTable
+		}
+
+		b.next = start + cr.n - int64(br.Buffered())
+	}
+
+	restarts := int(binary.BigEndian.Uint16(b.data[len(b.data)-2:]))
+	b.end = len(b.data) - 2 - 3*restarts
+	if b.end < b.start {
+		return nil, ErrMalformedTable
+	}
+
+	b.restarts = make([]int, restarts)
+	for i := range b.restarts {
+		off := int(getUint24(b.data[b.end+3*i:]))
+		if off < b.start || off >= b.end {
+			return nil, ErrMalformedTable
+		}
+
+		b.restarts[i] = off
+	}
+
+	return b, nil
+}
+
+// readAt reads n bytes at off, before the footer.
+func (r *Reader) readAt(off int64, n int) ([]byte, error) {
+	if off+int64(n) > r.end {
+		return nil, ErrMalformedTable
+	}
+
+	b := make([]byte, n)
+	if _, err := r.r.ReadAt(b, off); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// seek returns the offset of the last restart point with a key not greater
+// than key, or of the first record.
+func (b *block) seek(key []byte) (int, error) {
+	var err error
+	i := sort.Search(len(b.restarts), func(i int) bool {
+		k, kerr := b.restartKey(i)
+		if kerr != nil {
+			err = kerr
+			return true
+		}
+
+		return bytes.Compare(k, key) > 0
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if i == 0 {
+		return b.start, nil
+	}
+
+	return b.restarts[i-1], nil
+}
+
+// restartKey returns the key of the record at the i-th restart point, which
+// is not prefix compressed.
+func (b *block) restartKey(i int) ([]byte, error) {
+	pos := b.restarts[i]
+	prefix, n := readVarint(b.data[pos:b.end])
+	if n == 0 || prefix != 0 {
+		return nil, ErrMalformedTable
+	}
+
+	pos += n
+	v, n := readVarint(b.data[pos:b.end])
+	if n == 0 {
+		return nil, ErrMalformedTable
+	}
+
+	pos += n
+	suffix := int(v >> suffixLengthShift)
+	if pos+suffix > b.end {
+		return nil, ErrMalformedTable
+	}
+
+	return b.data[pos : pos+suffix], nil
+}
+
+// records calls fn with the records of the block starting at the restart
+// point at from, returning true if fn stopped the reading.
+func (b *block) records(from int, fn recordFunc) (bool, error) {
+	var key []byte
+	pos := from
+	for pos < b.end {
+		prefix, n := readVarint(b.data[pos:b.end])
+		if n == 0 {
+			return false, ErrMalformedTable
+		}
+
+		pos += n
+		v, n := readVarint(b.data[pos:b.end])
+		if n == 0 {
+			return false, ErrMalformedTable
+		}
+
+		pos += n
+		suffix := int(v >> suffixLengthShift)
+		if prefix > uint64(len(key)) || pos+suffix > b.end {
+			return false, ErrMalformedTable
+		}
+
+		key = append(key[:prefix], b.data[pos:pos+suffix]...)
+		pos += suffix
+
+		n, stop, err := fn(key, v&valueTypeMask, b.data[pos:b.end])
+		if err != nil {
+			return false, err
+		}
+
+		pos += n
+		if stop {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// decodeRef decodes a reference record, returning the length of its value.
+func (r *Reader) decodeRef(key []byte, valueType uint64, value []byte) (*RefRecord, int, error) {
+	delta, pos := readVarint(value)
+	if pos == 0 {
+		return nil, 0, ErrMalformedTable
+	}
+
+	rec := &RefRecord{
+		RefName:     string(key),
+		UpdateIndex: r.minUpdateIndex + delta,
+	}
+
+	switch valueType {
+	case refDeletion:
+		rec.Deleted = true
+	case refHash, refHashAndPeeled:
+		size := hashSize
+		if valueType == refHashAndPeeled {
+			size *= 2
+		}
+
+		if pos+size > len(value) {
+			return nil, 0, ErrMalformedTable
+		}
+
+		copy(rec.Hash[:], value[pos:])
+		if valueType == refHashAndPeeled {
+			copy(rec.Peeled[:], value[pos+hashSize:])
+		}
+
+		pos += size
+	case refSymbolic:
+		target, n := readString(value[pos:])
+		if n == 0 {
+			return nil, 0, ErrMalformedTable
+		}
+
+		rec.Target = target
+		pos += n
+	default:
+		return nil, 0, ErrMalformedTable
+	}
+
+	return rec, pos, nil
+}
+
+// decodeLog decodes a reflog record, returning the length of its value.
+func decodeLog(key []byte, valueType uint64, value []byte) (*LogRecord, int, error) {
+	if len(key) < logKeySuffix || key[len(key)-logKeySuffix] != 0 {
+		return nil, 0, ErrMalformedTable
+	}
+
+	rec := &LogRecord{
+		RefName:     string(key[:len(key)-logKeySuffix]),
+		UpdateIndex: ^binary.BigEndian.Uint64(key[len(key)-8:]),
+	}
+
+	switch valueType {
+	case logDeletion:
+		rec.Deleted = true
+		return rec, 0, nil
+	case logUpdate:
+	default:
+		return nil, 0, ErrMalformedTable
+	}
+
+	if len(value) < 2*hashSize {
+		return nil, 0, ErrMalformedTable
+	}
+
+	copy(rec.Old[:], value)
+	copy(rec.New[:], value[hashSize:])
+	pos := 2 * hashSize
+
+	var n int
+	if rec.Committer.Name, n = readString(value[pos:]); n == 0 {
+		return nil, 0, ErrMalformedTable
+	}
+
+	pos += n
+	if rec.Committer.Email, n = readString(value[pos:]); n == 0 {
+		return nil, 0, ErrMalformedTable
+	}
+
+	pos += n
+	ts, n := readVarint(value[pos:])
+	if n == 0 || pos+n+2 > len(value) {
+		return nil, 0, ErrMalformedTable
+	}
+
+	pos += n
+	tz := int(int16(binary.BigEndian.Uint16(value[pos:])))
+	rec.Committer.When = time.Unix(int64(ts), 0).In(time.FixedZone("", tz*60))
+	pos += 2
+
+	if rec.Message, n = readString(value[pos:]); n == 0 {
+		return nil, 0, ErrMalformedTable
+	}
+
+	return rec, pos + n, nil
+}
+
+// readString reads a string prefixed by its length, returning the bytes
+// read, or zero if b is too short.
+func readString(b []byte) (string, int) {
+	l, n := readVarint(b)
+	if n == 0 || uint64(len(b)-n) < l {
+		return "", 0
+	}
+
+	return string(b[n : n+int(l)]), n + int(l)
+}
@@ -0,0 +1,30 @@
+// Package reftable implements encoding and decoding of reftable files.
+//
+// A reftable is a binary file storing references and their reflogs, sorted
+// by name so they can be found without reading the whole file. A repository
+// using the reftable backend keeps a stack of tables, listed in the
+// reftable/tables.list file, every update adding a new table on top of the
+// stack, and the tables are compacted from time to time.
+//
+// A table has the following layout, all the integers in network byte order:
+//
+//	'REFT' uint8(version) uint24(block_size)
+//	uint64(min_update_index) uint64(max_update_index)
+//	ref blocks, padded to block_size
+//	ref index blocks, padded to block_size
+//	log blocks, compressed with zlib
+//	log index blocks, padded to block_size
+//	footer: a copy of the header, uint64(ref_index_position),
+//	uint64(obj_position << 5 | obj_id_len), uint64(obj_index_position),
+//	uint64(log_position), uint64(log_index_position), uint32(CRC-32)
+//
+// Every block starts with its type and uint24(block_len), followed by the
+// records, prefix compressed with the key of the previous one, and ends with
+// the offsets of the records with the full key, the restart points. The
+// index, written when a section has more than one block, holds the last key
+// of every block, so a Reader finds the block of a key descending the index
+// and searching the restart points, without reading the other blocks. The
+// optional object blocks are never written.
+//
+// More information at https://git-scm.com/docs/reftable
+package reftable
@@ -0,0 +1,321 @@
+package reftable
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+// An Encoder writes tables to an output stream.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the given table. The records of the table must be sorted,
+// see Table.Sort, and their update indexes must be in the range of the table.
+func (e *Encoder) Encode(t *Table) error {
+	blockSize := t.BlockSize
+	if blockSize == 0 {
+		blockSize = DefaultBlockSize
+	}
+
+	if blockSize > maxBlockSize || blockSize < headerSize+blockHeaderSize+footerSize {
+		return ErrMalformedTable
+	}
+
+	if t.MinUpdateIndex > t.MaxUpdateIndex {
+		return ErrMalformedTable
+	}
+
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	header[4] = version
+	putUint24(header[5:], blockSize)
+	binary.BigEndian.PutUint64(header[8:], t.MinUpdateIndex)
+	binary.BigEndian.PutUint64(header[16:], t.MaxUpdateIndex)
+
+	w := &tableWriter{header: header, blockSize: int(blockSize)}
+	if err := w.writeRefs(t); err != nil {
+		return err
+	}
+
+	refIndexPosition, err := w.writeIndex()
+	if err != nil {
+		return err
+	}
+
+	logPosition := uint64(len(w.out))
+	if err := w.writeLogs(t); err != nil {
+		return err
+	}
+
+	logIndexPosition, err := w.writeIndex()
+	if err != nil {
+		return err
+	}
+
+	if len(w.out) == 0 {
+		w.out = append(w.out, header...)
+	}
+
+	footer := make([]byte, footerSize)
+	copy(footer, header)
+	binary.BigEndian.PutUint64(footer[headerSize:], refIndexPosition)
+	binary.BigEndian.PutUint64(footer[headerSize+24:], logPosition)
+	binary.BigEndian.PutUint64(footer[headerSize+32:], logIndexPosition)
+	binary.BigEndian.PutUint32(footer[footerSize-4:], crc32.ChecksumIEEE(footer[:footerSize-4]))
+
+	if _, err := e.w.Write(w.out); err != nil {
+		return err
+	}
+
+	_, err = e.w.Write(footer)
+	return err
+}
+
+// tableWriter lays out the blocks of a table, the first one sharing its
+// space with the file header, keeping the last key and the position of the
+// blocks written to index them.
+type tableWriter struct {
+	header    []byte
+	blockSize int
+	out       []byte
+	block     *blockWriter
+	blocks    []indexEntry
+}
+
+// indexEntry is the last key of a block, and its position.
+type indexEntry struct {
+	key      []byte
+	position uint64
+}
+
+func (w *tableWriter) writeRefs(t *Table) error {
+	var last string
+	for i, r := range t.Refs {
+		if i > 0 && r.RefName <= last {
+			return ErrMalformedTable
+		}
+
+		if r.UpdateIndex < t.MinUpdateIndex || r.UpdateIndex > t.MaxUpdateIndex {
+			return ErrMalformedTable
+		}
+
+		last = r.RefName
+		value := appendVarint(nil, r.UpdateIndex-t.MinUpdateIndex)
+		switch r.valueType() {
+		case refHash:
+			value = append(value, r.Hash[:]...)
+		case refHashAndPeeled:
+			value = append(value, r.Hash[:]...)
+			value = append(value, r.Peeled[:]...)
+		case refSymbolic:
+			value = appendVarint(value, uint64(len(r.Target)))
+			value = append(value, r.Target...)
+		}
+
+		if err := w.add(refBlockType, []byte(r.RefName), r.valueType(), value); err != nil {
+			return err
+		}
+	}
+
+	return w.flush()
+}
+
+func (w *tableWriter) writeLogs(t *Table) error {
+	for i, r := range t.Logs {
+		if i > 0 && !t.Logs[i-1].less(r) {
+			return ErrMalformedTable
+		}
+
+		var value []byte
+		typ := uint64(logDeletion)
+		if !r.Deleted {
+			typ = logUpdate
+			value = append(value, r.Old[:]...)
+			value = append(value, r.New[:]...)
+			value = appendVarint(value, uint64(len(r.Committer.Name)))
+			value = append(value, r.Committer.Name...)
+			value = appendVarint(value, uint64(len(r.Committer.Email)))
+			value = append(value, r.Committer.Email...)
+			value = appendVarint(value, uint64(r.Committer.When.Unix()))
+			_, offset := r.Committer.When.Zone()
+			value = append(value, byte(int16(offset/60)>>8), byte(int16(offset/60)))
+			value = appendVarint(value, uint64(len(r.Message)))
+			value = append(value, r.Message...)
+		}
+
+		if err := w.add(logBlockType, r.key(), typ, value); err != nil {
+			return err
+		}
+	}
+
+	return w.flush()
+}
+
+// writeIndex writes the index of the blocks written since the last call, if
+// there are more than one, returning the position of its root block, or
+// zero. The index records point to the blocks by their last key, and the
+// index is split in several levels while it doesn't fit in a block.
+func (w *tableWriter) writeIndex() (uint64, error) {
+	entries := w.blocks
+	w.blocks = nil
+	if len(entries) < 2 {
+		return 0, nil
+	}
+
+	for {
+		for _, e := range entries {
+			if err := w.add(indexBlockType, e.key, 0, appendVarint(nil, e.position)); err != nil {
+				return 0, err
+			}
+		}
+
+		if err := w.flush(); err != nil {
+			return 0, err
+		}
+
+		entries, w.blocks = w.blocks, nil
+		if len(entries) == 1 {
+			return entries[0].position, nil
+		}
+	}
+}
+
+// add adds a record to the current block, starting a new one if it is full.
+func (w *tableWriter) add(typ byte, key []byte, valueType uint64, value []byte) error {
+	if w.block != nil && w.block.add(key, valueType, value) {
+		return nil
+	}
+
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	var prefix []byte
+	if len(w.out) == 0 {
+		prefix = w.header
+	}
+
+	w.block = newBlockWriter(typ, prefix, w.blockSize)
+	if !w.block.add(key, valueType, value) {
+		return ErrRecordTooLarge
+	}
+
+	return nil
+}
+
+// flush writes the current block, if any.
+func (w *tableWriter) flush() error {
+	if w.block == nil {
+		return nil
+	}
+
+	b, err := w.block.finish()
+	if err != nil {
+		return err
+	}
+
+	w.blocks = append(w.blocks, indexEntry{
+		key:      w.block.lastKey,
+		position: uint64(len(w.out)),
+	})
+
+	w.out = append(w.out, b...)
+	w.block = nil
+	return nil
+}
+
+type blockWriter struct {
+	typ       byte
+	headerOff int
+	blockSize int
+	buf       []byte
+	restarts  []uint32
+	entries   int
+	lastKey   []byte
+}
+
+func newBlockWriter(typ byte, prefix []byte, blockSize int) *blockWriter {
+	buf := make([]byte, 0, blockSize)
+	buf = append(buf, prefix...)
+	buf = append(buf, typ, 0, 0, 0)
+
+	return &blockWriter{
+		typ:       typ,
+		headerOff: len(prefix),
+		blockSize: blockSize,
+		buf:       buf,
+	}
+}
+
+// add appends a record to the block, returning false if it doesn't fit.
+func (b *blockWriter) add(key []byte, valueType uint64, value []byte) bool {
+	restart := b.entries%restartInterval == 0
+	prefix := 0
+	if !restart {
+		for prefix < len(key) && prefix < len(b.lastKey) && key[prefix] == b.lastKey[prefix] {
+			prefix++
+		}
+	}
+
+	rec := appendVarint(nil, uint64(prefix))
+	rec = appendVarint(rec, uint64(len(key)-prefix)<<suffixLengthShift|valueType)
+	rec = append(rec, key[prefix:]...)
+	rec = append(rec, value...)
+
+	restarts := len(b.restarts)
+	if restart {
+		restarts++
+	}
+
+	if len(b.buf)+len(rec)+3*restarts+2 > b.blockSize {
+		return false
+	}
+
+	if restart {
+		b.restarts = append(b.restarts, uint32(len(b.buf)))
+	}
+
+	b.buf = append(b.buf, rec...)
+	b.lastKey = append(b.lastKey[:0], key...)
+	b.entries++
+	return true
+}
+
+// finish returns the encoded block, the ref blocks padded to the block size,
+// and the log blocks compressed.
+func (b *blockWriter) finish() ([]byte, error) {
+	var u24 [3]byte
+	for _, r := range b.restarts {
+		putUint24(u24[:], r)
+		b.buf = append(b.buf, u24[:]...)
+	}
+
+	b.buf = append(b.buf, byte(len(b.restarts)>>8), byte(len(b.restarts)))
+	putUint24(b.buf[b.headerOff+1:], uint32(len(b.buf)))
+
+	if b.typ != logBlockType {
+		return append(b.buf, make([]byte, b.blockSize-len(b.buf))...), nil
+	}
+
+	start := b.headerOff + blockHeaderSize
+	out := bytes.NewBuffer(append([]byte(nil), b.buf[:start]...))
+	zw := zlib.NewWriter(out)
+	if _, err := zw.Write(b.buf[start:]); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
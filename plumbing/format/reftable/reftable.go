@@ -0,0 +1,216 @@
+package reftable
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/format/reflog"
+)
+
+var (
+	// ErrMalformedTable is returned when a table can not be decoded.
+	ErrMalformedTable = errors.New("malformed reftable")
+	// ErrUnsupportedVersion is returned when a table has a version, or uses
+	// a hash function, not supported.
+	ErrUnsupportedVersion = errors.New("unsupported reftable version")
+	// ErrRecordTooLarge is returned when a record doesn't fit in a block.
+	ErrRecordTooLarge = errors.New("reftable record larger than the block size")
+)
+
+const (
+	// DefaultBlockSize is the block size of the encoded tables, when the
+	// table doesn't set one.
+	DefaultBlockSize = 4096
+
+	version    = 1
+	headerSize = 24
+	footerSize = headerSize + 5*8 + 4
+
+	blockHeaderSize = 4
+	restartInterval = 16
+	maxBlockSize    = 1<<24 - 1
+
+	refBlockType      = 'r'
+	logBlockType      = 'g'
+	indexBlockType    = 'i'
+	objectBlockType   = 'o'
+	refDeletion       = 0
+	refHash           = 1
+	refHashAndPeeled  = 2
+	refSymbolic       = 3
+	logDeletion       = 0
+	logUpdate         = 1
+	valueTypeMask     = 7
+	suffixLengthShift = 3
+)
+
+var (
+	magic  = []byte("REFT")
+	sha1ID = []byte("sha1")
+)
+
+// Table is the content of a reftable file.
+type Table struct {
+	// BlockSize is the size of the blocks of the table, DefaultBlockSize is
+	// used to encode the table if it is zero.
+	BlockSize uint32
+	// MinUpdateIndex and MaxUpdateIndex are the range of update indexes of
+	// the records of the table.
+	MinUpdateIndex uint64
+	MaxUpdateIndex uint64
+	// Refs are the references of the table, sorted by name.
+	Refs []*RefRecord
+	// Logs are the reflog entries of the table, sorted by reference name,
+	// and then by update index, the newest first.
+	Logs []*LogRecord
+}
+
+// Sort sorts the records of the table in the order they are encoded.
+func (t *Table) Sort() {
+	sort.SliceStable(t.Refs, func(i, j int) bool {
+		return t.Refs[i].RefName < t.Refs[j].RefName
+	})
+
+	sort.SliceStable(t.Logs, func(i, j int) bool {
+		return t.Logs[i].less(t.Logs[j])
+	})
+}
+
+// RefRecord is the value of a reference at a given update index.
+type RefRecord struct {
+	// RefName is the full name of the reference.
+	RefName string
+	// UpdateIndex is the update index of the last change of the reference.
+	UpdateIndex uint64
+	// Deleted is true if the record removes the reference.
+	Deleted bool
+	// Hash is the object the reference points to, unless it is a symbolic
+	// reference.
+	Hash plumbing.Hash
+	// Peeled is the object an annotated tag points to, if known.
+	Peeled plumbing.Hash
+	// Target is the name of the reference a symbolic reference points to.
+	Target string
+}
+
+// NewRefRecord returns the record storing the given reference.
+func NewRefRecord(ref *plumbing.Reference, updateIndex uint64) *RefRecord {
+	r := &RefRecord{RefName: ref.Name().String(), UpdateIndex: updateIndex}
+	if ref.Type() == plumbing.SymbolicReference {
+		r.Target = ref.Target().String()
+	} else {
+		r.Hash = ref.Hash()
+	}
+
+	return r
+}
+
+// Reference returns the reference stored by the record, nil if the record
+// is a deletion.
+func (r *RefRecord) Reference() *plumbing.Reference {
+	if r.Deleted {
+		return nil
+	}
+
+	name := plumbing.ReferenceName(r.RefName)
+	if r.Target != "" {
+		return plumbing.NewSymbolicReference(name, plumbing.ReferenceName(r.Target))
+	}
+
+	return plumbing.NewHashReference(name, r.Hash)
+}
+
+func (r *RefRecord) valueType() uint64 {
+	switch {
+	case r.Deleted:
+		return refDeletion
+	case r.Target != "":
+		return refSymbolic
+	case !r.Peeled.IsZero():
+		return refHashAndPeeled
+	default:
+		return refHash
+	}
+}
+
+// LogRecord is an entry of the reflog of a reference.
+type LogRecord struct {
+	// RefName is the full name of the reference.
+	RefName string
+	// UpdateIndex is the update index of the entry, the entries of a
+	// reference are sorted by it.
+	UpdateIndex uint64
+	// Deleted is true if the record removes the entry with the same name
+	// and update index in the older tables.
+	Deleted bool
+	// Old and New are the values of the reference before and after the
+	// update.
+	Old plumbing.Hash
+	New plumbing.Hash
+	// Committer is who updated the reference, and when.
+	Committer reflog.Signature
+	// Message describes the update.
+	Message string
+}
+
+func (r *LogRecord) less(o *LogRecord) bool {
+	if r.RefName != o.RefName {
+		return r.RefName < o.RefName
+	}
+
+	return r.UpdateIndex > o.UpdateIndex
+}
+
+func (r *LogRecord) key() []byte {
+	key := make([]byte, len(r.RefName)+9)
+	copy(key, r.RefName)
+	binary.BigEndian.PutUint64(key[len(r.RefName)+1:], ^r.UpdateIndex)
+	return key
+}
+
+// appendVarint appends v encoded in the variable width format of git, the one
+// used for the offsets of the deltas in the packfiles.
+func appendVarint(b []byte, v uint64) []byte {
+	var buf [10]byte
+	pos := len(buf) - 1
+	buf[pos] = byte(v & 0x7f)
+	for v >>= 7; v != 0; v >>= 7 {
+		v--
+		pos--
+		buf[pos] = 0x80 | byte(v&0x7f)
+	}
+
+	return append(b, buf[pos:]...)
+}
+
+// readVarint decodes a variable width integer, returning the bytes read, or
+// zero if b is too short.
+func readVarint(b []byte) (uint64, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+
+	c := b[0]
+	v, n := uint64(c&0x7f), 1
+	for c&0x80 != 0 {
+		if n >= len(b) || n > 9 {
+			return 0, 0
+		}
+
+		c = b[n]
+		n++
+		v = (v+1)<<7 | uint64(c&0x7f)
+	}
+
+	return v, n
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0], b[1], b[2] = byte(v>>16), byte(v>>8), byte(v)
+}
+
+func getUint24(b []byte) uint32 {
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+}
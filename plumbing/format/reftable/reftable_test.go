@@ -0,0 +1,260 @@
+package reftable
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/format/reflog"
+	"github.com/goabstract/go-git/v5/utils/binary"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ReftableSuite struct{}
+
+var _ = Suite(&ReftableSuite{})
+
+func (s *ReftableSuite) TestVarint(c *C) {
+	for _, v := range []uint64{0, 1, 127, 128, 255, 16383, 16384, 1 << 32, 1<<63 + 5} {
+		b := appendVarint(nil, v)
+		if v < 1<<63 {
+			var buf bytes.Buffer
+			c.Assert(binary.WriteVariableWidthInt(&buf, int64(v)), IsNil)
+			c.Assert(b, DeepEquals, buf.Bytes(), Commentf("%d", v))
+		}
+
+		got, n := readVarint(b)
+		c.Assert(n, Equals, len(b))
+		c.Assert(got, Equals, v)
+	}
+
+	_, n := readVarint([]byte{0x80})
+	c.Assert(n, Equals, 0)
+}
+
+func (s *ReftableSuite) TestEncodeAndDecode(c *C) {
+	when := time.Unix(1494108223, 0).In(time.FixedZone("", -(2*60+30)*60))
+	t := &Table{
+		BlockSize:      256,
+		MinUpdateIndex: 4,
+		MaxUpdateIndex: 9,
+	}
+
+	for i := 0; i < 200; i++ {
+		h := plumbing.ComputeHash(plumbing.BlobObject, []byte(fmt.Sprint(i)))
+		t.Refs = append(t.Refs, &RefRecord{
+			RefName:     fmt.Sprintf("refs/heads/branch-%03d", i),
+			UpdateIndex: 4 + uint64(i%6),
+			Hash:        h,
+		})
+
+		t.Logs = append(t.Logs, &LogRecord{
+			RefName:     fmt.Sprintf("refs/heads/branch-%03d", i),
+			UpdateIndex: 4 + uint64(i%6),
+			New:         h,
+			Committer:   reflog.Signature{Name: "foo", Email: "foo@foo.foo", When: when},
+			Message:     "branch: Created from HEAD\n",
+		})
+	}
+
+	tag := plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47")
+	t.Refs = append(t.Refs,
+		&RefRecord{RefName: "HEAD", UpdateIndex: 9, Target: "refs/heads/branch-000"},
+		&RefRecord{RefName: "refs/tags/v1.0.0", UpdateIndex: 5, Hash: tag, Peeled: t.Refs[0].Hash},
+		&RefRecord{RefName: "refs/heads/deleted", UpdateIndex: 9, Deleted: true},
+	)
+
+	t.Logs = append(t.Logs,
+		&LogRecord{RefName: "refs/heads/branch-000", UpdateIndex: 9, Deleted: true},
+		&LogRecord{RefName: "HEAD", UpdateIndex: 9, Old: t.Refs[1].Hash, New: t.Refs[0].Hash, Message: "checkout"},
+	)
+
+	t.Sort()
+
+	var buf bytes.Buffer
+	c.Assert(NewEncoder(&buf).Encode(t), IsNil)
+
+	decoded := &Table{}
+	c.Assert(NewDecoder(&buf).Decode(decoded), IsNil)
+	c.Assert(decoded.BlockSize, Equals, uint32(256))
+	c.Assert(decoded.MinUpdateIndex, Equals, uint64(4))
+	c.Assert(decoded.MaxUpdateIndex, Equals, uint64(9))
+	c.Assert(decoded.Refs, DeepEquals, t.Refs)
+	c.Assert(decoded.Logs, HasLen, len(t.Logs))
+
+	for i, l := range decoded.Logs {
+		c.Assert(l.RefName, Equals, t.Logs[i].RefName)
+		c.Assert(l.UpdateIndex, Equals, t.Logs[i].UpdateIndex)
+		c.Assert(l.Deleted, Equals, t.Logs[i].Deleted)
+		c.Assert(l.Old, Equals, t.Logs[i].Old)
+		c.Assert(l.New, Equals, t.Logs[i].New)
+		c.Assert(l.Message, Equals, t.Logs[i].Message)
+		c.Assert(l.Committer.Name, Equals, t.Logs[i].Committer.Name)
+		c.Assert(l.Committer.Email, Equals, t.Logs[i].Committer.Email)
+		c.Assert(l.Committer.When.Unix(), Equals, t.Logs[i].Committer.When.Unix())
+
+		_, offset := l.Committer.When.Zone()
+		_, expected := t.Logs[i].Committer.When.Zone()
+		c.Assert(offset, Equals, expected)
+	}
+}
+
+func (s *ReftableSuite) TestEncodeAndDecodeOnlyLogs(c *C) {
+	t := &Table{
+		MinUpdateIndex: 1,
+		MaxUpdateIndex: 1,
+		Logs: []*LogRecord{
+			{RefName: "refs/heads/master", UpdateIndex: 1, Message: "foo"},
+		},
+	}
+
+	var buf bytes.Buffer
+	c.Assert(NewEncoder(&buf).Encode(t), IsNil)
+
+	decoded := &Table{}
+	c.Assert(NewDecoder(&buf).Decode(decoded), IsNil)
+	c.Assert(decoded.Refs, HasLen, 0)
+	c.Assert(decoded.Logs, HasLen, 1)
+	c.Assert(decoded.Logs[0].Message, Equals, "foo")
+}
+
+func (s *ReftableSuite) TestEncodeAndDecodeEmpty(c *C) {
+	var buf bytes.Buffer
+	c.Assert(NewEncoder(&buf).Encode(&Table{MinUpdateIndex: 1, MaxUpdateIndex: 1}), IsNil)
+	c.Assert(buf.Len(), Equals, headerSize+footerSize)
+
+	decoded := &Table{}
+	c.Assert(NewDecoder(&buf).Decode(decoded), IsNil)
+	c.Assert(decoded.Refs, HasLen, 0)
+	c.Assert(decoded.Logs, HasLen, 0)
+	c.Assert(decoded.MaxUpdateIndex, Equals, uint64(1))
+}
+
+func (s *ReftableSuite) TestEncodeUnsorted(c *C) {
+	t := &Table{
+		Refs: []*RefRecord{
+			{RefName: "refs/heads/b", Hash: plumbing.ZeroHash},
+			{RefName: "refs/heads/a", Hash: plumbing.ZeroHash},
+		},
+	}
+
+	c.Assert(NewEncoder(&bytes.Buffer{}).Encode(t), Equals, ErrMalformedTable)
+
+	t.Sort()
+	c.Assert(NewEncoder(&bytes.Buffer{}).Encode(t), IsNil)
+}
+
+func (s *ReftableSuite) TestEncodeRecordTooLarge(c *C) {
+	t := &Table{
+		BlockSize: 128,
+		Refs: []*RefRecord{
+			{RefName: "HEAD", Target: string(bytes.Repeat([]byte("a"), 200))},
+		},
+	}
+
+	c.Assert(NewEncoder(&bytes.Buffer{}).Encode(t), Equals, ErrRecordTooLarge)
+}
+
+func (s *ReftableSuite) TestDecodeMalformed(c *C) {
+	var buf bytes.Buffer
+	t := &Table{Refs: []*RefRecord{{RefName: "HEAD", Target: "refs/heads/master"}}}
+	c.Assert(NewEncoder(&buf).Encode(t), IsNil)
+
+	data := buf.Bytes()
+	data[len(data)-1]++
+	c.Assert(NewDecoder(bytes.NewReader(data)).Decode(&Table{}), Equals, ErrMalformedTable)
+
+	data[len(data)-1]--
+	data[4] = 3
+	c.Assert(NewDecoder(bytes.NewReader(data)).Decode(&Table{}), Equals, ErrUnsupportedVersion)
+
+	c.Assert(NewDecoder(bytes.NewReader([]byte("REFT"))).Decode(&Table{}), Equals, ErrMalformedTable)
+}
+
+func (s *ReftableSuite) TestReader(c *C) {
+	t := &Table{BlockSize: 256, MinUpdateIndex: 1, MaxUpdateIndex: 2}
+	for i := 0; i < 1000; i++ {
+		name := fmt.Sprintf("refs/heads/branch-%04d", i)
+		h := plumbing.ComputeHash(plumbing.BlobObject, []byte(name))
+		t.Refs = append(t.Refs, &RefRecord{RefName: name, UpdateIndex: 1, Hash: h})
+		t.Logs = append(t.Logs,
+			&LogRecord{RefName: name, UpdateIndex: 2, Old: h, Message: "second"},
+			&LogRecord{RefName: name, UpdateIndex: 1, New: h, Message: "first"},
+		)
+	}
+
+	var buf bytes.Buffer
+	c.Assert(NewEncoder(&buf).Encode(t), IsNil)
+
+	ra := &countingReaderAt{r: bytes.NewReader(buf.Bytes())}
+	r, err := NewReader(ra, int64(buf.Len()))
+	c.Assert(err, IsNil)
+	c.Assert(r.refIndexPosition, Not(Equals), int64(0))
+	c.Assert(r.logIndexPosition, Not(Equals), int64(0))
+
+	for _, i := range []int{0, 1, 15, 16, 17, 500, 998, 999} {
+		ra.n = 0
+		ref, err := r.Ref(t.Refs[i].RefName)
+		c.Assert(err, IsNil)
+		c.Assert(ref, DeepEquals, t.Refs[i])
+		c.Assert(ra.n < int64(buf.Len()/10), Equals, true, Commentf("read %d bytes", ra.n))
+
+		logs, err := r.Logs(t.Refs[i].RefName)
+		c.Assert(err, IsNil)
+		c.Assert(logs, HasLen, 2)
+		c.Assert(logs[0].Message, Equals, "second")
+		c.Assert(logs[1].Message, Equals, "first")
+	}
+
+	for _, name := range []string{"HEAD", "refs/heads/branch-0500a", "refs/heads/z"} {
+		ref, err := r.Ref(name)
+		c.Assert(err, IsNil)
+		c.Assert(ref, IsNil)
+
+		logs, err := r.Logs(name)
+		c.Assert(err, IsNil)
+		c.Assert(logs, HasLen, 0)
+	}
+
+	refs, err := r.Refs()
+	c.Assert(err, IsNil)
+	c.Assert(refs, DeepEquals, t.Refs)
+
+	logs, err := r.AllLogs()
+	c.Assert(err, IsNil)
+	c.Assert(logs, HasLen, len(t.Logs))
+}
+
+func (s *ReftableSuite) TestReaderOnlyRefs(c *C) {
+	var buf bytes.Buffer
+	t := &Table{Refs: []*RefRecord{{RefName: "HEAD", Target: "refs/heads/master"}}}
+	c.Assert(NewEncoder(&buf).Encode(t), IsNil)
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	c.Assert(err, IsNil)
+	c.Assert(r.refIndexPosition, Equals, int64(0))
+
+	ref, err := r.Ref("HEAD")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Target, Equals, "refs/heads/master")
+
+	logs, err := r.Logs("HEAD")
+	c.Assert(err, IsNil)
+	c.Assert(logs, HasLen, 0)
+}
+
+type countingReaderAt struct {
+	r *bytes.Reader
+	n int64
+}
+
+func (r *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.r.ReadAt(p, off)
+	r.n += int64(n)
+	return n, err
+}
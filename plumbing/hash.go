@@ -32,6 +32,17 @@ func NewHash(s string) Hash {
 	return h
 }
 
+// IsHash returns true if the given string is a valid hexadecimal
+// representation of a full Hash.
+func IsHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
 func (h Hash) IsZero() bool {
 	var empty Hash
 	return h == empty
@@ -34,6 +34,12 @@ func (s *HashSuite) TestIsZero(c *C) {
 	c.Assert(hash.IsZero(), Equals, false)
 }
 
+func (s *HashSuite) TestIsHash(c *C) {
+	c.Assert(IsHash("8ab686eafeb1f44702738c8b0f24f2567c36da6d"), Equals, true)
+	c.Assert(IsHash("8ab686eafeb1f44702738c8b0f24f2567c36da6"), Equals, false)
+	c.Assert(IsHash("8ab686eafeb1f44702738c8b0f24f2567c36dzz"), Equals, false)
+}
+
 func (s *HashSuite) TestNewHasher(c *C) {
 	content := "hasher test sample"
 	hasher := NewHasher(BlobObject, int64(len(content)))
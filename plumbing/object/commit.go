@@ -46,6 +46,10 @@ type Commit struct {
 	TreeHash plumbing.Hash
 	// ParentHashes are the hashes of the parent commits of the commit.
 	ParentHashes []plumbing.Hash
+	// Notes attached to the commit, shown by String. They are not part of the
+	// encoded commit and are only filled when requested, as with the
+	// ShowNotes option of git.LogOptions.
+	Notes []*Note
 
 	s storer.EncodedObjectStorer
 }
@@ -336,11 +340,17 @@ func (c *Commit) StatsContext(ctx context.Context) (FileStats, error) {
 }
 
 func (c *Commit) String() string {
-	return fmt.Sprintf(
+	s := fmt.Sprintf(
 		"%s %s\nAuthor: %s\nDate:   %s\n\n%s\n",
 		plumbing.CommitObject, c.Hash, c.Author.String(),
 		c.Author.When.Format(DateFormat), indent(c.Message),
 	)
+
+	for _, n := range c.Notes {
+		s += n.String()
+	}
+
+	return s
 }
 
 // Verify performs PGP verification of the commit with a provided armored
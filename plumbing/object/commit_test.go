@@ -245,6 +245,29 @@ func (s *SuiteCommit) TestString(c *C) {
 	)
 }
 
+func (s *SuiteCommit) TestStringWithNotes(c *C) {
+	commit := *s.Commit
+	commit.Notes = []*Note{
+		{Ref: "refs/notes/commits", Message: "build passed\n"},
+		{Ref: "refs/notes/ci", Message: "coverage: 80%\n"},
+	}
+
+	c.Assert(commit.String(), Equals, ""+
+		"commit 1669dce138d9b841a518c64b10914d88f5e488ea\n"+
+		"Author: Máximo Cuadros Ortiz <mcuadros@gmail.com>\n"+
+		"Date:   Tue Mar 31 13:48:14 2015 +0200\n"+
+		"\n"+
+		"    Merge branch 'master' of github.com:tyba/git-fixture\n"+
+		"\n"+
+		"Notes:\n"+
+		"    build passed\n"+
+		"\n"+
+		"Notes (ci):\n"+
+		"    coverage: 80%\n"+
+		"\n",
+	)
+}
+
 func (s *SuiteCommit) TestStringMultiLine(c *C) {
 	hash := plumbing.NewHash("e7d896db87294e33ca3202e536d4d9bb16023db3")
 
@@ -0,0 +1,270 @@
+package commitgraph
+
+import (
+	"github.com/emirpasic/gods/trees/binaryheap"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+)
+
+// Reachability answers reachability queries between commits, such as which
+// of many commits contain another one in their history, or how many commits
+// a branch is ahead and behind of another. The generation numbers of the
+// commit-graph are used, when the CommitNodeIndex provides them, to stop the
+// walks as soon as the commits can not be reachable anymore.
+//
+// The results are cached, so running many queries over the same history with
+// the same Reachability is cheaper than running them one by one.
+type Reachability struct {
+	index    CommitNodeIndex
+	nodes    map[plumbing.Hash]CommitNode
+	contains map[plumbing.Hash]map[plumbing.Hash]bool
+}
+
+// NewReachability returns a Reachability for the commits of the given index.
+func NewReachability(index CommitNodeIndex) *Reachability {
+	return &Reachability{
+		index:    index,
+		nodes:    make(map[plumbing.Hash]CommitNode),
+		contains: make(map[plumbing.Hash]map[plumbing.Hash]bool),
+	}
+}
+
+// Contains returns true if the commit h is reachable from the commit from,
+// or is the same commit, as `git merge-base --is-ancestor h from` does.
+//
+// The history walked is cached for every h, so asking if the same commit is
+// contained in many others, as `git tag --contains` does, walks every commit
+// at most once.
+func (r *Reachability) Contains(from, h plumbing.Hash) (bool, error) {
+	target, err := r.node(h)
+	if err != nil {
+		return false, err
+	}
+
+	memo, ok := r.contains[h]
+	if !ok {
+		memo = map[plumbing.Hash]bool{h: true}
+		r.contains[h] = memo
+	}
+
+	stack := []plumbing.Hash{from}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		if _, ok := memo[current]; ok {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		node, err := r.node(current)
+		if err == plumbing.ErrObjectNotFound {
+			// the history of shallow repositories is incomplete
+			memo[current] = false
+			continue
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		// commits with an older generation can not reach the target
+		if node.Generation() < target.Generation() {
+			memo[current] = false
+			continue
+		}
+
+		found, pending := false, false
+		for _, p := range node.ParentHashes() {
+			contained, ok := memo[p]
+			if !ok {
+				stack = append(stack, p)
+				pending = true
+				continue
+			}
+
+			if contained {
+				found = true
+				break
+			}
+		}
+
+		if pending && !found {
+			continue
+		}
+
+		memo[current] = found
+	}
+
+	return memo[from], nil
+}
+
+// ReachableFrom returns which of the given commits are reachable from the
+// commit from, as `git branch --merged from` does with the tips of the
+// branches. The walk stops once all of them are found, or the generation
+// numbers prove the rest are not reachable.
+func (r *Reachability) ReachableFrom(from plumbing.Hash, hashes []plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	result := make(map[plumbing.Hash]bool, len(hashes))
+	pending := make(map[plumbing.Hash]bool, len(hashes))
+	var minGeneration uint64 = 1<<64 - 1
+	for _, h := range hashes {
+		result[h] = false
+		node, err := r.node(h)
+		if err != nil {
+			return nil, err
+		}
+
+		pending[h] = true
+		if node.Generation() < minGeneration {
+			minGeneration = node.Generation()
+		}
+	}
+
+	seen := make(map[plumbing.Hash]bool)
+	stack := []plumbing.Hash{from}
+	for len(stack) > 0 && len(pending) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[current] {
+			continue
+		}
+
+		seen[current] = true
+		if pending[current] {
+			result[current] = true
+			delete(pending, current)
+		}
+
+		node, err := r.node(current)
+		if err == plumbing.ErrObjectNotFound {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if node.Generation() < minGeneration {
+			continue
+		}
+
+		stack = append(stack, node.ParentHashes()...)
+	}
+
+	return result, nil
+}
+
+const (
+	aheadFlag uint8 = 1 << iota
+	behindFlag
+	commonFlags = aheadFlag | behindFlag
+)
+
+// AheadBehind returns the number of commits reachable from the commit local
+// but not from upstream, and the number of commits reachable from upstream
+// but not from local, as `git rev-list --left-right --count local...upstream`
+// does.
+func (r *Reachability) AheadBehind(local, upstream plumbing.Hash) (ahead, behind int, err error) {
+	flags := make(map[plumbing.Hash]uint8)
+	queued := make(map[plumbing.Hash]bool)
+	heap := binaryheap.NewWith(compareNodes)
+
+	// active is the number of queued commits not known to be common yet, the
+	// walk stops when there is none
+	var active int
+	mark := func(h plumbing.Hash, f uint8) error {
+		old, seen := flags[h]
+		flags[h] = old | f
+		if seen {
+			if queued[h] && old != commonFlags && old|f == commonFlags {
+				active--
+			}
+
+			return nil
+		}
+
+		node, err := r.node(h)
+		if err == plumbing.ErrObjectNotFound {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		heap.Push(node)
+		queued[h] = true
+		if f != commonFlags {
+			active++
+		}
+
+		return nil
+	}
+
+	if err := mark(local, aheadFlag); err != nil {
+		return 0, 0, err
+	}
+
+	if err := mark(upstream, behindFlag); err != nil {
+		return 0, 0, err
+	}
+
+	for active > 0 {
+		v, ok := heap.Pop()
+		if !ok {
+			break
+		}
+
+		node := v.(CommitNode)
+		queued[node.ID()] = false
+
+		f := flags[node.ID()]
+		switch f {
+		case aheadFlag:
+			ahead++
+			active--
+		case behindFlag:
+			behind++
+			active--
+		}
+
+		for _, p := range node.ParentHashes() {
+			if err := mark(p, f); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	return ahead, behind, nil
+}
+
+// compareNodes sorts the commits by generation, and then by commit time, the
+// most recent first, so every commit is visited after its descendants.
+func compareNodes(a, b interface{}) int {
+	na, nb := a.(CommitNode), b.(CommitNode)
+	if na.Generation() != nb.Generation() {
+		if na.Generation() > nb.Generation() {
+			return -1
+		}
+
+		return 1
+	}
+
+	if na.CommitTime().Before(nb.CommitTime()) {
+		return 1
+	}
+
+	return -1
+}
+
+func (r *Reachability) node(h plumbing.Hash) (CommitNode, error) {
+	if node, ok := r.nodes[h]; ok {
+		return node, nil
+	}
+
+	node, err := r.index.Get(h)
+	if err != nil {
+		return nil, err
+	}
+
+	r.nodes[h] = node
+	return node, nil
+}
@@ -0,0 +1,113 @@
+package commitgraph
+
+import (
+	"path"
+
+	fixtures "github.com/go-git/go-git-fixtures/v4"
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/format/commitgraph"
+	. "gopkg.in/check.v1"
+)
+
+type ReachabilitySuite struct {
+	fixtures.Suite
+}
+
+var _ = Suite(&ReachabilitySuite{})
+
+var (
+	reachHead    = plumbing.NewHash("b9d69064b190e7aedccf84731ca1d917871f8a1c")
+	reachMaster  = plumbing.NewHash("d2dc5ac04916e156018db4482c40c39b894090e9")
+	reachMerges1 = plumbing.NewHash("b29328491a0682c259bcce28741eac71f3499f7d")
+	reachMerges3 = plumbing.NewHash("6f6c5d2be7852c782be1dd13e36496dd7ad39560")
+	reachCommit2 = plumbing.NewHash("e713b52d7e13807e87a002e812041f248db3f643")
+	reachCommit4 = plumbing.NewHash("03d2c021ff68954cf3ef0a36825e194a4b98f981")
+	reachCommit6 = plumbing.NewHash("c0edf780dd0da6a65a7a49a86032fcf8a0c2d467")
+	reachCommit7 = plumbing.NewHash("a45273fe2d63300e1962a9e26a6b15c276cd7082")
+)
+
+func (s *ReachabilitySuite) indexes(c *C) map[string]CommitNodeIndex {
+	f := fixtures.ByTag("commit-graph").One()
+	storer := unpackRepositry(f)
+
+	reader, err := storer.Filesystem().Open(path.Join("objects", "info", "commit-graph"))
+	c.Assert(err, IsNil)
+	index, err := commitgraph.OpenFileIndex(reader)
+	c.Assert(err, IsNil)
+
+	return map[string]CommitNodeIndex{
+		"object": NewObjectCommitNodeIndex(storer),
+		"graph":  NewGraphCommitNodeIndex(index, storer),
+	}
+}
+
+func (s *ReachabilitySuite) TestContains(c *C) {
+	tips := []plumbing.Hash{reachMaster, reachMerges1, reachMerges3}
+	tests := []struct {
+		commit   plumbing.Hash
+		expected []bool
+	}{
+		{reachCommit4, []bool{true, true, true}},
+		{reachCommit6, []bool{true, false, true}},
+		{reachCommit7, []bool{false, false, true}},
+		{reachCommit2, []bool{false, true, true}},
+		{reachMerges3, []bool{false, false, true}},
+		{reachHead, []bool{false, false, false}},
+	}
+
+	for name, index := range s.indexes(c) {
+		r := NewReachability(index)
+		for _, t := range tests {
+			for i, tip := range tips {
+				contained, err := r.Contains(tip, t.commit)
+				c.Assert(err, IsNil)
+				c.Assert(contained, Equals, t.expected[i],
+					Commentf("%s: %s in %s", name, t.commit, tip))
+			}
+		}
+	}
+}
+
+func (s *ReachabilitySuite) TestReachableFrom(c *C) {
+	tips := []plumbing.Hash{reachMaster, reachMerges1, reachMerges3}
+	for name, index := range s.indexes(c) {
+		r := NewReachability(index)
+		result, err := r.ReachableFrom(reachHead, tips)
+		c.Assert(err, IsNil)
+		c.Assert(result, DeepEquals, map[plumbing.Hash]bool{
+			reachMaster:  false,
+			reachMerges1: false,
+			reachMerges3: true,
+		}, Commentf(name))
+
+		result, err = r.ReachableFrom(reachCommit7, []plumbing.Hash{reachCommit6, reachCommit4})
+		c.Assert(err, IsNil)
+		c.Assert(result, DeepEquals, map[plumbing.Hash]bool{
+			reachCommit6: true,
+			reachCommit4: false,
+		}, Commentf(name))
+	}
+}
+
+func (s *ReachabilitySuite) TestAheadBehind(c *C) {
+	tests := []struct {
+		local, upstream plumbing.Hash
+		ahead, behind   int
+	}{
+		{reachMaster, reachMerges3, 1, 5},
+		{reachMerges1, reachMerges3, 1, 5},
+		{reachMerges3, reachMerges3, 0, 0},
+		{reachHead, reachMerges3, 1, 0},
+		{reachMerges3, reachHead, 0, 1},
+	}
+
+	for name, index := range s.indexes(c) {
+		r := NewReachability(index)
+		for _, t := range tests {
+			ahead, behind, err := r.AheadBehind(t.local, t.upstream)
+			c.Assert(err, IsNil)
+			c.Assert([]int{ahead, behind}, DeepEquals, []int{t.ahead, t.behind},
+				Commentf("%s: %s...%s", name, t.local, t.upstream))
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package object
 
 import (
+	"errors"
 	"fmt"
 	"sort"
 
@@ -11,6 +12,10 @@ import (
 // errIsReachable is thrown when first commit is an ancestor of the second
 var errIsReachable = fmt.Errorf("first is reachable from second")
 
+// ErrNoForkPoint is returned by ForkPoint when none of the commits of the
+// upstream is the fork point.
+var ErrNoForkPoint = errors.New("no fork point found")
+
 // MergeBase mimics the behavior of `git merge-base actual other`, returning the
 // best common ancestor between the actual and the passed one.
 // The best common ancestors can not be reached from other common ancestors.
@@ -40,6 +45,68 @@ func (c *Commit) MergeBase(other *Commit) ([]*Commit, error) {
 	return Independents(res)
 }
 
+// MergeBaseOctopus mimics the behavior of `git merge-base --octopus commit...`,
+// returning the best common ancestors of all the passed commits, in
+// preparation for an n-way merge.
+func MergeBaseOctopus(commits ...*Commit) ([]*Commit, error) {
+	if len(commits) == 0 {
+		return nil, nil
+	}
+
+	result := commits[:1]
+	for _, other := range commits[1:] {
+		var bases []*Commit
+		for _, commit := range result {
+			res, err := commit.MergeBase(other)
+			if err != nil {
+				return nil, err
+			}
+
+			bases = append(bases, res...)
+		}
+
+		if len(bases) == 0 {
+			return nil, nil
+		}
+
+		result = removeDuplicated(bases)
+	}
+
+	return Independents(result)
+}
+
+// ForkPoint mimics the behavior of `git merge-base --fork-point`, returning
+// the commit where the actual one forked from a branch, given all the commits
+// the branch pointed to, usually read from its reflog. The fork point is the
+// best common ancestor between the actual commit and all the upstream ones,
+// when it is unique and one of the upstream commits; that way it is found
+// even if the branch was rewritten after the fork. ErrNoForkPoint is
+// returned otherwise.
+func (c *Commit) ForkPoint(upstream []*Commit) (*Commit, error) {
+	upstream = removeDuplicated(upstream)
+
+	var bases []*Commit
+	for _, commit := range upstream {
+		res, err := c.MergeBase(commit)
+		if err != nil {
+			return nil, err
+		}
+
+		bases = append(bases, res...)
+	}
+
+	bases, err := Independents(bases)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bases) != 1 || indexOf(upstream, bases[0]) == -1 {
+		return nil, ErrNoForkPoint
+	}
+
+	return bases[0], nil
+}
+
 // IsAncestor returns true if the actual commit is ancestor of the passed one.
 // It returns an error if the history is not transversable
 // It mimics the behavior of `git merge --is-ancestor actual other`
@@ -51,6 +51,23 @@ candidates           result
  A, A^, A, N, N^      A, N        A^ and N^ are reachable from A and N
  A^^^, A^, A^^, A, N  A, N        A^^^, A^^ and A^ are reachable from A, so they're not
 
+MergeBaseOctopus
+----------------------------
+passed     merge-base
+ A, B, N               No commit is common to all of them
+ A, C, S    A          A is ancestor of the others
+ C, D, G    CD1, CD2   Cross merges causes more than one merge-base
+ Q, G, dev  GQ2        The merge-base of Q, G and dev is the one of the three
+
+ForkPoint
+----------------------------
+passed           fork-point
+ B, M AB A       AB         The best common ancestor of B and the upstream ones
+ C, CD1 M        CD1        M is reachable from CD1
+ A, N^ AB        AB         Upstream was rewritten to N^ after the fork
+ C, D                       Both CD1 and CD2 are merge-bases
+ A, B                       AB, the only merge-base, is not in the upstream
+
 IsAncestor
 ----------------------------
 passed   result
@@ -153,6 +170,43 @@ func (s *mergeBaseSuite) AssertIndependents(c *C, revs, expectedRevs []string) {
 	}
 }
 
+// AssertMergeBaseOctopus validates the octopus merge-base of the passed revs
+func (s *mergeBaseSuite) AssertMergeBaseOctopus(c *C, revs, expectedRevs []string) {
+	commits, err := s.commitsFromRevs(c, revs)
+	c.Assert(err, IsNil)
+
+	results, err := MergeBaseOctopus(commits...)
+	c.Assert(err, IsNil)
+
+	expected, err := s.commitsFromRevs(c, expectedRevs)
+	c.Assert(err, IsNil)
+
+	c.Assert(results, HasLen, len(expected))
+
+	alphabeticSortCommits(results)
+	alphabeticSortCommits(expected)
+	for i, commit := range results {
+		c.Assert(commit.Hash.String(), Equals, expected[i].Hash.String())
+	}
+}
+
+// AssertForkPoint validates the fork-point of the first rev from the others,
+// being none if expectedRev is empty
+func (s *mergeBaseSuite) AssertForkPoint(c *C, revs []string, expectedRev string) {
+	commits, err := s.commitsFromRevs(c, revs)
+	c.Assert(err, IsNil)
+
+	result, err := commits[0].ForkPoint(commits[1:])
+	if expectedRev == "" {
+		c.Assert(err, Equals, ErrNoForkPoint)
+		c.Assert(result, IsNil)
+		return
+	}
+
+	c.Assert(err, IsNil)
+	c.Assert(result.Hash, Equals, revisionIndex[expectedRev])
+}
+
 // AssertAncestor validates if the first rev is ancestor of the second one
 func (s *mergeBaseSuite) AssertAncestor(c *C, revs []string, shouldBeAncestor bool) {
 	c.Assert(revs, HasLen, 2)
@@ -320,3 +374,53 @@ func (s *mergeBaseSuite) TestAncestorUnrelated(c *C) {
 	revs = []string{"N", "M"}
 	s.AssertAncestor(c, revs, false)
 }
+
+// TestMergeBaseOctopusNoCommon validates that the octopus merge-base returns
+// no commits when no commit is common to all of them (A, B, N -> none)
+func (s *mergeBaseSuite) TestMergeBaseOctopusNoCommon(c *C) {
+	revs := []string{"A", "B", "N"}
+	s.AssertMergeBaseOctopus(c, revs, nil)
+}
+
+// TestMergeBaseOctopusWithAncestor validates that the octopus merge-base of
+// commits with a common ancestor, is the ancestor (A, C, S -> A)
+func (s *mergeBaseSuite) TestMergeBaseOctopusWithAncestor(c *C) {
+	revs := []string{"A", "C", "S"}
+	s.AssertMergeBaseOctopus(c, revs, []string{"A"})
+}
+
+// TestMergeBaseOctopusCrossMerge validates the octopus merge-base when there
+// are cross merges (C, D, G -> CD1, CD2)
+func (s *mergeBaseSuite) TestMergeBaseOctopusCrossMerge(c *C) {
+	revs := []string{"C", "D", "G"}
+	s.AssertMergeBaseOctopus(c, revs, []string{"CD1", "CD2"})
+}
+
+// TestMergeBaseOctopusFeatureBranches validates the octopus merge-base of
+// feature branches including merges (Q, G, dev -> GQ2)
+func (s *mergeBaseSuite) TestMergeBaseOctopusFeatureBranches(c *C) {
+	revs := []string{"Q", "G", "dev"}
+	s.AssertMergeBaseOctopus(c, revs, []string{"GQ2"})
+
+	revs = []string{"A"}
+	s.AssertMergeBaseOctopus(c, revs, []string{"A"})
+}
+
+// TestForkPoint validates the fork-point among the upstream commits
+func (s *mergeBaseSuite) TestForkPoint(c *C) {
+	s.AssertForkPoint(c, []string{"B", "M", "AB", "A"}, "AB")
+	s.AssertForkPoint(c, []string{"C", "CD1", "M"}, "CD1")
+}
+
+// TestForkPointRewrittenUpstream validates that the fork-point is found after
+// the upstream was rewritten (A, N^ AB -> AB)
+func (s *mergeBaseSuite) TestForkPointRewrittenUpstream(c *C) {
+	s.AssertForkPoint(c, []string{"A", "N^", "AB"}, "AB")
+}
+
+// TestForkPointNotFound validates that there is no fork-point when the merge
+// base is not unique, or is not one of the upstream commits
+func (s *mergeBaseSuite) TestForkPointNotFound(c *C) {
+	s.AssertForkPoint(c, []string{"C", "D"}, "")
+	s.AssertForkPoint(c, []string{"A", "B"}, "")
+}
@@ -0,0 +1,37 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+)
+
+// defaultNotesRef is the notes reference whose notes are shown without the
+// name of the reference.
+const defaultNotesRef = "refs/notes/commits"
+
+// Note is a message attached to an object without changing it, stored in a
+// notes reference. For more information: https://git-scm.com/docs/git-notes
+type Note struct {
+	// Ref is the notes reference the note belongs to.
+	Ref plumbing.ReferenceName
+	// Object is the hash of the object the note is attached to.
+	Object plumbing.Hash
+	// Hash is the hash of the blob containing the message.
+	Hash plumbing.Hash
+	// Message is the content of the note.
+	Message string
+}
+
+// String returns the note as shown by `git log`, headed by the name of its
+// notes reference.
+func (n *Note) String() string {
+	header := "Notes:"
+	if n.Ref != defaultNotesRef {
+		name := strings.TrimPrefix(n.Ref.String(), "refs/notes/")
+		header = fmt.Sprintf("Notes (%s):", name)
+	}
+
+	return fmt.Sprintf("%s\n%s\n", header, indent(n.Message))
+}
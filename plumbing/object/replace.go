@@ -0,0 +1,176 @@
+package object
+
+import (
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/storer"
+)
+
+// maxReplaceDepth is the maximum length of a chain of replacements, the same
+// as git.
+const maxReplaceDepth = 5
+
+// ReplaceObjectStorer is an EncodedObjectStorer honouring replacements and
+// grafts, as git does with the refs/replace references and the info/grafts
+// file. The replaced objects are returned with the content of their
+// replacement but keeping their own hash, and the grafted commits with their
+// parents rewritten.
+type ReplaceObjectStorer struct {
+	storer.EncodedObjectStorer
+	replace ReplacementFunc
+	grafts  map[plumbing.Hash][]plumbing.Hash
+}
+
+// ReplacementFunc returns the replacement of the object with the given hash,
+// and whether it is replaced.
+type ReplacementFunc func(plumbing.Hash) (plumbing.Hash, bool)
+
+// NewReplaceObjectStorer returns an EncodedObjectStorer returning the objects
+// of s with the given replacements, from the replaced object to its
+// replacement, and grafts, from a commit to its new parents.
+func NewReplaceObjectStorer(s storer.EncodedObjectStorer,
+	replacements map[plumbing.Hash]plumbing.Hash,
+	grafts map[plumbing.Hash][]plumbing.Hash) *ReplaceObjectStorer {
+
+	return NewReplaceObjectStorerFunc(s, func(h plumbing.Hash) (plumbing.Hash, bool) {
+		next, ok := replacements[h]
+		return next, ok
+	}, grafts)
+}
+
+// NewReplaceObjectStorerFunc returns an EncodedObjectStorer as
+// NewReplaceObjectStorer does, the replacement of every object being looked
+// up with replace when it is read, such as the replacements don't need to be
+// known beforehand.
+func NewReplaceObjectStorerFunc(s storer.EncodedObjectStorer,
+	replace ReplacementFunc,
+	grafts map[plumbing.Hash][]plumbing.Hash) *ReplaceObjectStorer {
+
+	return &ReplaceObjectStorer{
+		EncodedObjectStorer: s,
+		replace:             replace,
+		grafts:              grafts,
+	}
+}
+
+// EncodedObject returns the object with the given hash, or its replacement if
+// any.
+func (s *ReplaceObjectStorer) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	target := s.replacement(h)
+	o, err := s.EncodedObjectStorer.EncodedObject(t, target)
+	if err != nil {
+		return nil, err
+	}
+
+	parents, grafted := s.grafts[h]
+	if !grafted || o.Type() != plumbing.CommitObject {
+		if target == h {
+			return o, nil
+		}
+
+		return &replacedObject{EncodedObject: o, hash: h}, nil
+	}
+
+	c := &Commit{}
+	if err := c.Decode(o); err != nil {
+		return nil, err
+	}
+
+	c.ParentHashes = parents
+	grafts := &plumbing.MemoryObject{}
+	if err := c.Encode(grafts); err != nil {
+		return nil, err
+	}
+
+	return &replacedObject{EncodedObject: grafts, hash: h}, nil
+}
+
+// IterEncodedObjects returns an iterator over all the objects of the given
+// type, with the replacements and grafts applied.
+func (s *ReplaceObjectStorer) IterEncodedObjects(t plumbing.ObjectType) (storer.EncodedObjectIter, error) {
+	iter, err := s.EncodedObjectStorer.IterEncodedObjects(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return &replaceObjectIter{EncodedObjectIter: iter, s: s}, nil
+}
+
+// HasEncodedObject returns nil if the object, or its replacement, exists.
+func (s *ReplaceObjectStorer) HasEncodedObject(h plumbing.Hash) error {
+	return s.EncodedObjectStorer.HasEncodedObject(s.replacement(h))
+}
+
+// EncodedObjectSize returns the size of the object, or of its replacement.
+func (s *ReplaceObjectStorer) EncodedObjectSize(h plumbing.Hash) (int64, error) {
+	if _, ok := s.grafts[h]; ok {
+		o, err := s.EncodedObject(plumbing.AnyObject, h)
+		if err != nil {
+			return 0, err
+		}
+
+		return o.Size(), nil
+	}
+
+	return s.EncodedObjectStorer.EncodedObjectSize(s.replacement(h))
+}
+
+func (s *ReplaceObjectStorer) replacement(h plumbing.Hash) plumbing.Hash {
+	for i := 0; i < maxReplaceDepth; i++ {
+		next, ok := s.replace(h)
+		if !ok {
+			break
+		}
+
+		h = next
+	}
+
+	return h
+}
+
+func (s *ReplaceObjectStorer) isReplaced(h plumbing.Hash) bool {
+	_, replaced := s.replace(h)
+	_, grafted := s.grafts[h]
+	return replaced || grafted
+}
+
+type replacedObject struct {
+	plumbing.EncodedObject
+	hash plumbing.Hash
+}
+
+func (o *replacedObject) Hash() plumbing.Hash {
+	return o.hash
+}
+
+type replaceObjectIter struct {
+	storer.EncodedObjectIter
+	s *ReplaceObjectStorer
+}
+
+func (iter *replaceObjectIter) Next() (plumbing.EncodedObject, error) {
+	o, err := iter.EncodedObjectIter.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	return iter.replace(o)
+}
+
+func (iter *replaceObjectIter) ForEach(cb func(plumbing.EncodedObject) error) error {
+	return iter.EncodedObjectIter.ForEach(func(o plumbing.EncodedObject) error {
+		o, err := iter.replace(o)
+		if err != nil {
+			return err
+		}
+
+		return cb(o)
+	})
+}
+
+func (iter *replaceObjectIter) replace(o plumbing.EncodedObject) (plumbing.EncodedObject, error) {
+	if !iter.s.isReplaced(o.Hash()) {
+		return o, nil
+	}
+
+	return iter.s.EncodedObject(plumbing.AnyObject, o.Hash())
+}
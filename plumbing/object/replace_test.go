@@ -0,0 +1,80 @@
+package object
+
+import (
+	"github.com/goabstract/go-git/v5/plumbing"
+
+	. "gopkg.in/check.v1"
+)
+
+type ReplaceSuite struct {
+	BaseObjectsSuite
+}
+
+var _ = Suite(&ReplaceSuite{})
+
+func (s *ReplaceSuite) TestReplacement(c *C) {
+	head := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	parent := plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+	branch := plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")
+
+	sto := NewReplaceObjectStorer(s.Storer, map[plumbing.Hash]plumbing.Hash{
+		parent: branch,
+	}, nil)
+
+	commit, err := GetCommit(sto, head)
+	c.Assert(err, IsNil)
+
+	p, err := commit.Parent(0)
+	c.Assert(err, IsNil)
+	c.Assert(p.Hash, Equals, parent)
+	c.Assert(p.Message, Equals, s.commit(c, branch).Message)
+
+	size, err := sto.EncodedObjectSize(parent)
+	c.Assert(err, IsNil)
+	expected, err := s.Storer.EncodedObjectSize(branch)
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, expected)
+
+	iter, err := sto.IterEncodedObjects(plumbing.CommitObject)
+	c.Assert(err, IsNil)
+
+	var found bool
+	err = iter.ForEach(func(o plumbing.EncodedObject) error {
+		if o.Hash() != parent {
+			return nil
+		}
+
+		replaced, err := DecodeCommit(sto, o)
+		if err != nil {
+			return err
+		}
+
+		found = replaced.Message == p.Message
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(found, Equals, true)
+}
+
+func (s *ReplaceSuite) TestGrafts(c *C) {
+	head := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	initial := plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d")
+
+	sto := NewReplaceObjectStorer(s.Storer, nil, map[plumbing.Hash][]plumbing.Hash{
+		head: {initial},
+	})
+
+	commit, err := GetCommit(sto, head)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Hash, Equals, head)
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{initial})
+
+	var count int
+	err = commit.Parents().ForEach(func(p *Commit) error {
+		count++
+		c.Assert(p.Hash, Equals, initial)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(count, Equals, 1)
+}
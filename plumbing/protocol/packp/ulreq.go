@@ -55,6 +55,23 @@ func (d DepthReference) IsZero() bool {
 	return string(d) == ""
 }
 
+// Depths combines several depth values in the same request, as a DepthSince
+// with one or more DepthReference. A DepthCommits can not be combined with
+// other values.
+type Depths []Depth
+
+func (d Depths) isDepth() {}
+
+func (d Depths) IsZero() bool {
+	for _, v := range d {
+		if !v.IsZero() {
+			return false
+		}
+	}
+
+	return true
+}
+
 // NewUploadRequest returns a pointer to a new UploadRequest value, ready to be
 // used. It has no capabilities, wants or shallows and an infinite depth. Please
 // note that to encode an upload-request it has to have at least one wanted hash.
@@ -136,9 +153,20 @@ func (r *UploadRequest) validateRequiredCapabilities() error {
 		return fmt.Errorf(msg, capability.Shallow)
 	}
 
-	switch r.Depth.(type) {
+	if r.Capabilities.Supports(capability.DeepenRelative) &&
+		!r.Capabilities.Supports(capability.Shallow) {
+		return fmt.Errorf(msg, capability.Shallow)
+	}
+
+	return r.validateDepthCapabilities(r.Depth)
+}
+
+func (r *UploadRequest) validateDepthCapabilities(d Depth) error {
+	msg := "missing capability %s"
+
+	switch depth := d.(type) {
 	case DepthCommits:
-		if r.Depth != DepthCommits(0) {
+		if depth != DepthCommits(0) {
 			if !r.Capabilities.Supports(capability.Shallow) {
 				return fmt.Errorf(msg, capability.Shallow)
 			}
@@ -151,6 +179,17 @@ func (r *UploadRequest) validateRequiredCapabilities() error {
 		if !r.Capabilities.Supports(capability.DeepenNot) {
 			return fmt.Errorf(msg, capability.DeepenNot)
 		}
+	case Depths:
+		for _, v := range depth {
+			switch v.(type) {
+			case DepthCommits, Depths:
+				return fmt.Errorf("depth %T can not be combined", v)
+			}
+
+			if err := r.validateDepthCapabilities(v); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
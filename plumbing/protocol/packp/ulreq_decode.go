@@ -231,17 +231,54 @@ func (d *ulReqDecoder) decodeDeepenSince() stateFn {
 		return nil
 	}
 	t := time.Unix(secs, 0).UTC()
-	d.data.Depth = DepthSince(t)
+	d.addDepth(DepthSince(t))
 
-	return d.decodeFlush
+	return d.decodeOtherDeepen
 }
 
 func (d *ulReqDecoder) decodeDeepenReference() stateFn {
 	d.line = bytes.TrimPrefix(d.line, deepenReference)
 
-	d.data.Depth = DepthReference(string(d.line))
+	d.addDepth(DepthReference(string(d.line)))
 
-	return d.decodeFlush
+	return d.decodeOtherDeepen
+}
+
+// addDepth sets the depth of the request, combining it with the previous
+// one if any, as a deepen-since followed by one or more deepen-not.
+func (d *ulReqDecoder) addDepth(depth Depth) {
+	switch current := d.data.Depth.(type) {
+	case Depths:
+		d.data.Depth = append(current, depth)
+	default:
+		if current == nil || current.IsZero() {
+			d.data.Depth = depth
+			return
+		}
+
+		d.data.Depth = Depths{current, depth}
+	}
+}
+
+// Expected format: deepen-since <ul> / deepen-not <ref> / flush-pkt
+func (d *ulReqDecoder) decodeOtherDeepen() stateFn {
+	if ok := d.nextLine(); !ok {
+		return nil
+	}
+
+	if bytes.HasPrefix(d.line, deepenSince) {
+		return d.decodeDeepenSince
+	}
+
+	if bytes.HasPrefix(d.line, deepenReference) {
+		return d.decodeDeepenReference
+	}
+
+	if len(d.line) != 0 {
+		d.err = fmt.Errorf("unexpected payload while expecting a flush-pkt: %q", d.line)
+	}
+
+	return nil
 }
 
 func (d *ulReqDecoder) decodeFlush() stateFn {
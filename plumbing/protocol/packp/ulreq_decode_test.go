@@ -477,6 +477,23 @@ func (s *UlReqDecodeSuite) TestDeepenReference(c *C) {
 	c.Assert(string(reference), Equals, expected)
 }
 
+func (s *UlReqDecodeSuite) TestDeepenSinceAndReferences(c *C) {
+	payloads := []string{
+		"want 3333333333333333333333333333333333333333 ofs-delta multi_ack",
+		"deepen-since 1420167845",
+		"deepen-not refs/heads/master",
+		"deepen-not refs/tags/v1.0.0",
+		pktline.FlushString,
+	}
+	ur := s.testDecodeOK(c, payloads)
+
+	c.Assert(ur.Depth, DeepEquals, Depths{
+		DepthSince(time.Date(2015, time.January, 2, 3, 4, 5, 0, time.UTC)),
+		DepthReference("refs/heads/master"),
+		DepthReference("refs/tags/v1.0.0"),
+	})
+}
+
 func (s *UlReqDecodeSuite) TestAll(c *C) {
 	payloads := []string{
 		"want 3333333333333333333333333333333333333333 ofs-delta multi_ack",
@@ -106,33 +106,43 @@ func (e *ulReqEncoder) encodeShallows() stateFn {
 }
 
 func (e *ulReqEncoder) encodeDepth() stateFn {
-	switch depth := e.data.Depth.(type) {
+	if e.err = e.encodeDepthValue(e.data.Depth); e.err != nil {
+		return nil
+	}
+
+	return e.encodeFlush
+}
+
+func (e *ulReqEncoder) encodeDepthValue(d Depth) error {
+	switch depth := d.(type) {
 	case DepthCommits:
 		if depth != 0 {
 			commits := int(depth)
 			if err := e.pe.Encodef("deepen %d\n", commits); err != nil {
-				e.err = fmt.Errorf("encoding depth %d: %s", depth, err)
-				return nil
+				return fmt.Errorf("encoding depth %d: %s", depth, err)
 			}
 		}
 	case DepthSince:
 		when := time.Time(depth).UTC()
 		if err := e.pe.Encodef("deepen-since %d\n", when.Unix()); err != nil {
-			e.err = fmt.Errorf("encoding depth %s: %s", when, err)
-			return nil
+			return fmt.Errorf("encoding depth %s: %s", when, err)
 		}
 	case DepthReference:
 		reference := string(depth)
 		if err := e.pe.Encodef("deepen-not %s\n", reference); err != nil {
-			e.err = fmt.Errorf("encoding depth %s: %s", reference, err)
-			return nil
+			return fmt.Errorf("encoding depth %s: %s", reference, err)
+		}
+	case Depths:
+		for _, v := range depth {
+			if err := e.encodeDepthValue(v); err != nil {
+				return err
+			}
 		}
 	default:
-		e.err = fmt.Errorf("unsupported depth type")
-		return nil
+		return fmt.Errorf("unsupported depth type")
 	}
 
-	return e.encodeFlush
+	return nil
 }
 
 func (e *ulReqEncoder) encodeFlush() stateFn {
@@ -268,6 +268,27 @@ func (s *UlReqEncodeSuite) TestDepthReference(c *C) {
 	testUlReqEncode(c, ur, expected)
 }
 
+func (s *UlReqEncodeSuite) TestDepths(c *C) {
+	ur := NewUploadRequest()
+	ur.Wants = append(ur.Wants, plumbing.NewHash("1111111111111111111111111111111111111111"))
+	since := time.Date(2015, time.January, 2, 3, 4, 5, 0, time.UTC)
+	ur.Depth = Depths{
+		DepthSince(since),
+		DepthReference("refs/heads/feature-foo"),
+		DepthReference("refs/heads/feature-bar"),
+	}
+
+	expected := []string{
+		"want 1111111111111111111111111111111111111111\n",
+		"deepen-since 1420167845\n",
+		"deepen-not refs/heads/feature-foo\n",
+		"deepen-not refs/heads/feature-bar\n",
+		pktline.FlushString,
+	}
+
+	testUlReqEncode(c, ur, expected)
+}
+
 func (s *UlReqEncodeSuite) TestAll(c *C) {
 	ur := NewUploadRequest()
 	ur.Wants = append(ur.Wants,
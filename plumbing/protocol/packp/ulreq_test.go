@@ -82,6 +82,24 @@ func (s *UlReqSuite) TestValidateDepthReference(c *C) {
 	c.Assert(err, IsNil)
 }
 
+func (s *UlReqSuite) TestValidateDepths(c *C) {
+	r := NewUploadRequest()
+	r.Wants = append(r.Wants, plumbing.NewHash("1111111111111111111111111111111111111111"))
+	r.Depth = Depths{DepthSince(time.Now()), DepthReference("refs/heads/master")}
+
+	r.Capabilities.Set(capability.DeepenSince)
+	err := r.Validate()
+	c.Assert(err, NotNil)
+
+	r.Capabilities.Set(capability.DeepenNot)
+	err = r.Validate()
+	c.Assert(err, IsNil)
+
+	r.Depth = Depths{DepthCommits(1), DepthReference("refs/heads/master")}
+	err = r.Validate()
+	c.Assert(err, NotNil)
+}
+
 func (s *UlReqSuite) TestValidateDepthSince(c *C) {
 	r := NewUploadRequest()
 	r.Wants = append(r.Wants, plumbing.NewHash("1111111111111111111111111111111111111111"))
@@ -39,8 +39,12 @@ func NewUploadPackRequestFromCapabilities(adv *capability.List) *UploadPackReque
 }
 
 // IsEmpty a request if empty if Haves are contained in the Wants, or if Wants
-// length is zero
+// length is zero, unless it deepens the history of a shallow client
 func (r *UploadPackRequest) IsEmpty() bool {
+	if len(r.Shallows) != 0 && !r.Depth.IsZero() {
+		return false
+	}
+
 	return isSubset(r.Wants, r.Haves)
 }
 
@@ -88,6 +88,14 @@ type PackedObjectStorer interface {
 	DeleteOldObjectPackAndIndex(plumbing.Hash, time.Time) error
 }
 
+// HashPrefixObjectStorer is an optional interface for object storers able
+// to find objects by an abbreviated hash without decoding them.
+type HashPrefixObjectStorer interface {
+	// HashesWithPrefix returns the hashes of all the objects whose
+	// hexadecimal representation starts with the given prefix.
+	HashesWithPrefix(prefix string) ([]plumbing.Hash, error)
+}
+
 // PackfileWriter is a optional method for ObjectStorer, it enable direct write
 // of packfile to the storage
 type PackfileWriter interface {
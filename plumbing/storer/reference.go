@@ -5,13 +5,22 @@ import (
 	"io"
 
 	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/format/reflog"
 )
 
 const MaxResolveRecursion = 1024
 
-// ErrMaxResolveRecursion is returned by ResolveReference is MaxResolveRecursion
-// is exceeded
-var ErrMaxResolveRecursion = errors.New("max. recursion level reached")
+var (
+	// ErrMaxResolveRecursion is returned by ResolveReference is
+	// MaxResolveRecursion is exceeded
+	ErrMaxResolveRecursion = errors.New("max. recursion level reached")
+	// ErrDuplicatedReferenceUpdate is returned updating the same reference
+	// more than once at the same time.
+	ErrDuplicatedReferenceUpdate = errors.New("multiple updates of the same reference")
+	// ErrInvalidReferenceUpdate is returned by UpdateReferences when an
+	// update is not valid, such as an update without a new value.
+	ErrInvalidReferenceUpdate = errors.New("invalid reference update")
+)
 
 // ReferenceStorer is a generic storage of references.
 type ReferenceStorer interface {
@@ -28,6 +37,107 @@ type ReferenceStorer interface {
 	PackRefs() error
 }
 
+// ReflogStorer is an optional interface for reference storers keeping a log
+// of the updates of every reference, the reflog.
+type ReflogStorer interface {
+	// Reflog returns the entries of the reflog of the given reference, the
+	// oldest first. An empty reflog is returned if it doesn't exist.
+	Reflog(plumbing.ReferenceName) ([]*reflog.Entry, error)
+	// HasReflog reports whether the reflog of the given reference exists,
+	// without reading its entries.
+	HasReflog(plumbing.ReferenceName) (bool, error)
+	// AppendReflog adds a new entry at the end of the reflog of the given
+	// reference, creating it if needed.
+	AppendReflog(plumbing.ReferenceName, *reflog.Entry) error
+	// SetReflog replaces all the entries of the reflog of the given
+	// reference.
+	SetReflog(plumbing.ReferenceName, []*reflog.Entry) error
+	// DeleteReflog deletes the reflog of the given reference, if it exists.
+	DeleteReflog(plumbing.ReferenceName) error
+}
+
+// ReferenceTransactionStorer is an optional interface for reference storers
+// able to apply many updates of references at once.
+type ReferenceTransactionStorer interface {
+	// UpdateReferences applies all the updates, or none of them if the
+	// current value of any of the references is not the expected one, in
+	// which case storage.ErrReferenceHasChanged is returned. A reference
+	// can only be updated once, ErrDuplicatedReferenceUpdate is returned
+	// otherwise.
+	UpdateReferences([]ReferenceUpdate) error
+}
+
+// ReferenceUpdateAction is the kind of change of a ReferenceUpdate.
+type ReferenceUpdateAction int
+
+const (
+	// CreateReference creates the reference, it must not exist.
+	CreateReference ReferenceUpdateAction = iota
+	// UpdateReference sets the reference to the new value.
+	UpdateReference
+	// DeleteReference removes the reference, and its reflog.
+	DeleteReference
+	// VerifyReference only checks the current value of the reference.
+	VerifyReference
+)
+
+// ReferenceUpdate is a change of a reference applied, together with others,
+// by ReferenceTransactionStorer.UpdateReferences.
+type ReferenceUpdate struct {
+	// Action is the kind of change.
+	Action ReferenceUpdateAction
+	// Name is the name of the reference.
+	Name plumbing.ReferenceName
+	// New is the value set by CreateReference and UpdateReference.
+	New *plumbing.Reference
+	// Old is the value the reference must have before the update. If it is
+	// nil, the current value is not checked, but by VerifyReference, which
+	// checks that the reference doesn't exist.
+	Old *plumbing.Reference
+}
+
+// Check returns true if current, the value of the reference before the
+// update or nil if it doesn't exist, is the one expected by the update.
+func (u *ReferenceUpdate) Check(current *plumbing.Reference) bool {
+	switch {
+	case u.Action == CreateReference:
+		return current == nil
+	case u.Old == nil:
+		return u.Action != VerifyReference || current == nil
+	case current == nil || current.Type() != u.Old.Type():
+		return false
+	case current.Type() == plumbing.SymbolicReference:
+		return current.Target() == u.Old.Target()
+	default:
+		return current.Hash() == u.Old.Hash()
+	}
+}
+
+// CheckReferenceUpdates returns ErrDuplicatedReferenceUpdate if the same
+// reference is updated more than once, and ErrInvalidReferenceUpdate if an
+// update sets a value to a different reference.
+func CheckReferenceUpdates(updates []ReferenceUpdate) error {
+	seen := make(map[plumbing.ReferenceName]bool, len(updates))
+	for _, u := range updates {
+		if seen[u.Name] {
+			return ErrDuplicatedReferenceUpdate
+		}
+
+		seen[u.Name] = true
+		switch u.Action {
+		case CreateReference, UpdateReference:
+			if u.New == nil || u.New.Name() != u.Name {
+				return ErrInvalidReferenceUpdate
+			}
+		case DeleteReference, VerifyReference:
+		default:
+			return ErrInvalidReferenceUpdate
+		}
+	}
+
+	return nil
+}
+
 // ReferenceIter is a generic closable interface for iterating over references.
 type ReferenceIter interface {
 	Next() (*plumbing.Reference, error)
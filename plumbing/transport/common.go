@@ -107,6 +107,72 @@ type Endpoint struct {
 	Port int
 	// Path is the repository path.
 	Path string
+	// InsecureSkipTLS skips the verification of the TLS certificate of the
+	// server, for the HTTPS endpoints.
+	InsecureSkipTLS bool
+	// CABundle are the PEM encoded certificates of the certificate
+	// authorities, trusted besides the ones of the system to verify the TLS
+	// certificate of the server.
+	CABundle []byte
+	// Proxy is the proxy the HTTP requests are sent through, if its URL is
+	// set, otherwise the one of the environment is used.
+	Proxy ProxyOptions
+	// Headers are the extra headers sent with every HTTP request, in the
+	// "Name: value" form.
+	Headers []string
+	// CookieFile is the path of the file, in the Netscape format, the cookies
+	// sent with the HTTP requests are read from.
+	CookieFile string
+	// UserAgent is the user agent of the HTTP requests, if set.
+	UserAgent string
+}
+
+// ProxyOptions are the settings of a proxy.
+type ProxyOptions struct {
+	// URL is the URL of the proxy, as [protocol://][user[:password]@]host[:port],
+	// http if the protocol is omitted.
+	URL string
+	// Username and Password are the credentials of the proxy, overriding the
+	// ones of the URL, if any.
+	Username string
+	Password string
+}
+
+// Validate validates the proxy settings.
+func (o *ProxyOptions) Validate() error {
+	if o.URL == "" {
+		return nil
+	}
+
+	_, err := o.FullURL()
+	return err
+}
+
+// FullURL returns the URL of the proxy, with its credentials.
+func (o *ProxyOptions) FullURL() (*url.URL, error) {
+	raw := o.URL
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL: %s", o.URL)
+	}
+
+	if o.Username != "" {
+		if o.Password != "" {
+			u.User = url.UserPassword(o.Username, o.Password)
+		} else {
+			u.User = url.User(o.Username)
+		}
+	}
+
+	return u, nil
 }
 
 var defaultPorts = map[string]int{
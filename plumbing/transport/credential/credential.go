@@ -0,0 +1,269 @@
+// Package credential implements the git credential helper protocol, to fill
+// the credentials of the remotes with helpers, and to report them back to
+// the helpers whether they are accepted, as `git credential` does.
+//
+// See https://git-scm.com/docs/git-credential and
+// https://git-scm.com/docs/gitcredentials.
+package credential
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrCredentialNotFound is returned by Fill when the helpers give no
+	// complete credential.
+	ErrCredentialNotFound = errors.New("credential not found")
+	// ErrInvalidAttribute is returned decoding a credential with a malformed
+	// attribute line, or encoding one with a newline in an attribute.
+	ErrInvalidAttribute = errors.New("invalid credential attribute")
+)
+
+// Credential is the description of a credential, with the attributes of the
+// credential helper protocol.
+type Credential struct {
+	// Protocol is the protocol of the remote, e.g. https.
+	Protocol string
+	// Host is the host of the remote, with the port if it is not the
+	// default one.
+	Host string
+	// Path is the path of the repository on the remote, only set when the
+	// credentials are different for each repository of a host.
+	Path string
+	// Username and Password are the credentials.
+	Username string
+	Password string
+	// Quit is true if a helper asked to stop looking for the credential.
+	Quit bool
+}
+
+// NewCredential returns the description of the credential of the given URL,
+// with its path if withPath is true.
+func NewCredential(rawURL string, withPath bool) (*Credential, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Credential{
+		Protocol: u.Scheme,
+		Host:     u.Host,
+	}
+
+	if withPath {
+		c.Path = strings.TrimPrefix(u.Path, "/")
+	}
+
+	if u.User != nil {
+		c.Username = u.User.Username()
+		c.Password, _ = u.User.Password()
+	}
+
+	return c, nil
+}
+
+// IsComplete returns true if the credential has a username and a password.
+func (c *Credential) IsComplete() bool {
+	return c.Username != "" && c.Password != ""
+}
+
+// Encode writes the attributes of the credential set, as the helpers read
+// them.
+func (c *Credential) Encode(w io.Writer) error {
+	for _, a := range []struct{ key, value string }{
+		{"protocol", c.Protocol},
+		{"host", c.Host},
+		{"path", c.Path},
+		{"username", c.Username},
+		{"password", c.Password},
+	} {
+		if a.value == "" {
+			continue
+		}
+
+		if strings.ContainsAny(a.value, "\n\x00") {
+			return ErrInvalidAttribute
+		}
+
+		if _, err := fmt.Fprintf(w, "%s=%s\n", a.key, a.value); err != nil {
+			return err
+		}
+	}
+
+	if c.Quit {
+		if _, err := io.WriteString(w, "quit=1\n"); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Decode reads the attributes of the credential, as the helpers write them,
+// until a blank line or the end of the input. The unknown attributes are
+// ignored.
+func (c *Credential) Decode(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSuffix(s.Text(), "\r")
+		if line == "" {
+			break
+		}
+
+		i := strings.IndexByte(line, '=')
+		if i == -1 {
+			return ErrInvalidAttribute
+		}
+
+		key, value := line[:i], line[i+1:]
+		switch key {
+		case "protocol":
+			c.Protocol = value
+		case "host":
+			c.Host = value
+		case "path":
+			c.Path = value
+		case "username":
+			c.Username = value
+		case "password":
+			c.Password = value
+		case "url":
+			if err := c.decodeURL(value); err != nil {
+				return err
+			}
+		case "quit":
+			c.Quit, _ = strconv.ParseBool(value)
+		}
+	}
+
+	return s.Err()
+}
+
+func (c *Credential) decodeURL(value string) error {
+	u, err := NewCredential(value, true)
+	if err != nil {
+		return err
+	}
+
+	c.Protocol, c.Host, c.Path = u.Protocol, u.Host, u.Path
+	if u.Username != "" {
+		c.Username = u.Username
+	}
+
+	if u.Password != "" {
+		c.Password = u.Password
+	}
+
+	return nil
+}
+
+// matches returns true if every attribute of the query, but the password,
+// is the same in the credential, as git-credential-store and
+// git-credential-cache match the stored credentials.
+func (c *Credential) matches(query *Credential) bool {
+	return (query.Protocol == "" || query.Protocol == c.Protocol) &&
+		(query.Host == "" || query.Host == c.Host) &&
+		(query.Path == "" || query.Path == c.Path) &&
+		(query.Username == "" || query.Username == c.Username)
+}
+
+// merge sets the attributes set in the other credential.
+func (c *Credential) merge(o *Credential) {
+	for _, a := range []struct{ dst, src *string }{
+		{&c.Protocol, &o.Protocol},
+		{&c.Host, &o.Host},
+		{&c.Path, &o.Path},
+		{&c.Username, &o.Username},
+		{&c.Password, &o.Password},
+	} {
+		if *a.src != "" {
+			*a.dst = *a.src
+		}
+	}
+
+	c.Quit = c.Quit || o.Quit
+}
+
+// Helper is a credential helper.
+type Helper interface {
+	// Get returns the attributes of the credential the helper knows of,
+	// matching the given description, or nil if it has none.
+	Get(c *Credential) (*Credential, error)
+	// Store stores the given credential, accepted by the remote.
+	Store(c *Credential) error
+	// Erase erases the credentials matching the given description, rejected
+	// by the remote.
+	Erase(c *Credential) error
+}
+
+// Helpers are the credential helpers of a remote, asked in order.
+type Helpers []Helper
+
+// Fill returns the credential described by c, with the username and the
+// password given by the helpers, asked in order until one gives both, as
+// `git credential fill` does. It returns ErrCredentialNotFound if none does.
+func (h Helpers) Fill(c *Credential) (*Credential, error) {
+	filled := *c
+	for _, helper := range h {
+		if filled.IsComplete() {
+			break
+		}
+
+		got, err := helper.Get(&filled)
+		if err != nil {
+			return nil, err
+		}
+
+		if got == nil {
+			continue
+		}
+
+		filled.merge(got)
+		if filled.Quit {
+			break
+		}
+	}
+
+	if !filled.IsComplete() {
+		return nil, ErrCredentialNotFound
+	}
+
+	filled.Quit = false
+	return &filled, nil
+}
+
+// Approve reports the credential, accepted by the remote, to every helper,
+// for them to store it, as `git credential approve` does. It returns the
+// first error of the helpers.
+func (h Helpers) Approve(c *Credential) error {
+	if !c.IsComplete() {
+		return nil
+	}
+
+	return h.each(func(helper Helper) error { return helper.Store(c) })
+}
+
+// Reject reports the credential, rejected by the remote, to every helper,
+// for them to erase it, as `git credential reject` does. It returns the
+// first error of the helpers.
+func (h Helpers) Reject(c *Credential) error {
+	return h.each(func(helper Helper) error { return helper.Erase(c) })
+}
+
+func (h Helpers) each(f func(Helper) error) error {
+	var first error
+	for _, helper := range h {
+		if err := f(helper); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
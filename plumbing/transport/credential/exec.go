@@ -0,0 +1,81 @@
+package credential
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ExecHelper is an external credential helper, as set by credential.helper.
+type ExecHelper struct {
+	// Command is the helper as set in the config: the name of a
+	// git-credential-<name> helper, the absolute path of a helper, or a
+	// shell command starting with "!", with their arguments.
+	Command string
+}
+
+// NewExecHelper returns the external credential helper set in the config.
+func NewExecHelper(command string) *ExecHelper {
+	return &ExecHelper{Command: command}
+}
+
+// Get runs the helper with the get action.
+func (h *ExecHelper) Get(c *Credential) (*Credential, error) {
+	out, err := h.run("get", c)
+	if err != nil {
+		return nil, err
+	}
+
+	got := &Credential{}
+	if err := got.Decode(bytes.NewReader(out)); err != nil {
+		return nil, err
+	}
+
+	return got, nil
+}
+
+// Store runs the helper with the store action.
+func (h *ExecHelper) Store(c *Credential) error {
+	_, err := h.run("store", c)
+	return err
+}
+
+// Erase runs the helper with the erase action.
+func (h *ExecHelper) Erase(c *Credential) error {
+	_, err := h.run("erase", c)
+	return err
+}
+
+// run runs the helper with the action, through the shell as git does,
+// writing the credential to its input and returning its output.
+func (h *ExecHelper) run(action string, c *Credential) ([]byte, error) {
+	var stdin, stdout, stderr bytes.Buffer
+	if err := c.Encode(&stdin); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("sh", "-c", h.command()+" "+action)
+	cmd.Stdin = &stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("credential helper %q: %s: %s",
+			h.Command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// command returns the shell command of the helper.
+func (h *ExecHelper) command() string {
+	switch {
+	case strings.HasPrefix(h.Command, "!"):
+		return h.Command[1:]
+	case filepath.IsAbs(h.Command):
+		return h.Command
+	default:
+		return "git credential-" + h.Command
+	}
+}
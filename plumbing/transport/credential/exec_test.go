@@ -0,0 +1,68 @@
+package credential
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type ExecHelperSuite struct{}
+
+var _ = Suite(&ExecHelperSuite{})
+
+func (s *ExecHelperSuite) SetUpSuite(c *C) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		c.Skip("sh not found")
+	}
+}
+
+func (s *ExecHelperSuite) TestCommand(c *C) {
+	c.Assert(NewExecHelper("store --file=foo").command(), Equals, "git credential-store --file=foo")
+	c.Assert(NewExecHelper("/usr/bin/helper").command(), Equals, "/usr/bin/helper")
+	c.Assert(NewExecHelper("!f() { cat; }; f").command(), Equals, "f() { cat; }; f")
+}
+
+func (s *ExecHelperSuite) TestGetStoreErase(c *C) {
+	dir := c.MkDir()
+	log := filepath.Join(dir, "log")
+	script := filepath.Join(dir, "helper")
+	c.Assert(ioutil.WriteFile(script, []byte(`#!/bin/sh
+echo "$1" >> `+log+`
+cat >> `+log+`
+if [ "$1" = get ]; then
+	echo username=foo
+	echo password=bar
+fi
+`), 0755), IsNil)
+
+	h := NewExecHelper(script)
+	query := &Credential{Protocol: "https", Host: "example.com"}
+	cred, err := h.Get(query)
+	c.Assert(err, IsNil)
+	c.Assert(cred, DeepEquals, &Credential{Username: "foo", Password: "bar"})
+
+	approved := &Credential{Protocol: "https", Host: "example.com", Username: "foo", Password: "bar"}
+	c.Assert(h.Store(approved), IsNil)
+	c.Assert(h.Erase(approved), IsNil)
+
+	content, err := ioutil.ReadFile(log)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "get\nprotocol=https\nhost=example.com\n\n"+
+		"store\nprotocol=https\nhost=example.com\nusername=foo\npassword=bar\n\n"+
+		"erase\nprotocol=https\nhost=example.com\nusername=foo\npassword=bar\n\n")
+}
+
+func (s *ExecHelperSuite) TestShellHelper(c *C) {
+	h := NewExecHelper(`!f() { test "$1" = get && echo "password=secret"; }; f`)
+	cred, err := h.Get(&Credential{Protocol: "https", Host: "example.com", Username: "foo"})
+	c.Assert(err, IsNil)
+	c.Assert(cred, DeepEquals, &Credential{Password: "secret"})
+}
+
+func (s *ExecHelperSuite) TestFailure(c *C) {
+	h := NewExecHelper(`!echo failed >&2; exit 1; true`)
+	_, err := h.Get(&Credential{Protocol: "https", Host: "example.com"})
+	c.Assert(err, ErrorMatches, `credential helper .*: exit status 1: failed`)
+}
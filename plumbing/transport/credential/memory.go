@@ -0,0 +1,110 @@
+package credential
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultCacheTimeout is the time the credentials are kept by a Cache by
+// default, as git-credential-cache does.
+const DefaultCacheTimeout = 15 * time.Minute
+
+// Store is an in-process credential helper keeping the approved credentials
+// for the lifetime of the process, as git-credential-store does on disk.
+type Store struct {
+	m           sync.Mutex
+	credentials []*Credential
+	expires     []time.Time
+	timeout     time.Duration
+	now         func() time.Time
+}
+
+// NewStore returns an empty in-process store.
+func NewStore() *Store {
+	return &Store{now: time.Now}
+}
+
+// NewCache returns an empty in-process cache, keeping the approved
+// credentials for the given time, as git-credential-cache does, or for
+// DefaultCacheTimeout if it is 0.
+func NewCache(timeout time.Duration) *Store {
+	if timeout == 0 {
+		timeout = DefaultCacheTimeout
+	}
+
+	return &Store{timeout: timeout, now: time.Now}
+}
+
+// Get returns the first credential stored matching the given description.
+func (s *Store) Get(c *Credential) (*Credential, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.expire()
+	for _, stored := range s.credentials {
+		if stored.matches(c) {
+			found := *stored
+			return &found, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Store stores the credential, replacing the one stored for the same
+// protocol, host, path and username, if any.
+func (s *Store) Store(c *Credential) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	stored := *c
+	stored.Quit = false
+
+	var expires time.Time
+	if s.timeout != 0 {
+		expires = s.now().Add(s.timeout)
+	}
+
+	for i, o := range s.credentials {
+		if o.Protocol == c.Protocol && o.Host == c.Host && o.Path == c.Path && o.Username == c.Username {
+			s.credentials[i], s.expires[i] = &stored, expires
+			return nil
+		}
+	}
+
+	s.credentials = append(s.credentials, &stored)
+	s.expires = append(s.expires, expires)
+	return nil
+}
+
+// Erase removes the credentials stored matching the given description.
+func (s *Store) Erase(c *Credential) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.remove(func(i int) bool { return s.credentials[i].matches(c) })
+	return nil
+}
+
+// expire removes the expired credentials.
+func (s *Store) expire() {
+	if s.timeout == 0 {
+		return
+	}
+
+	now := s.now()
+	s.remove(func(i int) bool { return !now.Before(s.expires[i]) })
+}
+
+func (s *Store) remove(f func(i int) bool) {
+	var credentials []*Credential
+	var expires []time.Time
+	for i, c := range s.credentials {
+		if !f(i) {
+			credentials = append(credentials, c)
+			expires = append(expires, s.expires[i])
+		}
+	}
+
+	s.credentials, s.expires = credentials, expires
+}
@@ -0,0 +1,69 @@
+package credential
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type StoreSuite struct{}
+
+var _ = Suite(&StoreSuite{})
+
+func (s *StoreSuite) TestStore(c *C) {
+	store := NewStore()
+	query := &Credential{Protocol: "https", Host: "example.com"}
+
+	cred, err := store.Get(query)
+	c.Assert(err, IsNil)
+	c.Assert(cred, IsNil)
+
+	foo := &Credential{Protocol: "https", Host: "example.com", Username: "foo", Password: "bar"}
+	qux := &Credential{Protocol: "https", Host: "example.com", Username: "qux", Password: "baz"}
+	c.Assert(store.Store(foo), IsNil)
+	c.Assert(store.Store(qux), IsNil)
+
+	cred, err = store.Get(query)
+	c.Assert(err, IsNil)
+	c.Assert(cred, DeepEquals, foo)
+
+	cred, err = store.Get(&Credential{Protocol: "https", Host: "example.com", Username: "qux"})
+	c.Assert(err, IsNil)
+	c.Assert(cred, DeepEquals, qux)
+
+	cred, err = store.Get(&Credential{Protocol: "http", Host: "example.com"})
+	c.Assert(err, IsNil)
+	c.Assert(cred, IsNil)
+
+	updated := &Credential{Protocol: "https", Host: "example.com", Username: "foo", Password: "new"}
+	c.Assert(store.Store(updated), IsNil)
+	cred, err = store.Get(query)
+	c.Assert(err, IsNil)
+	c.Assert(cred.Password, Equals, "new")
+
+	c.Assert(store.Erase(&Credential{Protocol: "https", Host: "example.com", Username: "foo"}), IsNil)
+	cred, err = store.Get(query)
+	c.Assert(err, IsNil)
+	c.Assert(cred, DeepEquals, qux)
+}
+
+func (s *StoreSuite) TestCache(c *C) {
+	now := time.Now()
+	cache := NewCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cred := &Credential{Protocol: "https", Host: "example.com", Username: "foo", Password: "bar"}
+	c.Assert(cache.Store(cred), IsNil)
+
+	now = now.Add(59 * time.Second)
+	found, err := cache.Get(&Credential{Protocol: "https", Host: "example.com"})
+	c.Assert(err, IsNil)
+	c.Assert(found, DeepEquals, cred)
+
+	now = now.Add(time.Second)
+	found, err = cache.Get(&Credential{Protocol: "https", Host: "example.com"})
+	c.Assert(err, IsNil)
+	c.Assert(found, IsNil)
+
+	c.Assert(NewCache(0).timeout, Equals, DefaultCacheTimeout)
+}
@@ -0,0 +1,147 @@
+package credential
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Netrc is a read-only credential helper giving the credentials of a netrc
+// file, as curl reads them for git.
+type Netrc struct {
+	// Path is the path of the netrc file, ~/.netrc (~/_netrc on Windows) if
+	// empty.
+	Path string
+}
+
+// Get returns the login and the password of the first machine of the file
+// with the host of the credential, or else of the default entry, skipping
+// the ones with another login than the username of the credential, if any.
+// A missing file gives no credential.
+func (n *Netrc) Get(c *Credential) (*Credential, error) {
+	path, err := n.path()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	host := c.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	for _, m := range parseNetrc(string(content)) {
+		if (m.name != "" && !strings.EqualFold(m.name, host)) ||
+			(c.Username != "" && m.login != "" && m.login != c.Username) {
+			continue
+		}
+
+		return &Credential{Username: m.login, Password: m.password}, nil
+	}
+
+	return nil, nil
+}
+
+// Store does nothing, the netrc file is not written.
+func (*Netrc) Store(*Credential) error {
+	return nil
+}
+
+// Erase does nothing, the netrc file is not written.
+func (*Netrc) Erase(*Credential) error {
+	return nil
+}
+
+func (n *Netrc) path() (string, error) {
+	if n.Path != "" {
+		return n.Path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	name := ".netrc"
+	if runtime.GOOS == "windows" {
+		name = "_netrc"
+	}
+
+	return filepath.Join(home, name), nil
+}
+
+// netrcMachine is an entry of a netrc file, with an empty name for the
+// default entry.
+type netrcMachine struct {
+	name, login, password string
+}
+
+// parseNetrc returns the entries of a netrc file, skipping the macro
+// definitions, the default entry last.
+func parseNetrc(content string) []netrcMachine {
+	var machines []netrcMachine
+	var current *netrcMachine
+	var defaults *netrcMachine
+
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+		for j := 0; j < len(fields); j++ {
+			if strings.HasPrefix(fields[j], "#") {
+				break
+			}
+
+			var value string
+			if j+1 < len(fields) {
+				value = fields[j+1]
+			}
+
+			switch fields[j] {
+			case "machine":
+				machines = append(machines, netrcMachine{name: value})
+				current = &machines[len(machines)-1]
+				j++
+			case "default":
+				defaults = &netrcMachine{}
+				current = defaults
+			case "login":
+				if current != nil {
+					current.login = value
+				}
+				j++
+			case "password":
+				if current != nil {
+					current.password = value
+				}
+				j++
+			case "account":
+				j++
+			case "macdef":
+				// the macro lasts until a blank line
+				for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+					i++
+				}
+
+				current = nil
+				j = len(fields)
+			}
+		}
+	}
+
+	if defaults != nil {
+		machines = append(machines, *defaults)
+	}
+
+	return machines
+}
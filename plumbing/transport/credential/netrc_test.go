@@ -0,0 +1,60 @@
+package credential
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type NetrcSuite struct{}
+
+var _ = Suite(&NetrcSuite{})
+
+func (s *NetrcSuite) TestGet(c *C) {
+	path := filepath.Join(c.MkDir(), ".netrc")
+	c.Assert(ioutil.WriteFile(path, []byte(`# credentials
+machine example.com login foo password bar
+machine example.com
+	login qux
+	password baz
+macdef init
+	machine evil.com login evil password evil
+
+default login anonymous password guest
+machine git.example.com login other # comment
+	password secret
+`), 0600), IsNil)
+
+	n := &Netrc{Path: path}
+	for _, t := range []struct {
+		query, cred *Credential
+	}{{
+		&Credential{Protocol: "https", Host: "example.com:8443"},
+		&Credential{Username: "foo", Password: "bar"},
+	}, {
+		&Credential{Protocol: "https", Host: "EXAMPLE.com", Username: "qux"},
+		&Credential{Username: "qux", Password: "baz"},
+	}, {
+		&Credential{Protocol: "https", Host: "git.example.com"},
+		&Credential{Username: "other", Password: "secret"},
+	}, {
+		&Credential{Protocol: "https", Host: "evil.com"},
+		&Credential{Username: "anonymous", Password: "guest"},
+	}, {
+		&Credential{Protocol: "https", Host: "example.com", Username: "nobody"},
+		nil,
+	}} {
+		cred, err := n.Get(t.query)
+		c.Assert(err, IsNil)
+		c.Assert(cred, DeepEquals, t.cred, Commentf("%v", t.query))
+	}
+
+	c.Assert(n.Store(&Credential{}), IsNil)
+	c.Assert(n.Erase(&Credential{}), IsNil)
+
+	missing := &Netrc{Path: filepath.Join(c.MkDir(), ".netrc")}
+	cred, err := missing.Get(&Credential{Host: "example.com"})
+	c.Assert(err, IsNil)
+	c.Assert(cred, IsNil)
+}
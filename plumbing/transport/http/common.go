@@ -3,11 +3,16 @@ package http
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/goabstract/go-git/v5/plumbing"
 	"github.com/goabstract/go-git/v5/plumbing/protocol/packp"
@@ -15,10 +20,33 @@ import (
 	"github.com/goabstract/go-git/v5/utils/ioutil"
 )
 
+var (
+	// ErrInvalidCABundle is returned when the CA bundle of an endpoint
+	// contains no PEM encoded certificate.
+	ErrInvalidCABundle = errors.New("invalid CA bundle")
+	// ErrUnsupportedTransport is returned when the TLS or proxy settings of an
+	// endpoint can't be applied, because the transport of the client is not
+	// an *http.Transport.
+	ErrUnsupportedTransport = errors.New("endpoint TLS and proxy settings require an *http.Transport")
+)
+
 // it requires a bytes.Buffer, because we need to know the length
-func applyHeadersToRequest(req *http.Request, content *bytes.Buffer, host string, requestType string) {
-	req.Header.Add("User-Agent", "git/1.0")
-	req.Header.Add("Host", host) // host:port
+func applyHeadersToRequest(req *http.Request, content *bytes.Buffer, ep *transport.Endpoint, requestType string) {
+	userAgent := "git/1.0"
+	if ep.UserAgent != "" {
+		userAgent = ep.UserAgent
+	}
+
+	req.Header.Add("User-Agent", userAgent)
+	req.Header.Add("Host", ep.Host) // host:port
+	for _, h := range ep.Headers {
+		i := strings.IndexByte(h, ':')
+		if i == -1 {
+			continue
+		}
+
+		req.Header.Add(strings.TrimSpace(h[:i]), strings.TrimSpace(h[i+1:]))
+	}
 
 	if content == nil {
 		req.Header.Add("Accept", "*/*")
@@ -43,9 +71,8 @@ func advertisedReferences(s *session, serviceName string) (ref *packp.AdvRefs, e
 		return nil, err
 	}
 
-	s.ApplyAuthToRequest(req)
-	applyHeadersToRequest(req, nil, s.endpoint.Host, serviceName)
-	res, err := s.client.Do(req)
+	applyHeadersToRequest(req, nil, s.endpoint, serviceName)
+	res, err := s.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -74,6 +101,17 @@ func advertisedReferences(s *session, serviceName string) (ref *packp.AdvRefs, e
 
 type client struct {
 	c *http.Client
+
+	m          sync.Mutex
+	transports map[transportOptions]*http.Transport
+}
+
+// transportOptions are the settings of an endpoint requiring a transport of
+// their own.
+type transportOptions struct {
+	insecureSkipTLS bool
+	caBundle        string
+	proxy           string
 }
 
 // DefaultClient is the default HTTP client, which uses `http.DefaultClient`.
@@ -84,29 +122,145 @@ var DefaultClient = NewClient(nil)
 // Unless a properly initialized client is given, it will fall back into
 // `http.DefaultClient`.
 //
+// The endpoints skipping the verification of the TLS certificates, with a
+// CA bundle or with a proxy, use a copy of the transport of the client with
+// these settings, if it is an *http.Transport, and the endpoints with a
+// cookie file a copy of the client with a cookie jar.
+//
 // Note that for HTTP client cannot distinguish between private repositories and
 // unexistent repositories on GitHub. So it returns `ErrAuthorizationRequired`
 // for both.
 func NewClient(c *http.Client) transport.Transport {
 	if c == nil {
-		return &client{http.DefaultClient}
+		c = http.DefaultClient
 	}
 
 	return &client{
-		c: c,
+		c:          c,
+		transports: make(map[transportOptions]*http.Transport),
 	}
 }
 
 func (c *client) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (
 	transport.UploadPackSession, error) {
 
-	return newUploadPackSession(c.c, ep, auth)
+	hc, err := c.httpClient(ep)
+	if err != nil {
+		return nil, err
+	}
+
+	return newUploadPackSession(hc, ep, auth)
 }
 
 func (c *client) NewReceivePackSession(ep *transport.Endpoint, auth transport.AuthMethod) (
 	transport.ReceivePackSession, error) {
 
-	return newReceivePackSession(c.c, ep, auth)
+	hc, err := c.httpClient(ep)
+	if err != nil {
+		return nil, err
+	}
+
+	return newReceivePackSession(hc, ep, auth)
+}
+
+// httpClient returns the net/http client the requests to the endpoint are
+// sent with.
+func (c *client) httpClient(ep *transport.Endpoint) (*http.Client, error) {
+	opts := transportOptions{
+		insecureSkipTLS: ep.InsecureSkipTLS,
+		caBundle:        string(ep.CABundle),
+		proxy:           ep.Proxy.URL,
+	}
+
+	if ep.Proxy.URL != "" {
+		proxy, err := ep.Proxy.FullURL()
+		if err != nil {
+			return nil, err
+		}
+
+		opts.proxy = proxy.String()
+	}
+
+	hc := c.c
+	if opts != (transportOptions{}) {
+		t, err := c.transport(opts)
+		if err != nil {
+			return nil, err
+		}
+
+		withTransport := *hc
+		withTransport.Transport = t
+		hc = &withTransport
+	}
+
+	if ep.CookieFile != "" {
+		jar, err := loadCookieFile(ep.CookieFile)
+		if err != nil {
+			return nil, err
+		}
+
+		withJar := *hc
+		withJar.Jar = jar
+		hc = &withJar
+	}
+
+	return hc, nil
+}
+
+// transport returns the transport of the endpoints with the given settings,
+// or ErrUnsupportedTransport if the transport of the client is not an
+// *http.Transport.
+func (c *client) transport(opts transportOptions) (*http.Transport, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if t, ok := c.transports[opts]; ok {
+		return t, nil
+	}
+
+	base := c.c.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	bt, ok := base.(*http.Transport)
+	if !ok {
+		return nil, ErrUnsupportedTransport
+	}
+
+	t := bt.Clone()
+	if opts.insecureSkipTLS || opts.caBundle != "" {
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+
+		t.TLSClientConfig.InsecureSkipVerify = opts.insecureSkipTLS
+	}
+
+	if opts.caBundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM([]byte(opts.caBundle)) {
+			return nil, ErrInvalidCABundle
+		}
+
+		t.TLSClientConfig.RootCAs = pool
+	}
+
+	if opts.proxy != "" {
+		proxy, err := url.Parse(opts.proxy)
+		if err != nil {
+			return nil, err
+		}
+
+		t.Proxy = http.ProxyURL(proxy)
+	}
+
+	c.transports[opts] = t
+	return t, nil
 }
 
 type session struct {
@@ -142,6 +296,56 @@ func (s *session) ApplyAuthToRequest(req *http.Request) {
 	s.auth.SetAuth(req)
 }
 
+// do sends the request with the auth of the session. If the server requires
+// authentication and the auth is a CredentialHelperAuth, the request is sent
+// again with the credentials filled by the helpers, which are told whether
+// the server accepted them.
+func (s *session) do(req *http.Request) (*http.Response, error) {
+	s.ApplyAuthToRequest(req)
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	helper, ok := s.auth.(*CredentialHelperAuth)
+	if !ok {
+		return res, nil
+	}
+
+	if res.StatusCode != http.StatusUnauthorized {
+		if res.StatusCode >= http.StatusOK && res.StatusCode < http.StatusMultipleChoices {
+			helper.approve()
+		}
+
+		return res, nil
+	}
+
+	if helper.isFilled() {
+		helper.reject()
+		return res, nil
+	}
+
+	filled, err := helper.fill(s.endpoint)
+	if err != nil {
+		_ = res.Body.Close()
+		return nil, err
+	}
+
+	if !filled {
+		return res, nil
+	}
+
+	_ = res.Body.Close()
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.do(retry)
+}
+
 func (s *session) ModifyEndpointIfRedirect(res *http.Response) {
 	if res.Request == nil {
 		return
@@ -2,12 +2,14 @@ package http
 
 import (
 	"crypto/tls"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"net/http/cgi"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"os/exec"
@@ -216,3 +218,144 @@ func (s *BaseSuite) TearDownTest(c *C) {
 	err := os.RemoveAll(s.base)
 	c.Assert(err, IsNil)
 }
+
+// newRecordingServer returns a server answering not found to every request,
+// storing the last one in req.
+func newRecordingServer(tls bool, req **http.Request) *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*req = r
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	if tls {
+		return httptest.NewTLSServer(handler)
+	}
+
+	return httptest.NewServer(handler)
+}
+
+func (s *ClientSuite) advertisedReferences(c *C, cl transport.Transport, ep *transport.Endpoint) error {
+	session, err := cl.NewUploadPackSession(ep, nil)
+	c.Assert(err, IsNil)
+	_, err = session.AdvertisedReferences()
+	return err
+}
+
+func (s *ClientSuite) TestEndpointHeaders(c *C) {
+	var req *http.Request
+	srv := newRecordingServer(false, &req)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "cookies")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	cookieFile := filepath.Join(dir, "cookies.txt")
+	c.Assert(ioutil.WriteFile(cookieFile, []byte(strings.Join([]string{
+		"# Netscape HTTP Cookie File",
+		"127.0.0.1\tFALSE\t/\tFALSE\t0\tsession\tfoo",
+		"#HttpOnly_127.0.0.1\tFALSE\t/foo.git\tFALSE\t0\tscoped\tbar",
+		"127.0.0.1\tFALSE\t/bar.git\tFALSE\t0\tother\tqux",
+		"127.0.0.1\tFALSE\t/\tFALSE\t1\texpired\tqux",
+		".example.com\tTRUE\t/\tFALSE\t0\tforeign\tqux",
+	}, "\n")), 0644), IsNil)
+
+	ep, err := transport.NewEndpoint(srv.URL + "/foo.git")
+	c.Assert(err, IsNil)
+	ep.Headers = []string{"X-Foo: foo", "X-Foo:bar", "invalid"}
+	ep.UserAgent = "git/2.30.0"
+	ep.CookieFile = cookieFile
+
+	err = s.advertisedReferences(c, NewClient(nil), ep)
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+	c.Assert(req.Header["X-Foo"], DeepEquals, []string{"foo", "bar"})
+	c.Assert(req.UserAgent(), Equals, "git/2.30.0")
+
+	var cookies []string
+	for _, cookie := range req.Cookies() {
+		cookies = append(cookies, cookie.String())
+	}
+
+	c.Assert(cookies, DeepEquals, []string{"scoped=bar", "session=foo"})
+
+	ep.CookieFile = filepath.Join(dir, "missing.txt")
+	err = s.advertisedReferences(c, NewClient(nil), ep)
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+	c.Assert(req.Cookies(), HasLen, 0)
+}
+
+func (s *ClientSuite) TestEndpointTLS(c *C) {
+	var req *http.Request
+	srv := newRecordingServer(true, &req)
+	defer srv.Close()
+
+	ep, err := transport.NewEndpoint(srv.URL + "/foo.git")
+	c.Assert(err, IsNil)
+
+	cl := NewClient(nil)
+	err = s.advertisedReferences(c, cl, ep)
+	c.Assert(err, ErrorMatches, ".*certificate.*")
+
+	ep.InsecureSkipTLS = true
+	c.Assert(s.advertisedReferences(c, cl, ep), Equals, transport.ErrRepositoryNotFound)
+
+	ep.InsecureSkipTLS = false
+	ep.CABundle = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	c.Assert(s.advertisedReferences(c, cl, ep), Equals, transport.ErrRepositoryNotFound)
+	c.Assert(s.advertisedReferences(c, cl, ep), Equals, transport.ErrRepositoryNotFound)
+	c.Assert(cl.(*client).transports, HasLen, 2)
+
+	ep.CABundle = []byte("foo")
+	_, err = cl.NewUploadPackSession(ep, nil)
+	c.Assert(err, Equals, ErrInvalidCABundle)
+}
+
+func (s *ClientSuite) TestEndpointProxy(c *C) {
+	var req *http.Request
+	proxy := newRecordingServer(false, &req)
+	defer proxy.Close()
+
+	ep, err := transport.NewEndpoint("http://example.com/foo.git")
+	c.Assert(err, IsNil)
+	ep.Proxy = transport.ProxyOptions{
+		URL:      strings.TrimPrefix(proxy.URL, "http://"),
+		Username: "foo",
+		Password: "bar",
+	}
+
+	c.Assert(s.advertisedReferences(c, NewClient(nil), ep), Equals, transport.ErrRepositoryNotFound)
+	c.Assert(req.URL.String(), Equals, "http://example.com/foo.git/info/refs?service=git-upload-pack")
+	c.Assert(req.Header.Get("Proxy-Authorization"), Equals, "Basic Zm9vOmJhcg==")
+
+	ep.Proxy.URL = "http://[::1"
+	_, err = NewClient(nil).NewUploadPackSession(ep, nil)
+	c.Assert(err, NotNil)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func (s *ClientSuite) TestEndpointCustomTransport(c *C) {
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(strings.NewReader("")), Request: r}, nil
+	})
+
+	ep, err := transport.NewEndpoint("https://example.com/foo.git")
+	c.Assert(err, IsNil)
+
+	cl := NewClient(&http.Client{Transport: rt})
+	c.Assert(s.advertisedReferences(c, cl, ep), Equals, transport.ErrRepositoryNotFound)
+
+	ep.InsecureSkipTLS = true
+	_, err = cl.NewUploadPackSession(ep, nil)
+	c.Assert(err, Equals, ErrUnsupportedTransport)
+
+	ep.InsecureSkipTLS = false
+	ep.Proxy.URL = "http://proxy.example.com"
+	_, err = cl.NewUploadPackSession(ep, nil)
+	c.Assert(err, Equals, ErrUnsupportedTransport)
+	c.Assert(cl.(*client).transports, HasLen, 0)
+}
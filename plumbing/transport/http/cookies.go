@@ -0,0 +1,91 @@
+package http
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const httpOnlyPrefix = "#HttpOnly_"
+
+// loadCookieFile returns a cookie jar with the cookies of the given file, in
+// the Netscape format, as curl and git read it. The expired cookies are
+// skipped and a missing file gives an empty jar.
+func loadCookieFile(path string) (http.CookieJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return jar, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	now := time.Now()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		u, cookie := parseCookieLine(scanner.Text())
+		if cookie == nil || (!cookie.Expires.IsZero() && cookie.Expires.Before(now)) {
+			continue
+		}
+
+		jar.SetCookies(u, []*http.Cookie{cookie})
+	}
+
+	return jar, scanner.Err()
+}
+
+// parseCookieLine parses a line of a cookie file, returning the cookie and
+// the URL it is set for, or a nil cookie for the blank lines, the comments
+// and the malformed lines.
+func parseCookieLine(text string) (*url.URL, *http.Cookie) {
+	httpOnly := strings.HasPrefix(text, httpOnlyPrefix)
+	if httpOnly {
+		text = text[len(httpOnlyPrefix):]
+	}
+
+	if strings.HasPrefix(text, "#") {
+		return nil, nil
+	}
+
+	fields := strings.Split(strings.TrimRight(text, "\r"), "\t")
+	if len(fields) != 7 {
+		return nil, nil
+	}
+
+	domain, subdomains, path, secure := fields[0], fields[1], fields[2], fields[3]
+	cookie := &http.Cookie{
+		Name:     fields[5],
+		Value:    fields[6],
+		Path:     path,
+		Secure:   strings.EqualFold(secure, "TRUE"),
+		HttpOnly: httpOnly,
+	}
+
+	if strings.EqualFold(subdomains, "TRUE") {
+		cookie.Domain = domain
+	}
+
+	if expires, err := strconv.ParseInt(fields[4], 10, 64); err == nil && expires != 0 {
+		cookie.Expires = time.Unix(expires, 0)
+	}
+
+	scheme := "http"
+	if cookie.Secure {
+		scheme = "https"
+	}
+
+	return &url.URL{Scheme: scheme, Host: strings.TrimPrefix(domain, "."), Path: path}, cookie
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/goabstract/go-git/v5/plumbing/transport"
+	"github.com/goabstract/go-git/v5/plumbing/transport/credential"
+)
+
+// CredentialHelperAuth implements an http.AuthMethod filling the credentials
+// with credential helpers when the server requires authentication, and
+// reporting to the helpers whether the server accepted them, as git does.
+type CredentialHelperAuth struct {
+	// Helpers are the credential helpers asked for the credentials.
+	Helpers credential.Helpers
+	// Credential describes the credentials asked for, the ones of the
+	// protocol and the host of the endpoint if nil.
+	Credential *credential.Credential
+
+	m        sync.Mutex
+	filled   *credential.Credential
+	approved bool
+}
+
+// SetAuth sets the credentials filled by the helpers, if any, to the request.
+func (a *CredentialHelperAuth) SetAuth(r *http.Request) {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	if a.filled != nil {
+		r.SetBasicAuth(a.filled.Username, a.filled.Password)
+	}
+}
+
+// Name is name of the auth
+func (a *CredentialHelperAuth) Name() string {
+	return "http-credential-helper"
+}
+
+func (a *CredentialHelperAuth) String() string {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	if a.filled == nil {
+		return fmt.Sprintf("%s - <empty>", a.Name())
+	}
+
+	return fmt.Sprintf("%s - %s:*******", a.Name(), a.filled.Username)
+}
+
+// fill fills the credentials with the helpers, returning false if they have
+// none.
+func (a *CredentialHelperAuth) fill(ep *transport.Endpoint) (bool, error) {
+	query := a.Credential
+	if query == nil {
+		var err error
+		if query, err = credential.NewCredential(ep.String(), false); err != nil {
+			return false, err
+		}
+	}
+
+	filled, err := a.Helpers.Fill(query)
+	if err == credential.ErrCredentialNotFound {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	a.filled, a.approved = filled, false
+	return true, nil
+}
+
+// isFilled returns true if the credentials were filled by the helpers.
+func (a *CredentialHelperAuth) isFilled() bool {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	return a.filled != nil
+}
+
+// approve reports the credentials filled, accepted by the server, to the
+// helpers, once. As git does, the errors of the helpers are ignored.
+func (a *CredentialHelperAuth) approve() {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	if a.filled == nil || a.approved {
+		return
+	}
+
+	a.approved = true
+	_ = a.Helpers.Approve(a.filled)
+}
+
+// reject reports the credentials filled, rejected by the server, to the
+// helpers, and forgets them. As git does, the errors of the helpers are
+// ignored.
+func (a *CredentialHelperAuth) reject() {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	if a.filled == nil {
+		return
+	}
+
+	_ = a.Helpers.Reject(a.filled)
+	a.filled = nil
+}
@@ -0,0 +1,114 @@
+package http
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/goabstract/go-git/v5/plumbing/transport"
+	"github.com/goabstract/go-git/v5/plumbing/transport/credential"
+
+	. "gopkg.in/check.v1"
+)
+
+type CredentialHelperAuthSuite struct {
+	srv      *httptest.Server
+	requests int
+}
+
+var _ = Suite(&CredentialHelperAuthSuite{})
+
+func (s *CredentialHelperAuthSuite) SetUpTest(c *C) {
+	s.requests = 0
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests++
+		if user, pass, ok := r.BasicAuth(); !ok || user != "foo" || pass != "bar" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+}
+
+func (s *CredentialHelperAuthSuite) TearDownTest(c *C) {
+	s.srv.Close()
+}
+
+func (s *CredentialHelperAuthSuite) session(c *C, auth *CredentialHelperAuth) *session {
+	ep, err := transport.NewEndpoint(s.srv.URL + "/foo.git")
+	c.Assert(err, IsNil)
+
+	session, err := newSession(http.DefaultClient, ep, auth)
+	c.Assert(err, IsNil)
+	return session
+}
+
+func (s *CredentialHelperAuthSuite) post(c *C, session *session) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, s.srv.URL+"/foo.git/git-upload-pack", bytes.NewBufferString("foo"))
+	c.Assert(err, IsNil)
+
+	res, err := session.do(req)
+	c.Assert(err, IsNil)
+	return res
+}
+
+func (s *CredentialHelperAuthSuite) TestApprove(c *C) {
+	store, cache := credential.NewStore(), credential.NewCache(0)
+	host := s.srv.Listener.Addr().String()
+	c.Assert(store.Store(&credential.Credential{
+		Protocol: "http", Host: host, Username: "foo", Password: "bar",
+	}), IsNil)
+
+	auth := &CredentialHelperAuth{Helpers: credential.Helpers{cache, store}}
+	session := s.session(c, auth)
+
+	res := s.post(c, session)
+	c.Assert(res.StatusCode, Equals, http.StatusOK)
+	body, err := ioutil.ReadAll(res.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Equals, "foo")
+	c.Assert(s.requests, Equals, 2)
+	c.Assert(auth.String(), Equals, "http-credential-helper - foo:*******")
+
+	cached, err := cache.Get(&credential.Credential{Protocol: "http", Host: host})
+	c.Assert(err, IsNil)
+	c.Assert(cached.Password, Equals, "bar")
+
+	res = s.post(c, session)
+	c.Assert(res.StatusCode, Equals, http.StatusOK)
+	c.Assert(s.requests, Equals, 3)
+}
+
+func (s *CredentialHelperAuthSuite) TestReject(c *C) {
+	store := credential.NewStore()
+	query := &credential.Credential{Protocol: "http", Host: s.srv.Listener.Addr().String(), Username: "foo"}
+	c.Assert(store.Store(&credential.Credential{
+		Protocol: query.Protocol, Host: query.Host, Username: "foo", Password: "wrong",
+	}), IsNil)
+
+	auth := &CredentialHelperAuth{Helpers: credential.Helpers{store}, Credential: query}
+	_, err := advertisedReferences(s.session(c, auth), transport.UploadPackServiceName)
+	c.Assert(err, Equals, transport.ErrAuthenticationRequired)
+	c.Assert(s.requests, Equals, 2)
+	c.Assert(auth.String(), Equals, "http-credential-helper - <empty>")
+
+	stored, err := store.Get(query)
+	c.Assert(err, IsNil)
+	c.Assert(stored, IsNil)
+
+	_, err = advertisedReferences(s.session(c, auth), transport.UploadPackServiceName)
+	c.Assert(err, Equals, transport.ErrAuthenticationRequired)
+	c.Assert(s.requests, Equals, 3)
+}
+
+func (s *CredentialHelperAuthSuite) TestHelperError(c *C) {
+	auth := &CredentialHelperAuth{Helpers: credential.Helpers{
+		credential.NewExecHelper("!exit 1; true"),
+	}}
+
+	_, err := advertisedReferences(s.session(c, auth), transport.UploadPackServiceName)
+	c.Assert(err, ErrorMatches, "credential helper .*")
+}
@@ -89,10 +89,8 @@ func (s *rpSession) doRequest(
 		return nil, plumbing.NewPermanentError(err)
 	}
 
-	applyHeadersToRequest(req, content, s.endpoint.Host, transport.ReceivePackServiceName)
-	s.ApplyAuthToRequest(req)
-
-	res, err := s.client.Do(req.WithContext(ctx))
+	applyHeadersToRequest(req, content, s.endpoint, transport.ReceivePackServiceName)
+	res, err := s.do(req.WithContext(ctx))
 	if err != nil {
 		return nil, plumbing.NewUnexpectedError(err)
 	}
@@ -87,10 +87,8 @@ func (s *upSession) doRequest(
 		return nil, plumbing.NewPermanentError(err)
 	}
 
-	applyHeadersToRequest(req, content, s.endpoint.Host, transport.UploadPackServiceName)
-	s.ApplyAuthToRequest(req)
-
-	res, err := s.client.Do(req.WithContext(ctx))
+	applyHeadersToRequest(req, content, s.endpoint, transport.UploadPackServiceName)
+	res, err := s.do(req.WithContext(ctx))
 	if err != nil {
 		return nil, plumbing.NewUnexpectedError(err)
 	}
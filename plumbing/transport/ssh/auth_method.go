@@ -320,3 +320,7 @@ func (m *HostKeyCallbackHelper) SetHostKeyCallback(cfg *ssh.ClientConfig) (*ssh.
 	cfg.HostKeyCallback = m.HostKeyCallback
 	return cfg, nil
 }
+
+func (m *HostKeyCallbackHelper) hostKeyCallbackHelper() *HostKeyCallbackHelper {
+	return m
+}
@@ -4,8 +4,11 @@ package ssh
 import (
 	"context"
 	"fmt"
+	"net"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/goabstract/go-git/v5/plumbing/transport"
 	"github.com/goabstract/go-git/v5/plumbing/transport/internal/common"
@@ -31,6 +34,14 @@ func NewClient(config *ssh.ClientConfig) transport.Transport {
 	return common.NewClient(&runner{config: config})
 }
 
+// NewPooledClient creates a new SSH client with an optional
+// *ssh.ClientConfig, reusing the connections of the given pool, such as
+// several sessions to the same server share one connection. It can be
+// installed with client.InstallProtocol, the pool being closed once done.
+func NewPooledClient(config *ssh.ClientConfig, pool *Pool) transport.Transport {
+	return common.NewClient(&runner{config: config, pool: pool})
+}
+
 // DefaultAuthBuilder is the function used to create a default AuthMethod, when
 // the user doesn't provide any.
 var DefaultAuthBuilder = func(user string) (AuthMethod, error) {
@@ -41,10 +52,11 @@ const DefaultPort = 22
 
 type runner struct {
 	config *ssh.ClientConfig
+	pool   *Pool
 }
 
 func (r *runner) Command(cmd string, ep *transport.Endpoint, auth transport.AuthMethod) (common.Command, error) {
-	c := &command{command: cmd, endpoint: ep, config: r.config}
+	c := &command{command: cmd, endpoint: ep, config: r.config, pool: r.pool}
 	if auth != nil {
 		c.setAuth(auth)
 	}
@@ -63,6 +75,9 @@ type command struct {
 	client    *ssh.Client
 	auth      AuthMethod
 	config    *ssh.ClientConfig
+	pool      *Pool
+	pooled    bool
+	key       poolKey
 }
 
 func (c *command) setAuth(auth transport.AuthMethod) error {
@@ -91,40 +106,79 @@ func (c *command) Close() error {
 	//     closed.
 	_ = c.Session.Close()
 
-	return c.client.Close()
+	return c.release()
+}
+
+// release closes the connection, or releases it to the pool it was got from.
+func (c *command) release() error {
+	if !c.pooled {
+		return c.client.Close()
+	}
+
+	c.pool.put(c.key, c.client)
+	return nil
 }
 
 // connect connects to the SSH server, unless a AuthMethod was set with
 // SetAuth method, by default uses an auth method based on PublicKeysCallback,
 // it connects to a SSH agent, using the address stored in the SSH_AUTH_SOCK
 // environment var.
+//
+// The settings of the ssh_config files for the host of the endpoint are
+// honoured: the user, the identities and the known hosts are used unless
+// given by the AuthMethod, the connect timeout and the host key algorithms
+// unless set by the client config, and the connection goes through the
+// ProxyJump hosts or the ProxyCommand.
 func (c *command) connect() error {
 	if c.connected {
 		return transport.ErrAlreadyConnected
 	}
 
-	if c.auth == nil {
-		if err := c.setAuthFromEndpoint(); err != nil {
+	addr := c.getHostWithPort()
+	hc := newHostConfig(c.endpoint.Host)
+	t := c.tokens(addr, hc)
+
+	explicit := c.auth != nil
+	if !explicit {
+		if err := c.setAuthFromEndpoint(hc, t); err != nil {
 			return err
 		}
 	}
 
-	var err error
-	config, err := c.auth.ClientConfig()
+	config, err := c.clientConfig(c.auth, hc, t)
 	if err != nil {
 		return err
 	}
 
-	overrideConfig(c.config, config)
+	dial := func() (*ssh.Client, error) {
+		client, err := c.dial(addr, config, hc, t, explicit)
+		if err == nil && hc.serverAliveInterval > 0 {
+			go keepAlive(client, hc.serverAliveInterval, hc.serverAliveCountMax)
+		}
+
+		return client, err
+	}
+
+	var auth AuthMethod
+	if explicit {
+		auth = c.auth
+	}
+
+	if c.pool != nil && (auth == nil || reflect.TypeOf(auth).Comparable()) {
+		c.key = poolKey{addr: addr, user: config.User, auth: auth, config: c.config}
+		c.client, err = c.pool.get(c.key, dial)
+		c.pooled = err == nil
+	} else {
+		c.client, err = dial()
+	}
 
-	c.client, err = dial("tcp", c.getHostWithPort(), config)
 	if err != nil {
 		return err
 	}
 
 	c.Session, err = c.client.NewSession()
 	if err != nil {
-		_ = c.client.Close()
+		_ = c.release()
 		return err
 	}
 
@@ -132,6 +186,238 @@ func (c *command) connect() error {
 	return nil
 }
 
+// tokens returns the values of the tokens of ssh_config for the address the
+// endpoint is connected to.
+func (c *command) tokens(addr string, hc *hostConfig) *tokens {
+	t := &tokens{alias: c.endpoint.Host, host: addr, user: c.endpoint.User}
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		t.host = host
+		t.port, _ = strconv.Atoi(port)
+	}
+
+	if t.user == "" {
+		t.user = hc.user
+	}
+
+	return t
+}
+
+// clientConfig returns the client config of the auth method, overridden by
+// the config of the client, with the known hosts, the connect timeout and the
+// host key algorithms of ssh_config unless set by them.
+func (c *command) clientConfig(auth AuthMethod, hc *hostConfig, t *tokens) (*ssh.ClientConfig, error) {
+	var callback ssh.HostKeyCallback
+	if h, ok := auth.(interface {
+		hostKeyCallbackHelper() *HostKeyCallbackHelper
+	}); ok && h.hostKeyCallbackHelper().HostKeyCallback == nil {
+		var err error
+		if callback, err = hc.hostKeyCallback(t); err != nil {
+			return nil, err
+		}
+	}
+
+	// the default known hosts of the auth method are replaced by the ones of
+	// ssh_config, so they are not required to load
+	config, err := auth.ClientConfig()
+	if err != nil && (callback == nil || config == nil) {
+		return nil, err
+	}
+
+	if callback != nil {
+		config.HostKeyCallback = callback
+	}
+
+	overrideConfig(c.config, config)
+	if config.Timeout == 0 {
+		config.Timeout = hc.connectTimeout
+	}
+
+	if len(config.HostKeyAlgorithms) == 0 {
+		config.HostKeyAlgorithms = hc.hostKeyAlgorithms
+	}
+
+	return config, nil
+}
+
+// dial connects to the address, through the ProxyJump hosts or the
+// ProxyCommand of the host config, if any.
+func (c *command) dial(addr string, config *ssh.ClientConfig, hc *hostConfig, t *tokens, explicit bool) (*ssh.Client, error) {
+	var conn net.Conn
+	var err error
+	switch {
+	case hc.proxyJump != "":
+		conn, err = c.dialJumps(hc.proxyJump, addr, config.Timeout, explicit)
+	case hc.proxyCommand != "":
+		conn, err = dialCommand(t.expand(hc.proxyCommand))
+	default:
+		return dial("tcp", addr, config)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newClient(conn, addr, config)
+}
+
+// dialJumps connects to the address through the ProxyJump hosts, given as
+// comma separated [user@]host[:port] or ssh://[user@]host[:port], each one
+// with the settings of ssh_config for its host. An AuthMethod given is used
+// for the jump hosts too.
+func (c *command) dialJumps(jumps, addr string, timeout time.Duration, explicit bool) (net.Conn, error) {
+	var clients []*ssh.Client
+	closeAll := func() {
+		for i := len(clients) - 1; i >= 0; i-- {
+			_ = clients[i].Close()
+		}
+	}
+
+	for _, jump := range strings.Split(jumps, ",") {
+		user, alias, port := parseJump(jump)
+		hc := newHostConfig(alias)
+		if user == "" {
+			user = hc.user
+		}
+
+		t := &tokens{alias: alias, host: alias, user: user, port: port}
+		if hc.hostname != "" {
+			t.host = hc.hostname
+		}
+
+		if t.port == 0 {
+			t.port = hc.port
+		}
+
+		if t.port == 0 {
+			t.port = DefaultPort
+		}
+
+		jumpAddr := net.JoinHostPort(t.host, strconv.Itoa(t.port))
+		config, err := c.jumpClientConfig(user, hc, t, explicit)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		if config.Timeout == 0 {
+			config.Timeout = timeout
+		}
+
+		var client *ssh.Client
+		if len(clients) == 0 {
+			client, err = dial("tcp", jumpAddr, config)
+		} else {
+			var conn net.Conn
+			if conn, err = clients[len(clients)-1].Dial("tcp", jumpAddr); err == nil {
+				client, err = newClient(conn, jumpAddr, config)
+			}
+		}
+
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		clients = append(clients, client)
+	}
+
+	conn, err := clients[len(clients)-1].Dial("tcp", addr)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+
+	return &jumpConn{Conn: conn, jumps: clients}, nil
+}
+
+// jumpClientConfig returns the client config to connect to a jump host as
+// the user, or as the local user if empty and the AuthMethod is given.
+func (c *command) jumpClientConfig(user string, hc *hostConfig, t *tokens, explicit bool) (*ssh.ClientConfig, error) {
+	if !explicit {
+		auth, err := hc.defaultAuth(user, t)
+		if err != nil {
+			return nil, err
+		}
+
+		return c.clientConfig(auth, hc, t)
+	}
+
+	config, err := c.clientConfig(c.auth, hc, t)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == "" {
+		if user, err = username(); err != nil {
+			return nil, err
+		}
+	}
+
+	config.User = user
+	return config, nil
+}
+
+// parseJump parses a ProxyJump host.
+func parseJump(jump string) (user, host string, port int) {
+	host = strings.TrimPrefix(strings.TrimSpace(jump), "ssh://")
+	if i := strings.LastIndexByte(host, '@'); i != -1 {
+		user, host = host[:i], host[i+1:]
+	}
+
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host = h
+		port, _ = strconv.Atoi(p)
+	}
+
+	return user, host, port
+}
+
+// keepAlive sends a keepalive request to the server every interval, closing
+// the connection after count requests without answer, as ssh does with
+// ServerAliveInterval and ServerAliveCountMax, until it is closed.
+func keepAlive(client *ssh.Client, interval time.Duration, count int) {
+	closed := make(chan struct{})
+	go func() {
+		_ = client.Wait()
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	missed := 0
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+		}
+
+		answered := make(chan error, 1)
+		go func() {
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			answered <- err
+		}()
+
+		select {
+		case <-closed:
+			return
+		case err := <-answered:
+			if err == nil {
+				missed = 0
+				continue
+			}
+		case <-time.After(interval):
+		}
+
+		missed++
+		if missed >= count {
+			_ = client.Close()
+			return
+		}
+	}
+}
+
 func dial(network, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
 	var (
 		ctx    = context.Background()
@@ -148,10 +434,19 @@ func dial(network, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	return newClient(conn, addr, config)
+}
+
+// newClient returns a client of the SSH server connected with conn, closing
+// it on errors.
+func newClient(conn net.Conn, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
 	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
 	if err != nil {
+		_ = conn.Close()
 		return nil, err
 	}
+
 	return ssh.NewClient(c, chans, reqs), nil
 }
 
@@ -198,9 +493,9 @@ func (c *command) doGetHostWithPortFromSSHConfig() (addr string, found bool) {
 	return
 }
 
-func (c *command) setAuthFromEndpoint() error {
+func (c *command) setAuthFromEndpoint(hc *hostConfig, t *tokens) error {
 	var err error
-	c.auth, err = DefaultAuthBuilder(c.endpoint.User)
+	c.auth, err = hc.defaultAuth(c.endpoint.User, t)
 	return err
 }
 
@@ -106,3 +106,12 @@ func (c *mockSSHConfig) Get(alias, key string) string {
 
 	return a[key]
 }
+
+type mockSSHConfigAll struct {
+	*mockSSHConfig
+	All map[string]map[string][]string
+}
+
+func (c *mockSSHConfigAll) GetAll(alias, key string) []string {
+	return c.All[alias][key]
+}
@@ -0,0 +1,205 @@
+package ssh
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultIdleTimeout is the time a Pool keeps open a connection without
+// sessions by default.
+const DefaultIdleTimeout = time.Minute
+
+// maxSessions is the number of sessions multiplexed over a connection of a
+// Pool, the default MaxSessions of sshd, before another one is opened.
+const maxSessions = 10
+
+// Pool is a pool of connections to SSH servers, shared by the clients
+// created with NewPooledClient. The sessions to the same address, as the
+// same user, with the same AuthMethod and client config, are multiplexed
+// over one connection, closed once it has been idle for the timeout of the
+// pool.
+type Pool struct {
+	m           sync.Mutex
+	conns       map[poolKey][]*pooledConn
+	idleTimeout time.Duration
+}
+
+// poolKey identifies the connections of a Pool which can be shared. The
+// auth is nil for the default AuthMethod of the user.
+type poolKey struct {
+	addr   string
+	user   string
+	auth   AuthMethod
+	config *ssh.ClientConfig
+}
+
+// pooledConn is a connection of a Pool with the number of sessions using it.
+type pooledConn struct {
+	client   *ssh.Client
+	sessions int
+	idle     *time.Timer
+}
+
+// NewPool returns an empty pool, closing the connections idle for the
+// given time, or for DefaultIdleTimeout if it is 0.
+func NewPool(idleTimeout time.Duration) *Pool {
+	if idleTimeout == 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+
+	return &Pool{
+		conns:       make(map[poolKey][]*pooledConn),
+		idleTimeout: idleTimeout,
+	}
+}
+
+// get returns a connection of the pool for the key with less than
+// maxSessions sessions, or else a new one connected with dial, adding a
+// session to it. It must be released with put once the session is closed.
+func (p *Pool) get(key poolKey, dial func() (*ssh.Client, error)) (*ssh.Client, error) {
+	if client := p.reuse(key); client != nil {
+		return client, nil
+	}
+
+	client, err := dial()
+	if err != nil {
+		return nil, err
+	}
+
+	pc := &pooledConn{client: client, sessions: 1}
+	p.m.Lock()
+	p.conns[key] = append(p.conns[key], pc)
+	p.m.Unlock()
+
+	go func() {
+		_ = client.Wait()
+		p.remove(key, pc)
+	}()
+
+	return client, nil
+}
+
+// reuse returns a connection of the pool for the key with less than
+// maxSessions sessions, adding a session to it, nil if there is none.
+func (p *Pool) reuse(key poolKey) *ssh.Client {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	for _, pc := range p.conns[key] {
+		if pc.sessions >= maxSessions {
+			continue
+		}
+
+		if pc.idle != nil {
+			pc.idle.Stop()
+			pc.idle = nil
+		}
+
+		pc.sessions++
+		return pc.client
+	}
+
+	return nil
+}
+
+// put releases a session of the connection got for the key, closing it
+// after the idle timeout if it was the last one.
+func (p *Pool) put(key poolKey, client *ssh.Client) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	for _, pc := range p.conns[key] {
+		if pc.client != client {
+			continue
+		}
+
+		pc.sessions--
+		if pc.sessions == 0 {
+			pc.idle = time.AfterFunc(p.idleTimeout, func() { p.expire(key, pc) })
+		}
+
+		return
+	}
+}
+
+// expire closes the connection if it is still idle.
+func (p *Pool) expire(key poolKey, pc *pooledConn) {
+	p.m.Lock()
+	idle := pc.sessions == 0
+	if idle {
+		p.removeLocked(key, pc)
+	}
+	p.m.Unlock()
+
+	if idle {
+		_ = pc.client.Close()
+	}
+}
+
+// remove removes the connection from the pool.
+func (p *Pool) remove(key poolKey, pc *pooledConn) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.removeLocked(key, pc)
+}
+
+func (p *Pool) removeLocked(key poolKey, pc *pooledConn) {
+	conns := p.conns[key]
+	for i := range conns {
+		if conns[i] != pc {
+			continue
+		}
+
+		conns = append(conns[:i:i], conns[i+1:]...)
+		if len(conns) == 0 {
+			delete(p.conns, key)
+		} else {
+			p.conns[key] = conns
+		}
+
+		return
+	}
+}
+
+// Len returns the number of open connections of the pool.
+func (p *Pool) Len() int {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	var n int
+	for _, conns := range p.conns {
+		n += len(conns)
+	}
+
+	return n
+}
+
+// Close closes all the connections of the pool, the idle ones and the ones
+// in use. The pool can still be used afterwards, opening new connections.
+func (p *Pool) Close() error {
+	p.m.Lock()
+	var clients []*ssh.Client
+	for _, conns := range p.conns {
+		for _, pc := range conns {
+			if pc.idle != nil {
+				pc.idle.Stop()
+			}
+
+			clients = append(clients, pc.client)
+		}
+	}
+
+	p.conns = make(map[poolKey][]*pooledConn)
+	p.m.Unlock()
+
+	var firstErr error
+	for _, client := range clients {
+		if err := client.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
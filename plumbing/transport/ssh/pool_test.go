@@ -0,0 +1,160 @@
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/goabstract/go-git/v5/plumbing/transport"
+	"github.com/goabstract/go-git/v5/plumbing/transport/internal/common"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/kevinburke/ssh_config"
+	stdssh "golang.org/x/crypto/ssh"
+	. "gopkg.in/check.v1"
+)
+
+type PoolSuite struct {
+	port       int
+	handshakes int32
+	auth       *Password
+}
+
+var _ = Suite(&PoolSuite{})
+
+func (s *PoolSuite) SetUpSuite(c *C) {
+	l, err := net.Listen("tcp", "localhost:0")
+	c.Assert(err, IsNil)
+
+	s.port = l.Addr().(*net.TCPAddr).Port
+	server := &ssh.Server{
+		Handler: func(ssh.Session) {},
+		PasswordHandler: func(ssh.Context, string) bool {
+			atomic.AddInt32(&s.handshakes, 1)
+			return true
+		},
+	}
+
+	go server.Serve(l)
+}
+
+func (s *PoolSuite) SetUpTest(c *C) {
+	DefaultSSHConfig = nil
+	atomic.StoreInt32(&s.handshakes, 0)
+	s.auth = &Password{User: "git", HostKeyCallbackHelper: HostKeyCallbackHelper{
+		HostKeyCallback: stdssh.InsecureIgnoreHostKey(),
+	}}
+}
+
+func (s *PoolSuite) TearDownTest(c *C) {
+	DefaultSSHConfig = ssh_config.DefaultUserSettings
+}
+
+func (s *PoolSuite) command(c *C, pool *Pool, user string, auth transport.AuthMethod) common.Command {
+	ep, err := transport.NewEndpoint(fmt.Sprintf("ssh://%s@localhost:%d/foo.git", user, s.port))
+	c.Assert(err, IsNil)
+
+	r := &runner{pool: pool}
+	cmd, err := r.Command(transport.UploadPackServiceName, ep, auth)
+	c.Assert(err, IsNil)
+	return cmd
+}
+
+func (s *PoolSuite) TestReuse(c *C) {
+	pool := NewPool(0)
+	defer pool.Close()
+
+	for i := 0; i < 3; i++ {
+		cmd := s.command(c, pool, "git", s.auth)
+		c.Assert(cmd.Close(), IsNil)
+	}
+
+	c.Assert(atomic.LoadInt32(&s.handshakes), Equals, int32(1))
+	c.Assert(pool.Len(), Equals, 1)
+}
+
+func (s *PoolSuite) TestReuseDefaultAuth(c *C) {
+	DefaultAuthBuilder = func(user string) (AuthMethod, error) {
+		return &Password{User: user, HostKeyCallbackHelper: HostKeyCallbackHelper{
+			HostKeyCallback: stdssh.InsecureIgnoreHostKey(),
+		}}, nil
+	}
+
+	pool := NewPool(0)
+	defer pool.Close()
+
+	for _, user := range []string{"foo", "foo", "bar"} {
+		cmd := s.command(c, pool, user, nil)
+		c.Assert(cmd.Close(), IsNil)
+	}
+
+	c.Assert(atomic.LoadInt32(&s.handshakes), Equals, int32(2))
+	c.Assert(pool.Len(), Equals, 2)
+}
+
+func (s *PoolSuite) TestMaxSessions(c *C) {
+	pool := NewPool(0)
+	defer pool.Close()
+
+	var cmds []common.Command
+	for i := 0; i < maxSessions+1; i++ {
+		cmds = append(cmds, s.command(c, pool, "git", s.auth))
+	}
+
+	c.Assert(atomic.LoadInt32(&s.handshakes), Equals, int32(2))
+	c.Assert(pool.Len(), Equals, 2)
+
+	for _, cmd := range cmds {
+		c.Assert(cmd.Close(), IsNil)
+	}
+
+	cmd := s.command(c, pool, "git", s.auth)
+	c.Assert(cmd.Close(), IsNil)
+	c.Assert(atomic.LoadInt32(&s.handshakes), Equals, int32(2))
+}
+
+func (s *PoolSuite) TestIdleTimeout(c *C) {
+	pool := NewPool(50 * time.Millisecond)
+	defer pool.Close()
+
+	cmd := s.command(c, pool, "git", s.auth)
+	time.Sleep(100 * time.Millisecond)
+	c.Assert(pool.Len(), Equals, 1)
+	c.Assert(cmd.Close(), IsNil)
+
+	time.Sleep(100 * time.Millisecond)
+	c.Assert(pool.Len(), Equals, 0)
+
+	cmd = s.command(c, pool, "git", s.auth)
+	c.Assert(cmd.Close(), IsNil)
+	c.Assert(atomic.LoadInt32(&s.handshakes), Equals, int32(2))
+}
+
+func (s *PoolSuite) TestClose(c *C) {
+	pool := NewPool(0)
+
+	cmd := s.command(c, pool, "git", s.auth)
+	c.Assert(cmd.Close(), IsNil)
+	c.Assert(pool.Close(), IsNil)
+	c.Assert(pool.Len(), Equals, 0)
+
+	cmd = s.command(c, pool, "git", s.auth)
+	c.Assert(cmd.Close(), IsNil)
+	c.Assert(pool.Close(), IsNil)
+	c.Assert(atomic.LoadInt32(&s.handshakes), Equals, int32(2))
+}
+
+func (s *PoolSuite) TestNewClient(c *C) {
+	ep, err := transport.NewEndpoint(fmt.Sprintf("ssh://git@localhost:%d/foo.git", s.port))
+	c.Assert(err, IsNil)
+
+	r := &runner{}
+	for i := 0; i < 2; i++ {
+		cmd, err := r.Command(transport.UploadPackServiceName, ep, s.auth)
+		c.Assert(err, IsNil)
+		c.Assert(cmd.Close(), IsNil)
+	}
+
+	c.Assert(atomic.LoadInt32(&s.handshakes), Equals, int32(2))
+}
@@ -0,0 +1,359 @@
+package ssh
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kevinburke/ssh_config"
+	"github.com/mitchellh/go-homedir"
+	sshagent "github.com/xanzy/ssh-agent"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// defaultServerAliveCountMax is the number of keepalive requests without
+// answer before the connection is closed, unless ServerAliveCountMax is set.
+const defaultServerAliveCountMax = 3
+
+// defaultIdentityFiles are the identities used when IdentitiesOnly is set
+// without IdentityFile, as ssh does.
+var defaultIdentityFiles = []string{
+	"~/.ssh/id_rsa", "~/.ssh/id_ecdsa", "~/.ssh/id_ed25519", "~/.ssh/id_dsa",
+}
+
+// hostConfig are the settings of the ssh_config files applying to a host
+// alias. The values equal to the defaults of ssh_config are ignored, so the
+// settings of the transport apply instead.
+type hostConfig struct {
+	alias                 string
+	hostname              string
+	port                  int
+	user                  string
+	identityFiles         []string
+	identitiesOnly        bool
+	proxyCommand          string
+	proxyJump             string
+	knownHostsFiles       []string
+	strictHostKeyChecking string
+	connectTimeout        time.Duration
+	serverAliveInterval   time.Duration
+	serverAliveCountMax   int
+	hostKeyAlgorithms     []string
+}
+
+// newHostConfig returns the settings of DefaultSSHConfig for the host alias,
+// none if it is nil. The HostKeyAlgorithms changing the default algorithms,
+// starting with +, - or ^, are ignored.
+func newHostConfig(alias string) *hostConfig {
+	hc := &hostConfig{alias: alias, serverAliveCountMax: defaultServerAliveCountMax}
+	if DefaultSSHConfig == nil {
+		return hc
+	}
+
+	get := func(key string) string {
+		value := DefaultSSHConfig.Get(alias, key)
+		if value == ssh_config.Default(key) {
+			return ""
+		}
+
+		return value
+	}
+
+	seconds := func(key string) time.Duration {
+		n, _ := strconv.Atoi(get(key))
+		return time.Duration(n) * time.Second
+	}
+
+	hc.hostname = get("Hostname")
+	hc.port, _ = strconv.Atoi(get("Port"))
+	hc.user = get("User")
+	for _, file := range getAll(alias, "IdentityFile") {
+		if file != ssh_config.Default("IdentityFile") {
+			hc.identityFiles = append(hc.identityFiles, file)
+		}
+	}
+
+	hc.identitiesOnly = strings.EqualFold(get("IdentitiesOnly"), "yes")
+	if command := get("ProxyCommand"); !strings.EqualFold(command, "none") {
+		hc.proxyCommand = command
+	}
+
+	if jump := get("ProxyJump"); !strings.EqualFold(jump, "none") {
+		hc.proxyJump = jump
+	}
+
+	hc.knownHostsFiles = strings.Fields(get("UserKnownHostsFile"))
+	hc.strictHostKeyChecking = strings.ToLower(get("StrictHostKeyChecking"))
+	hc.connectTimeout = seconds("ConnectTimeout")
+	hc.serverAliveInterval = seconds("ServerAliveInterval")
+	if n, err := strconv.Atoi(get("ServerAliveCountMax")); err == nil {
+		hc.serverAliveCountMax = n
+	}
+
+	if algorithms := get("HostKeyAlgorithms"); algorithms != "" && !strings.ContainsAny(algorithms[:1], "+-^") {
+		hc.hostKeyAlgorithms = strings.Split(algorithms, ",")
+	}
+
+	return hc
+}
+
+// sshConfigAll is implemented by the ssh_config readers returning all the
+// values of a key for a host alias, as the IdentityFile ones, which add up
+// instead of the first one applying.
+type sshConfigAll interface {
+	GetAll(alias, key string) []string
+}
+
+// getAll returns all the values of DefaultSSHConfig for the key and the host
+// alias. The ssh_config files of the user and of the system are read again
+// for ssh_config.DefaultUserSettings, which only returns the first value, and
+// only the first value is returned by the other readers.
+func getAll(alias, key string) []string {
+	switch c := DefaultSSHConfig.(type) {
+	case sshConfigAll:
+		return c.GetAll(alias, key)
+	case *ssh_config.UserSettings:
+		if c == ssh_config.DefaultUserSettings {
+			return userSettingsGetAll(alias, key)
+		}
+	}
+
+	if value := DefaultSSHConfig.Get(alias, key); value != "" {
+		return []string{value}
+	}
+
+	return nil
+}
+
+// userSettingsGetAll returns the values for the key of the hosts matching the
+// alias in the ssh_config files of the user and of the system, in order. The
+// values of the included files are skipped but the first one.
+func userSettingsGetAll(alias, key string) []string {
+	var files []string
+	if home, err := homedir.Dir(); err == nil {
+		files = append(files, filepath.Join(home, ".ssh", "config"))
+	}
+
+	files = append(files, filepath.Join("/", "etc", "ssh", "ssh_config"))
+
+	var values []string
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			continue
+		}
+
+		cfg, err := ssh_config.Decode(f)
+		_ = f.Close()
+		if err != nil {
+			continue
+		}
+
+		for _, host := range cfg.Hosts {
+			if !host.Matches(alias) {
+				continue
+			}
+
+			for _, node := range host.Nodes {
+				switch n := node.(type) {
+				case *ssh_config.KV:
+					if strings.EqualFold(n.Key, key) {
+						values = append(values, n.Value)
+					}
+				case *ssh_config.Include:
+					if value := n.Get(alias, key); value != "" {
+						values = append(values, value)
+					}
+				}
+			}
+		}
+	}
+
+	return values
+}
+
+// tokens are the values of the tokens expanded in the paths and the
+// commands of ssh_config.
+type tokens struct {
+	alias, host, user string
+	port              int
+}
+
+// expand expands the leading ~ and the %%, %d, %h, %n, %p, %r and %u tokens
+// of the value, as ssh does.
+func (t *tokens) expand(value string) string {
+	home, _ := homedir.Dir()
+	if strings.HasPrefix(value, "~/") && home != "" {
+		value = filepath.Join(home, value[2:])
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '%' || i == len(value)-1 {
+			b.WriteByte(value[i])
+			continue
+		}
+
+		i++
+		switch value[i] {
+		case '%':
+			b.WriteByte('%')
+		case 'd':
+			b.WriteString(home)
+		case 'h':
+			b.WriteString(t.host)
+		case 'n':
+			b.WriteString(t.alias)
+		case 'p':
+			b.WriteString(strconv.Itoa(t.port))
+		case 'r':
+			b.WriteString(t.user)
+		case 'u':
+			local, _ := username()
+			b.WriteString(local)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(value[i])
+		}
+	}
+
+	return b.String()
+}
+
+// defaultAuth returns the auth method used when none is given: the one of
+// DefaultAuthBuilder, or the keys of IdentityFile, with the ones of the SSH
+// agent unless IdentitiesOnly is set. The missing and the encrypted key files
+// are skipped, as the agent may hold them.
+func (hc *hostConfig) defaultAuth(user string, t *tokens) (AuthMethod, error) {
+	if user == "" {
+		user = hc.user
+	}
+
+	files := hc.identityFiles
+	if len(files) == 0 && hc.identitiesOnly {
+		files = defaultIdentityFiles
+	}
+
+	if len(files) == 0 {
+		return DefaultAuthBuilder(user)
+	}
+
+	if user == "" {
+		var err error
+		if user, err = username(); err != nil {
+			return nil, err
+		}
+	}
+
+	var signers []ssh.Signer
+	for _, file := range files {
+		pem, err := ioutil.ReadFile(t.expand(file))
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if signer, err := ssh.ParsePrivateKey(pem); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+
+	callback := func() ([]ssh.Signer, error) { return signers, nil }
+	if !hc.identitiesOnly {
+		if agent, _, err := sshagent.New(); err == nil {
+			callback = func() ([]ssh.Signer, error) {
+				agentSigners, err := agent.Signers()
+				return append(append([]ssh.Signer(nil), signers...), agentSigners...), err
+			}
+		}
+	}
+
+	return &PublicKeysCallback{User: user, Callback: callback}, nil
+}
+
+// hostKeyCallback returns the callback verifying the host keys with the
+// files of UserKnownHostsFile, or else the default ones, as set by
+// StrictHostKeyChecking: the unknown keys are added to the first file with
+// accept-new, and any key is accepted, the unknown ones being added, with
+// no. It returns nil if neither is set.
+func (hc *hostConfig) hostKeyCallback(t *tokens) (ssh.HostKeyCallback, error) {
+	if len(hc.knownHostsFiles) == 0 && hc.strictHostKeyChecking == "" {
+		return nil, nil
+	}
+
+	files := make([]string, len(hc.knownHostsFiles))
+	for i, file := range hc.knownHostsFiles {
+		files[i] = t.expand(file)
+	}
+
+	if len(files) == 0 {
+		var err error
+		if files, err = getDefaultKnownHostsFiles(); err != nil {
+			return nil, err
+		}
+	}
+
+	mode := hc.strictHostKeyChecking
+	switch mode {
+	case "", "yes", "ask":
+		return NewKnownHostsCallback(files...)
+	case "no", "off", "accept-new":
+	default:
+		return nil, fmt.Errorf("invalid StrictHostKeyChecking: %s", mode)
+	}
+
+	var known ssh.HostKeyCallback
+	if existing, err := filterKnownHostsFiles(files...); err == nil {
+		if known, err = knownhosts.New(existing...); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if known != nil {
+			err := known(hostname, remote, key)
+			if err == nil {
+				return nil
+			}
+
+			keyErr, ok := err.(*knownhosts.KeyError)
+			if !ok || len(keyErr.Want) != 0 {
+				if mode == "accept-new" || !ok {
+					return err
+				}
+
+				return nil
+			}
+		}
+
+		return addKnownHost(files[0], hostname, key)
+	}, nil
+}
+
+// addKnownHost adds the key of the host to the known_hosts file.
+func addKnownHost(file, hostname string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintln(f, knownhosts.Line([]string{hostname}, key)); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
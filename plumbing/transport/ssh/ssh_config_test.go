@@ -0,0 +1,385 @@
+package ssh
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/goabstract/go-git/v5/plumbing/transport"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/kevinburke/ssh_config"
+	"github.com/mitchellh/go-homedir"
+	stdssh "golang.org/x/crypto/ssh"
+	. "gopkg.in/check.v1"
+)
+
+type SSHConfigSuite struct {
+	dir string
+
+	m     sync.Mutex
+	users []string
+}
+
+var _ = Suite(&SSHConfigSuite{})
+
+func (s *SSHConfigSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	s.users = nil
+}
+
+func (s *SSHConfigSuite) TearDownTest(c *C) {
+	DefaultSSHConfig = ssh_config.DefaultUserSettings
+}
+
+// serve starts a SSH server accepting any password, recording the users,
+// and forwarding connections if jump is set.
+func (s *SSHConfigSuite) serve(c *C, jump bool) int {
+	l, err := net.Listen("tcp", "localhost:0")
+	c.Assert(err, IsNil)
+
+	server := &ssh.Server{
+		Handler: func(ssh.Session) {},
+		PasswordHandler: func(ctx ssh.Context, password string) bool {
+			s.m.Lock()
+			defer s.m.Unlock()
+			s.users = append(s.users, ctx.User())
+			return true
+		},
+	}
+
+	if jump {
+		server.LocalPortForwardingCallback = func(ssh.Context, string, uint32) bool {
+			return true
+		}
+
+		server.ChannelHandlers = map[string]ssh.ChannelHandler{
+			"session":      ssh.DefaultSessionHandler,
+			"direct-tcpip": ssh.DirectTCPIPHandler,
+		}
+	}
+
+	go server.Serve(l)
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func (s *SSHConfigSuite) connect(c *C, url string) *command {
+	DefaultAuthBuilder = func(user string) (AuthMethod, error) {
+		return &Password{User: user, HostKeyCallbackHelper: HostKeyCallbackHelper{
+			HostKeyCallback: stdssh.InsecureIgnoreHostKey(),
+		}}, nil
+	}
+
+	ep, err := transport.NewEndpoint(url)
+	c.Assert(err, IsNil)
+
+	cmd := &command{endpoint: ep}
+	c.Assert(cmd.connect(), IsNil)
+	return cmd
+}
+
+func (s *SSHConfigSuite) TestNewHostConfig(c *C) {
+	DefaultSSHConfig = &mockSSHConfig{Values: map[string]map[string]string{
+		"example": {
+			"Hostname":            "example.com",
+			"Port":                "2222",
+			"User":                "foo",
+			"IdentityFile":        "~/.ssh/id_example",
+			"IdentitiesOnly":      "yes",
+			"ProxyCommand":        "none",
+			"ProxyJump":           "bar@jump",
+			"UserKnownHostsFile":  "/tmp/a /tmp/b",
+			"ConnectTimeout":      "5",
+			"ServerAliveInterval": "10",
+			"ServerAliveCountMax": "1",
+			"HostKeyAlgorithms":   "ssh-ed25519,ssh-rsa",
+		},
+		"*": {
+			"IdentityFile":          ssh_config.Default("IdentityFile"),
+			"StrictHostKeyChecking": ssh_config.Default("StrictHostKeyChecking"),
+			"HostKeyAlgorithms":     "+ssh-dss",
+		},
+	}}
+
+	hc := newHostConfig("example")
+	c.Assert(hc.hostname, Equals, "example.com")
+	c.Assert(hc.port, Equals, 2222)
+	c.Assert(hc.user, Equals, "foo")
+	c.Assert(hc.identityFiles, DeepEquals, []string{"~/.ssh/id_example"})
+	c.Assert(hc.identitiesOnly, Equals, true)
+	c.Assert(hc.proxyCommand, Equals, "")
+	c.Assert(hc.proxyJump, Equals, "bar@jump")
+	c.Assert(hc.knownHostsFiles, DeepEquals, []string{"/tmp/a", "/tmp/b"})
+	c.Assert(hc.connectTimeout, Equals, 5*time.Second)
+	c.Assert(hc.serverAliveInterval, Equals, 10*time.Second)
+	c.Assert(hc.serverAliveCountMax, Equals, 1)
+	c.Assert(hc.hostKeyAlgorithms, DeepEquals, []string{"ssh-ed25519", "ssh-rsa"})
+
+	hc = newHostConfig("other")
+	c.Assert(hc.identityFiles, HasLen, 0)
+	c.Assert(hc.strictHostKeyChecking, Equals, "")
+	c.Assert(hc.serverAliveCountMax, Equals, defaultServerAliveCountMax)
+	c.Assert(hc.hostKeyAlgorithms, HasLen, 0)
+
+	DefaultSSHConfig = &mockSSHConfigAll{
+		mockSSHConfig: DefaultSSHConfig.(*mockSSHConfig),
+		All: map[string]map[string][]string{
+			"example": {
+				"IdentityFile": {"~/.ssh/id_example", "~/.ssh/id_other", ssh_config.Default("IdentityFile")},
+			},
+		},
+	}
+
+	hc = newHostConfig("example")
+	c.Assert(hc.identityFiles, DeepEquals, []string{"~/.ssh/id_example", "~/.ssh/id_other"})
+}
+
+func (s *SSHConfigSuite) TestUserSettingsGetAll(c *C) {
+	homedir.DisableCache = true
+	defer func() { homedir.DisableCache = false }()
+
+	home := os.Getenv("HOME")
+	defer os.Setenv("HOME", home)
+	os.Setenv("HOME", s.dir)
+
+	c.Assert(os.MkdirAll(filepath.Join(s.dir, ".ssh"), 0700), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, ".ssh", "config"), []byte(
+		"Host example\n"+
+			"  IdentityFile ~/.ssh/id_example\n"+
+			"Host other\n"+
+			"  IdentityFile ~/.ssh/id_other\n"+
+			"Host ex*\n"+
+			"  IdentityFile ~/.ssh/id_ex\n",
+	), 0600), IsNil)
+
+	files := userSettingsGetAll("example", "IdentityFile")
+	c.Assert(len(files) >= 2, Equals, true)
+	c.Assert(files[:2], DeepEquals, []string{"~/.ssh/id_example", "~/.ssh/id_ex"})
+}
+
+func (s *SSHConfigSuite) TestTokensExpand(c *C) {
+	t := &tokens{alias: "example", host: "example.com", user: "foo", port: 22}
+	c.Assert(
+		t.expand("nc %h %p %r@%n 100%% %x"),
+		Equals,
+		"nc example.com 22 foo@example 100% %x",
+	)
+
+	home, err := os.UserHomeDir()
+	c.Assert(err, IsNil)
+	c.Assert(t.expand("~/.ssh/%n"), Equals, filepath.Join(home, ".ssh/example"))
+}
+
+func (s *SSHConfigSuite) writeKey(c *C, name string) stdssh.PublicKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+
+	data := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+
+	err = ioutil.WriteFile(filepath.Join(s.dir, name), data, 0600)
+	c.Assert(err, IsNil)
+
+	public, err := stdssh.NewPublicKey(&key.PublicKey)
+	c.Assert(err, IsNil)
+	return public
+}
+
+func (s *SSHConfigSuite) TestDefaultAuthIdentityFile(c *C) {
+	public := s.writeKey(c, "id_foo")
+	hc := &hostConfig{
+		user:           "foo",
+		identityFiles:  []string{filepath.Join(s.dir, "id_%n"), filepath.Join(s.dir, "missing")},
+		identitiesOnly: true,
+	}
+
+	auth, err := hc.defaultAuth("", &tokens{alias: "foo"})
+	c.Assert(err, IsNil)
+
+	keys, ok := auth.(*PublicKeysCallback)
+	c.Assert(ok, Equals, true)
+	c.Assert(keys.User, Equals, "foo")
+
+	signers, err := keys.Callback()
+	c.Assert(err, IsNil)
+	c.Assert(signers, HasLen, 1)
+	c.Assert(signers[0].PublicKey().Marshal(), DeepEquals, public.Marshal())
+}
+
+func (s *SSHConfigSuite) TestDefaultAuthBuilder(c *C) {
+	DefaultAuthBuilder = func(user string) (AuthMethod, error) {
+		return &Password{User: user}, nil
+	}
+
+	auth, err := (&hostConfig{user: "foo"}).defaultAuth("bar", &tokens{})
+	c.Assert(err, IsNil)
+	c.Assert(auth.(*Password).User, Equals, "bar")
+
+	auth, err = (&hostConfig{user: "foo"}).defaultAuth("", &tokens{})
+	c.Assert(err, IsNil)
+	c.Assert(auth.(*Password).User, Equals, "foo")
+}
+
+func (s *SSHConfigSuite) TestHostKeyCallback(c *C) {
+	key := s.writeKey(c, "host")
+	other := s.writeKey(c, "other")
+	file := filepath.Join(s.dir, "known_hosts")
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 22}
+
+	hc := &hostConfig{knownHostsFiles: []string{file}, strictHostKeyChecking: "accept-new"}
+	callback, err := hc.hostKeyCallback(&tokens{})
+	c.Assert(err, IsNil)
+	c.Assert(callback("example.com:22", addr, key), IsNil)
+
+	_, err = os.Stat(file)
+	c.Assert(err, IsNil)
+
+	hc.strictHostKeyChecking = "yes"
+	callback, err = hc.hostKeyCallback(&tokens{})
+	c.Assert(err, IsNil)
+	c.Assert(callback("example.com:22", addr, key), IsNil)
+	c.Assert(callback("example.com:22", addr, other), NotNil)
+	c.Assert(callback("example.org:22", addr, key), NotNil)
+
+	hc.strictHostKeyChecking = "accept-new"
+	callback, err = hc.hostKeyCallback(&tokens{})
+	c.Assert(err, IsNil)
+	c.Assert(callback("example.com:22", addr, other), NotNil)
+
+	hc.strictHostKeyChecking = "no"
+	callback, err = hc.hostKeyCallback(&tokens{})
+	c.Assert(err, IsNil)
+	c.Assert(callback("example.com:22", addr, other), IsNil)
+
+	hc.strictHostKeyChecking = "maybe"
+	_, err = hc.hostKeyCallback(&tokens{})
+	c.Assert(err, NotNil)
+
+	callback, err = (&hostConfig{}).hostKeyCallback(&tokens{})
+	c.Assert(err, IsNil)
+	c.Assert(callback, IsNil)
+}
+
+func (s *SSHConfigSuite) TestClientConfig(c *C) {
+	file := filepath.Join(s.dir, "known_hosts")
+	c.Assert(ioutil.WriteFile(file, nil, 0600), IsNil)
+
+	DefaultSSHConfig = &mockSSHConfig{Values: map[string]map[string]string{
+		"example": {
+			"UserKnownHostsFile": file,
+			"ConnectTimeout":     "5",
+			"HostKeyAlgorithms":  "ssh-ed25519",
+		},
+	}}
+
+	ep, err := transport.NewEndpoint("ssh://git@example/foo.git")
+	c.Assert(err, IsNil)
+
+	// the default known hosts are not needed when ssh_config has some
+	knownHosts := os.Getenv("SSH_KNOWN_HOSTS")
+	defer os.Setenv("SSH_KNOWN_HOSTS", knownHosts)
+	os.Setenv("SSH_KNOWN_HOSTS", filepath.Join(s.dir, "missing"))
+
+	auth := &Password{User: "git"}
+	cmd := &command{endpoint: ep}
+	config, err := cmd.clientConfig(auth, newHostConfig("example"), &tokens{})
+	c.Assert(err, IsNil)
+	c.Assert(config.HostKeyCallback, NotNil)
+	c.Assert(config.Timeout, Equals, 5*time.Second)
+	c.Assert(config.HostKeyAlgorithms, DeepEquals, []string{"ssh-ed25519"})
+	c.Assert(auth.HostKeyCallback, IsNil)
+
+	cmd.config = &stdssh.ClientConfig{Timeout: time.Second}
+	config, err = cmd.clientConfig(auth, newHostConfig("example"), &tokens{})
+	c.Assert(err, IsNil)
+	c.Assert(config.Timeout, Equals, time.Second)
+}
+
+func (s *SSHConfigSuite) TestConnectUser(c *C) {
+	port := s.serve(c, false)
+	DefaultSSHConfig = &mockSSHConfig{Values: map[string]map[string]string{
+		"example": {
+			"Hostname": "localhost",
+			"Port":     strconv.Itoa(port),
+			"User":     "foo",
+		},
+	}}
+
+	cmd := s.connect(c, "ssh://example/foo.git")
+	c.Assert(cmd.Close(), IsNil)
+
+	cmd = s.connect(c, "ssh://bar@example/foo.git")
+	c.Assert(cmd.Close(), IsNil)
+
+	c.Assert(s.users, DeepEquals, []string{"foo", "bar"})
+}
+
+func (s *SSHConfigSuite) TestConnectProxyJump(c *C) {
+	target := s.serve(c, false)
+	jump := s.serve(c, true)
+	DefaultSSHConfig = &mockSSHConfig{Values: map[string]map[string]string{
+		"example": {
+			"Hostname":  "localhost",
+			"Port":      strconv.Itoa(target),
+			"ProxyJump": "jump,qux@localhost:" + strconv.Itoa(jump),
+		},
+		"jump": {
+			"Hostname": "localhost",
+			"Port":     strconv.Itoa(jump),
+			"User":     "bar",
+		},
+	}}
+
+	cmd := s.connect(c, "ssh://foo@example/foo.git")
+	c.Assert(cmd.Close(), IsNil)
+
+	c.Assert(s.users, DeepEquals, []string{"bar", "qux", "foo"})
+}
+
+func (s *SSHConfigSuite) TestConnectProxyCommand(c *C) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		c.Skip("bash not found")
+	}
+
+	port := s.serve(c, false)
+	DefaultSSHConfig = &mockSSHConfig{Values: map[string]map[string]string{
+		"example": {
+			"Hostname":     "127.0.0.1",
+			"Port":         strconv.Itoa(port),
+			"ProxyCommand": "bash -c 'exec 3<>/dev/tcp/%h/%p; cat <&3 & exec cat >&3'",
+		},
+	}}
+
+	cmd := s.connect(c, "ssh://foo@example/foo.git")
+	c.Assert(cmd.Close(), IsNil)
+
+	c.Assert(s.users, DeepEquals, []string{"foo"})
+}
+
+func (s *SSHConfigSuite) TestConnectProxyCommandError(c *C) {
+	DefaultSSHConfig = &mockSSHConfig{Values: map[string]map[string]string{
+		"example": {"ProxyCommand": "exit 1"},
+	}}
+
+	ep, err := transport.NewEndpoint("ssh://foo@example/foo.git")
+	c.Assert(err, IsNil)
+
+	cmd := &command{endpoint: ep, auth: &Password{User: "foo", HostKeyCallbackHelper: HostKeyCallbackHelper{
+		HostKeyCallback: stdssh.InsecureIgnoreHostKey(),
+	}}}
+
+	c.Assert(cmd.connect(), NotNil)
+	c.Assert(fmt.Sprint(s.users), Equals, "[]")
+}
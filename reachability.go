@@ -0,0 +1,248 @@
+package git
+
+import (
+	"io"
+	"os"
+	"path"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+	commitgraphfmt "github.com/goabstract/go-git/v5/plumbing/format/commitgraph"
+	"github.com/goabstract/go-git/v5/plumbing/object"
+	"github.com/goabstract/go-git/v5/plumbing/object/commitgraph"
+	"github.com/goabstract/go-git/v5/plumbing/storer"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// FilterReferences returns the references of the given iterator matching the
+// reachability filters of the options, as `git branch` and `git tag` do with
+// `--contains`, `--no-contains`, `--merged` and `--no-merged`. Tags are
+// peeled to the commit they point to, and the references not pointing to a
+// commit are discarded if any filter is given.
+//
+// The walks over the history use the commit-graph file when available, and
+// share their results, so filtering many references is cheaper than asking
+// for each of them.
+func (r *Repository) FilterReferences(refs storer.ReferenceIter, o *ReachabilityOptions) (storer.ReferenceIter, error) {
+	defer refs.Close()
+	if o == nil {
+		o = &ReachabilityOptions{}
+	}
+
+	var all []*plumbing.Reference
+	if err := refs.ForEach(func(ref *plumbing.Reference) error {
+		all = append(all, ref)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if o.isEmpty() {
+		return storer.NewReferenceSliceIter(all), nil
+	}
+
+	reach, closer, err := r.reachability()
+	if err != nil {
+		return nil, err
+	}
+
+	defer closer.Close()
+
+	tips := make(map[plumbing.ReferenceName]plumbing.Hash, len(all))
+	var hashes []plumbing.Hash
+	for _, ref := range all {
+		h, ok, err := r.peelToCommit(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			tips[ref.Name()] = h
+			hashes = append(hashes, h)
+		}
+	}
+
+	merged, err := reachableFromAny(reach, o.Merged, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	notMerged, err := reachableFromAny(reach, o.NoMerged, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []*plumbing.Reference
+	for _, ref := range all {
+		h, ok := tips[ref.Name()]
+		if !ok {
+			continue
+		}
+
+		if len(o.Merged) != 0 && !merged[h] || notMerged[h] {
+			continue
+		}
+
+		contains, err := containsAny(reach, h, o.Contains)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(o.Contains) != 0 && !contains {
+			continue
+		}
+
+		contains, err = containsAny(reach, h, o.NoContains)
+		if err != nil {
+			return nil, err
+		}
+
+		if contains {
+			continue
+		}
+
+		filtered = append(filtered, ref)
+	}
+
+	return storer.NewReferenceSliceIter(filtered), nil
+}
+
+// AheadBehind returns the number of commits of the given branch not in its
+// upstream, and the number of commits of the upstream not in the branch, as
+// `git status` reports them. ErrNoUpstream is returned if the branch has no
+// upstream configured.
+func (r *Repository) AheadBehind(branch string) (ahead, behind int, err error) {
+	cfg, err := r.MergedConfig()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	upstream, err := r.upstreamRefName(cfg, branch)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	local, err := storer.ResolveReference(r.Storer, plumbing.NewBranchReferenceName(branch))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	remote, err := storer.ResolveReference(r.Storer, upstream)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	reach, closer, err := r.reachability()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	defer closer.Close()
+	return reach.AheadBehind(local.Hash(), remote.Hash())
+}
+
+// peelToCommit returns the commit the reference points to, peeling the tags,
+// and false if it does not point to a commit.
+func (r *Repository) peelToCommit(ref *plumbing.Reference) (plumbing.Hash, bool, error) {
+	ref, err := storer.ResolveReference(r.Storer, ref.Name())
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+
+	h := ref.Hash()
+	for {
+		obj, err := r.getObject(h)
+		if err == plumbing.ErrObjectNotFound {
+			return plumbing.ZeroHash, false, nil
+		}
+
+		if err != nil {
+			return plumbing.ZeroHash, false, err
+		}
+
+		switch o := obj.(type) {
+		case *object.Commit:
+			return o.Hash, true, nil
+		case *object.Tag:
+			h = o.Target
+		default:
+			return plumbing.ZeroHash, false, nil
+		}
+	}
+}
+
+// reachability returns the reachability queries of the repository, using
+// the commit-graph file when available. The returned closer must be closed
+// once done.
+func (r *Repository) reachability() (*commitgraph.Reachability, io.Closer, error) {
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index := commitgraph.NewObjectCommitNodeIndex(s)
+
+	type fsBased interface {
+		Filesystem() billy.Filesystem
+	}
+
+	// the commit-graph does not know about the replaced objects
+	sto, ok := r.Storer.(fsBased)
+	if !ok || s != r.Storer {
+		return commitgraph.NewReachability(index), nopCloser{}, nil
+	}
+
+	f, err := sto.Filesystem().Open(path.Join("objects", "info", "commit-graph"))
+	if os.IsNotExist(err) {
+		return commitgraph.NewReachability(index), nopCloser{}, nil
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	graph, err := commitgraphfmt.OpenFileIndex(f)
+	if err != nil {
+		_ = f.Close()
+		return commitgraph.NewReachability(index), nopCloser{}, nil
+	}
+
+	index = commitgraph.NewGraphCommitNodeIndex(graph, s)
+	return commitgraph.NewReachability(index), f, nil
+}
+
+// reachableFromAny returns which of the hashes are reachable from any of the
+// given commits.
+func reachableFromAny(reach *commitgraph.Reachability, from, hashes []plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	result := make(map[plumbing.Hash]bool, len(hashes))
+	for _, f := range from {
+		reachable, err := reach.ReachableFrom(f, hashes)
+		if err != nil {
+			return nil, err
+		}
+
+		for h, ok := range reachable {
+			if ok {
+				result[h] = true
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// containsAny returns true if any of the hashes is reachable from the commit.
+func containsAny(reach *commitgraph.Reachability, from plumbing.Hash, hashes []plumbing.Hash) (bool, error) {
+	for _, h := range hashes {
+		contains, err := reach.Contains(from, h)
+		if err != nil || contains {
+			return contains, err
+		}
+	}
+
+	return false, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
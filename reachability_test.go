@@ -0,0 +1,140 @@
+package git
+
+import (
+	"os/exec"
+	"sort"
+
+	"github.com/goabstract/go-git/v5/config"
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/storer"
+	"github.com/goabstract/go-git/v5/storage/filesystem"
+
+	fixtures "github.com/go-git/go-git-fixtures/v4"
+	. "gopkg.in/check.v1"
+)
+
+type ReachabilitySuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&ReachabilitySuite{})
+
+var (
+	reachMaster = plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	reachBranch = plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	reachFork   = plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+)
+
+func (s *ReachabilitySuite) TestFilterReferences(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+	s.testFilterReferences(c, r)
+}
+
+func (s *ReachabilitySuite) TestFilterReferencesCommitGraph(c *C) {
+	if err := exec.Command("git", "--version").Run(); err != nil {
+		c.Skip("git command not found")
+	}
+
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+	fs := r.Storer.(*filesystem.Storage).Filesystem()
+
+	cmd := exec.Command("git", "commit-graph", "write", "--reachable")
+	cmd.Env = []string{"GIT_DIR=" + fs.Root()}
+	c.Assert(cmd.Run(), IsNil)
+
+	_, err := fs.Stat("objects/info/commit-graph")
+	c.Assert(err, IsNil)
+
+	s.testFilterReferences(c, r)
+}
+
+func (s *ReachabilitySuite) testFilterReferences(c *C, r *Repository) {
+	tests := []struct {
+		opts     *ReachabilityOptions
+		expected []string
+	}{
+		{&ReachabilityOptions{}, []string{"refs/heads/branch", "refs/heads/master"}},
+		{&ReachabilityOptions{Contains: []plumbing.Hash{reachFork}}, []string{"refs/heads/branch", "refs/heads/master"}},
+		{&ReachabilityOptions{Contains: []plumbing.Hash{reachBranch}}, []string{"refs/heads/branch"}},
+		{&ReachabilityOptions{NoContains: []plumbing.Hash{reachBranch}}, []string{"refs/heads/master"}},
+		{&ReachabilityOptions{Merged: []plumbing.Hash{reachMaster}}, []string{"refs/heads/master"}},
+		{&ReachabilityOptions{NoMerged: []plumbing.Hash{reachMaster}}, []string{"refs/heads/branch"}},
+		{&ReachabilityOptions{Merged: []plumbing.Hash{reachMaster, reachBranch}}, []string{"refs/heads/branch", "refs/heads/master"}},
+		{&ReachabilityOptions{Merged: []plumbing.Hash{reachFork}}, nil},
+		{&ReachabilityOptions{
+			Contains: []plumbing.Hash{reachFork},
+			NoMerged: []plumbing.Hash{reachBranch},
+		}, []string{"refs/heads/master"}},
+	}
+
+	for _, t := range tests {
+		branches, err := r.Branches()
+		c.Assert(err, IsNil)
+
+		iter, err := r.FilterReferences(branches, t.opts)
+		c.Assert(err, IsNil)
+		c.Assert(referenceNames(c, iter), DeepEquals, t.expected, Commentf("%+v", t.opts))
+	}
+
+	tags, err := r.Tags()
+	c.Assert(err, IsNil)
+
+	iter, err := r.FilterReferences(tags, &ReachabilityOptions{Contains: []plumbing.Hash{reachFork}})
+	c.Assert(err, IsNil)
+	c.Assert(referenceNames(c, iter), DeepEquals, []string{"refs/tags/v1.0.0"})
+}
+
+func (s *ReachabilitySuite) TestFilterReferencesAnnotatedTags(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.ByTag("tags").One())
+
+	tags, err := r.Tags()
+	c.Assert(err, IsNil)
+
+	iter, err := r.FilterReferences(tags, &ReachabilityOptions{
+		Contains: []plumbing.Hash{plumbing.NewHash("f7b877701fbf855b44c0a9e86f3fdce2c298b07f")},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(referenceNames(c, iter), DeepEquals, []string{
+		"refs/tags/annotated-tag", "refs/tags/commit-tag", "refs/tags/lightweight-tag",
+	})
+}
+
+func (s *ReachabilitySuite) TestAheadBehind(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	ahead, behind, err := r.AheadBehind("master")
+	c.Assert(err, IsNil)
+	c.Assert(ahead, Equals, 0)
+	c.Assert(behind, Equals, 0)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Branches["branch"] = &config.Branch{
+		Name:   "branch",
+		Remote: ".",
+		Merge:  plumbing.Master,
+	}
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	ahead, behind, err = r.AheadBehind("branch")
+	c.Assert(err, IsNil)
+	c.Assert(ahead, Equals, 1)
+	c.Assert(behind, Equals, 1)
+
+	delete(cfg.Branches, "branch")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	_, _, err = r.AheadBehind("branch")
+	c.Assert(err, Equals, ErrNoUpstream)
+}
+
+func referenceNames(c *C, iter storer.ReferenceIter) []string {
+	var names []string
+	c.Assert(iter.ForEach(func(ref *plumbing.Reference) error {
+		names = append(names, ref.Name().String())
+		return nil
+	}), IsNil)
+
+	sort.Strings(names)
+	return names
+}
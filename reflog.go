@@ -0,0 +1,319 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/goabstract/go-git/v5/config"
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/format/reflog"
+	"github.com/goabstract/go-git/v5/plumbing/object"
+	"github.com/goabstract/go-git/v5/plumbing/storer"
+	"github.com/goabstract/go-git/v5/storage"
+)
+
+var (
+	// ErrReflogNotSupported is returned when the storer of the repository
+	// doesn't keep a reflog.
+	ErrReflogNotSupported = errors.New("storer doesn't support reflog")
+)
+
+const (
+	logAllRefUpdatesKey    = "logAllRefUpdates"
+	logAllRefUpdatesAlways = "always"
+
+	defaultReflogExpire            = 90 * 24 * time.Hour
+	defaultReflogExpireUnreachable = 30 * 24 * time.Hour
+)
+
+// Reflog returns the entries of the reflog of the given reference, the most
+// recent first, as `git reflog show` does. The n-th entry is the value of
+// the reference as resolved by the `<ref>@{n}` revision.
+func (r *Repository) Reflog(name plumbing.ReferenceName) ([]*reflog.Entry, error) {
+	rs, ok := r.Storer.(storer.ReflogStorer)
+	if !ok {
+		return nil, ErrReflogNotSupported
+	}
+
+	entries, err := rs.Reflog(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	return entries, nil
+}
+
+// ExpireReflog prunes the entries of the reflog of the given reference older
+// than the expiration times given in the options, as `git reflog expire`
+// does.
+func (r *Repository) ExpireReflog(name plumbing.ReferenceName, o *ReflogExpireOptions) error {
+	if o == nil {
+		o = &ReflogExpireOptions{}
+	}
+
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	rs, ok := r.Storer.(storer.ReflogStorer)
+	if !ok {
+		return ErrReflogNotSupported
+	}
+
+	entries, err := rs.Reflog(name)
+	if err != nil {
+		return err
+	}
+
+	var reachable map[plumbing.Hash]bool
+	var kept []*reflog.Entry
+	for _, e := range entries {
+		when := e.Committer.When
+		if when.Before(o.Expire) {
+			continue
+		}
+
+		if when.Before(o.ExpireUnreachable) {
+			if reachable == nil {
+				if reachable, err = r.reachableFromReference(name); err != nil {
+					return err
+				}
+			}
+
+			if !reachable[e.New] {
+				continue
+			}
+		}
+
+		kept = append(kept, e)
+	}
+
+	if len(kept) == len(entries) {
+		return nil
+	}
+
+	if o.Rewrite {
+		rewriteReflog(kept)
+	}
+
+	return rs.SetReflog(name, kept)
+}
+
+// DeleteReflogEntry removes the n-th entry of the reflog of the given
+// reference, counting from the most recent one, as `git reflog delete
+// <ref>@{<n>}` does. The old value of the next entry is rewritten to keep
+// the log consistent.
+func (r *Repository) DeleteReflogEntry(name plumbing.ReferenceName, n int) error {
+	rs, ok := r.Storer.(storer.ReflogStorer)
+	if !ok {
+		return ErrReflogNotSupported
+	}
+
+	entries, err := rs.Reflog(name)
+	if err != nil {
+		return err
+	}
+
+	i := len(entries) - 1 - n
+	if n < 0 || i < 0 {
+		return ErrReflogEntryNotFound
+	}
+
+	entries = append(entries[:i], entries[i+1:]...)
+	if i < len(entries) {
+		old := plumbing.ZeroHash
+		if i > 0 {
+			old = entries[i-1].New
+		}
+
+		entries[i].Old = old
+	}
+
+	return rs.SetReflog(name, entries)
+}
+
+// reachableFromReference returns the set of commits reachable from the
+// current value of the given reference.
+func (r *Repository) reachableFromReference(name plumbing.ReferenceName) (map[plumbing.Hash]bool, error) {
+	seen := make(map[plumbing.Hash]bool)
+	ref, err := storer.ResolveReference(r.Storer, name)
+	if err == plumbing.ErrReferenceNotFound {
+		return seen, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := r.CommitObject(ref.Hash())
+	if err == plumbing.ErrObjectNotFound {
+		return seen, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	iter := object.NewCommitPreorderIter(c, nil, nil)
+	err = iter.ForEach(func(c *object.Commit) error {
+		seen[c.Hash] = true
+		return nil
+	})
+
+	return seen, err
+}
+
+// rewriteReflog sets the old value of every entry to the new value of the
+// previous one.
+func rewriteReflog(entries []*reflog.Entry) {
+	for i := 1; i < len(entries); i++ {
+		entries[i].Old = entries[i-1].New
+	}
+}
+
+// reflogConfig returns the merged config ruling the reflog of the references
+// of s, read once before updating many of them, or nil if s doesn't keep a
+// reflog.
+func reflogConfig(s storage.Storer) (*config.Config, error) {
+	if _, ok := s.(storer.ReflogStorer); !ok {
+		return nil, nil
+	}
+
+	return mergedConfig(s)
+}
+
+// updateReference sets the given reference, checking first its current value
+// if old is not nil, and records the change in its reflog with the given
+// message. The reflog of HEAD is updated as well when HEAD points to the
+// reference. No reflog is written if the message is empty or the storer
+// doesn't keep one. The config ruling the reflog, see reflogConfig, is read
+// before the update if cfg is nil.
+func updateReference(s storage.Storer, cfg *config.Config, ref, old *plumbing.Reference,
+	committer *object.Signature, msg string) error {
+
+	rs, ok := s.(storer.ReflogStorer)
+	logged := ok && msg != ""
+	if logged && cfg == nil {
+		var err error
+		if cfg, err = mergedConfig(s); err != nil {
+			return err
+		}
+	}
+
+	name := ref.Name()
+	before := resolvedReferenceHash(s, name)
+	head, _ := s.Reference(plumbing.HEAD)
+
+	if err := s.CheckAndSetReference(ref, old); err != nil {
+		return err
+	}
+
+	if !logged {
+		return nil
+	}
+
+	logReferenceUpdate(cfg, rs, head, name, &reflog.Entry{
+		Old:       before,
+		New:       resolvedReferenceHash(s, name),
+		Committer: reflogSignature(cfg, committer),
+		Message:   msg,
+	})
+
+	return nil
+}
+
+// logReferenceUpdate appends the entry to the reflog of the updated
+// reference, and to the one of HEAD if it pointed to the reference. The
+// errors are ignored, since the reference is already updated.
+func logReferenceUpdate(cfg *config.Config, rs storer.ReflogStorer,
+	head *plumbing.Reference, name plumbing.ReferenceName, e *reflog.Entry) {
+
+	_ = appendReflog(cfg, rs, name, e)
+	if name != plumbing.HEAD && head != nil &&
+		head.Type() == plumbing.SymbolicReference && head.Target() == name {
+		_ = appendReflog(cfg, rs, plumbing.HEAD, e)
+	}
+}
+
+func appendReflog(cfg *config.Config, rs storer.ReflogStorer,
+	name plumbing.ReferenceName, e *reflog.Entry) error {
+
+	ok, err := shouldLogReference(cfg, rs, name)
+	if err != nil || !ok {
+		return err
+	}
+
+	return rs.AppendReflog(name, e)
+}
+
+// shouldLogReference reports whether the updates of the given reference
+// are logged, following the semantics of core.logAllRefUpdates.
+func shouldLogReference(cfg *config.Config, rs storer.ReflogStorer,
+	name plumbing.ReferenceName) (bool, error) {
+
+	mode := strings.ToLower(cfg.Raw.Section("core").Option(logAllRefUpdatesKey))
+	switch {
+	case mode == logAllRefUpdatesAlways:
+		return true, nil
+	case mode == "true" || mode == "" && !cfg.Core.IsBare:
+		if name == plumbing.HEAD || name.IsBranch() || name.IsRemote() || name.IsNote() {
+			return true, nil
+		}
+	}
+
+	return rs.HasReflog(name)
+}
+
+func resolvedReferenceHash(s storer.ReferenceStorer, name plumbing.ReferenceName) plumbing.Hash {
+	ref, err := storer.ResolveReference(s, name)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+
+	return ref.Hash()
+}
+
+// reflogSignature returns the identity recorded in a reflog entry: the given
+// signature, if any, or the user from the config, falling back to the user
+// of the system.
+func reflogSignature(cfg *config.Config, sig *object.Signature) reflog.Signature {
+	if sig != nil {
+		return reflog.Signature{Name: sig.Name, Email: sig.Email, When: sig.When}
+	}
+
+	section := cfg.Raw.Section("user")
+	name, email := section.Option("name"), section.Option("email")
+
+	if name == "" || email == "" {
+		login := "unknown"
+		if u, err := user.Current(); err == nil {
+			login = u.Username
+		}
+
+		if name == "" {
+			name = login
+		}
+
+		if email == "" {
+			host, _ := os.Hostname()
+			email = login + "@" + host
+		}
+	}
+
+	return reflog.Signature{Name: name, Email: email, When: time.Now()}
+}
+
+// reflogAction returns a function building the reflog messages of the given
+// action, as "<action>: <what>".
+func reflogAction(action string) func(string) string {
+	return func(what string) string {
+		return action + ": " + what
+	}
+}
@@ -0,0 +1,292 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/goabstract/go-git/v5/config"
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/storage/memory"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	fixtures "github.com/go-git/go-git-fixtures/v4"
+	. "gopkg.in/check.v1"
+)
+
+type ReflogSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&ReflogSuite{})
+
+func (s *ReflogSuite) TestReflog(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	entries, err := r.Reflog(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 5)
+	c.Assert(entries[0].New.String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	c.Assert(entries[1].Message, Equals, "checkout: moving from branch to master")
+	c.Assert(entries[2].Message, Equals, "checkout: moving from master to branch")
+	c.Assert(entries[2].New.String(), Equals, "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	c.Assert(entries[4].Old, Equals, plumbing.ZeroHash)
+
+	entries, err = r.Reflog(plumbing.NewBranchReferenceName("unknown"))
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+}
+
+func (s *ReflogSuite) TestReflogCommitCheckoutAndReset(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, "foo", []byte("foo"), 0644)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	first, err := w.Commit("foo\n\nbody\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, "foo", []byte("bar"), 0644)
+	second, err := w.Commit("bar\n", &CommitOptions{All: true, Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	err = w.Checkout(&CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName("feature"),
+		Create: true,
+	})
+	c.Assert(err, IsNil)
+
+	err = w.Reset(&ResetOptions{Commit: first, Mode: HardReset})
+	c.Assert(err, IsNil)
+
+	head, err := r.Reflog(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(head, HasLen, 4)
+	c.Assert(head[0].Message, Equals, "reset: moving to "+first.String())
+	c.Assert(head[0].Old, Equals, second)
+	c.Assert(head[0].New, Equals, first)
+	c.Assert(head[1].Message, Equals, "checkout: moving from master to feature")
+	c.Assert(head[2].Message, Equals, "commit: bar")
+	c.Assert(head[2].Committer.Name, Equals, "foo")
+	c.Assert(head[2].Committer.Email, Equals, "foo@foo.foo")
+	c.Assert(head[2].Committer.When.Equal(defaultSignature().When), Equals, true)
+	c.Assert(head[3].Message, Equals, "commit (initial): foo")
+	c.Assert(head[3].Old, Equals, plumbing.ZeroHash)
+
+	master, err := r.Reflog(plumbing.Master)
+	c.Assert(err, IsNil)
+	c.Assert(master, HasLen, 2)
+	c.Assert(master[0].New, Equals, second)
+
+	feature, err := r.Reflog(plumbing.NewBranchReferenceName("feature"))
+	c.Assert(err, IsNil)
+	c.Assert(feature, HasLen, 2)
+	c.Assert(feature[0].Message, Equals, "reset: moving to "+first.String())
+	c.Assert(feature[1].Message, Equals, "branch: Created from HEAD")
+
+	hash, err := r.ResolveRevision("HEAD@{1}")
+	c.Assert(err, IsNil)
+	c.Assert(*hash, Equals, second)
+
+	hash, err = r.ResolveRevision("@{-1}")
+	c.Assert(err, IsNil)
+	c.Assert(*hash, Equals, second)
+}
+
+func (s *ReflogSuite) TestReflogClone(c *C) {
+	url := s.GetBasicLocalRepositoryURL()
+	r, err := Clone(memory.NewStorage(), memfs.New(), &CloneOptions{URL: url})
+	c.Assert(err, IsNil)
+
+	head, err := r.Reflog(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(head, HasLen, 1)
+	c.Assert(head[0].Message, Equals, "clone: from "+url)
+	c.Assert(head[0].New.String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	origin, err := r.Reflog(plumbing.NewRemoteReferenceName("origin", "branch"))
+	c.Assert(err, IsNil)
+	c.Assert(origin, HasLen, 1)
+	c.Assert(origin[0].Message, Equals, "clone: from "+url)
+
+	tags, err := r.Reflog(plumbing.NewTagReferenceName("v1.0.0"))
+	c.Assert(err, IsNil)
+	c.Assert(tags, HasLen, 0)
+}
+
+func (s *ReflogSuite) TestReflogFetch(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{s.GetBasicLocalRepositoryURL()},
+	})
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("core").SetOption(logAllRefUpdatesKey, "true")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	err = r.Fetch(&FetchOptions{})
+	c.Assert(err, IsNil)
+
+	entries, err := r.Reflog(plumbing.NewRemoteReferenceName("origin", "master"))
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].Message, Equals, "fetch: storing head")
+
+	ref := plumbing.NewHashReference(
+		plumbing.NewRemoteReferenceName("origin", "master"),
+		plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294"),
+	)
+	c.Assert(r.Storer.SetReference(ref), IsNil)
+
+	err = r.Fetch(&FetchOptions{})
+	c.Assert(err, IsNil)
+
+	entries, err = r.Reflog(plumbing.NewRemoteReferenceName("origin", "master"))
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Assert(entries[0].Message, Equals, "fetch: fast-forward")
+	c.Assert(entries[0].Old, Equals, ref.Hash())
+}
+
+func (s *ReflogSuite) TestReflogLogAllRefUpdates(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	commit := plumbing.NewHashReference(plumbing.Master, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	tag := plumbing.NewHashReference(plumbing.NewTagReferenceName("v1"), commit.Hash())
+
+	c.Assert(updateReference(r.Storer, nil, commit, nil, nil, "bare"), IsNil)
+	entries, err := r.Reflog(plumbing.Master)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("core").SetOption(logAllRefUpdatesKey, "always")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	c.Assert(updateReference(r.Storer, nil, commit, nil, nil, "always"), IsNil)
+	c.Assert(updateReference(r.Storer, nil, tag, nil, nil, "always"), IsNil)
+
+	cfg.Raw.Section("core").SetOption(logAllRefUpdatesKey, "false")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	c.Assert(updateReference(r.Storer, nil, commit, nil, nil, "existing"), IsNil)
+
+	entries, err = r.Reflog(plumbing.Master)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Assert(entries[0].Message, Equals, "existing")
+
+	entries, err = r.Reflog(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Assert(entries[1].Message, Equals, "always")
+
+	entries, err = r.Reflog(tag.Name())
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+}
+
+func (s *ReflogSuite) TestReflogMalformedConfig(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	global := filepath.Join(c.MkDir(), "config")
+	c.Assert(ioutil.WriteFile(global, []byte("[core\n"), 0644), IsNil)
+	os.Setenv("GIT_CONFIG_GLOBAL", global)
+	defer os.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	// the config is read before the reference is updated
+	ref := plumbing.NewHashReference(plumbing.Master, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	c.Assert(updateReference(r.Storer, nil, ref, nil, nil, "foo"), NotNil)
+
+	_, err = r.Storer.Reference(plumbing.Master)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	c.Assert(updateReference(r.Storer, nil, ref, nil, nil, ""), IsNil)
+	_, err = r.Storer.Reference(plumbing.Master)
+	c.Assert(err, IsNil)
+}
+
+func (s *ReflogSuite) TestExpireReflog(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	at := func(value string) time.Time {
+		t, err := time.Parse(time.RFC3339, value)
+		c.Assert(err, IsNil)
+		return t
+	}
+
+	err := r.ExpireReflog(plumbing.HEAD, &ReflogExpireOptions{
+		Expire:            at("2016-12-01T20:55:00Z"),
+		ExpireUnreachable: at("2016-12-01T20:55:00Z"),
+	})
+	c.Assert(err, IsNil)
+
+	entries, err := r.Reflog(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Assert(entries[1].Message, Equals, "checkout: moving from branch to master")
+
+	err = r.ExpireReflog(plumbing.HEAD, nil)
+	c.Assert(err, IsNil)
+
+	entries, err = r.Reflog(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+}
+
+func (s *ReflogSuite) TestExpireReflogUnreachable(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	err := r.ExpireReflog(plumbing.HEAD, &ReflogExpireOptions{
+		Expire:            time.Unix(0, 0),
+		ExpireUnreachable: time.Now(),
+		Rewrite:           true,
+	})
+	c.Assert(err, IsNil)
+
+	entries, err := r.Reflog(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 4)
+	for _, e := range entries {
+		c.Assert(e.New.String(), Not(Equals), "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	}
+
+	c.Assert(entries[0].Old, Equals, entries[1].New)
+}
+
+func (s *ReflogSuite) TestDeleteReflogEntry(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	before, err := r.Reflog(plumbing.HEAD)
+	c.Assert(err, IsNil)
+
+	err = r.DeleteReflogEntry(plumbing.HEAD, 2)
+	c.Assert(err, IsNil)
+
+	entries, err := r.Reflog(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 4)
+	c.Assert(entries[1].Message, Equals, "checkout: moving from branch to master")
+	c.Assert(entries[1].Old, Equals, before[3].New)
+	c.Assert(strings.HasPrefix(entries[2].Message, "checkout:"), Equals, false)
+
+	err = r.DeleteReflogEntry(plumbing.HEAD, 4)
+	c.Assert(err, Equals, ErrReflogEntryNotFound)
+}
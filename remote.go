@@ -5,9 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	stdioutil "io/ioutil"
 
 	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/goabstract/go-git/v5/config"
+	giturl "github.com/goabstract/go-git/v5/internal/url"
 	"github.com/goabstract/go-git/v5/plumbing"
 	"github.com/goabstract/go-git/v5/plumbing/cache"
 	"github.com/goabstract/go-git/v5/plumbing/format/packfile"
@@ -19,6 +21,8 @@ import (
 	"github.com/goabstract/go-git/v5/plumbing/storer"
 	"github.com/goabstract/go-git/v5/plumbing/transport"
 	"github.com/goabstract/go-git/v5/plumbing/transport/client"
+	"github.com/goabstract/go-git/v5/plumbing/transport/credential"
+	"github.com/goabstract/go-git/v5/plumbing/transport/http"
 	"github.com/goabstract/go-git/v5/storage"
 	"github.com/goabstract/go-git/v5/storage/filesystem"
 	"github.com/goabstract/go-git/v5/storage/memory"
@@ -29,9 +33,20 @@ var (
 	NoErrAlreadyUpToDate     = errors.New("already up-to-date")
 	ErrDeleteRefNotSupported = errors.New("server does not support delete-refs")
 	ErrForceNeeded           = errors.New("some refs were not updated")
+	// ErrNotShallow is returned unshallowing a repository with its complete
+	// history.
+	ErrNotShallow = errors.New("repository is not shallow")
 )
 
 const (
+	fetchSection = "fetch"
+	pruneKey     = "prune"
+	pruneTagsKey = "pruneTags"
+
+	// infiniteDepth is the depth requested to unshallow a repository, the
+	// same used by git.
+	infiniteDepth = 0x7fffffff
+
 	// This describes the maximum number of commits to walk when
 	// computing the haves to send to a server, for each ref in the
 	// repo containing this remote, when not using the multi-ack
@@ -60,8 +75,14 @@ func (r *Remote) Config() *config.RemoteConfig {
 func (r *Remote) String() string {
 	var fetch, push string
 	if len(r.c.URLs) > 0 {
-		fetch = r.c.URLs[0]
-		push = r.c.URLs[0]
+		fetch, push = r.c.URLs[0], r.c.URLs[0]
+		if url, err := r.fetchURL(); err == nil {
+			fetch = url
+		}
+
+		if urls, err := r.pushURLs(); err == nil {
+			push = urls[0]
+		}
 	}
 
 	return fmt.Sprintf("%s\t%s (fetch)\n%[1]s\t%[3]s (push)", r.c.Name, fetch, push)
@@ -88,7 +109,96 @@ func (r *Remote) PushContext(ctx context.Context, o *PushOptions) (err error) {
 		return fmt.Errorf("remote names don't match: %s != %s", o.RemoteName, r.c.Name)
 	}
 
-	s, err := newSendPackSession(r.c.URLs[0], o.Auth)
+	urls, err := r.pushURLs()
+	if err != nil {
+		return err
+	}
+
+	upToDate := true
+	for _, url := range urls {
+		err := r.push(ctx, url, o)
+		if err == NoErrAlreadyUpToDate {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		upToDate = false
+	}
+
+	if upToDate {
+		return NoErrAlreadyUpToDate
+	}
+
+	return nil
+}
+
+// fetchURL returns the URL a fetch is sent to, the first URL of the remote
+// rewritten by the url.<base>.insteadOf rules.
+func (r *Remote) fetchURL() (string, error) {
+	cfg, err := r.repositoryConfig()
+	if err != nil || cfg == nil {
+		return r.c.URLs[0], err
+	}
+
+	return cfg.RewriteURL(r.c.URLs[0]), nil
+}
+
+// pushURLs returns the URLs a push is sent to: the push URLs of the remote,
+// rewritten by the url.<base>.insteadOf rules, if any, or else its first URL,
+// rewritten by the url.<base>.pushInsteadOf rules first, as git does.
+func (r *Remote) pushURLs() ([]string, error) {
+	cfg, err := r.repositoryConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.c.PushURLs) == 0 {
+		url := r.c.URLs[0]
+		if cfg != nil {
+			url = cfg.RewritePushURL(url)
+		}
+
+		return []string{url}, nil
+	}
+
+	urls := make([]string, len(r.c.PushURLs))
+	for i, url := range r.c.PushURLs {
+		if cfg != nil {
+			url = cfg.RewriteURL(url)
+		}
+
+		urls[i] = url
+	}
+
+	return urls, nil
+}
+
+// repositoryConfig returns the merged config of the repository of the
+// remote, the URL rewriting rules, the HTTP settings and the fetch defaults
+// are read from, nil if the remote has no storer.
+func (r *Remote) repositoryConfig() (*config.Config, error) {
+	if r.s == nil {
+		return nil, nil
+	}
+
+	return mergedConfig(r.s)
+}
+
+func (r *Remote) push(ctx context.Context, url string, o *PushOptions) (err error) {
+	ep, err := r.endpoint(url, &o.HTTP)
+	if err != nil {
+		return err
+	}
+
+	auth, err := r.auth(ep, url, o.Auth)
+	if err != nil {
+		return err
+	}
+
+	s, err := newSendPackSession(ep, auth)
 	if err != nil {
 		return err
 	}
@@ -155,12 +265,12 @@ func (r *Remote) PushContext(ctx context.Context, o *PushOptions) (err error) {
 	var hashesToPush []plumbing.Hash
 	// Avoid the expensive revlist operation if we're only doing deletes.
 	if !allDelete {
-		if r.c.IsFirstURLLocal() {
+		if giturl.IsLocalEndpoint(url) {
 			// If we're are pushing to a local repo, it might be much
 			// faster to use a local storage layer to get the commits
 			// to ignore, when calculating the object revlist.
 			localStorer := filesystem.NewStorage(
-				osfs.New(r.c.URLs[0]), cache.NewObjectLRUDefault())
+				osfs.New(url), cache.NewObjectLRUDefault())
 			hashesToPush, err = revlist.ObjectsWithStorageForIgnores(
 				r.s, localStorer, objects, haves)
 		} else {
@@ -226,6 +336,11 @@ func (r *Remote) updateRemoteReferenceStorage(
 	result *packp.ReportStatus,
 ) error {
 
+	cfg, err := reflogConfig(r.s)
+	if err != nil {
+		return err
+	}
+
 	for _, spec := range r.c.Fetch {
 		for _, c := range req.Commands {
 			if !spec.Match(c.Name) {
@@ -236,7 +351,7 @@ func (r *Remote) updateRemoteReferenceStorage(
 			ref := plumbing.NewHashReference(local, c.New)
 			switch c.Action() {
 			case packp.Create, packp.Update:
-				if err := r.s.SetReference(ref); err != nil {
+				if err := updateReference(r.s, cfg, ref, nil, nil, "update by push"); err != nil {
 					return err
 				}
 			case packp.Delete:
@@ -260,7 +375,7 @@ func (r *Remote) updateRemoteReferenceStorage(
 // operation is complete, an error is returned. The context only affects to the
 // transport operations.
 func (r *Remote) FetchContext(ctx context.Context, o *FetchOptions) error {
-	_, err := r.fetch(ctx, o)
+	_, err := r.fetch(ctx, o, reflogAction("fetch"))
 	return err
 }
 
@@ -273,7 +388,12 @@ func (r *Remote) Fetch(o *FetchOptions) error {
 	return r.FetchContext(context.Background(), o)
 }
 
-func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.ReferenceStorer, err error) {
+// fetch fetches the references given in the options, recording their
+// updates in the reflog with the messages returned by reflogMsg for every
+// kind of update: "storing head", "storing tag", "fast-forward" and
+// "forced-update".
+func (r *Remote) fetch(ctx context.Context, o *FetchOptions,
+	reflogMsg func(string) string) (sto storer.ReferenceStorer, err error) {
 	if o.RemoteName == "" {
 		o.RemoteName = r.c.Name
 	}
@@ -286,7 +406,35 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 		o.RefSpecs = r.c.Fetch
 	}
 
-	s, err := newUploadPackSession(r.c.URLs[0], o.Auth)
+	// the shallow commits are checked before connecting, a remote without a
+	// storer has none.
+	var shallows []plumbing.Hash
+	if r.s != nil {
+		if shallows, err = r.s.Shallow(); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.Unshallow && len(shallows) == 0 {
+		return nil, ErrNotShallow
+	}
+
+	url, err := r.fetchURL()
+	if err != nil {
+		return nil, err
+	}
+
+	ep, err := r.endpoint(url, &o.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := r.auth(ep, url, o.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := newUploadPackSession(ep, auth)
 	if err != nil {
 		return nil, err
 	}
@@ -318,11 +466,12 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 		return nil, err
 	}
 
-	wantRefs, err := getWants(r.s, refs)
+	wantRefs, err := getWants(r.s, refs, shallows, o.isShallow())
 	if err != nil {
 		return nil, err
 	}
 
+	var shallowUpdated bool
 	req.Wants = make([]plumbing.Hash, 0)
 	req.Wants = append(req.Wants, o.Hashes...)
 	req.Wants = append(req.Wants, wantRefs...)
@@ -333,82 +482,232 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 			return nil, err
 		}
 
-		req.Shallows, err = r.s.Shallow()
-		if err != nil {
-			return nil, err
+		req.Shallows = shallows
+		if len(req.Shallows) != 0 {
+			if err := req.Capabilities.Set(capability.Shallow); err != nil {
+				return nil, err
+			}
 		}
 
-		if err = r.fetchPack(ctx, o, s, req); err != nil {
+		if shallowUpdated, err = r.fetchPack(ctx, o, s, req); err != nil {
 			return nil, err
 		}
 	}
 
-	updated, err := r.updateLocalReferenceStorage(o.RefSpecs, refs, remoteRefs, o.Tags, o.Force)
+	pruned, err := r.pruneFetched(o, remoteRefs)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := r.updateLocalReferenceStorage(o.RefSpecs, refs, remoteRefs, o.Tags, o.Force, reflogMsg)
 	if err != nil {
 		return nil, err
 	}
 
-	if !updated {
+	if !updated && !shallowUpdated && !pruned {
 		return remoteRefs, NoErrAlreadyUpToDate
 	}
 
 	return remoteRefs, nil
 }
 
-func newUploadPackSession(url string, auth transport.AuthMethod) (transport.UploadPackSession, error) {
-	c, ep, err := newClient(url)
+// pruneFetched deletes the local references matched by the refspecs of the
+// fetch, and the tags if pruning them, that are missing from the given
+// references of the remote, if the fetch prunes.
+func (r *Remote) pruneFetched(o *FetchOptions, remoteRefs storer.ReferenceStorer) (pruned bool, err error) {
+	prune, pruneTags, err := r.pruneConfig(o)
+	if err != nil || !prune {
+		return false, err
+	}
+
+	specs := o.RefSpecs
+	if pruneTags {
+		specs = append(specs[:len(specs):len(specs)], config.RefSpec(refspecAllTags))
+	}
+
+	stale, err := r.staleReferences(specs, remoteRefs)
+	if err != nil {
+		return false, err
+	}
+
+	for _, name := range stale {
+		if err := r.s.RemoveReference(name); err != nil {
+			return false, err
+		}
+	}
+
+	return len(stale) != 0, nil
+}
+
+// pruneConfig returns whether the fetch prunes the references, and the tags,
+// following remote.<name>.prune and fetch.prune, and their pruneTags
+// counterparts, unless set in the options.
+func (r *Remote) pruneConfig(o *FetchOptions) (prune, pruneTags bool, err error) {
+	if o.Prune != PruneFromConfig && o.PruneTags != PruneFromConfig {
+		return o.Prune == PruneEnabled, o.PruneTags == PruneEnabled, nil
+	}
+
+	cfg, err := r.repositoryConfig()
+	if err != nil {
+		return false, false, err
+	}
+
+	enabled := func(mode PruneMode, key string) bool {
+		if mode != PruneFromConfig || cfg == nil {
+			return mode == PruneEnabled
+		}
+
+		remotes := cfg.Raw.Section(remoteSection)
+		if remotes.HasSubsection(r.c.Name) {
+			if value, ok := remotes.Subsection(r.c.Name).Options.Bool(key); ok {
+				return value
+			}
+		}
+
+		value, _ := cfg.Raw.Section(fetchSection).Options.Bool(key)
+		return value
+	}
+
+	return enabled(o.Prune, pruneKey), enabled(o.PruneTags, pruneTagsKey), nil
+}
+
+// endpoint returns the endpoint of the URL, with the settings of the http
+// section of the config applying to it, overridden by the given options, if
+// it is an HTTP or HTTPS URL.
+func (r *Remote) endpoint(url string, o *HTTPOptions) (*transport.Endpoint, error) {
+	ep, err := transport.NewEndpoint(url)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.NewUploadPackSession(ep, auth)
+	if ep.Protocol != "http" && ep.Protocol != "https" {
+		return ep, nil
+	}
+
+	cfg, err := r.repositoryConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg != nil {
+		h := cfg.HTTP(url)
+		ep.InsecureSkipTLS = !h.SSLVerify
+		ep.Proxy.URL = h.Proxy
+		ep.Headers = h.ExtraHeaders
+		ep.CookieFile = expandHomePath(h.CookieFile)
+		ep.UserAgent = h.UserAgent
+
+		if h.SSLCAInfo != "" {
+			ep.CABundle, err = stdioutil.ReadFile(expandHomePath(h.SSLCAInfo))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if o == nil {
+		return ep, nil
+	}
+
+	ep.InsecureSkipTLS = ep.InsecureSkipTLS || o.InsecureSkipTLS
+	ep.Headers = append(ep.Headers, o.ExtraHeaders...)
+	if o.CABundle != nil {
+		ep.CABundle = o.CABundle
+	}
+
+	if o.Proxy.URL != "" {
+		ep.Proxy = o.Proxy
+	}
+
+	if o.CookieFile != "" {
+		ep.CookieFile = o.CookieFile
+	}
+
+	if o.UserAgent != "" {
+		ep.UserAgent = o.UserAgent
+	}
+
+	return ep, nil
 }
 
-func newSendPackSession(url string, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
-	c, ep, err := newClient(url)
+// auth returns the auth method of the endpoint of the URL: the given one, or
+// else, for the HTTP endpoints without a password, one filling the
+// credentials with ~/.netrc and the helpers of the credential section of the
+// config when the server requires authentication.
+func (r *Remote) auth(ep *transport.Endpoint, url string, auth transport.AuthMethod) (transport.AuthMethod, error) {
+	if auth != nil || ep.Password != "" || (ep.Protocol != "http" && ep.Protocol != "https") {
+		return auth, nil
+	}
+
+	cfg, err := r.repositoryConfig()
 	if err != nil {
 		return nil, err
 	}
 
-	return c.NewReceivePackSession(ep, auth)
+	settings := &config.Credential{}
+	if cfg != nil {
+		settings = cfg.Credential(url)
+	}
+
+	query, err := credential.NewCredential(url, settings.UseHTTPPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Username == "" {
+		query.Username = settings.Username
+	}
+
+	helpers := credential.Helpers{&credential.Netrc{}}
+	for _, helper := range settings.Helpers {
+		helpers = append(helpers, credential.NewExecHelper(helper))
+	}
+
+	return &http.CredentialHelperAuth{Helpers: helpers, Credential: query}, nil
 }
 
-func newClient(url string) (transport.Transport, *transport.Endpoint, error) {
-	ep, err := transport.NewEndpoint(url)
+func newUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	c, err := client.NewClient(ep)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
+	return c.NewUploadPackSession(ep, auth)
+}
+
+func newSendPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
 	c, err := client.NewClient(ep)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	return c, ep, err
+	return c.NewReceivePackSession(ep, auth)
 }
 
+// fetchPack fetches the packfile for the given request, returning true if
+// the shallow commits of the repository changed.
 func (r *Remote) fetchPack(ctx context.Context, o *FetchOptions, s transport.UploadPackSession,
-	req *packp.UploadPackRequest) (err error) {
+	req *packp.UploadPackRequest) (shallowUpdated bool, err error) {
 
 	reader, err := s.UploadPack(ctx, req)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	defer ioutil.CheckClose(reader, &err)
 
-	if err = r.updateShallow(o, reader); err != nil {
-		return err
+	if shallowUpdated, err = r.updateShallow(reader); err != nil {
+		return false, err
 	}
 
 	if err = packfile.UpdateObjectStorage(r.s,
 		buildSidebandIfSupported(req.Capabilities, reader, o.Progress),
 		o.ProgressReporter,
 	); err != nil {
-		return err
+		return false, err
 	}
 
-	return err
+	return shallowUpdated, err
 }
 
 func (r *Remote) addReferencesToUpdate(
@@ -425,6 +724,14 @@ func (r *Remote) addReferencesToUpdate(
 	}
 
 	for _, rs := range refspecs {
+		if rs.IsMatching() {
+			if err := r.addMatchingReferences(rs, localRefs, remoteRefs, req); err != nil {
+				return err
+			}
+
+			continue
+		}
+
 		if rs.IsDelete() {
 			if err := r.deleteReferences(rs, remoteRefs, refsDict, req, false); err != nil {
 				return err
@@ -474,6 +781,41 @@ func (r *Remote) addOrUpdateReferences(
 	return nil
 }
 
+// addMatchingReferences updates the branches existing with the same name
+// both locally and in the remote, as the ":" refspec does.
+func (r *Remote) addMatchingReferences(
+	rs config.RefSpec,
+	localRefs []*plumbing.Reference,
+	remoteRefs storer.ReferenceStorer,
+	req *packp.ReferenceUpdateRequest,
+) error {
+	for _, ref := range localRefs {
+		if !ref.Name().IsBranch() {
+			continue
+		}
+
+		_, err := remoteRefs.Reference(ref.Name())
+		if err == plumbing.ErrReferenceNotFound {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		spec := config.RefSpec(ref.Name() + ":" + ref.Name())
+		if rs.IsForceUpdate() {
+			spec = "+" + spec
+		}
+
+		if err := r.addReferenceIfRefSpecMatches(spec, remoteRefs, ref, req); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (r *Remote) deleteReferences(rs config.RefSpec,
 	remoteRefs storer.ReferenceStorer,
 	refsDict map[string]*plumbing.Reference,
@@ -753,13 +1095,12 @@ func doCalculateRefs(
 	return err
 }
 
-func getWants(localStorer storage.Storer, refs memory.ReferenceStorage) ([]plumbing.Hash, error) {
-	wants := map[plumbing.Hash]bool{}
+// getWants returns the objects of the references not available locally, or
+// all of them when deepening a shallow repository.
+func getWants(localStorer storage.Storer, refs memory.ReferenceStorage,
+	shallows []plumbing.Hash, deepen bool) ([]plumbing.Hash, error) {
 
-	shallows, err := localStorer.Shallow()
-	if err != nil {
-		return nil, err
-	}
+	wants := map[plumbing.Hash]bool{}
 	shallowCommits := map[plumbing.Hash]bool{}
 	for _, shallow := range shallows {
 		shallowCommits[shallow] = true
@@ -773,7 +1114,7 @@ func getWants(localStorer storage.Storer, refs memory.ReferenceStorage) ([]plumb
 		}
 
 		if exists {
-			if _, isShallow := shallowCommits[hash]; isShallow {
+			if _, isShallow := shallowCommits[hash]; isShallow || (deepen && len(shallows) != 0) {
 				wants[hash] = true
 			}
 		} else {
@@ -847,12 +1188,8 @@ func (r *Remote) newUploadPackRequest(o *FetchOptions,
 	ar *packp.AdvRefs) (*packp.UploadPackRequest, error) {
 
 	req := packp.NewUploadPackRequestFromCapabilities(ar.Capabilities)
-
-	if o.Depth != 0 {
-		req.Depth = packp.DepthCommits(o.Depth)
-		if err := req.Capabilities.Set(capability.Shallow); err != nil {
-			return nil, err
-		}
+	if err := setUploadPackDepth(req, o, ar); err != nil {
+		return nil, err
 	}
 
 	if o.Progress == nil && ar.Capabilities.Supports(capability.NoProgress) {
@@ -901,10 +1238,16 @@ func (r *Remote) updateLocalReferenceStorage(
 	fetchedRefs, remoteRefs memory.ReferenceStorage,
 	tagMode TagMode,
 	force bool,
+	reflogMsg func(string) string,
 ) (updated bool, err error) {
 	isWildcard := true
 	forceNeeded := false
 
+	cfg, err := reflogConfig(r.s)
+	if err != nil {
+		return false, err
+	}
+
 	for _, spec := range specs {
 		if !spec.IsWildcard() {
 			isWildcard = false
@@ -923,21 +1266,28 @@ func (r *Remote) updateLocalReferenceStorage(
 			old, _ := storer.ResolveReference(r.s, localName)
 			new := plumbing.NewHashReference(localName, ref.Hash())
 
-			// If the ref exists locally as a branch and force is not specified,
-			// only update if the new ref is an ancestor of the old
-			if old != nil && old.Name().IsBranch() && !force && !spec.IsForceUpdate() {
+			action := "storing head"
+			if old != nil && old.Hash() != new.Hash() {
+				// If the ref exists locally as a branch and force is not
+				// specified, only update if the new ref is an ancestor of the old
+				checkFF := old.Name().IsBranch() && !force && !spec.IsForceUpdate()
 				ff, err := isFastForward(r.s, old.Hash(), new.Hash())
-				if err != nil {
+				if err != nil && checkFF {
 					return updated, err
 				}
 
-				if !ff {
+				if !ff && checkFF {
 					forceNeeded = true
 					continue
 				}
+
+				action = "forced-update"
+				if ff {
+					action = "fast-forward"
+				}
 			}
 
-			refUpdated, err := checkAndUpdateReferenceStorerIfNeeded(r.s, new, old)
+			refUpdated, err := checkAndUpdateReferenceStorerIfNeeded(r.s, cfg, new, old, reflogMsg(action))
 			if err != nil {
 				return updated, err
 			}
@@ -956,7 +1306,7 @@ func (r *Remote) updateLocalReferenceStorage(
 	if isWildcard {
 		tags = remoteRefs
 	}
-	tagUpdated, err := r.buildFetchedTags(tags)
+	tagUpdated, err := r.buildFetchedTags(cfg, tags, reflogMsg("storing tag"))
 	if err != nil {
 		return updated, err
 	}
@@ -972,7 +1322,7 @@ func (r *Remote) updateLocalReferenceStorage(
 	return
 }
 
-func (r *Remote) buildFetchedTags(refs memory.ReferenceStorage) (updated bool, err error) {
+func (r *Remote) buildFetchedTags(cfg *config.Config, refs memory.ReferenceStorage, msg string) (updated bool, err error) {
 	for _, ref := range refs {
 		if !ref.Name().IsTag() {
 			continue
@@ -987,7 +1337,7 @@ func (r *Remote) buildFetchedTags(refs memory.ReferenceStorage) (updated bool, e
 			return false, err
 		}
 
-		refUpdated, err := updateReferenceStorerIfNeeded(r.s, ref)
+		refUpdated, err := updateReferenceStorerIfNeeded(r.s, cfg, ref, msg)
 		if err != nil {
 			return updated, err
 		}
@@ -1002,35 +1352,55 @@ func (r *Remote) buildFetchedTags(refs memory.ReferenceStorage) (updated bool, e
 
 // List the references on the remote repository.
 func (r *Remote) List(o *ListOptions) (rfs []*plumbing.Reference, err error) {
-	s, err := newUploadPackSession(r.c.URLs[0], o.Auth)
+	allRefs, err := r.advertisedReferences(o.Auth, &o.HTTP)
 	if err != nil {
 		return nil, err
 	}
 
-	defer ioutil.CheckClose(s, &err)
+	refs, err := allRefs.IterReferences()
+	if err != nil {
+		return nil, err
+	}
 
-	ar, err := s.AdvertisedReferences()
+	var resultRefs []*plumbing.Reference
+	refs.ForEach(func(ref *plumbing.Reference) error {
+		resultRefs = append(resultRefs, ref)
+		return nil
+	})
+
+	return resultRefs, nil
+}
+
+// advertisedReferences returns the references advertised by the remote
+// repository, HEAD included.
+func (r *Remote) advertisedReferences(auth transport.AuthMethod, o *HTTPOptions) (refs memory.ReferenceStorage, err error) {
+	url, err := r.fetchURL()
 	if err != nil {
 		return nil, err
 	}
 
-	allRefs, err := ar.AllReferences()
+	ep, err := r.endpoint(url, o)
 	if err != nil {
 		return nil, err
 	}
 
-	refs, err := allRefs.IterReferences()
+	if auth, err = r.auth(ep, url, auth); err != nil {
+		return nil, err
+	}
+
+	s, err := newUploadPackSession(ep, auth)
 	if err != nil {
 		return nil, err
 	}
 
-	var resultRefs []*plumbing.Reference
-	refs.ForEach(func(ref *plumbing.Reference) error {
-		resultRefs = append(resultRefs, ref)
-		return nil
-	})
+	defer ioutil.CheckClose(s, &err)
 
-	return resultRefs, nil
+	ar, err := s.AdvertisedReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	return ar.AllReferences()
 }
 
 func objectsToPush(commands []*packp.Command) []plumbing.Hash {
@@ -1118,9 +1488,61 @@ func pushHashes(
 	return rs, nil
 }
 
-func (r *Remote) updateShallow(o *FetchOptions, resp *packp.UploadPackResponse) error {
-	if o.Depth == 0 || len(resp.Shallows)+len(resp.Unshallows) == 0 {
+// setUploadPackDepth sets the depth of the request from the shallow options
+// of the fetch, along with the capabilities required by it.
+func setUploadPackDepth(req *packp.UploadPackRequest, o *FetchOptions, ar *packp.AdvRefs) error {
+	var depths packp.Depths
+	caps := []capability.Capability{capability.Shallow}
+	switch {
+	case o.Depth != 0:
+		depths = append(depths, packp.DepthCommits(o.Depth))
+	case o.Deepen != 0:
+		depths = append(depths, packp.DepthCommits(o.Deepen))
+		caps = append(caps, capability.DeepenRelative)
+	case o.Unshallow:
+		depths = append(depths, packp.DepthCommits(infiniteDepth))
+	default:
+		if !o.ShallowSince.IsZero() {
+			depths = append(depths, packp.DepthSince(o.ShallowSince))
+			caps = append(caps, capability.DeepenSince)
+		}
+
+		for _, ref := range o.ShallowExclude {
+			depths = append(depths, packp.DepthReference(ref))
+		}
+
+		if len(o.ShallowExclude) != 0 {
+			caps = append(caps, capability.DeepenNot)
+		}
+	}
+
+	switch len(depths) {
+	case 0:
 		return nil
+	case 1:
+		req.Depth = depths[0]
+	default:
+		req.Depth = depths
+	}
+
+	for _, c := range caps {
+		if c != capability.Shallow && !ar.Capabilities.Supports(c) {
+			return fmt.Errorf("server does not support %s", c)
+		}
+
+		if err := req.Capabilities.Set(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateShallow updates the shallow commits of the repository from the
+// shallow-update sent by the server, returning true if they changed.
+func (r *Remote) updateShallow(resp *packp.UploadPackResponse) (bool, error) {
+	if len(resp.Shallows)+len(resp.Unshallows) == 0 {
+		return false, nil
 	}
 
 	shallowUpdates := map[plumbing.Hash]bool{}
@@ -1130,7 +1552,7 @@ func (r *Remote) updateShallow(o *FetchOptions, resp *packp.UploadPackResponse)
 
 	currentShallows, err := r.s.Shallow()
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	shallows := []plumbing.Hash{}
@@ -1145,5 +1567,23 @@ func (r *Remote) updateShallow(o *FetchOptions, resp *packp.UploadPackResponse)
 		shallows = append(shallows, s)
 	}
 
-	return r.s.SetShallow(shallows)
+	if equalHashes(currentShallows, shallows) {
+		return false, nil
+	}
+
+	return true, r.s.SetShallow(shallows)
+}
+
+func equalHashes(a, b []plumbing.Hash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
 }
@@ -0,0 +1,380 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/goabstract/go-git/v5/config"
+	"github.com/goabstract/go-git/v5/plumbing"
+	format "github.com/goabstract/go-git/v5/plumbing/format/config"
+	"github.com/goabstract/go-git/v5/plumbing/format/reflog"
+	"github.com/goabstract/go-git/v5/plumbing/storer"
+	"github.com/goabstract/go-git/v5/storage"
+)
+
+var (
+	// ErrRemoteHeadNotFound is returned setting the HEAD of a remote from the
+	// remote repository, when the HEAD of the remote repository isn't a
+	// branch.
+	ErrRemoteHeadNotFound = errors.New("cannot determine the HEAD branch of the remote")
+)
+
+const (
+	branchPushRemoteKey  = "pushRemote"
+	remoteFetchKey       = "fetch"
+	remotePushDefaultKey = "pushDefault"
+	remoteTrackingPrefix = "refs/remotes/"
+	refSpecSeparator     = ":"
+)
+
+// RenameRemote renames a remote, as `git remote rename` does: its
+// remote-tracking references, with their reflogs, are moved from
+// refs/remotes/<old> to refs/remotes/<new>, and its fetch refspecs, and the
+// branches fetching or pushing to it, are updated.
+func (r *Repository) RenameRemote(old, new string) error {
+	if new == "" {
+		return config.ErrRemoteConfigEmptyName
+	}
+
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := cfg.Remotes[old]; !ok {
+		return ErrRemoteNotFound
+	}
+
+	if _, ok := cfg.Remotes[new]; ok {
+		return ErrRemoteExists
+	}
+
+	cfg, err = renameRemoteConfig(cfg, old, new)
+	if err != nil {
+		return err
+	}
+
+	if err := r.Storer.SetConfig(cfg); err != nil {
+		return err
+	}
+
+	return renameRemoteReferences(r.Storer, old, new)
+}
+
+// renameRemoteConfig returns the config with the remote.<old> section moved
+// to remote.<new>, with all its options, and every reference to the remote
+// updated.
+func renameRemoteConfig(cfg *config.Config, old, new string) (*config.Config, error) {
+	// the raw config is updated from the remotes and branches first
+	if _, err := cfg.Marshal(); err != nil {
+		return nil, err
+	}
+
+	remotes := cfg.Raw.Section(remoteSection)
+	ss := remotes.Subsection(old)
+	ss.Name = new
+
+	from := refSpecSeparator + remoteTrackingPrefix + old + "/"
+	to := refSpecSeparator + remoteTrackingPrefix + new + "/"
+	for _, o := range ss.Options {
+		if o.IsKey(remoteFetchKey) {
+			o.Value = strings.Replace(o.Value, from, to, 1)
+		}
+	}
+
+	for _, b := range cfg.Raw.Section(branchSection).Subsections {
+		for _, o := range b.Options {
+			if (o.IsKey(branchRemoteKey) || o.IsKey(branchPushRemoteKey)) && o.Value == old {
+				o.Value = new
+			}
+		}
+	}
+
+	for _, o := range remotes.Options {
+		if o.IsKey(remotePushDefaultKey) && o.Value == old {
+			o.Value = new
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := format.NewEncoder(&buf).Encode(cfg.Raw); err != nil {
+		return nil, err
+	}
+
+	result := config.NewConfig()
+	if err := result.Unmarshal(buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// renameRemoteReferences moves the references under refs/remotes/<old> to
+// refs/remotes/<new>, retargeting the symbolic ones, as HEAD, at once if the
+// storer supports transactions.
+func renameRemoteReferences(s storage.Storer, old, new string) error {
+	from := remoteTrackingPrefix + old + "/"
+	to := remoteTrackingPrefix + new + "/"
+	rename := func(name plumbing.ReferenceName) plumbing.ReferenceName {
+		return plumbing.ReferenceName(to + strings.TrimPrefix(name.String(), from))
+	}
+
+	iter, err := s.IterReferences()
+	if err != nil {
+		return err
+	}
+
+	var refs []*plumbing.Reference
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().String(), from) {
+			refs = append(refs, ref)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	rs, hasReflog := s.(storer.ReflogStorer)
+	reflogs := make(map[plumbing.ReferenceName][]*reflog.Entry)
+	var updates []storer.ReferenceUpdate
+	for _, ref := range refs {
+		moved := plumbing.NewHashReference(rename(ref.Name()), ref.Hash())
+		if ref.Type() == plumbing.SymbolicReference {
+			target := ref.Target()
+			if strings.HasPrefix(target.String(), from) {
+				target = rename(target)
+			}
+
+			moved = plumbing.NewSymbolicReference(rename(ref.Name()), target)
+		}
+
+		updates = append(updates,
+			storer.ReferenceUpdate{Action: storer.UpdateReference, Name: moved.Name(), New: moved},
+			storer.ReferenceUpdate{Action: storer.DeleteReference, Name: ref.Name(), Old: ref},
+		)
+
+		if !hasReflog {
+			continue
+		}
+
+		entries, err := rs.Reflog(ref.Name())
+		if err != nil {
+			return err
+		}
+
+		if len(entries) != 0 {
+			reflogs[moved.Name()] = entries
+		}
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	if ts, ok := s.(storer.ReferenceTransactionStorer); ok {
+		if err := ts.UpdateReferences(updates); err != nil {
+			return err
+		}
+	} else {
+		for _, u := range updates {
+			if u.Action == storer.DeleteReference {
+				err = s.RemoveReference(u.Name)
+			} else {
+				err = s.SetReference(u.New)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for name, entries := range reflogs {
+		if err := rs.SetReflog(name, entries); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetURL sets the URLs of the remote, as `git remote set-url` does. The first
+// one is used to fetch, and all of them to push, unless the remote has push
+// URLs.
+func (r *Remote) SetURL(urls ...string) error {
+	if len(urls) == 0 {
+		return config.ErrRemoteConfigEmptyURL
+	}
+
+	return r.updateConfig(func(c *config.RemoteConfig) {
+		c.URLs = urls
+	})
+}
+
+// SetPushURL sets the URLs the remote pushes to, as
+// `git remote set-url --push` does, stored as remote.<name>.pushurl. Without
+// URLs, the remote pushes again to its URLs.
+func (r *Remote) SetPushURL(urls ...string) error {
+	return r.updateConfig(func(c *config.RemoteConfig) {
+		c.PushURLs = urls
+	})
+}
+
+// updateConfig applies the given change to the config of the remote, and
+// stores it in the config of the repository.
+func (r *Remote) updateConfig(f func(c *config.RemoteConfig)) error {
+	cfg, err := r.s.Config()
+	if err != nil {
+		return err
+	}
+
+	c, ok := cfg.Remotes[r.c.Name]
+	if !ok {
+		return ErrRemoteNotFound
+	}
+
+	f(c)
+	if err := r.s.SetConfig(cfg); err != nil {
+		return err
+	}
+
+	r.c = c
+	return nil
+}
+
+// SetHead sets refs/remotes/<name>/HEAD, the default branch of the remote,
+// as `git remote set-head` does. Without a branch, the branch HEAD points to
+// in the remote repository is used, as `git remote set-head --auto`.
+func (r *Remote) SetHead(o *SetHeadOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	name := plumbing.NewRemoteHEADReferenceName(r.c.Name)
+	if o.Delete {
+		return r.s.RemoveReference(name)
+	}
+
+	target := plumbing.NewRemoteReferenceName(r.c.Name, o.Branch)
+	if o.Branch == "" {
+		remoteRefs, err := r.advertisedReferences(o.Auth, nil)
+		if err != nil {
+			return err
+		}
+
+		head, ok := remoteHeadReference(r.c.Name, remoteRefs)
+		if !ok {
+			return ErrRemoteHeadNotFound
+		}
+
+		target = head.Target()
+	}
+
+	if _, err := r.s.Reference(target); err != nil {
+		return err
+	}
+
+	return r.s.SetReference(plumbing.NewSymbolicReference(name, target))
+}
+
+// remoteHeadReference returns refs/remotes/<name>/HEAD pointing to the
+// remote-tracking branch of the branch HEAD points to in the given
+// references of the remote, if any.
+func remoteHeadReference(name string, remoteRefs storer.ReferenceStorer) (*plumbing.Reference, bool) {
+	head, err := remoteRefs.Reference(plumbing.HEAD)
+	if err != nil || head.Type() != plumbing.SymbolicReference || !head.Target().IsBranch() {
+		return nil, false
+	}
+
+	return plumbing.NewSymbolicReference(
+		plumbing.NewRemoteHEADReferenceName(name),
+		plumbing.NewRemoteReferenceName(name, head.Target().Short()),
+	), true
+}
+
+// Prune deletes the remote-tracking references of the remote whose
+// references no longer exist in the remote repository, as
+// `git remote prune` does, returning the names of the deleted references.
+func (r *Remote) Prune(o *RemotePruneOptions) ([]plumbing.ReferenceName, error) {
+	remoteRefs, err := r.advertisedReferences(o.Auth, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stale, err := r.staleReferences(r.c.Fetch, remoteRefs)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.DryRun {
+		return stale, nil
+	}
+
+	for _, name := range stale {
+		if err := r.s.RemoveReference(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return stale, nil
+}
+
+// staleReferences returns the names of the local references matched by the
+// destination of the given refspecs, whose sources aren't in the given
+// references of the remote. The symbolic references are never stale.
+func (r *Remote) staleReferences(specs []config.RefSpec,
+	remoteRefs storer.ReferenceStorer) ([]plumbing.ReferenceName, error) {
+
+	var reverse []config.RefSpec
+	for _, spec := range specs {
+		s := strings.TrimPrefix(spec.String(), "+")
+		if strings.Contains(s, refSpecSeparator) {
+			reverse = append(reverse, config.RefSpec(s).Reverse())
+		}
+	}
+
+	iter, err := r.s.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []plumbing.ReferenceName
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+
+		matched := false
+		for _, spec := range reverse {
+			if !spec.Match(ref.Name()) {
+				continue
+			}
+
+			matched = true
+			_, err := remoteRefs.Reference(spec.Dst(ref.Name()))
+			if err == nil {
+				return nil
+			}
+
+			if err != plumbing.ErrReferenceNotFound {
+				return err
+			}
+		}
+
+		if matched {
+			stale = append(stale, ref.Name())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(stale, func(i, j int) bool { return stale[i] < stale[j] })
+	return stale, nil
+}
@@ -0,0 +1,264 @@
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/goabstract/go-git/v5/config"
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/storage/memory"
+
+	fixtures "github.com/go-git/go-git-fixtures/v4"
+	. "gopkg.in/check.v1"
+)
+
+type RemoteAdminSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&RemoteAdminSuite{})
+
+// serverSetup returns a writable bare clone of the basic fixture, with the
+// branch "branch", and a clone of it.
+func (s *RemoteAdminSuite) serverSetup(c *C) (server, r *Repository) {
+	url := c.MkDir()
+	server, err := PlainClone(url, true, &CloneOptions{
+		URL: fixtures.Basic().One().DotGit().Root(),
+	})
+	c.Assert(err, IsNil)
+
+	branch := plumbing.NewReferenceFromStrings("refs/heads/branch", "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	c.Assert(server.Storer.SetReference(branch), IsNil)
+
+	r, err = Clone(memory.NewStorage(), memfs.New(), &CloneOptions{URL: url})
+	c.Assert(err, IsNil)
+	return server, r
+}
+
+func (s *RemoteAdminSuite) TestCloneSetsRemoteHead(c *C) {
+	_, r := s.serverSetup(c)
+
+	head, err := r.Reference(plumbing.NewRemoteHEADReferenceName("origin"), false)
+	c.Assert(err, IsNil)
+	c.Assert(head.Type(), Equals, plumbing.SymbolicReference)
+	c.Assert(head.Target(), Equals, plumbing.ReferenceName("refs/remotes/origin/master"))
+}
+
+func (s *RemoteAdminSuite) TestRenameRemote(c *C) {
+	_, r := s.serverSetup(c)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+
+	// the config is read back, as from a file, to keep the raw options
+	b, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	cfg = config.NewConfig()
+	c.Assert(cfg.Unmarshal(b), IsNil)
+
+	cfg.Raw.Section("remote").Subsection("origin").SetOption("tagOpt", "--no-tags")
+	cfg.Raw.Section("remote").SetOption("pushDefault", "origin")
+	cfg.Raw.Section("branch").Subsection("master").SetOption("pushRemote", "origin")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	c.Assert(r.RenameRemote("origin", "upstream"), IsNil)
+
+	cfg, err = r.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Remotes["origin"], IsNil)
+	c.Assert(cfg.Remotes["upstream"].Fetch, DeepEquals, []config.RefSpec{
+		"+refs/heads/*:refs/remotes/upstream/*",
+	})
+
+	raw := cfg.Raw.Section("remote")
+	c.Assert(raw.Subsection("upstream").Option("tagOpt"), Equals, "--no-tags")
+	c.Assert(raw.Option("pushDefault"), Equals, "upstream")
+	c.Assert(cfg.Branches["master"].Remote, Equals, "upstream")
+	c.Assert(cfg.Raw.Section("branch").Subsection("master").Option("pushRemote"), Equals, "upstream")
+
+	_, err = r.Reference("refs/remotes/origin/master", false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	ref, err := r.Reference("refs/remotes/upstream/branch", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash().String(), Equals, "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+
+	head, err := r.Reference("refs/remotes/upstream/HEAD", false)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.ReferenceName("refs/remotes/upstream/master"))
+
+	entries, err := r.Reflog("refs/remotes/upstream/master")
+	c.Assert(err, IsNil)
+	c.Assert(entries, Not(HasLen), 0)
+
+	entries, err = r.Reflog("refs/remotes/origin/master")
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+}
+
+func (s *RemoteAdminSuite) TestRenameRemoteErrors(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	c.Assert(r.RenameRemote("foo", "bar"), Equals, ErrRemoteNotFound)
+	c.Assert(r.RenameRemote("origin", ""), Equals, config.ErrRemoteConfigEmptyName)
+
+	_, err := r.CreateRemote(&config.RemoteConfig{Name: "upstream", URLs: []string{"http://foo/bar"}})
+	c.Assert(err, IsNil)
+	c.Assert(r.RenameRemote("origin", "upstream"), Equals, ErrRemoteExists)
+}
+
+func (s *RemoteAdminSuite) TestSetURL(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	remote, err := r.Remote("origin")
+	c.Assert(err, IsNil)
+	c.Assert(remote.SetURL(), Equals, config.ErrRemoteConfigEmptyURL)
+	c.Assert(remote.SetURL("http://foo/bar"), IsNil)
+	c.Assert(remote.SetPushURL("http://foo/baz", "http://foo/qux"), IsNil)
+	c.Assert(remote.String(), Equals, "origin\thttp://foo/bar (fetch)\norigin\thttp://foo/baz (push)")
+
+	remote, err = r.Remote("origin")
+	c.Assert(err, IsNil)
+	c.Assert(remote.Config().URLs, DeepEquals, []string{"http://foo/bar"})
+	c.Assert(remote.Config().PushURLs, DeepEquals, []string{"http://foo/baz", "http://foo/qux"})
+
+	c.Assert(remote.SetPushURL(), IsNil)
+	c.Assert(remote.Config().PushURLs, HasLen, 0)
+
+	anonymous := NewRemote(r.Storer, &config.RemoteConfig{Name: "foo", URLs: []string{"http://foo/bar"}})
+	c.Assert(anonymous.SetURL("http://foo/baz"), Equals, ErrRemoteNotFound)
+}
+
+func (s *RemoteAdminSuite) TestPushToPushURLs(c *C) {
+	_, r := s.serverSetup(c)
+
+	var servers []*Repository
+	var urls []string
+	for i := 0; i < 2; i++ {
+		url := c.MkDir()
+		server, err := PlainInit(url, true)
+		c.Assert(err, IsNil)
+
+		servers = append(servers, server)
+		urls = append(urls, url)
+	}
+
+	remote, err := r.Remote("origin")
+	c.Assert(err, IsNil)
+	c.Assert(remote.SetPushURL(urls...), IsNil)
+
+	c.Assert(r.Push(&PushOptions{}), IsNil)
+	for _, server := range servers {
+		AssertReferences(c, server, map[string]string{
+			"refs/heads/master": "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		})
+	}
+
+	c.Assert(r.Push(&PushOptions{}), Equals, NoErrAlreadyUpToDate)
+}
+
+func (s *RemoteAdminSuite) TestSetHead(c *C) {
+	_, r := s.serverSetup(c)
+	name := plumbing.NewRemoteHEADReferenceName("origin")
+
+	remote, err := r.Remote("origin")
+	c.Assert(err, IsNil)
+
+	c.Assert(remote.SetHead(&SetHeadOptions{Delete: true}), IsNil)
+	_, err = r.Reference(name, false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	c.Assert(remote.SetHead(&SetHeadOptions{Branch: "branch"}), IsNil)
+	head, err := r.Reference(name, false)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.ReferenceName("refs/remotes/origin/branch"))
+
+	c.Assert(remote.SetHead(&SetHeadOptions{}), IsNil)
+	head, err = r.Reference(name, false)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.ReferenceName("refs/remotes/origin/master"))
+
+	err = remote.SetHead(&SetHeadOptions{Branch: "foo"})
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	err = remote.SetHead(&SetHeadOptions{Branch: "branch", Delete: true})
+	c.Assert(err, Equals, ErrBranchDeleteExclusive)
+}
+
+func (s *RemoteAdminSuite) TestPrune(c *C) {
+	server, r := s.serverSetup(c)
+	c.Assert(server.Storer.RemoveReference("refs/heads/branch"), IsNil)
+
+	remote, err := r.Remote("origin")
+	c.Assert(err, IsNil)
+
+	pruned, err := remote.Prune(&RemotePruneOptions{DryRun: true})
+	c.Assert(err, IsNil)
+	c.Assert(pruned, DeepEquals, []plumbing.ReferenceName{"refs/remotes/origin/branch"})
+
+	_, err = r.Reference("refs/remotes/origin/branch", false)
+	c.Assert(err, IsNil)
+
+	pruned, err = remote.Prune(&RemotePruneOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(pruned, DeepEquals, []plumbing.ReferenceName{"refs/remotes/origin/branch"})
+
+	_, err = r.Reference("refs/remotes/origin/branch", false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	_, err = r.Reference("refs/remotes/origin/master", false)
+	c.Assert(err, IsNil)
+	_, err = r.Reference("refs/remotes/origin/HEAD", false)
+	c.Assert(err, IsNil)
+
+	pruned, err = remote.Prune(&RemotePruneOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(pruned, HasLen, 0)
+}
+
+func (s *RemoteAdminSuite) TestURLInsteadOf(c *C) {
+	server, r := s.serverSetup(c)
+	remote, err := r.Remote("origin")
+	c.Assert(err, IsNil)
+	url := remote.Config().URLs[0]
+
+	mirror := c.MkDir()
+	_, err = PlainInit(filepath.Join(mirror, "foo.git"), true)
+	c.Assert(err, IsNil)
+
+	global := filepath.Join(c.MkDir(), "config")
+	err = ioutil.WriteFile(global, []byte(fmt.Sprintf(`[url "%s"]
+	insteadOf = https://example.invalid/foo.git
+[url "%s/"]
+	pushInsteadOf = https://example.invalid/
+`, url, mirror)), 0644)
+	c.Assert(err, IsNil)
+
+	os.Setenv("GIT_CONFIG_GLOBAL", global)
+	defer os.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	c.Assert(remote.SetURL("https://example.invalid/foo.git"), IsNil)
+	remote, err = r.Remote("origin")
+	c.Assert(err, IsNil)
+	c.Assert(remote.String(), Equals, fmt.Sprintf(
+		"origin\t%s (fetch)\norigin\t%s/foo.git (push)", url, mirror,
+	))
+
+	branch := plumbing.NewReferenceFromStrings("refs/heads/new", "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	c.Assert(server.Storer.SetReference(branch), IsNil)
+
+	c.Assert(r.Fetch(&FetchOptions{}), IsNil)
+	_, err = r.Reference("refs/remotes/origin/new", false)
+	c.Assert(err, IsNil)
+
+	pushed, err := PlainOpen(filepath.Join(mirror, "foo.git"))
+	c.Assert(err, IsNil)
+
+	c.Assert(r.Push(&PushOptions{}), IsNil)
+	AssertReferences(c, pushed, map[string]string{
+		"refs/heads/master": "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+	})
+}
@@ -0,0 +1,257 @@
+package git
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/object"
+	"github.com/goabstract/go-git/v5/plumbing/storer"
+	"github.com/goabstract/go-git/v5/storage"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+var (
+	// ErrReplaceExists is returned creating a replacement for an object
+	// already replaced, without forcing it.
+	ErrReplaceExists = errors.New("replace reference already exists")
+	// ErrReplaceTypeMismatch is returned creating a replacement of a
+	// different type than the replaced object, without forcing it.
+	ErrReplaceTypeMismatch = errors.New("object and replacement have different types")
+	// ErrReplaceSameObject is returned replacing an object by itself.
+	ErrReplaceSameObject = errors.New("object can not be replaced by itself")
+)
+
+const (
+	replaceRefPrefix    = "refs/replace/"
+	useReplaceRefsKey   = "useReplaceRefs"
+	noReplaceObjectsEnv = "GIT_NO_REPLACE_OBJECTS"
+	graftsPath          = "info/grafts"
+)
+
+// SetReplaceObjects enables or disables the replacement of the objects by
+// the refs/replace references and the grafts, overriding core.useReplaceRefs
+// and GIT_NO_REPLACE_OBJECTS. Disabling it is the equivalent of
+// `git --no-replace-objects`.
+//
+// The refs/replace reference of an object is read every time the object is
+// looked up, so changes of the replacements made by any means are honoured,
+// while core.useReplaceRefs and the grafts are read once, on the first
+// lookup.
+func (r *Repository) SetReplaceObjects(enabled bool) {
+	r.replaceObjects = &enabled
+	r.objects = nil
+}
+
+// Replacements returns the replaced objects with their replacements, as
+// `git replace --list`.
+func (r *Repository) Replacements() (map[plumbing.Hash]plumbing.Hash, error) {
+	refs, err := r.Storer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	replacements := make(map[plumbing.Hash]plumbing.Hash)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().String()
+		if !strings.HasPrefix(name, replaceRefPrefix) {
+			return nil
+		}
+
+		replaced := strings.TrimPrefix(name, replaceRefPrefix)
+		if !plumbing.IsHash(replaced) {
+			return nil
+		}
+
+		ref, err := storer.ResolveReference(r.Storer, ref.Name())
+		if err != nil {
+			return err
+		}
+
+		replacements[plumbing.NewHash(replaced)] = ref.Hash()
+		return nil
+	})
+
+	return replacements, err
+}
+
+// CreateReplace replaces an object by another one creating the reference
+// refs/replace/<object>, as `git replace`. Unless force is set, an object
+// already replaced can not be replaced again, and the replacement must have
+// the same type as the object.
+func (r *Repository) CreateReplace(obj, replacement plumbing.Hash, force bool) error {
+	if obj == replacement {
+		return ErrReplaceSameObject
+	}
+
+	o, err := r.Storer.EncodedObject(plumbing.AnyObject, obj)
+	if err != nil {
+		return err
+	}
+
+	ro, err := r.Storer.EncodedObject(plumbing.AnyObject, replacement)
+	if err != nil {
+		return err
+	}
+
+	if !force && o.Type() != ro.Type() {
+		return ErrReplaceTypeMismatch
+	}
+
+	name := plumbing.ReferenceName(replaceRefPrefix + obj.String())
+	if _, err := r.Storer.Reference(name); err == nil && !force {
+		return ErrReplaceExists
+	} else if err != nil && err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+
+	return r.Storer.SetReference(plumbing.NewHashReference(name, replacement))
+}
+
+// DeleteReplace deletes the replacement of an object, as `git replace
+// --delete`. plumbing.ErrReferenceNotFound is returned if the object is not
+// replaced.
+func (r *Repository) DeleteReplace(obj plumbing.Hash) error {
+	name := plumbing.ReferenceName(replaceRefPrefix + obj.String())
+	if _, err := r.Storer.Reference(name); err != nil {
+		return err
+	}
+
+	return r.Storer.RemoveReference(name)
+}
+
+// objectStorer returns the storer used to read the objects of the
+// repository, with the replacements and grafts applied unless disabled. It
+// is set up the first time it is called.
+func (r *Repository) objectStorer() (storage.Storer, error) {
+	if r.objects != nil {
+		return r.objects, nil
+	}
+
+	enabled, err := r.replaceObjectsEnabled()
+	if err != nil {
+		return nil, err
+	}
+
+	if !enabled {
+		r.objects = r.Storer
+		return r.objects, nil
+	}
+
+	grafts, err := r.grafts()
+	if err != nil {
+		return nil, err
+	}
+
+	r.objects = &replacedStorer{
+		Storer:  r.Storer,
+		objects: object.NewReplaceObjectStorerFunc(r.Storer, r.replacement, grafts),
+	}
+
+	return r.objects, nil
+}
+
+// replacement returns the target of the refs/replace reference of the
+// object, if any.
+func (r *Repository) replacement(h plumbing.Hash) (plumbing.Hash, bool) {
+	ref, err := storer.ResolveReference(r.Storer, plumbing.ReferenceName(replaceRefPrefix+h.String()))
+	if err != nil {
+		return h, false
+	}
+
+	return ref.Hash(), true
+}
+
+func (r *Repository) replaceObjectsEnabled() (bool, error) {
+	if r.replaceObjects != nil {
+		return *r.replaceObjects, nil
+	}
+
+	if os.Getenv(noReplaceObjectsEnv) != "" {
+		return false, nil
+	}
+
+	cfg, err := r.MergedConfig()
+	if err != nil {
+		return false, err
+	}
+
+	value := cfg.Raw.Section("core").Option(useReplaceRefsKey)
+	return !strings.EqualFold(value, "false"), nil
+}
+
+// grafts returns the parents of the commits listed in the info/grafts file,
+// only available for storers backed by a filesystem.
+func (r *Repository) grafts() (map[plumbing.Hash][]plumbing.Hash, error) {
+	type fsBased interface {
+		Filesystem() billy.Filesystem
+	}
+
+	sto, ok := r.Storer.(fsBased)
+	if !ok {
+		return nil, nil
+	}
+
+	f, err := sto.Filesystem().Open(graftsPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	grafts := make(map[plumbing.Hash][]plumbing.Hash)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || !plumbing.IsHash(fields[0]) {
+			continue
+		}
+
+		parents := make([]plumbing.Hash, 0, len(fields)-1)
+		for _, p := range fields[1:] {
+			parents = append(parents, plumbing.NewHash(p))
+		}
+
+		grafts[plumbing.NewHash(fields[0])] = parents
+	}
+
+	return grafts, scanner.Err()
+}
+
+func (r *Repository) getObject(h plumbing.Hash) (object.Object, error) {
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetObject(s, h)
+}
+
+// replacedStorer is a storage.Storer reading its objects from another
+// storer, the one with the replacements applied.
+type replacedStorer struct {
+	storage.Storer
+	objects storer.EncodedObjectStorer
+}
+
+func (s *replacedStorer) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	return s.objects.EncodedObject(t, h)
+}
+
+func (s *replacedStorer) IterEncodedObjects(t plumbing.ObjectType) (storer.EncodedObjectIter, error) {
+	return s.objects.IterEncodedObjects(t)
+}
+
+func (s *replacedStorer) HasEncodedObject(h plumbing.Hash) error {
+	return s.objects.HasEncodedObject(h)
+}
+
+func (s *replacedStorer) EncodedObjectSize(h plumbing.Hash) (int64, error) {
+	return s.objects.EncodedObjectSize(h)
+}
@@ -0,0 +1,192 @@
+package git
+
+import (
+	"os"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/object"
+	"github.com/goabstract/go-git/v5/storage/filesystem"
+
+	"github.com/go-git/go-billy/v5/util"
+	fixtures "github.com/go-git/go-git-fixtures/v4"
+	. "gopkg.in/check.v1"
+)
+
+type ReplaceSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&ReplaceSuite{})
+
+var (
+	replaceHead    = plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	replaceParent  = plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+	replaceBranch  = plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	replaceInitial = plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d")
+)
+
+func (s *ReplaceSuite) TestCreateReplace(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	commit, err := r.CommitObject(replaceParent)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Not(Equals), "")
+	original := commit.Message
+
+	err = r.CreateReplace(replaceParent, replaceBranch, false)
+	c.Assert(err, IsNil)
+
+	replacements, err := r.Replacements()
+	c.Assert(err, IsNil)
+	c.Assert(replacements, DeepEquals, map[plumbing.Hash]plumbing.Hash{
+		replaceParent: replaceBranch,
+	})
+
+	branch, err := r.CommitObject(replaceBranch)
+	c.Assert(err, IsNil)
+
+	commit, err = r.CommitObject(replaceParent)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Hash, Equals, replaceParent)
+	c.Assert(commit.Message, Equals, branch.Message)
+
+	head, err := r.CommitObject(replaceHead)
+	c.Assert(err, IsNil)
+	parent, err := head.Parent(0)
+	c.Assert(err, IsNil)
+	c.Assert(parent.Message, Equals, branch.Message)
+
+	r.SetReplaceObjects(false)
+	commit, err = r.CommitObject(replaceParent)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, original)
+
+	r.SetReplaceObjects(true)
+	c.Assert(r.DeleteReplace(replaceParent), IsNil)
+	c.Assert(r.DeleteReplace(replaceParent), Equals, plumbing.ErrReferenceNotFound)
+
+	commit, err = r.CommitObject(replaceParent)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, original)
+}
+
+func (s *ReplaceSuite) TestReplaceRefsChangedByStorer(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	commit, err := r.CommitObject(replaceParent)
+	c.Assert(err, IsNil)
+	original := commit.Message
+
+	branch, err := r.CommitObject(replaceBranch)
+	c.Assert(err, IsNil)
+
+	name := plumbing.ReferenceName("refs/replace/" + replaceParent.String())
+	err = r.Storer.SetReference(plumbing.NewHashReference(name, replaceBranch))
+	c.Assert(err, IsNil)
+
+	commit, err = r.CommitObject(replaceParent)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, branch.Message)
+
+	c.Assert(r.Storer.RemoveReference(name), IsNil)
+	commit, err = r.CommitObject(replaceParent)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, original)
+}
+
+func (s *ReplaceSuite) TestCreateReplaceErrors(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	c.Assert(r.CreateReplace(replaceParent, replaceParent, false), Equals, ErrReplaceSameObject)
+
+	commit, err := r.CommitObject(replaceParent)
+	c.Assert(err, IsNil)
+	c.Assert(r.CreateReplace(replaceParent, commit.TreeHash, false), Equals, ErrReplaceTypeMismatch)
+
+	c.Assert(r.CreateReplace(replaceParent, replaceBranch, false), IsNil)
+	c.Assert(r.CreateReplace(replaceParent, replaceInitial, false), Equals, ErrReplaceExists)
+	c.Assert(r.CreateReplace(replaceParent, replaceInitial, true), IsNil)
+
+	unknown := plumbing.NewHash("0000000000000000000000000000000000000001")
+	c.Assert(r.CreateReplace(unknown, replaceInitial, false), Equals, plumbing.ErrObjectNotFound)
+}
+
+func (s *ReplaceSuite) TestReplaceLogAndRevision(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+	c.Assert(r.CreateReplace(replaceParent, replaceInitial, false), IsNil)
+
+	iter, err := r.Log(&LogOptions{From: replaceHead})
+	c.Assert(err, IsNil)
+
+	var hashes []plumbing.Hash
+	c.Assert(iter.ForEach(func(commit *object.Commit) error {
+		hashes = append(hashes, commit.Hash)
+		return nil
+	}), IsNil)
+	c.Assert(hashes, DeepEquals, []plumbing.Hash{replaceHead, replaceParent})
+
+	h, err := r.ResolveRevision("HEAD~2")
+	c.Assert(err, Equals, object.ErrParentNotFound)
+	c.Assert(h, NotNil)
+}
+
+func (s *ReplaceSuite) TestReplaceUseReplaceRefs(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+	c.Assert(r.CreateReplace(replaceParent, replaceBranch, false), IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("core").SetOption(useReplaceRefsKey, "false")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	r, err = Open(r.Storer, nil)
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(replaceParent)
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, HasLen, 1)
+	c.Assert(commit.ParentHashes[0], Not(Equals), replaceBranch)
+
+	os.Setenv(noReplaceObjectsEnv, "1")
+	defer os.Unsetenv(noReplaceObjectsEnv)
+
+	cfg.Raw.Section("core").RemoveOption(useReplaceRefsKey)
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	r, err = Open(r.Storer, nil)
+	c.Assert(err, IsNil)
+
+	branch, err := r.CommitObject(replaceBranch)
+	c.Assert(err, IsNil)
+	commit, err = r.CommitObject(replaceParent)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Not(Equals), branch.Message)
+}
+
+func (s *ReplaceSuite) TestGrafts(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	fs := r.Storer.(*filesystem.Storage).Filesystem()
+	err := util.WriteFile(fs, graftsPath, []byte(
+		"# comment\n"+
+			replaceHead.String()+" "+replaceInitial.String()+"\n",
+	), 0644)
+	c.Assert(err, IsNil)
+
+	r, err = Open(r.Storer, nil)
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(replaceHead)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Hash, Equals, replaceHead)
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{replaceInitial})
+
+	h, err := r.ResolveRevision("HEAD~1")
+	c.Assert(err, IsNil)
+	c.Assert(*h, Equals, replaceInitial)
+
+	r.SetReplaceObjects(false)
+	h, err = r.ResolveRevision("HEAD~1")
+	c.Assert(err, IsNil)
+	c.Assert(*h, Equals, replaceParent)
+}
@@ -14,7 +14,6 @@ import (
 	"time"
 
 	"github.com/goabstract/go-git/v5/config"
-	"github.com/goabstract/go-git/v5/internal/revision"
 	"github.com/goabstract/go-git/v5/plumbing"
 	"github.com/goabstract/go-git/v5/plumbing/cache"
 	"github.com/goabstract/go-git/v5/plumbing/format/packfile"
@@ -64,11 +63,17 @@ type Repository struct {
 
 	r  map[string]*Remote
 	wt billy.Filesystem
+
+	// replaceObjects overrides core.useReplaceRefs if not nil, objects is
+	// the storer the objects are read from, set up on first use.
+	replaceObjects *bool
+	objects        storage.Storer
 }
 
 // Init creates an empty git repository, based on the given Storer and worktree.
 // The worktree Filesystem is optional, if nil a bare repository is created. If
-// the given storer is not empty ErrRepositoryAlreadyExists is returned
+// the given storer is not empty ErrRepositoryAlreadyExists is returned. HEAD
+// points to the branch init.defaultBranch of the config, master by default.
 func Init(s storage.Storer, worktree billy.Filesystem) (*Repository, error) {
 	if err := initStorer(s); err != nil {
 		return nil, err
@@ -84,7 +89,17 @@ func Init(s storage.Storer, worktree billy.Filesystem) (*Repository, error) {
 		return nil, err
 	}
 
-	h := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Master)
+	cfg, err := r.MergedConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	branch := plumbing.Master
+	if cfg.Init.DefaultBranch != "" {
+		branch = plumbing.NewBranchReferenceName(cfg.Init.DefaultBranch)
+	}
+
+	h := plumbing.NewSymbolicReference(plumbing.HEAD, branch)
 	if err := s.SetReference(h); err != nil {
 		return nil, err
 	}
@@ -436,11 +451,24 @@ func cleanUpDir(path string, all bool) error {
 	return err
 }
 
-// Config return the repository config
+// Config return the repository config, the one of .git/config only. Unlike
+// `git config`, it doesn't include the system, global and worktree configs,
+// since the returned config is the one written back by Storer.SetConfig; the
+// merged view git reads is returned by MergedConfig.
 func (r *Repository) Config() (*config.Config, error) {
 	return r.Storer.Config()
 }
 
+// MergedConfig returns the config of the repository merged, as git reads it,
+// with the config of the system and of the user, and the config of the
+// worktree if extensions.worktreeConfig is set, following their include
+// directives. Each raw option has the scope of the file it comes from. The
+// merged config is read-only, the config of the repository is changed with
+// Config and Storer.SetConfig.
+func (r *Repository) MergedConfig() (*config.Config, error) {
+	return mergedConfig(r.Storer)
+}
+
 // Remote return a remote if exists
 func (r *Repository) Remote(name string) (*Remote, error) {
 	cfg, err := r.Storer.Config()
@@ -766,7 +794,10 @@ func (r *Repository) clone(ctx context.Context, o *CloneOptions) error {
 	ref, err := r.fetchAndUpdateReferences(ctx, &FetchOptions{
 		RefSpecs:         c.Fetch,
 		Depth:            o.Depth,
+		ShallowSince:     o.ShallowSince,
+		ShallowExclude:   o.ShallowExclude,
 		Auth:             o.Auth,
+		HTTP:             o.HTTP,
 		Progress:         o.Progress,
 		Tags:             o.Tags,
 		RemoteName:       o.RemoteName,
@@ -787,10 +818,10 @@ func (r *Repository) clone(ctx context.Context, o *CloneOptions) error {
 			return err
 		}
 
-		if err := w.Reset(&ResetOptions{
+		if err := w.reset(&ResetOptions{
 			Mode:   MergeReset,
 			Commit: head.Hash(),
-		}); err != nil {
+		}, ""); err != nil {
 			return err
 		}
 
@@ -896,8 +927,11 @@ func (r *Repository) fetchAndUpdateReferences(
 		return nil, err
 	}
 
+	msg := "clone: from " + remote.c.URLs[0]
+	reflogMsg := func(string) string { return msg }
+
 	objsUpdated := true
-	remoteRefs, err := remote.fetch(ctx, o)
+	remoteRefs, err := remote.fetch(ctx, o, reflogMsg)
 	if err == NoErrAlreadyUpToDate {
 		objsUpdated = false
 	} else if err == packfile.ErrEmptyPackfile {
@@ -911,11 +945,15 @@ func (r *Repository) fetchAndUpdateReferences(
 		return nil, err
 	}
 
-	refsUpdated, err := r.updateReferences(remote.c.Fetch, resolvedRef)
+	refsUpdated, err := r.updateReferences(remote.c.Fetch, resolvedRef, msg)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := r.updateRemoteHead(remote.c.Name, remoteRefs); err != nil {
+		return nil, err
+	}
+
 	if !objsUpdated && !refsUpdated {
 		return nil, NoErrAlreadyUpToDate
 	}
@@ -924,7 +962,12 @@ func (r *Repository) fetchAndUpdateReferences(
 }
 
 func (r *Repository) updateReferences(spec []config.RefSpec,
-	resolvedRef *plumbing.Reference) (updated bool, err error) {
+	resolvedRef *plumbing.Reference, msg string) (updated bool, err error) {
+
+	cfg, err := reflogConfig(r.Storer)
+	if err != nil {
+		return false, err
+	}
 
 	if !resolvedRef.Name().IsBranch() {
 		// Detached HEAD mode
@@ -933,7 +976,7 @@ func (r *Repository) updateReferences(spec []config.RefSpec,
 			return false, err
 		}
 		head := plumbing.NewHashReference(plumbing.HEAD, h)
-		return updateReferenceStorerIfNeeded(r.Storer, head)
+		return updateReferenceStorerIfNeeded(r.Storer, cfg, head, msg)
 	}
 
 	refs := []*plumbing.Reference{
@@ -946,7 +989,7 @@ func (r *Repository) updateReferences(spec []config.RefSpec,
 	refs = append(refs, r.calculateRemoteHeadReference(spec, resolvedRef)...)
 
 	for _, ref := range refs {
-		u, err := updateReferenceStorerIfNeeded(r.Storer, ref)
+		u, err := updateReferenceStorerIfNeeded(r.Storer, cfg, ref, msg)
 		if err != nil {
 			return updated, err
 		}
@@ -959,6 +1002,36 @@ func (r *Repository) updateReferences(spec []config.RefSpec,
 	return
 }
 
+// updateRemoteHead creates refs/remotes/<name>/HEAD, pointing to the
+// remote-tracking branch of the HEAD of the remote, if it was fetched, unless
+// the fetch refspecs already created it.
+func (r *Repository) updateRemoteHead(name string, remoteRefs storer.ReferenceStorer) error {
+	head, ok := remoteHeadReference(name, remoteRefs)
+	if !ok {
+		return nil
+	}
+
+	_, err := r.Storer.Reference(head.Name())
+	if err == nil {
+		return nil
+	}
+
+	if err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+
+	_, err = r.Storer.Reference(head.Target())
+	if err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return r.Storer.SetReference(head)
+}
+
 func (r *Repository) calculateRemoteHeadReference(spec []config.RefSpec,
 	resolvedHead *plumbing.Reference) []*plumbing.Reference {
 
@@ -983,7 +1056,7 @@ func (r *Repository) calculateRemoteHeadReference(spec []config.RefSpec,
 }
 
 func checkAndUpdateReferenceStorerIfNeeded(
-	s storer.ReferenceStorer, r, old *plumbing.Reference) (
+	s storage.Storer, cfg *config.Config, r, old *plumbing.Reference, msg string) (
 	updated bool, err error) {
 	p, err := s.Reference(r.Name())
 	if err != nil && err != plumbing.ErrReferenceNotFound {
@@ -992,7 +1065,7 @@ func checkAndUpdateReferenceStorerIfNeeded(
 
 	// we use the string method to compare references, is the easiest way
 	if err == plumbing.ErrReferenceNotFound || r.String() != p.String() {
-		if err := s.CheckAndSetReference(r, old); err != nil {
+		if err := updateReference(s, cfg, r, old, nil, msg); err != nil {
 			return false, err
 		}
 
@@ -1003,8 +1076,8 @@ func checkAndUpdateReferenceStorerIfNeeded(
 }
 
 func updateReferenceStorerIfNeeded(
-	s storer.ReferenceStorer, r *plumbing.Reference) (updated bool, err error) {
-	return checkAndUpdateReferenceStorerIfNeeded(s, r, nil)
+	s storage.Storer, cfg *config.Config, r *plumbing.Reference, msg string) (updated bool, err error) {
+	return checkAndUpdateReferenceStorerIfNeeded(s, cfg, r, nil, msg)
 }
 
 // Fetch fetches references along with the objects necessary to complete
@@ -1049,10 +1122,24 @@ func (r *Repository) Push(o *PushOptions) error {
 // the remote was already up-to-date, from the remote named as
 // FetchOptions.RemoteName.
 //
+// Without RefSpecs, the remote.<name>.push refspecs are pushed, or the
+// branches selected by push.default, as git does: by default the current
+// branch is pushed to its upstream, and to the branch with the same name of
+// the remote if pushing to another remote. Without RemoteName, the current
+// branch is pushed to its branch.<name>.pushRemote, remote.pushDefault or
+// upstream remote.
+//
 // The provided Context must be non-nil. If the context expires before the
 // operation is complete, an error is returned. The context only affects to the
 // transport operations.
 func (r *Repository) PushContext(ctx context.Context, o *PushOptions) error {
+	if len(o.RefSpecs) == 0 {
+		var err error
+		if o, err = r.defaultPushOptions(o); err != nil {
+			return err
+		}
+	}
+
 	if err := o.Validate(); err != nil {
 		return err
 	}
@@ -1099,6 +1186,23 @@ func (r *Repository) Log(o *LogOptions) (object.CommitIter, error) {
 		it = r.logWithLimit(it, limitOptions)
 	}
 
+	if o.Mailmap != nil {
+		it = newCommitMailmapIter(it, o.Mailmap)
+	}
+
+	if o.ShowNotes {
+		refs := o.NotesRefs
+		if len(refs) == 0 {
+			if refs, err = r.displayNotesRefs(); err != nil {
+				return nil, err
+			}
+		}
+
+		if it, err = newCommitNotesIter(r, it, refs); err != nil {
+			return nil, err
+		}
+	}
+
 	return it, nil
 }
 
@@ -1121,7 +1225,12 @@ func (r *Repository) log(from plumbing.Hash, commitIterFunc func(*object.Commit)
 }
 
 func (r *Repository) logAll(commitIterFunc func(*object.Commit) object.CommitIter) (object.CommitIter, error) {
-	return object.NewCommitAllIter(r.Storer, commitIterFunc)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.NewCommitAllIter(s, commitIterFunc)
 }
 
 func (*Repository) logWithFile(fileName string, commitIter object.CommitIter, checkParent bool) object.CommitIter {
@@ -1239,88 +1348,138 @@ func (r *Repository) Notes() (storer.ReferenceIter, error) {
 // TreeObject return a Tree with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned
 func (r *Repository) TreeObject(h plumbing.Hash) (*object.Tree, error) {
-	return object.GetTree(r.Storer, h)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetTree(s, h)
 }
 
 // TreeObjects returns an unsorted TreeIter with all the trees in the repository
 func (r *Repository) TreeObjects() (*object.TreeIter, error) {
-	iter, err := r.Storer.IterEncodedObjects(plumbing.TreeObject)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := s.IterEncodedObjects(plumbing.TreeObject)
 	if err != nil {
 		return nil, err
 	}
 
-	return object.NewTreeIter(r.Storer, iter), nil
+	return object.NewTreeIter(s, iter), nil
 }
 
 // CommitObject return a Commit with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned.
 func (r *Repository) CommitObject(h plumbing.Hash) (*object.Commit, error) {
-	return object.GetCommit(r.Storer, h)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetCommit(s, h)
 }
 
 // CommitObjects returns an unsorted CommitIter with all the commits in the repository.
 func (r *Repository) CommitObjects() (object.CommitIter, error) {
-	iter, err := r.Storer.IterEncodedObjects(plumbing.CommitObject)
+	s, err := r.objectStorer()
 	if err != nil {
 		return nil, err
 	}
 
-	return object.NewCommitIter(r.Storer, iter), nil
+	iter, err := s.IterEncodedObjects(plumbing.CommitObject)
+	if err != nil {
+		return nil, err
+	}
+
+	return object.NewCommitIter(s, iter), nil
 }
 
 // BlobObject returns a Blob with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned.
 func (r *Repository) BlobObject(h plumbing.Hash) (*object.Blob, error) {
-	return object.GetBlob(r.Storer, h)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetBlob(s, h)
 }
 
 // BlobObjects returns an unsorted BlobIter with all the blobs in the repository.
 func (r *Repository) BlobObjects() (*object.BlobIter, error) {
-	iter, err := r.Storer.IterEncodedObjects(plumbing.BlobObject)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := s.IterEncodedObjects(plumbing.BlobObject)
 	if err != nil {
 		return nil, err
 	}
 
-	return object.NewBlobIter(r.Storer, iter), nil
+	return object.NewBlobIter(s, iter), nil
 }
 
 // TagObject returns a Tag with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned. This method only returns
 // annotated Tags, no lightweight Tags.
 func (r *Repository) TagObject(h plumbing.Hash) (*object.Tag, error) {
-	return object.GetTag(r.Storer, h)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetTag(s, h)
 }
 
 // TagObjects returns a unsorted TagIter that can step through all of the annotated
 // tags in the repository.
 func (r *Repository) TagObjects() (*object.TagIter, error) {
-	iter, err := r.Storer.IterEncodedObjects(plumbing.TagObject)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := s.IterEncodedObjects(plumbing.TagObject)
 	if err != nil {
 		return nil, err
 	}
 
-	return object.NewTagIter(r.Storer, iter), nil
+	return object.NewTagIter(s, iter), nil
 }
 
 // Object returns an Object with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned.
 func (r *Repository) Object(t plumbing.ObjectType, h plumbing.Hash) (object.Object, error) {
-	obj, err := r.Storer.EncodedObject(t, h)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := s.EncodedObject(t, h)
 	if err != nil {
 		return nil, err
 	}
 
-	return object.DecodeObject(r.Storer, obj)
+	return object.DecodeObject(s, obj)
 }
 
 // Objects returns an unsorted ObjectIter with all the objects in the repository.
 func (r *Repository) Objects() (*object.ObjectIter, error) {
-	iter, err := r.Storer.IterEncodedObjects(plumbing.AnyObject)
+	s, err := r.objectStorer()
 	if err != nil {
 		return nil, err
 	}
 
-	return object.NewObjectIter(r.Storer, iter), nil
+	iter, err := s.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		return nil, err
+	}
+
+	return object.NewObjectIter(s, iter), nil
 }
 
 // Head returns the reference where HEAD is pointing to.
@@ -1356,148 +1515,27 @@ func (r *Repository) Worktree() (*Worktree, error) {
 }
 
 // ResolveRevision resolves revision to corresponding hash. It will always
-// resolve to a commit hash, not a tree or annotated tag.
+// resolve to a commit hash, not a tree or annotated tag, revisions resolving
+// to a tree or a blob return ErrUnableToResolveCommit.
 //
-// Implemented resolvers : HEAD, branch, tag, heads/branch, refs/heads/branch,
-// refs/tags/tag, refs/remotes/origin/branch, refs/remotes/origin/HEAD, tilde and caret (HEAD~1, master~^, tag~2, ref/heads/master~1, ...), selection by text (HEAD^{/fix nasty bug})
+// The full syntax supported by ResolveRevisionObject is accepted, such as
+// HEAD~1, master@{upstream}, v1.0.0^{commit}, @{-1} or HEAD^{/fix nasty bug}.
 func (r *Repository) ResolveRevision(rev plumbing.Revision) (*plumbing.Hash, error) {
-	p := revision.NewParserFromString(string(rev))
-
-	items, err := p.Parse()
-
+	o, err := r.ResolveRevisionObject(rev)
 	if err != nil {
-		return nil, err
+		return &plumbing.ZeroHash, err
 	}
 
-	var commit *object.Commit
-
-	for _, item := range items {
-		switch item.(type) {
-		case revision.Ref:
-			revisionRef := item.(revision.Ref)
-
-			var tryHashes []plumbing.Hash
-
-			maybeHash := plumbing.NewHash(string(revisionRef))
-
-			if !maybeHash.IsZero() {
-				tryHashes = append(tryHashes, maybeHash)
-			}
-
-			for _, rule := range append([]string{"%s"}, plumbing.RefRevParseRules...) {
-				ref, err := storer.ResolveReference(r.Storer, plumbing.ReferenceName(fmt.Sprintf(rule, revisionRef)))
-
-				if err == nil {
-					tryHashes = append(tryHashes, ref.Hash())
-					break
-				}
-			}
-
-			// in ambiguous cases, `git rev-parse` will emit a warning, but
-			// will always return the oid in preference to a ref; we don't have
-			// the ability to emit a warning here, so (for speed purposes)
-			// don't bother to detect the ambiguity either, just return in the
-			// priority that git would.
-			gotOne := false
-			for _, hash := range tryHashes {
-				commitObj, err := r.CommitObject(hash)
-				if err == nil {
-					commit = commitObj
-					gotOne = true
-					break
-				}
-
-				tagObj, err := r.TagObject(hash)
-				if err == nil {
-					// If the tag target lookup fails here, this most likely
-					// represents some sort of repo corruption, so let the
-					// error bubble up.
-					tagCommit, err := tagObj.Commit()
-					if err != nil {
-						return &plumbing.ZeroHash, err
-					}
-					commit = tagCommit
-					gotOne = true
-					break
-				}
-			}
-
-			if !gotOne {
-				return &plumbing.ZeroHash, plumbing.ErrReferenceNotFound
-			}
-
-		case revision.CaretPath:
-			depth := item.(revision.CaretPath).Depth
-
-			if depth == 0 {
-				break
-			}
-
-			iter := commit.Parents()
-
-			c, err := iter.Next()
-
-			if err != nil {
-				return &plumbing.ZeroHash, err
-			}
-
-			if depth == 1 {
-				commit = c
-
-				break
-			}
-
-			c, err = iter.Next()
-
-			if err != nil {
-				return &plumbing.ZeroHash, err
-			}
-
-			commit = c
-		case revision.TildePath:
-			for i := 0; i < item.(revision.TildePath).Depth; i++ {
-				c, err := commit.Parents().Next()
-
-				if err != nil {
-					return &plumbing.ZeroHash, err
-				}
-
-				commit = c
-			}
-		case revision.CaretReg:
-			history := object.NewCommitPreorderIter(commit, nil, nil)
-
-			re := item.(revision.CaretReg).Regexp
-			negate := item.(revision.CaretReg).Negate
-
-			var c *object.Commit
-
-			err := history.ForEach(func(hc *object.Commit) error {
-				if !negate && re.MatchString(hc.Message) {
-					c = hc
-					return storer.ErrStop
-				}
-
-				if negate && !re.MatchString(hc.Message) {
-					c = hc
-					return storer.ErrStop
-				}
-
-				return nil
-			})
-			if err != nil {
-				return &plumbing.ZeroHash, err
-			}
-
-			if c == nil {
-				return &plumbing.ZeroHash, fmt.Errorf(`No commit message match regexp : "%s"`, re.String())
-			}
+	c, err := peelRevisionCommit(o)
+	if err == ErrUnexpectedObjectType {
+		return &plumbing.ZeroHash, ErrUnableToResolveCommit
+	}
 
-			commit = c
-		}
+	if err != nil {
+		return &plumbing.ZeroHash, err
 	}
 
-	return &commit.Hash, nil
+	return &c.Hash, nil
 }
 
 type RepackConfig struct {
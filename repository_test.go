@@ -53,6 +53,22 @@ func (s *RepositorySuite) TestInit(c *C) {
 	c.Assert(cfg.Core.IsBare, Equals, false)
 }
 
+func (s *RepositorySuite) TestInitDefaultBranch(c *C) {
+	global := filepath.Join(c.MkDir(), "config")
+	err := ioutil.WriteFile(global, []byte("[init]\n\tdefaultBranch = main\n"), 0644)
+	c.Assert(err, IsNil)
+
+	os.Setenv("GIT_CONFIG_GLOBAL", global)
+	defer os.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	head, err := r.Reference(plumbing.HEAD, false)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.NewBranchReferenceName("main"))
+}
+
 func (s *RepositorySuite) TestInitNonStandardDotGit(c *C) {
 	dir, err := ioutil.TempDir("", "init-non-standard")
 	c.Assert(err, IsNil)
@@ -124,6 +140,45 @@ func (s *RepositorySuite) TestInitAlreadyExists(c *C) {
 	c.Assert(r, IsNil)
 }
 
+func (s *RepositorySuite) TestInitReftable(c *C) {
+	dir := c.MkDir()
+
+	fs := osfs.New(dir)
+	dot, _ := fs.Chroot(".git")
+	st := filesystem.NewStorageWithOptions(dot, cache.NewObjectLRUDefault(), filesystem.Options{Reftable: true})
+
+	r, err := Init(st, fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	c.Assert(util.WriteFile(fs, "foo", []byte("foo"), 0644), IsNil)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	h, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	_, err = Init(st, fs)
+	c.Assert(err, Equals, ErrRepositoryAlreadyExists)
+
+	r, err = PlainOpen(dir)
+	c.Assert(err, IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.Master)
+	c.Assert(head.Hash(), Equals, h)
+
+	entries, err := r.Reflog(plumbing.Master)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].New, Equals, h)
+
+	_, err = dot.Stat("refs/heads/master")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
 func (s *RepositorySuite) TestOpen(c *C) {
 	st := memory.NewStorage()
 
@@ -208,7 +263,7 @@ func (s *RepositorySuite) TestCloneWithTags(c *C) {
 	var count int
 	i.ForEach(func(r *plumbing.Reference) error { count++; return nil })
 
-	c.Assert(count, Equals, 3)
+	c.Assert(count, Equals, 4)
 }
 
 func (s *RepositorySuite) TestCreateRemoteAndRemote(c *C) {
@@ -1094,6 +1149,38 @@ func (s *RepositorySuite) TestCloneDetachedHEADAndShallow(c *C) {
 	c.Assert(count, Equals, 15)
 }
 
+func (s *RepositorySuite) TestCloneShallowSinceAndUnshallow(c *C) {
+	dir := c.MkDir()
+	r, err := PlainClone(dir, false, &CloneOptions{
+		URL:          s.GetBasicLocalRepositoryURL(),
+		ShallowSince: time.Unix(1427802900, 0),
+	})
+	c.Assert(err, IsNil)
+
+	shallows, err := r.Storer.Shallow()
+	c.Assert(err, IsNil)
+	c.Assert(shallows, DeepEquals, []plumbing.Hash{
+		plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294"),
+	})
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, GitDirName, "shallow"))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "918c48b83bd081e863dbe1b80f8998f058cd8294\n")
+
+	err = r.Fetch(&FetchOptions{})
+	c.Assert(err, Equals, NoErrAlreadyUpToDate)
+
+	err = r.Fetch(&FetchOptions{Unshallow: true})
+	c.Assert(err, IsNil)
+
+	shallows, err = r.Storer.Shallow()
+	c.Assert(err, IsNil)
+	c.Assert(shallows, HasLen, 0)
+
+	_, err = r.CommitObject(plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d"))
+	c.Assert(err, IsNil)
+}
+
 func (s *RepositorySuite) TestCloneDetachedHEADAnnotatedTag(c *C) {
 	r, _ := Init(memory.NewStorage(), nil)
 	err := r.clone(context.Background(), &CloneOptions{
@@ -1135,14 +1222,16 @@ func (s *RepositorySuite) TestPush(c *C) {
 	})
 	c.Assert(err, IsNil)
 
+	// only the current branch is pushed by default
 	AssertReferences(c, server, map[string]string{
 		"refs/heads/master": "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
-		"refs/heads/branch": "e8d3ffab552895c19b9fcf7aa264d277cde33881",
 	})
 
+	_, err = server.Reference("refs/heads/branch", false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
 	AssertReferences(c, s.Repository, map[string]string{
 		"refs/remotes/test/master": "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
-		"refs/remotes/test/branch": "e8d3ffab552895c19b9fcf7aa264d277cde33881",
 	})
 }
 
@@ -1195,6 +1284,7 @@ func (s *RepositorySuite) TestPushWithProgress(c *C) {
 	var p bytes.Buffer
 	err = s.Repository.Push(&PushOptions{
 		RemoteName: "bar",
+		RefSpecs:   []config.RefSpec{config.DefaultPushRefSpec},
 		Progress:   &p,
 	})
 	c.Assert(err, IsNil)
@@ -1303,7 +1393,7 @@ func (s *RepositorySuite) TestLogAll(c *C) {
 		return nil
 	})
 	c.Assert(err, IsNil)
-	c.Assert(refCount, Equals, 5)
+	c.Assert(refCount, Equals, 6)
 
 	cIter, err := r.Log(&LogOptions{
 		All: true,
@@ -1350,7 +1440,7 @@ func (s *RepositorySuite) TestLogAllMissingReferences(c *C) {
 		return nil
 	})
 	c.Assert(err, IsNil)
-	c.Assert(refCount, Equals, 4)
+	c.Assert(refCount, Equals, 5)
 
 	err = r.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName("DUMMY"), plumbing.NewHash("DUMMY")))
 	c.Assert(err, IsNil)
@@ -1364,7 +1454,7 @@ func (s *RepositorySuite) TestLogAllMissingReferences(c *C) {
 		return nil
 	})
 	c.Assert(err, IsNil)
-	c.Assert(refCount, Equals, 5)
+	c.Assert(refCount, Equals, 6)
 
 	cIter, err := r.Log(&LogOptions{
 		All: true,
@@ -2159,6 +2249,53 @@ func (s *RepositorySuite) TestCreateTagSigned(c *C) {
 	c.Assert(actual.PrimaryKey, DeepEquals, key.PrimaryKey)
 }
 
+func (s *RepositorySuite) TestCreateTagDefaultsFromConfig(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+	h, err := r.Head()
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Storer.Config()
+	c.Assert(err, IsNil)
+	cfg.User.Name = "foo bar"
+	cfg.User.Email = "foo@foo.foo"
+	cfg.Tag.GpgSign = true
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	// without a key ring tag.gpgSign can't be honoured
+	ref, err := r.CreateTag("v1.1.0", h.Hash(), &CreateTagOptions{Message: "foo"})
+	c.Assert(err, IsNil)
+
+	tag, err := r.TagObject(ref.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(tag.PGPSignature, Equals, "")
+
+	key := commitSignKey(c, true)
+	ref, err = r.CreateTag("v2.0.0", h.Hash(), &CreateTagOptions{
+		Message: "foo",
+		KeyRing: openpgp.EntityList{key},
+	})
+	c.Assert(err, IsNil)
+
+	tag, err = r.TagObject(ref.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(tag.Tagger.Name, Equals, "foo bar")
+	c.Assert(tag.Tagger.Email, Equals, "foo@foo.foo")
+	c.Assert(tag.PGPSignature, Not(Equals), "")
+
+	cfg.User.SigningKey = "0xDEADBEEF"
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	_, err = r.CreateTag("v2.1.0", h.Hash(), &CreateTagOptions{
+		Message: "foo",
+		KeyRing: openpgp.EntityList{key},
+	})
+	c.Assert(err, Equals, ErrMissingSignKey)
+
+	// lightweight tags are never signed
+	_, err = r.CreateTag("v3.0.0", h.Hash(), nil)
+	c.Assert(err, IsNil)
+}
+
 func (s *RepositorySuite) TestCreateTagSignedBadKey(c *C) {
 	url := s.GetLocalRepositoryURL(
 		fixtures.ByURL("https://github.com/git-fixtures/tags.git").One(),
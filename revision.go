@@ -0,0 +1,689 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goabstract/go-git/v5/config"
+	"github.com/goabstract/go-git/v5/internal/revision"
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/filemode"
+	"github.com/goabstract/go-git/v5/plumbing/format/index"
+	"github.com/goabstract/go-git/v5/plumbing/format/reflog"
+	"github.com/goabstract/go-git/v5/plumbing/object"
+	"github.com/goabstract/go-git/v5/plumbing/storer"
+)
+
+var (
+	// ErrAmbiguousRevision is returned when an abbreviated hash matches more
+	// than one object and they can not be told apart.
+	ErrAmbiguousRevision = errors.New("ambiguous abbreviated hash")
+	// ErrReflogEntryNotFound is returned when a @{<n>} or @{<date>}
+	// revision can not be found in the reflog of the reference.
+	ErrReflogEntryNotFound = errors.New("reflog entry not found")
+	// ErrNoUpstream is returned resolving @{upstream} or @{push} for a
+	// branch without a configured upstream.
+	ErrNoUpstream = errors.New("no upstream configured for branch")
+	// ErrUnexpectedObjectType is returned when a revision can not be peeled
+	// to the requested object type.
+	ErrUnexpectedObjectType = errors.New("revision does not resolve to the expected object type")
+)
+
+const (
+	// minAbbreviatedHashLength is the shortest hexadecimal prefix accepted
+	// as an abbreviated hash, the same as git.
+	minAbbreviatedHashLength = 4
+
+	checkoutReflogPrefix = "checkout: moving from "
+)
+
+// ResolveRevisionObject resolves a revision to the object it refers to, that
+// can be a commit, a tag, a tree or a blob. All the syntax described in
+// gitrevisions(7) is supported, besides ranges:
+//
+//   <sha1>: full or abbreviated hashes
+//   <refname>, @: references, expanded as `git rev-parse` does
+//   [<refname>]@{<n>}, [<refname>]@{<date>}: values from the reflog
+//   @{-<n>}: the n-th branch or commit checked out before the current one
+//   [<branchname>]@{upstream}, [<branchname>]@{push}: the tracking branches
+//   <rev>^<n>, <rev>~<n>: ancestors of a commit
+//   <rev>^{<type>}, <rev>^{}: peeling of tags, commits and trees
+//   <rev>^{/<text>}, :/<text>: the youngest commit whose message matches
+//   <rev>:<path>: a blob or tree from the tree of the revision
+//   :[<n>:]<path>: a blob from the index, at the given stage
+//
+// When an abbreviated hash matches several objects, it is resolved to the
+// only commit, or tag pointing to a commit, among them if any.
+func (r *Repository) ResolveRevisionObject(rev plumbing.Revision) (object.Object, error) {
+	items, err := revision.NewParserFromString(string(rev)).Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	var obj object.Object
+	var ref string
+
+	for i, item := range items {
+		switch item := item.(type) {
+		case revision.Ref:
+			if i+1 < len(items) && isAtRevisioner(items[i+1]) {
+				ref = string(item)
+				continue
+			}
+
+			obj, err = r.resolveRevisionRef(string(item))
+		case revision.AtReflog:
+			obj, err = r.resolveAtReflog(ref, item.Depth)
+		case revision.AtDate:
+			obj, err = r.resolveAtDate(ref, item.Date)
+		case revision.AtCheckout:
+			obj, err = r.resolveAtCheckout(item.Depth)
+		case revision.AtUpstream:
+			obj, err = r.resolveAtTracking(ref, r.upstreamRefName)
+		case revision.AtPush:
+			obj, err = r.resolveAtTracking(ref, r.pushRefName)
+		case revision.CaretPath:
+			obj, err = revisionParent(obj, item.Depth)
+		case revision.TildePath:
+			obj, err = revisionAncestor(obj, item.Depth)
+		case revision.CaretReg:
+			obj, err = revisionMessageMatch(obj, item)
+		case revision.CaretType:
+			obj, err = peelRevisionType(obj, item.ObjectType)
+		case revision.ColonReg:
+			obj, err = r.resolveColonReg(item)
+		case revision.ColonPath:
+			if i == 0 {
+				obj, err = r.resolveIndexPath(item.Path, 0)
+				break
+			}
+
+			obj, err = r.resolveTreePath(obj, item.Path)
+		case revision.ColonStagePath:
+			obj, err = r.resolveIndexPath(item.Path, index.Stage(item.Stage))
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if obj == nil {
+		return nil, plumbing.ErrReferenceNotFound
+	}
+
+	return obj, nil
+}
+
+func isAtRevisioner(item revision.Revisioner) bool {
+	switch item.(type) {
+	case revision.AtReflog, revision.AtDate, revision.AtUpstream, revision.AtPush:
+		return true
+	}
+
+	return false
+}
+
+// resolveRevisionRef resolves a full hash, a reference name or an
+// abbreviated hash, in this order of preference as git does.
+func (r *Repository) resolveRevisionRef(name string) (object.Object, error) {
+	if plumbing.IsHash(name) {
+		o, err := r.getObject(plumbing.NewHash(name))
+		if err == nil {
+			return o, nil
+		}
+	}
+
+	for _, rule := range append([]string{"%s"}, plumbing.RefRevParseRules...) {
+		ref, err := storer.ResolveReference(r.Storer, plumbing.ReferenceName(fmt.Sprintf(rule, name)))
+		if err == nil {
+			return r.revisionObject(ref.Hash())
+		}
+	}
+
+	if isAbbreviatedHash(name) {
+		return r.resolveAbbreviatedHash(strings.ToLower(name))
+	}
+
+	return nil, plumbing.ErrReferenceNotFound
+}
+
+func (r *Repository) revisionObject(h plumbing.Hash) (object.Object, error) {
+	o, err := r.getObject(h)
+	if err == plumbing.ErrObjectNotFound {
+		return nil, plumbing.ErrReferenceNotFound
+	}
+
+	return o, err
+}
+
+func isAbbreviatedHash(s string) bool {
+	if len(s) < minAbbreviatedHashLength || len(s) >= len(plumbing.ZeroHash)*2 {
+		return false
+	}
+
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *Repository) resolveAbbreviatedHash(prefix string) (object.Object, error) {
+	hashes, err := r.hashesWithPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(hashes) {
+	case 0:
+		return nil, plumbing.ErrReferenceNotFound
+	case 1:
+		return r.getObject(hashes[0])
+	}
+
+	var found object.Object
+	for _, h := range hashes {
+		o, err := r.getObject(h)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := peelRevisionObject(o, plumbing.CommitObject); err != nil {
+			continue
+		}
+
+		if found != nil {
+			return nil, ErrAmbiguousRevision
+		}
+
+		found = o
+	}
+
+	if found == nil {
+		return nil, ErrAmbiguousRevision
+	}
+
+	return found, nil
+}
+
+func (r *Repository) hashesWithPrefix(prefix string) ([]plumbing.Hash, error) {
+	if s, ok := r.Storer.(storer.HashPrefixObjectStorer); ok {
+		return s.HashesWithPrefix(prefix)
+	}
+
+	iter, err := r.Storer.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []plumbing.Hash
+	err = iter.ForEach(func(o plumbing.EncodedObject) error {
+		if strings.HasPrefix(o.Hash().String(), prefix) {
+			hashes = append(hashes, o.Hash())
+		}
+
+		return nil
+	})
+
+	return hashes, err
+}
+
+// peelRevisionObject peels tags, and commits to trees, until an object of the
+// given type is found. With plumbing.AnyObject all the tags are peeled.
+func peelRevisionObject(o object.Object, t plumbing.ObjectType) (object.Object, error) {
+	for {
+		if o.Type() == t {
+			return o, nil
+		}
+
+		switch v := o.(type) {
+		case *object.Tag:
+			next, err := v.Object()
+			if err != nil {
+				return nil, err
+			}
+
+			o = next
+		case *object.Commit:
+			if t == plumbing.TreeObject {
+				return v.Tree()
+			}
+
+			if t == plumbing.AnyObject {
+				return o, nil
+			}
+
+			return nil, ErrUnexpectedObjectType
+		default:
+			if t == plumbing.AnyObject {
+				return o, nil
+			}
+
+			return nil, ErrUnexpectedObjectType
+		}
+	}
+}
+
+func peelRevisionCommit(o object.Object) (*object.Commit, error) {
+	o, err := peelRevisionObject(o, plumbing.CommitObject)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.(*object.Commit), nil
+}
+
+func peelRevisionType(o object.Object, objectType string) (object.Object, error) {
+	switch objectType {
+	case "object":
+		return o, nil
+	case "":
+		return peelRevisionObject(o, plumbing.AnyObject)
+	case "tag":
+		if o.Type() != plumbing.TagObject {
+			return nil, ErrUnexpectedObjectType
+		}
+
+		return o, nil
+	}
+
+	t, err := plumbing.ParseObjectType(objectType)
+	if err != nil {
+		return nil, err
+	}
+
+	return peelRevisionObject(o, t)
+}
+
+func revisionParent(o object.Object, n int) (object.Object, error) {
+	c, err := peelRevisionCommit(o)
+	if err != nil || n == 0 {
+		return c, err
+	}
+
+	return c.Parent(n - 1)
+}
+
+func revisionAncestor(o object.Object, n int) (object.Object, error) {
+	c, err := peelRevisionCommit(o)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < n; i++ {
+		if c, err = c.Parent(0); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func revisionMessageMatch(o object.Object, item revision.CaretReg) (object.Object, error) {
+	c, err := peelRevisionCommit(o)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *object.Commit
+	err = object.NewCommitPreorderIter(c, nil, nil).ForEach(func(c *object.Commit) error {
+		if item.Regexp.MatchString(c.Message) != item.Negate {
+			found = c
+			return storer.ErrStop
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf(`No commit message match regexp : "%s"`, item.Regexp.String())
+	}
+
+	return found, nil
+}
+
+// resolveColonReg returns the youngest commit, reachable from any reference,
+// whose message matches the regular expression.
+func (r *Repository) resolveColonReg(item revision.ColonReg) (object.Object, error) {
+	refs, err := r.Storer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []*object.Commit
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		ref, err := storer.ResolveReference(r.Storer, ref.Name())
+		if err != nil {
+			return nil
+		}
+
+		o, err := r.getObject(ref.Hash())
+		if err != nil {
+			return nil
+		}
+
+		if c, err := peelRevisionCommit(o); err == nil {
+			pending = append(pending, c)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var found *object.Commit
+	seen := make(map[plumbing.Hash]bool)
+	for len(pending) > 0 {
+		c := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if seen[c.Hash] {
+			continue
+		}
+
+		seen[c.Hash] = true
+		if item.Regexp.MatchString(c.Message) != item.Negate &&
+			(found == nil || c.Committer.When.After(found.Committer.When)) {
+			found = c
+		}
+
+		err := c.Parents().ForEach(func(p *object.Commit) error {
+			pending = append(pending, p)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf(`No commit message match regexp : "%s"`, item.Regexp.String())
+	}
+
+	return found, nil
+}
+
+func (r *Repository) resolveTreePath(o object.Object, path string) (object.Object, error) {
+	t, err := peelRevisionObject(o, plumbing.TreeObject)
+	if err != nil {
+		return nil, err
+	}
+
+	path = strings.TrimPrefix(path, "./")
+	if path == "" {
+		return t, nil
+	}
+
+	e, err := t.(*object.Tree).FindEntry(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Mode == filemode.Submodule {
+		return nil, ErrUnexpectedObjectType
+	}
+
+	return r.getObject(e.Hash)
+}
+
+func (r *Repository) resolveIndexPath(path string, stage index.Stage) (object.Object, error) {
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	path = strings.TrimPrefix(path, "./")
+	for _, e := range idx.Entries {
+		if e.Name == path && e.Stage == stage {
+			return r.BlobObject(e.Hash)
+		}
+	}
+
+	return nil, index.ErrEntryNotFound
+}
+
+// reflogRefName returns the name of the reference whose reflog is used by
+// <name>@{<n>}. An empty name means the current branch, or HEAD when it is
+// detached.
+func (r *Repository) reflogRefName(name string) (plumbing.ReferenceName, error) {
+	if name == "" {
+		head, err := r.Storer.Reference(plumbing.HEAD)
+		if err != nil {
+			return "", err
+		}
+
+		if head.Type() == plumbing.SymbolicReference {
+			return head.Target(), nil
+		}
+
+		return plumbing.HEAD, nil
+	}
+
+	for _, rule := range append([]string{"%s"}, plumbing.RefRevParseRules...) {
+		n := plumbing.ReferenceName(fmt.Sprintf(rule, name))
+		if _, err := r.Storer.Reference(n); err == nil {
+			return n, nil
+		}
+	}
+
+	return "", plumbing.ErrReferenceNotFound
+}
+
+// readReflog returns the entries of the reflog of the given reference, the
+// oldest first. Storers not keeping a reflog return an empty one.
+func (r *Repository) readReflog(name plumbing.ReferenceName) ([]*reflog.Entry, error) {
+	rs, ok := r.Storer.(storer.ReflogStorer)
+	if !ok {
+		return nil, nil
+	}
+
+	return rs.Reflog(name)
+}
+
+func (r *Repository) resolveAtReflog(ref string, n int) (object.Object, error) {
+	name, err := r.reflogRefName(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := r.readReflog(name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case n < len(entries):
+		return r.revisionObject(entries[len(entries)-1-n].New)
+	case n == len(entries) && n > 0 && !entries[0].Old.IsZero():
+		return r.revisionObject(entries[0].Old)
+	}
+
+	return nil, ErrReflogEntryNotFound
+}
+
+func (r *Repository) resolveAtDate(ref string, date time.Time) (object.Object, error) {
+	name, err := r.reflogRefName(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := r.readReflog(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, ErrReflogEntryNotFound
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !entries[i].Committer.When.After(date) {
+			return r.revisionObject(entries[i].New)
+		}
+	}
+
+	// as git does, the oldest known value is used for dates older than the
+	// reflog itself
+	if !entries[0].Old.IsZero() {
+		return r.revisionObject(entries[0].Old)
+	}
+
+	return r.revisionObject(entries[0].New)
+}
+
+// resolveAtCheckout resolves @{-<n>} using the checkout entries of the HEAD
+// reflog, written as "checkout: moving from <old> to <new>".
+func (r *Repository) resolveAtCheckout(n int) (object.Object, error) {
+	entries, err := r.readReflog(plumbing.HEAD)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		msg := entries[i].Message
+		if !strings.HasPrefix(msg, checkoutReflogPrefix) {
+			continue
+		}
+
+		if n--; n > 0 {
+			continue
+		}
+
+		from := strings.SplitN(strings.TrimPrefix(msg, checkoutReflogPrefix), " to ", 2)[0]
+		return r.resolveRevisionRef(from)
+	}
+
+	return nil, ErrReflogEntryNotFound
+}
+
+func (r *Repository) resolveAtTracking(
+	ref string,
+	trackingRefName func(*config.Config, string) (plumbing.ReferenceName, error),
+) (object.Object, error) {
+	branch, err := r.revisionBranchName(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := r.MergedConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := trackingRefName(cfg, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	tracking, err := storer.ResolveReference(r.Storer, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.revisionObject(tracking.Hash())
+}
+
+// revisionBranchName returns the short name of the branch of a
+// <branchname>@{upstream} revision, the current branch if empty.
+func (r *Repository) revisionBranchName(ref string) (string, error) {
+	if ref == "" || ref == plumbing.HEAD.String() {
+		head, err := r.Storer.Reference(plumbing.HEAD)
+		if err != nil {
+			return "", err
+		}
+
+		if head.Type() != plumbing.SymbolicReference || !head.Target().IsBranch() {
+			return "", ErrNoUpstream
+		}
+
+		return head.Target().Short(), nil
+	}
+
+	ref = strings.TrimPrefix(ref, "refs/")
+	return strings.TrimPrefix(ref, "heads/"), nil
+}
+
+// upstreamRefName returns the remote-tracking reference of the upstream of
+// the given branch, the one merged by `git pull`.
+func (r *Repository) upstreamRefName(cfg *config.Config, branch string) (plumbing.ReferenceName, error) {
+	b, ok := cfg.Branches[branch]
+	if !ok || b.Remote == "" || b.Merge == "" {
+		return "", ErrNoUpstream
+	}
+
+	return trackingRefName(cfg, b.Remote, b.Merge)
+}
+
+// pushRefName returns the remote-tracking reference of the branch where the
+// given branch would be pushed by `git push`, following branch.<name>.pushRemote,
+// remote.pushDefault and push.default.
+func (r *Repository) pushRefName(cfg *config.Config, branch string) (plumbing.ReferenceName, error) {
+	b := cfg.Branches[branch]
+	remote := pushRemoteName(cfg, branch)
+	if remote == "" {
+		return "", ErrNoUpstream
+	}
+
+	dst := plumbing.NewBranchReferenceName(branch)
+	switch cfg.Raw.Section("push").Option("default") {
+	case "nothing":
+		return "", ErrNoUpstream
+	case "upstream", "tracking":
+		if b == nil || b.Remote != remote || b.Merge == "" {
+			return "", ErrNoUpstream
+		}
+
+		dst = b.Merge
+	case "", "simple":
+		if b != nil && b.Remote == remote && b.Merge != dst {
+			return "", ErrNoUpstream
+		}
+	}
+
+	return trackingRefName(cfg, remote, dst)
+}
+
+// pushRemoteName returns the remote where the given branch is pushed, from
+// branch.<name>.pushRemote, remote.pushDefault or branch.<name>.remote.
+func pushRemoteName(cfg *config.Config, branch string) string {
+	remote := cfg.Raw.Section("branch").Subsection(branch).Option("pushRemote")
+	if remote == "" {
+		remote = cfg.Raw.Section("remote").Option("pushDefault")
+	}
+
+	if b := cfg.Branches[branch]; remote == "" && b != nil {
+		remote = b.Remote
+	}
+
+	return remote
+}
+
+// trackingRefName maps a reference of the given remote to the local
+// remote-tracking reference using the fetch refspecs of the remote. The "."
+// remote is the repository itself.
+func trackingRefName(cfg *config.Config, remote string, name plumbing.ReferenceName) (plumbing.ReferenceName, error) {
+	if remote == "." {
+		return name, nil
+	}
+
+	rc, ok := cfg.Remotes[remote]
+	if !ok {
+		return "", ErrRemoteNotFound
+	}
+
+	for _, spec := range rc.Fetch {
+		if spec.Match(name) {
+			return spec.Dst(name), nil
+		}
+	}
+
+	return "", ErrNoUpstream
+}
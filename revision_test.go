@@ -0,0 +1,255 @@
+package git
+
+import (
+	"github.com/goabstract/go-git/v5/config"
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/format/index"
+	"github.com/goabstract/go-git/v5/plumbing/object"
+	"github.com/goabstract/go-git/v5/storage/memory"
+
+	fixtures "github.com/go-git/go-git-fixtures/v4"
+	. "gopkg.in/check.v1"
+)
+
+type RevisionSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&RevisionSuite{})
+
+func (s *RevisionSuite) assertRevisions(c *C, r *Repository, datas map[string]string) {
+	for rev, hash := range datas {
+		o, err := r.ResolveRevisionObject(plumbing.Revision(rev))
+		c.Assert(err, IsNil, Commentf("while checking %s", rev))
+		c.Check(o.ID().String(), Equals, hash, Commentf("while checking %s", rev))
+	}
+}
+
+func (s *RevisionSuite) assertRevisionErrors(c *C, r *Repository, datas map[string]error) {
+	for rev, expected := range datas {
+		_, err := r.ResolveRevisionObject(plumbing.Revision(rev))
+		c.Check(err, Equals, expected, Commentf("while checking %s", rev))
+	}
+}
+
+func (s *RevisionSuite) storeBlob(c *C, r *Repository, content string) {
+	blob := r.Storer.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+
+	w, err := blob.Writer()
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	_, err = r.Storer.SetEncodedObject(blob)
+	c.Assert(err, IsNil)
+}
+
+func (s *RevisionSuite) TestResolveRevisionObject(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	s.assertRevisions(c, r, map[string]string{
+		"HEAD":                "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"b029517f6300c2da0f4": "b029517f6300c2da0f4b651b8642506cd6aaf45d",
+		"@":                   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"6ecf0ef":             "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"6ECF0EF~1":           "918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"HEAD^{}":             "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"HEAD^{commit}":       "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"HEAD^{object}":       "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"HEAD^{tree}":         "a8d315b2b1c615d43042c3a62402b8a54288cf5c",
+		"HEAD:":               "a8d315b2b1c615d43042c3a62402b8a54288cf5c",
+		"HEAD:CHANGELOG":      "d3ff53e0564a9f87d8e84b6e28e5060e517008aa",
+		"master:./go":         "a39771a7651f97faf5c72e08224d857fc35133db",
+		"HEAD~1:json":         "5a877e6a906a2743ad6e45d99c1793642aaf8eda",
+		":/some code":         "e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		":/^vendor":           "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"HEAD^{/some co}":     "918c48b83bd081e863dbe1b80f8998f058cd8294",
+	})
+
+	o, err := r.ResolveRevisionObject("HEAD:go")
+	c.Assert(err, IsNil)
+	c.Assert(o, FitsTypeOf, &object.Tree{})
+
+	o, err = r.ResolveRevisionObject("HEAD:CHANGELOG")
+	c.Assert(err, IsNil)
+	c.Assert(o, FitsTypeOf, &object.Blob{})
+
+	s.assertRevisionErrors(c, r, map[string]error{
+		"HEAD^{blob}":          ErrUnexpectedObjectType,
+		"HEAD^{tag}":           ErrUnexpectedObjectType,
+		"HEAD:CHANGELOG~1":     object.ErrEntryNotFound,
+		"HEAD:go^{tree}":       object.ErrEntryNotFound,
+		"HEAD~1:vendor":        object.ErrEntryNotFound,
+		"b029517f6300c2da0f40": plumbing.ErrReferenceNotFound,
+		"ffff":                 plumbing.ErrReferenceNotFound,
+		"b029517f6300c2~1":     object.ErrParentNotFound,
+		"HEAD^2":               object.ErrParentNotFound,
+	})
+}
+
+func (s *RevisionSuite) TestResolveRevisionObjectAnnotated(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(
+		fixtures.ByURL("https://github.com/git-fixtures/tags.git").One(),
+	)
+
+	s.assertRevisions(c, r, map[string]string{
+		"annotated-tag":          "b742a2a9fa0afcfa9a6fad080980fbc26b007c69",
+		"annotated-tag^{tag}":    "b742a2a9fa0afcfa9a6fad080980fbc26b007c69",
+		"annotated-tag^{}":       "f7b877701fbf855b44c0a9e86f3fdce2c298b07f",
+		"annotated-tag^{commit}": "f7b877701fbf855b44c0a9e86f3fdce2c298b07f",
+		"annotated-tag^{tree}":   "70846e9a10ef7b41064b40f07713d5b8b9a8fc73",
+		"annotated-tag^0":        "f7b877701fbf855b44c0a9e86f3fdce2c298b07f",
+		"tree-tag^{}":            "70846e9a10ef7b41064b40f07713d5b8b9a8fc73",
+		"tree-tag^{tree}":        "70846e9a10ef7b41064b40f07713d5b8b9a8fc73",
+		"blob-tag^{blob}":        "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391",
+		"b742a2a":                "b742a2a9fa0afcfa9a6fad080980fbc26b007c69",
+	})
+
+	s.assertRevisionErrors(c, r, map[string]error{
+		"tree-tag^{commit}":     ErrUnexpectedObjectType,
+		"lightweight-tag^{tag}": ErrUnexpectedObjectType,
+		"blob-tag~1":            ErrUnexpectedObjectType,
+	})
+
+	_, err := r.ResolveRevision("tree-tag")
+	c.Assert(err, Equals, ErrUnableToResolveCommit)
+}
+
+func (s *RevisionSuite) TestResolveRevisionObjectAbbreviated(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	// the hash of this blob is 6ecf0050d61374f96e2bbb0c392f6b892ef41f0e, a
+	// commit shares its first four characters
+	s.storeBlob(c, r, "13433\n")
+
+	s.assertRevisions(c, r, map[string]string{
+		"6ecf":   "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"6ecf00": "6ecf0050d61374f96e2bbb0c392f6b892ef41f0e",
+	})
+
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	// both blobs hashes start with 6bb2
+	s.storeBlob(c, r, "195\n")
+	s.storeBlob(c, r, "389\n")
+
+	s.assertRevisionErrors(c, r, map[string]error{
+		"6bb2": ErrAmbiguousRevision,
+	})
+}
+
+func (s *RevisionSuite) TestResolveRevisionObjectReflog(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	s.assertRevisions(c, r, map[string]string{
+		"HEAD@{0}":                    "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"HEAD@{2}":                    "e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		"HEAD@{2}~1":                  "918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"HEAD@{4}":                    "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"@{0}":                        "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"master@{0}":                  "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"refs/heads/branch@{0}":       "e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		"HEAD@{2016-12-01T20:55:00Z}": "e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		"HEAD@{2016-12-01T20:56:00Z}": "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"HEAD@{2010-01-01}":           "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"@{-1}":                       "e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		"@{-1}~1":                     "918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"@{-2}":                       "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+	})
+
+	s.assertRevisionErrors(c, r, map[string]error{
+		"HEAD@{5}":    ErrReflogEntryNotFound,
+		"@{1}":        ErrReflogEntryNotFound,
+		"@{-3}":       ErrReflogEntryNotFound,
+		"unknown@{0}": plumbing.ErrReferenceNotFound,
+	})
+}
+
+func (s *RevisionSuite) TestResolveRevisionObjectTracking(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+
+	delete(cfg.Branches, "branch")
+	cfg.Branches["topic"] = &config.Branch{
+		Name: "topic", Remote: "origin", Merge: "refs/heads/branch",
+	}
+
+	cfg.Branches["local"] = &config.Branch{
+		Name: "local", Remote: ".", Merge: "refs/heads/branch",
+	}
+
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	s.assertRevisions(c, r, map[string]string{
+		"@{u}":                     "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"@{upstream}~1":            "918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"master@{u}":               "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"topic@{upstream}":         "e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		"heads/local@{u}":          "e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		"@{push}":                  "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"refs/heads/master@{push}": "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+	})
+
+	s.assertRevisionErrors(c, r, map[string]error{
+		"branch@{u}":   ErrNoUpstream,
+		"topic@{push}": ErrNoUpstream,
+	})
+
+	cfg.Raw.Section("push").SetOption("default", "upstream")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	s.assertRevisions(c, r, map[string]string{
+		"topic@{push}": "e8d3ffab552895c19b9fcf7aa264d277cde33881",
+	})
+
+	cfg.Raw.Section("push").SetOption("default", "current")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	s.assertRevisionErrors(c, r, map[string]error{
+		"topic@{push}": plumbing.ErrReferenceNotFound,
+	})
+
+	cfg.Raw.Section("branch").Subsection("topic").SetOption("pushRemote", ".")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	s.assertRevisionErrors(c, r, map[string]error{
+		"topic@{push}": plumbing.ErrReferenceNotFound,
+	})
+
+	c.Assert(r.Storer.SetReference(plumbing.NewHashReference(
+		"refs/heads/topic", plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294"),
+	)), IsNil)
+
+	s.assertRevisions(c, r, map[string]string{
+		"topic@{push}": "918c48b83bd081e863dbe1b80f8998f058cd8294",
+	})
+}
+
+func (s *RevisionSuite) TestResolveRevisionObjectIndex(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	idx := &index.Index{Version: 2}
+	idx.Add("CHANGELOG").Hash = plumbing.NewHash("d3ff53e0564a9f87d8e84b6e28e5060e517008aa")
+	e := idx.Add("LICENSE")
+	e.Hash = plumbing.NewHash("c192bd6a24ea1ab01d78686e417c8bdc7c3d197f")
+	e.Stage = index.OurMode
+	c.Assert(r.Storer.SetIndex(idx), IsNil)
+
+	s.assertRevisions(c, r, map[string]string{
+		":CHANGELOG":   "d3ff53e0564a9f87d8e84b6e28e5060e517008aa",
+		":./CHANGELOG": "d3ff53e0564a9f87d8e84b6e28e5060e517008aa",
+		":0:CHANGELOG": "d3ff53e0564a9f87d8e84b6e28e5060e517008aa",
+		":2:LICENSE":   "c192bd6a24ea1ab01d78686e417c8bdc7c3d197f",
+	})
+
+	s.assertRevisionErrors(c, r, map[string]error{
+		":LICENSE":     index.ErrEntryNotFound,
+		":3:LICENSE":   index.ErrEntryNotFound,
+		":1:CHANGELOG": index.ErrEntryNotFound,
+	})
+}
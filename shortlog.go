@@ -0,0 +1,136 @@
+package git
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/goabstract/go-git/v5/plumbing/object"
+)
+
+// ShortlogEntry summarizes the commits of a single contributor.
+type ShortlogEntry struct {
+	// Name is the canonical name of the contributor.
+	Name string
+	// Email is the canonical email of the contributor, only set when the
+	// entries are grouped by email.
+	Email string
+	// Commits is the number of commits of the contributor.
+	Commits int
+	// Subjects contains the first line of the message of every commit, in
+	// the order they were walked.
+	Subjects []string
+	// Additions is the number of added lines, only set if Stats is requested.
+	Additions int
+	// Deletions is the number of deleted lines, only set if Stats is requested.
+	Deletions int
+	// FilesChanged is the sum of the number of files changed by every commit,
+	// only set if Stats is requested.
+	FilesChanged int
+}
+
+// Shortlog summarizes the history by contributor, as `git shortlog` does. The
+// identities are mapped through the mailmap, so contributors that changed
+// their name or email are only reported once.
+func (r *Repository) Shortlog(o *ShortlogOptions) ([]*ShortlogEntry, error) {
+	return r.ShortlogContext(context.Background(), o)
+}
+
+// ShortlogContext summarizes the history by contributor, as `git shortlog`
+// does. See Shortlog.
+//
+// The provided Context must be non-nil. If the context expires before the
+// operation is complete, an error is returned. The context only affects the
+// computation of the stats.
+func (r *Repository) ShortlogContext(ctx context.Context, o *ShortlogOptions) ([]*ShortlogEntry, error) {
+	m := o.Mailmap
+	if m == nil {
+		var err error
+		if m, err = r.Mailmap(); err != nil {
+			return nil, err
+		}
+	}
+
+	iter, err := r.Log(&LogOptions{From: o.From, All: o.All, Mailmap: m})
+	if err != nil {
+		return nil, err
+	}
+
+	defer iter.Close()
+
+	entries := make(map[string]*ShortlogEntry)
+	err = iter.ForEach(func(c *object.Commit) error {
+		sig := c.Author
+		if o.Committer {
+			sig = c.Committer
+		}
+
+		key := sig.Name
+		if o.Email {
+			key += "\x00" + sig.Email
+		}
+
+		e, ok := entries[key]
+		if !ok {
+			e = &ShortlogEntry{Name: sig.Name}
+			if o.Email {
+				e.Email = sig.Email
+			}
+
+			entries[key] = e
+		}
+
+		e.Commits++
+		e.Subjects = append(e.Subjects, commitSubject(c.Message))
+
+		if !o.Stats || c.NumParents() > 1 {
+			return nil
+		}
+
+		stats, err := c.StatsContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range stats {
+			e.Additions += s.Addition
+			e.Deletions += s.Deletion
+		}
+
+		e.FilesChanged += len(stats)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*ShortlogEntry, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, e)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if o.Numbered && a.Commits != b.Commits {
+			return a.Commits > b.Commits
+		}
+
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+
+		return a.Email < b.Email
+	})
+
+	return result, nil
+}
+
+func commitSubject(msg string) string {
+	msg = strings.TrimLeft(msg, "\n")
+	if i := strings.IndexByte(msg, '\n'); i != -1 {
+		msg = msg[:i]
+	}
+
+	return strings.TrimSpace(msg)
+}
@@ -0,0 +1,99 @@
+package git
+
+import (
+	"strings"
+	"time"
+
+	"github.com/goabstract/go-git/v5/plumbing/format/mailmap"
+	"github.com/goabstract/go-git/v5/plumbing/object"
+	"github.com/goabstract/go-git/v5/storage/memory"
+
+	"github.com/go-git/go-billy/v5/memfs"
+
+	fixtures "github.com/go-git/go-git-fixtures/v4"
+	. "gopkg.in/check.v1"
+)
+
+type ShortlogSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&ShortlogSuite{})
+
+func (s *ShortlogSuite) TestShortlog(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+
+	entries, err := r.Shortlog(&ShortlogOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 3)
+	c.Assert(entries[0].Name, Equals, "Daniel Ripolles")
+	c.Assert(entries[0].Commits, Equals, 1)
+	c.Assert(entries[0].Subjects, DeepEquals, []string{"Creating changelog"})
+	c.Assert(entries[1].Name, Equals, "Máximo Cuadros")
+	c.Assert(entries[1].Commits, Equals, 2)
+	c.Assert(entries[2].Name, Equals, "Máximo Cuadros Ortiz")
+	c.Assert(entries[2].Commits, Equals, 5)
+	c.Assert(entries[2].Email, Equals, "")
+}
+
+func (s *ShortlogSuite) TestShortlogWithMailmap(c *C) {
+	m, err := mailmap.Parse(strings.NewReader(
+		"Máximo Cuadros Ortiz <mcuadros@gmail.com>\n",
+	))
+	c.Assert(err, IsNil)
+
+	r := s.NewRepository(fixtures.Basic().One())
+	entries, err := r.Shortlog(&ShortlogOptions{Mailmap: m, Numbered: true, Email: true})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Assert(entries[0].Name, Equals, "Máximo Cuadros Ortiz")
+	c.Assert(entries[0].Email, Equals, "mcuadros@gmail.com")
+	c.Assert(entries[0].Commits, Equals, 7)
+	c.Assert(entries[1].Name, Equals, "Daniel Ripolles")
+	c.Assert(entries[1].Email, Equals, "daniel@lordran.local")
+}
+
+func (s *ShortlogSuite) TestShortlogCommitter(c *C) {
+	m, err := mailmap.Parse(strings.NewReader(
+		"Daniel <daniel@example.com> <daniel@lordran.local>\n",
+	))
+	c.Assert(err, IsNil)
+
+	r := s.NewRepository(fixtures.Basic().One())
+	entries, err := r.Shortlog(&ShortlogOptions{Mailmap: m, Committer: true})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 3)
+	c.Assert(entries[0].Name, Equals, "Daniel")
+}
+
+func (s *ShortlogSuite) TestShortlogStats(c *C) {
+	r := s.NewRepository(fixtures.Basic().One())
+	entries, err := r.Shortlog(&ShortlogOptions{Stats: true})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 3)
+	c.Assert(entries[0].Name, Equals, "Daniel Ripolles")
+	c.Assert(entries[0].Additions, Equals, 1)
+	c.Assert(entries[0].Deletions, Equals, 0)
+	c.Assert(entries[0].FilesChanged, Equals, 1)
+}
+
+func (s *ShortlogSuite) TestShortlogEmailCaseSensitive(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	for _, email := range []string{"foo@example.com", "FOO@example.com"} {
+		_, err = w.Commit("foo\n", &CommitOptions{Author: &object.Signature{
+			Name: "Foo", Email: email, When: time.Now(),
+		}})
+		c.Assert(err, IsNil)
+	}
+
+	entries, err := r.Shortlog(&ShortlogOptions{Email: true})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Assert(entries[0].Email, Equals, "FOO@example.com")
+	c.Assert(entries[1].Email, Equals, "foo@example.com")
+}
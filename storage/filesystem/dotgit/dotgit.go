@@ -58,6 +58,9 @@ var (
 	// targeting a non-existing object. This usually means the repository
 	// is corrupt.
 	ErrSymRefTargetNotFound = errors.New("symbolic reference target not found")
+	// ErrRefLocked is returned updating a reference locked by another
+	// update, that is, its lock file exists.
+	ErrRefLocked = errors.New("reference is locked")
 )
 
 // Options holds configuration for the storage.
@@ -611,17 +614,21 @@ func (d *DotGit) checkReferenceAndTruncate(f billy.File, old *plumbing.Reference
 }
 
 func (d *DotGit) SetRef(r, old *plumbing.Reference) error {
-	var content string
+	fileName := r.Name().String()
+
+	return d.setRef(fileName, refContent(r), old)
+}
+
+// refContent returns the content of the file of a loose reference.
+func refContent(r *plumbing.Reference) string {
 	switch r.Type() {
 	case plumbing.SymbolicReference:
-		content = fmt.Sprintf("ref: %s\n", r.Target())
+		return fmt.Sprintf("ref: %s\n", r.Target())
 	case plumbing.HashReference:
-		content = fmt.Sprintln(r.Hash().String())
+		return fmt.Sprintln(r.Hash().String())
 	}
 
-	fileName := r.Name().String()
-
-	return d.setRef(fileName, content, old)
+	return ""
 }
 
 // Refs scans the git directory collecting references, which it returns.
@@ -699,10 +706,21 @@ func (d *DotGit) packedRef(name plumbing.ReferenceName) (*plumbing.Reference, er
 	return nil, plumbing.ErrReferenceNotFound
 }
 
-// RemoveRef removes a reference by name.
+// RemoveRef removes a reference by name, along with its reflog. The lock
+// file of the reference is held meanwhile, as setRef does.
 func (d *DotGit) RemoveRef(name plumbing.ReferenceName) error {
+	lock, err := d.lockRef(name)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = lock.Close()
+		_ = d.fs.Remove(lock.Name())
+	}()
+
 	path := d.fs.Join(".", name.String())
-	_, err := d.fs.Stat(path)
+	_, err = d.fs.Stat(path)
 	if err == nil {
 		err = d.fs.Remove(path)
 		// Drop down to remove it from the packed refs file, too.
@@ -712,6 +730,10 @@ func (d *DotGit) RemoveRef(name plumbing.ReferenceName) error {
 		return err
 	}
 
+	if err := d.DeleteReflog(name); err != nil {
+		return err
+	}
+
 	return d.rewritePackedRefsWithoutRef(name)
 }
 
@@ -800,7 +822,7 @@ func (d *DotGit) openAndLockPackedRefs(doCreate bool) (
 	return f, nil
 }
 
-func (d *DotGit) rewritePackedRefsWithoutRef(name plumbing.ReferenceName) (err error) {
+func (d *DotGit) rewritePackedRefsWithoutRef(names ...plumbing.ReferenceName) (err error) {
 	pr, err := d.openAndLockPackedRefs(false)
 	if err != nil {
 		return err
@@ -822,34 +844,47 @@ func (d *DotGit) rewritePackedRefsWithoutRef(name plumbing.ReferenceName) (err e
 		_ = d.fs.Remove(tmpName) // don't check err, we might have renamed it
 	}()
 
-	s := bufio.NewScanner(pr)
-	found := false
+	found, err := d.copyPackedRefsWithout(tmp, pr, names)
+	if err != nil || !found {
+		return err
+	}
+
+	return d.rewritePackedRefsWhileLocked(tmp, pr)
+}
+
+// copyPackedRefsWithout copies the lines of the packed-refs file read from r
+// to w but the ones of the given references, reporting whether any of them
+// was found.
+func (d *DotGit) copyPackedRefsWithout(w io.Writer, r io.Reader, names []plumbing.ReferenceName) (found bool, err error) {
+	s := bufio.NewScanner(r)
 	for s.Scan() {
 		line := s.Text()
 		ref, err := d.processLine(line)
 		if err != nil {
-			return err
+			return false, err
 		}
 
-		if ref != nil && ref.Name() == name {
+		if ref != nil && containsRefName(names, ref.Name()) {
 			found = true
 			continue
 		}
 
-		if _, err := fmt.Fprintln(tmp, line); err != nil {
-			return err
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return false, err
 		}
 	}
 
-	if err := s.Err(); err != nil {
-		return err
-	}
+	return found, s.Err()
+}
 
-	if !found {
-		return nil
+func containsRefName(names []plumbing.ReferenceName, name plumbing.ReferenceName) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
 	}
 
-	return d.rewritePackedRefsWhileLocked(tmp, pr)
+	return false
 }
 
 // process lines from a packed-refs file
@@ -888,6 +923,11 @@ func (d *DotGit) walkReferencesTree(refs *[]*plumbing.Reference, relPath []strin
 	}
 
 	for _, f := range files {
+		// the lock files of the references being updated
+		if strings.HasSuffix(f.Name(), lockExt) {
+			continue
+		}
+
 		newRelPath := append(append([]string(nil), relPath...), f.Name())
 		if f.IsDir() {
 			if err = d.walkReferencesTree(refs, newRelPath, seen); err != nil {
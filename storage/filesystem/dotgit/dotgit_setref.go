@@ -10,7 +10,20 @@ import (
 	"github.com/go-git/go-billy/v5"
 )
 
+// setRef writes the content of a reference, checking first its current value
+// if old is not nil. The lock file of the reference is held meanwhile, so
+// the update is excluded from the ones of UpdateRefs and of git.
 func (d *DotGit) setRef(fileName, content string, old *plumbing.Reference) (err error) {
+	lock, err := d.lockFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = lock.Close()
+		_ = d.fs.Remove(lock.Name())
+	}()
+
 	if billy.CapabilityCheck(d.fs, billy.ReadAndWriteCapability) {
 		return d.setRefRwfs(fileName, content, old)
 	}
@@ -0,0 +1,98 @@
+package dotgit
+
+import (
+	"os"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/format/reflog"
+	"github.com/goabstract/go-git/v5/utils/ioutil"
+)
+
+const (
+	logsPath = "logs"
+	lockExt  = ".lock"
+)
+
+func (d *DotGit) reflogPath(name plumbing.ReferenceName) string {
+	return d.fs.Join(logsPath, name.String())
+}
+
+// Reflog returns the entries of the reflog of the given reference, the oldest
+// first. An empty slice is returned if the reflog doesn't exist.
+func (d *DotGit) Reflog(name plumbing.ReferenceName) (entries []*reflog.Entry, err error) {
+	f, err := d.fs.Open(d.reflogPath(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return reflog.NewDecoder(f).DecodeAll()
+}
+
+// HasReflog reports whether the reflog file of the given reference exists,
+// even if it is empty, as git does.
+func (d *DotGit) HasReflog(name plumbing.ReferenceName) (bool, error) {
+	_, err := d.fs.Stat(d.reflogPath(name))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// AppendReflog adds an entry at the end of the reflog of the given reference,
+// the file and its parent directories are created if needed.
+func (d *DotGit) AppendReflog(name plumbing.ReferenceName, e *reflog.Entry) (err error) {
+	f, err := d.fs.OpenFile(d.reflogPath(name), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return reflog.NewEncoder(f).Encode(e)
+}
+
+// SetReflog replaces the content of the reflog of the given reference. The
+// new content is written to a lock file renamed afterwards, so readers never
+// see a partial reflog.
+func (d *DotGit) SetReflog(name plumbing.ReferenceName, entries []*reflog.Entry) error {
+	path := d.reflogPath(name)
+	lock := path + lockExt
+
+	f, err := d.fs.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+
+	e := reflog.NewEncoder(f)
+	for _, entry := range entries {
+		if err = e.Encode(entry); err != nil {
+			break
+		}
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		_ = d.fs.Remove(lock)
+		return err
+	}
+
+	return d.fs.Rename(lock, path)
+}
+
+// DeleteReflog removes the reflog of the given reference, if it exists.
+func (d *DotGit) DeleteReflog(name plumbing.ReferenceName) error {
+	err := d.fs.Remove(d.reflogPath(name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
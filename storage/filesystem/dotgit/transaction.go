@@ -0,0 +1,232 @@
+package dotgit
+
+import (
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/storer"
+	"github.com/goabstract/go-git/v5/storage"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+const (
+	// lockTimeout is how long taking a lock file is retried while it
+	// exists, the default of core.filesRefLockTimeout.
+	lockTimeout       = 100 * time.Millisecond
+	lockRetryInterval = 5 * time.Millisecond
+)
+
+// UpdateRefs applies all the updates, or none of them if any reference is
+// locked or hasn't the expected value. As git does, every reference is
+// locked, creating its lock file, and checked before writing any of them,
+// the new values are written to the lock files and then renamed over the
+// references. The deleted references are removed from packed-refs, written
+// to its own lock file, last. The references already written are restored
+// if any step fails.
+func (d *DotGit) UpdateRefs(updates []storer.ReferenceUpdate) (err error) {
+	if err := storer.CheckReferenceUpdates(updates); err != nil {
+		return err
+	}
+
+	// the locks are always taken in the same order
+	updates = append([]storer.ReferenceUpdate(nil), updates...)
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].Name < updates[j].Name
+	})
+
+	var locks []billy.File
+	defer func() {
+		for _, l := range locks {
+			if l == nil {
+				continue
+			}
+
+			_ = l.Close()
+			_ = d.fs.Remove(l.Name())
+		}
+	}()
+
+	current := make([]*plumbing.Reference, len(updates))
+	var deleted []plumbing.ReferenceName
+	for i, u := range updates {
+		lock, err := d.lockRef(u.Name)
+		if err != nil {
+			return err
+		}
+
+		locks = append(locks, lock)
+		ref, err := d.Ref(u.Name)
+		if err == plumbing.ErrReferenceNotFound {
+			ref = nil
+		} else if err != nil {
+			return err
+		}
+
+		if !u.Check(ref) {
+			return storage.ErrReferenceHasChanged
+		}
+
+		current[i] = ref
+		switch u.Action {
+		case storer.CreateReference, storer.UpdateReference:
+			if _, err := lock.Write([]byte(refContent(u.New))); err != nil {
+				return err
+			}
+		case storer.DeleteReference:
+			deleted = append(deleted, u.Name)
+		}
+	}
+
+	var packed, packedLock billy.File
+	if len(deleted) != 0 {
+		packed, packedLock, err = d.lockPackedRefsWithout(deleted)
+		if err != nil {
+			return err
+		}
+
+		if packed != nil {
+			defer func() {
+				_ = packed.Close()
+				_ = packedLock.Close()
+				_ = d.fs.Remove(packedLock.Name())
+			}()
+		}
+	}
+
+	var applied int
+	defer func() {
+		if err != nil {
+			d.restoreRefs(updates[:applied], current[:applied])
+		}
+	}()
+
+	for i, u := range updates {
+		lock := locks[i]
+		if err := lock.Close(); err != nil {
+			return err
+		}
+
+		locks[i] = nil
+		switch u.Action {
+		case storer.CreateReference, storer.UpdateReference:
+			err = d.fs.Rename(lock.Name(), u.Name.String())
+		case storer.DeleteReference:
+			err = d.removeLooseRef(u.Name)
+			_ = d.fs.Remove(lock.Name())
+		default:
+			err = d.fs.Remove(lock.Name())
+		}
+
+		if err != nil {
+			_ = d.fs.Remove(lock.Name())
+			return err
+		}
+
+		applied++
+	}
+
+	if packed != nil {
+		if err := d.rewritePackedRefsWhileLocked(packedLock, packed); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range deleted {
+		if err := d.DeleteReflog(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lockPackedRefsWithout locks packed-refs, taking its lock file and the lock
+// of the file itself, and writes to the lock file its content without the
+// given references. No lock is kept if none of them is packed.
+func (d *DotGit) lockPackedRefsWithout(names []plumbing.ReferenceName) (packed, lock billy.File, err error) {
+	l, err := d.lockFile(packedRefsPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer func() {
+		if packed == nil {
+			_ = l.Close()
+			_ = d.fs.Remove(l.Name())
+		}
+	}()
+
+	pr, err := d.openAndLockPackedRefs(false)
+	if err != nil || pr == nil {
+		return nil, nil, err
+	}
+
+	found, err := d.copyPackedRefsWithout(l, pr, names)
+	if err != nil || !found {
+		_ = pr.Close()
+		return nil, nil, err
+	}
+
+	return pr, l, nil
+}
+
+// restoreRefs sets back the references updated by a failed transaction to
+// their previous value, removing the ones which didn't exist.
+func (d *DotGit) restoreRefs(updates []storer.ReferenceUpdate, previous []*plumbing.Reference) {
+	for i, u := range updates {
+		if previous[i] == nil {
+			_ = d.removeLooseRef(u.Name)
+			continue
+		}
+
+		f, err := d.fs.Create(u.Name.String())
+		if err != nil {
+			continue
+		}
+
+		_, _ = f.Write([]byte(refContent(previous[i])))
+		_ = f.Close()
+	}
+}
+
+// lockRef creates the lock file of a reference, see lockFile.
+func (d *DotGit) lockRef(name plumbing.ReferenceName) (billy.File, error) {
+	return d.lockFile(name.String())
+}
+
+// lockFile creates the lock file of the given file, retrying for lockTimeout
+// while it exists, failing then with ErrRefLocked.
+func (d *DotGit) lockFile(p string) (billy.File, error) {
+	if dir := path.Dir(p); dir != "." {
+		if err := d.fs.MkdirAll(dir, os.ModeDir|os.ModePerm); err != nil {
+			return nil, err
+		}
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := d.fs.OpenFile(p+lockExt, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if !os.IsExist(err) {
+			return f, err
+		}
+
+		if time.Now().After(deadline) {
+			return nil, ErrRefLocked
+		}
+
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+func (d *DotGit) removeLooseRef(name plumbing.ReferenceName) error {
+	err := d.fs.Remove(name.String())
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,177 @@
+package dotgit
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/storer"
+	"github.com/goabstract/go-git/v5/storage"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	fixtures "github.com/go-git/go-git-fixtures/v4"
+	. "gopkg.in/check.v1"
+)
+
+func (s *SuiteDotGit) TestUpdateRefs(c *C) {
+	fs := fixtures.Basic().ByTag(".git").One().DotGit()
+	dir := New(fs)
+
+	master := plumbing.NewReferenceFromStrings("refs/heads/master", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	tag := plumbing.NewReferenceFromStrings("refs/tags/v2.0.0", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	moved := plumbing.NewReferenceFromStrings("refs/heads/master", "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	c.Assert(dir.UpdateRefs([]storer.ReferenceUpdate{
+		{Action: storer.UpdateReference, Name: master.Name(), New: moved, Old: master},
+		{Action: storer.CreateReference, Name: tag.Name(), New: tag},
+		{Action: storer.DeleteReference, Name: "refs/remotes/origin/branch"},
+	}), IsNil)
+
+	ref, err := dir.Ref(master.Name())
+	c.Assert(err, IsNil)
+	c.Assert(ref, DeepEquals, moved)
+
+	ref, err = dir.Ref(tag.Name())
+	c.Assert(err, IsNil)
+	c.Assert(ref, DeepEquals, tag)
+
+	b, err := ioutil.ReadFile(filepath.Join(fs.Root(), packedRefsPath))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, ""+
+		"# pack-refs with: peeled fully-peeled \n"+
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5 refs/heads/master\n"+
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5 refs/remotes/origin/master\n")
+
+	// no lock file is left behind
+	_, err = fs.Stat("refs/heads/master" + lockExt)
+	c.Assert(err, NotNil)
+	_, err = fs.Stat("refs/tags/v2.0.0" + lockExt)
+	c.Assert(err, NotNil)
+}
+
+func (s *SuiteDotGit) TestUpdateRefsConflict(c *C) {
+	fs := fixtures.Basic().ByTag(".git").One().DotGit()
+	dir := New(fs)
+
+	master := plumbing.NewReferenceFromStrings("refs/heads/master", "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	tag := plumbing.NewReferenceFromStrings("refs/tags/v2.0.0", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	err := dir.UpdateRefs([]storer.ReferenceUpdate{
+		{Action: storer.CreateReference, Name: tag.Name(), New: tag},
+		{Action: storer.VerifyReference, Name: master.Name(), Old: master},
+	})
+	c.Assert(err, Equals, storage.ErrReferenceHasChanged)
+
+	_, err = dir.Ref(tag.Name())
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+	_, err = fs.Stat("refs/tags/v2.0.0" + lockExt)
+	c.Assert(err, NotNil)
+}
+
+func (s *SuiteDotGit) TestUpdateRefsLocked(c *C) {
+	fs := fixtures.Basic().ByTag(".git").One().DotGit()
+	dir := New(fs)
+
+	c.Assert(util.WriteFile(fs, "refs/heads/master"+lockExt, nil, 0644), IsNil)
+
+	tag := plumbing.NewReferenceFromStrings("refs/tags/v2.0.0", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	moved := plumbing.NewReferenceFromStrings("refs/heads/master", "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	err := dir.UpdateRefs([]storer.ReferenceUpdate{
+		{Action: storer.CreateReference, Name: tag.Name(), New: tag},
+		{Action: storer.UpdateReference, Name: moved.Name(), New: moved},
+	})
+	c.Assert(err, Equals, ErrRefLocked)
+
+	_, err = dir.Ref(tag.Name())
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	// the lock files are not references
+	refs, err := dir.Refs()
+	c.Assert(err, IsNil)
+	for _, ref := range refs {
+		c.Assert(ref.Name(), Not(Equals), plumbing.ReferenceName("refs/heads/master"+lockExt))
+	}
+
+	// the lock of another process is kept
+	_, err = fs.Stat("refs/heads/master" + lockExt)
+	c.Assert(err, IsNil)
+}
+
+var errRename = errors.New("rename failed")
+
+// renameFailingFS is a filesystem failing to rename any file to path.
+type renameFailingFS struct {
+	billy.Filesystem
+	path string
+}
+
+func (fs *renameFailingFS) Rename(from, to string) error {
+	if to == fs.path {
+		return errRename
+	}
+
+	return fs.Filesystem.Rename(from, to)
+}
+
+func (s *SuiteDotGit) TestUpdateRefsRollback(c *C) {
+	for _, path := range []string{"refs/tags/v2.0.0", packedRefsPath} {
+		fs := fixtures.Basic().ByTag(".git").One().DotGit()
+		dir := New(&renameFailingFS{Filesystem: fs, path: path})
+
+		master, err := dir.Ref("refs/heads/master")
+		c.Assert(err, IsNil)
+		branch, err := dir.Ref("refs/remotes/origin/branch")
+		c.Assert(err, IsNil)
+		packed, err := ioutil.ReadFile(filepath.Join(fs.Root(), packedRefsPath))
+		c.Assert(err, IsNil)
+
+		tag := plumbing.NewReferenceFromStrings("refs/tags/v2.0.0", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+		moved := plumbing.NewReferenceFromStrings("refs/heads/master", "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+		err = dir.UpdateRefs([]storer.ReferenceUpdate{
+			{Action: storer.UpdateReference, Name: moved.Name(), New: moved, Old: master},
+			{Action: storer.CreateReference, Name: tag.Name(), New: tag},
+			{Action: storer.DeleteReference, Name: branch.Name()},
+		})
+		c.Assert(err, Equals, errRename, Commentf(path))
+
+		ref, err := dir.Ref(master.Name())
+		c.Assert(err, IsNil)
+		c.Assert(ref, DeepEquals, master, Commentf(path))
+
+		ref, err = dir.Ref(branch.Name())
+		c.Assert(err, IsNil)
+		c.Assert(ref, DeepEquals, branch, Commentf(path))
+
+		_, err = dir.Ref(tag.Name())
+		c.Assert(err, Equals, plumbing.ErrReferenceNotFound, Commentf(path))
+
+		b, err := ioutil.ReadFile(filepath.Join(fs.Root(), packedRefsPath))
+		c.Assert(err, IsNil)
+		c.Assert(string(b), Equals, string(packed), Commentf(path))
+
+		for _, name := range []string{master.Name().String(), tag.Name().String(), branch.Name().String(), packedRefsPath} {
+			_, err = fs.Stat(name + lockExt)
+			c.Assert(err, NotNil, Commentf(name))
+		}
+	}
+}
+
+func (s *SuiteDotGit) TestSetRefLocked(c *C) {
+	fs := fixtures.Basic().ByTag(".git").One().DotGit()
+	dir := New(fs)
+
+	c.Assert(util.WriteFile(fs, "refs/heads/master"+lockExt, nil, 0644), IsNil)
+
+	moved := plumbing.NewReferenceFromStrings("refs/heads/master", "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	c.Assert(dir.SetRef(moved, nil), Equals, ErrRefLocked)
+
+	ref, err := dir.Ref(moved.Name())
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Not(Equals), moved.Hash())
+
+	c.Assert(fs.Remove("refs/heads/master"+lockExt), IsNil)
+	c.Assert(dir.SetRef(moved, nil), IsNil)
+
+	_, err = fs.Stat("refs/heads/master" + lockExt)
+	c.Assert(err, NotNil)
+}
@@ -3,6 +3,7 @@ package filesystem
 import (
 	"io"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/goabstract/go-git/v5/plumbing"
@@ -805,6 +806,58 @@ func (s *ObjectStorage) ForEachObjectHash(fun func(plumbing.Hash) error) error {
 	return err
 }
 
+// HashesWithPrefix returns the hashes of the loose and packed objects
+// starting with the given hexadecimal prefix.
+func (s *ObjectStorage) HashesWithPrefix(prefix string) ([]plumbing.Hash, error) {
+	seen := make(map[plumbing.Hash]struct{})
+	var hashes []plumbing.Hash
+	add := func(h plumbing.Hash) {
+		if _, ok := seen[h]; ok || !strings.HasPrefix(h.String(), prefix) {
+			return
+		}
+
+		seen[h] = struct{}{}
+		hashes = append(hashes, h)
+	}
+
+	err := s.ForEachObjectHash(func(h plumbing.Hash) error {
+		add(h)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requireIndex(); err != nil {
+		return nil, err
+	}
+
+	for _, idx := range s.index {
+		iter, err := idx.Entries()
+		if err != nil {
+			return nil, err
+		}
+
+		for {
+			e, err := iter.Next()
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+
+			add(e.Hash)
+		}
+
+		iter.Close()
+	}
+
+	return hashes, nil
+}
+
 func (s *ObjectStorage) LooseObjectTime(hash plumbing.Hash) (time.Time, error) {
 	fi, err := s.dir.ObjectStat(hash)
 	if err != nil {
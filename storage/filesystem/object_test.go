@@ -335,6 +335,32 @@ func (s *FsSuite) TestGetFromObjectFileSharedCache(c *C) {
 	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
 }
 
+func (s *FsSuite) TestHashesWithPrefix(c *C) {
+	fs := fixtures.Basic().ByTag(".git").One().DotGit()
+	o := NewObjectStorage(dotgit.New(fs), cache.NewObjectLRUDefault())
+
+	hashes, err := o.HashesWithPrefix("6ecf0ef2")
+	c.Assert(err, IsNil)
+	c.Assert(hashes, DeepEquals, []plumbing.Hash{
+		plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+	})
+
+	hashes, err = o.HashesWithPrefix("ffffffff")
+	c.Assert(err, IsNil)
+	c.Assert(hashes, HasLen, 0)
+}
+
+func (s *FsSuite) TestHashesWithPrefixLoose(c *C) {
+	fs := fixtures.ByTag(".git").ByTag("unpacked").One().DotGit()
+	o := NewObjectStorage(dotgit.New(fs), cache.NewObjectLRUDefault())
+
+	hashes, err := o.HashesWithPrefix("f3dfe2")
+	c.Assert(err, IsNil)
+	c.Assert(hashes, DeepEquals, []plumbing.Hash{
+		plumbing.NewHash("f3dfe29d268303fc6e1bbce268605fc99573406e"),
+	})
+}
+
 func BenchmarkPackfileIter(b *testing.B) {
 	defer fixtures.Clean()
 
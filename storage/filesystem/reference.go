@@ -2,27 +2,45 @@ package filesystem
 
 import (
 	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/format/reflog"
 	"github.com/goabstract/go-git/v5/plumbing/storer"
 	"github.com/goabstract/go-git/v5/storage/filesystem/dotgit"
 )
 
 type ReferenceStorage struct {
-	dir *dotgit.DotGit
+	dir      *dotgit.DotGit
+	reftable *ReftableStorage
 }
 
 func (r *ReferenceStorage) SetReference(ref *plumbing.Reference) error {
+	if r.reftable != nil {
+		return r.reftable.SetReference(ref)
+	}
+
 	return r.dir.SetRef(ref, nil)
 }
 
 func (r *ReferenceStorage) CheckAndSetReference(ref, old *plumbing.Reference) error {
+	if r.reftable != nil {
+		return r.reftable.CheckAndSetReference(ref, old)
+	}
+
 	return r.dir.SetRef(ref, old)
 }
 
 func (r *ReferenceStorage) Reference(n plumbing.ReferenceName) (*plumbing.Reference, error) {
+	if r.reftable != nil {
+		return r.reftable.Reference(n)
+	}
+
 	return r.dir.Ref(n)
 }
 
 func (r *ReferenceStorage) IterReferences() (storer.ReferenceIter, error) {
+	if r.reftable != nil {
+		return r.reftable.IterReferences()
+	}
+
 	refs, err := r.dir.Refs()
 	if err != nil {
 		return nil, err
@@ -32,13 +50,76 @@ func (r *ReferenceStorage) IterReferences() (storer.ReferenceIter, error) {
 }
 
 func (r *ReferenceStorage) RemoveReference(n plumbing.ReferenceName) error {
+	if r.reftable != nil {
+		return r.reftable.RemoveReference(n)
+	}
+
 	return r.dir.RemoveRef(n)
 }
 
+// UpdateReferences applies all the updates, or none of them. The references
+// stored in reftables are all written at once, while the loose references
+// are locked and checked before writing any of them.
+func (r *ReferenceStorage) UpdateReferences(updates []storer.ReferenceUpdate) error {
+	if r.reftable != nil {
+		return r.reftable.UpdateReferences(updates)
+	}
+
+	return r.dir.UpdateRefs(updates)
+}
+
 func (r *ReferenceStorage) CountLooseRefs() (int, error) {
+	if r.reftable != nil {
+		return r.reftable.CountLooseRefs()
+	}
+
 	return r.dir.CountLooseRefs()
 }
 
 func (r *ReferenceStorage) PackRefs() error {
+	if r.reftable != nil {
+		return r.reftable.PackRefs()
+	}
+
 	return r.dir.PackRefs()
 }
+
+func (r *ReferenceStorage) Reflog(n plumbing.ReferenceName) ([]*reflog.Entry, error) {
+	if r.reftable != nil {
+		return r.reftable.Reflog(n)
+	}
+
+	return r.dir.Reflog(n)
+}
+
+func (r *ReferenceStorage) HasReflog(n plumbing.ReferenceName) (bool, error) {
+	if r.reftable != nil {
+		return r.reftable.HasReflog(n)
+	}
+
+	return r.dir.HasReflog(n)
+}
+
+func (r *ReferenceStorage) AppendReflog(n plumbing.ReferenceName, e *reflog.Entry) error {
+	if r.reftable != nil {
+		return r.reftable.AppendReflog(n, e)
+	}
+
+	return r.dir.AppendReflog(n, e)
+}
+
+func (r *ReferenceStorage) SetReflog(n plumbing.ReferenceName, entries []*reflog.Entry) error {
+	if r.reftable != nil {
+		return r.reftable.SetReflog(n, entries)
+	}
+
+	return r.dir.SetReflog(n, entries)
+}
+
+func (r *ReferenceStorage) DeleteReflog(n plumbing.ReferenceName) error {
+	if r.reftable != nil {
+		return r.reftable.DeleteReflog(n)
+	}
+
+	return r.dir.DeleteReflog(n)
+}
@@ -0,0 +1,760 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/format/reflog"
+	"github.com/goabstract/go-git/v5/plumbing/format/reftable"
+	"github.com/goabstract/go-git/v5/plumbing/storer"
+	"github.com/goabstract/go-git/v5/storage"
+	"github.com/goabstract/go-git/v5/utils/ioutil"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// ErrReftableLocked is returned updating the references of a reftable
+// repository while another process is updating them.
+var ErrReftableLocked = errors.New("reftable stack is locked")
+
+const (
+	reftablePath       = "reftable"
+	tablesListPath     = "reftable/tables.list"
+	tablesListLockPath = tablesListPath + ".lock"
+	tableNameFormat    = "0x%012x-0x%012x-%08x.ref"
+	tmpTablePrefix     = "tmp_table_"
+
+	extensionsSection  = "extensions"
+	refStorageKey      = "refStorage"
+	coreSection        = "core"
+	formatVersionKey   = "repositoryformatversion"
+	reftableRefStorage = "reftable"
+	invalidHEAD        = "ref: refs/heads/.invalid\n"
+
+	// compactionFactor is the geometric factor the sizes of the tables of
+	// the stack are kept at, as git does.
+	compactionFactor = 2
+	// maxReloads is how many times the stack is read again when one of its
+	// tables is removed, by a compaction, while reading it.
+	maxReloads = 3
+)
+
+// ReftableStorage stores the references, and their reflogs, in a stack of
+// reftables, as the repositories with extensions.refStorage=reftable do.
+// Every update adds a new table on top of the stack, so its cost doesn't
+// depend on the number of references, and the tables are compacted as they
+// pile up, keeping their sizes a geometric sequence. The references and the
+// reflogs are looked up in the tables, from the newest one, reading only the
+// blocks holding them.
+type ReftableStorage struct {
+	fs billy.Filesystem
+
+	// m serializes the updates of the process, the lock file of the stack
+	// only keeps out the other ones.
+	m sync.Mutex
+}
+
+// stackTable is a table of the stack, open for reading.
+type stackTable struct {
+	name   string
+	size   int64
+	file   billy.File
+	reader *reftable.Reader
+}
+
+// reftableStack are the tables of the stack, the oldest first.
+type reftableStack []*stackTable
+
+// NewReftableStorage returns a ReftableStorage reading and writing the
+// reftables of the repository stored in fs.
+func NewReftableStorage(fs billy.Filesystem) *ReftableStorage {
+	return &ReftableStorage{fs: fs}
+}
+
+// usesReftable returns true if the repository stored in fs keeps its
+// references in reftables.
+func usesReftable(fs billy.Filesystem, cs *ConfigStorage) bool {
+	if _, err := fs.Stat(reftablePath); err != nil {
+		return false
+	}
+
+	cfg, err := cs.Config()
+	if err != nil {
+		return false
+	}
+
+	return cfg.Raw.Section(extensionsSection).Option(refStorageKey) == reftableRefStorage
+}
+
+// Init creates an empty stack of tables, and sets the configuration of the
+// repository to use it. HEAD is left pointing to an invalid branch, so the
+// versions of git without reftable support don't see a valid repository.
+func (s *ReftableStorage) Init(cs *ConfigStorage) error {
+	if _, err := s.fs.Stat(tablesListPath); err == nil {
+		return nil
+	}
+
+	if err := s.fs.MkdirAll(reftablePath, os.ModeDir|os.ModePerm); err != nil {
+		return err
+	}
+
+	if err := writeFile(s.fs, tablesListPath, nil); err != nil {
+		return err
+	}
+
+	if err := writeFile(s.fs, plumbing.HEAD.String(), []byte(invalidHEAD)); err != nil {
+		return err
+	}
+
+	cfg, err := cs.Config()
+	if err != nil {
+		return err
+	}
+
+	cfg.Raw.Section(coreSection).SetOption(formatVersionKey, "1")
+	cfg.Raw.Section(extensionsSection).SetOption(refStorageKey, reftableRefStorage)
+	return cs.SetConfig(cfg)
+}
+
+func writeFile(fs billy.Filesystem, path string, content []byte) (err error) {
+	f, err := fs.Create(path)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	_, err = f.Write(content)
+	return err
+}
+
+func (s *ReftableStorage) SetReference(ref *plumbing.Reference) error {
+	return s.CheckAndSetReference(ref, nil)
+}
+
+func (s *ReftableStorage) CheckAndSetReference(ref, old *plumbing.Reference) error {
+	return s.update(func(t *reftable.Table, stack reftableStack) error {
+		if old != nil {
+			current, err := stack.reference(old.Name().String())
+			if err != nil {
+				return err
+			}
+
+			if current != nil && current.Reference().Hash() != old.Hash() {
+				return storage.ErrReferenceHasChanged
+			}
+		}
+
+		t.Refs = append(t.Refs, reftable.NewRefRecord(ref, t.MinUpdateIndex))
+		return nil
+	}, false)
+}
+
+func (s *ReftableStorage) Reference(n plumbing.ReferenceName) (*plumbing.Reference, error) {
+	stack, err := s.openStack()
+	if err != nil {
+		return nil, err
+	}
+
+	defer stack.close()
+	r, err := stack.reference(n.String())
+	if err != nil {
+		return nil, err
+	}
+
+	if r == nil {
+		return nil, plumbing.ErrReferenceNotFound
+	}
+
+	return r.Reference(), nil
+}
+
+func (s *ReftableStorage) IterReferences() (storer.ReferenceIter, error) {
+	stack, err := s.openStack()
+	if err != nil {
+		return nil, err
+	}
+
+	defer stack.close()
+	merged := make(map[string]*reftable.RefRecord)
+	for _, t := range stack {
+		records, err := t.reader.Refs()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range records {
+			if r.Deleted {
+				delete(merged, r.RefName)
+				continue
+			}
+
+			merged[r.RefName] = r
+		}
+	}
+
+	refs := make([]*plumbing.Reference, 0, len(merged))
+	for _, r := range merged {
+		refs = append(refs, r.Reference())
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Name() < refs[j].Name()
+	})
+
+	return storer.NewReferenceSliceIter(refs), nil
+}
+
+// RemoveReference removes the reference and its reflog.
+func (s *ReftableStorage) RemoveReference(n plumbing.ReferenceName) error {
+	return s.update(func(t *reftable.Table, stack reftableStack) error {
+		return removeReference(t, stack, n)
+	}, false)
+}
+
+// removeReference adds to t the deletion of a reference and its reflog.
+func removeReference(t *reftable.Table, stack reftableStack, n plumbing.ReferenceName) error {
+	r, err := stack.reference(n.String())
+	if err != nil {
+		return err
+	}
+
+	if r != nil {
+		t.Refs = append(t.Refs, &reftable.RefRecord{
+			RefName:     n.String(),
+			UpdateIndex: t.MinUpdateIndex,
+			Deleted:     true,
+		})
+	}
+
+	return deleteLogs(t, stack, n)
+}
+
+// CountLooseRefs returns zero, there are no loose references in a reftable
+// repository.
+func (s *ReftableStorage) CountLooseRefs() (int, error) {
+	return 0, nil
+}
+
+// PackRefs compacts all the tables of the stack into a single one.
+func (s *ReftableStorage) PackRefs() error {
+	return s.update(func(*reftable.Table, reftableStack) error { return nil }, true)
+}
+
+// UpdateReferences applies all the updates, or none of them if the value of
+// any of the references is not the expected one. The updates are written in
+// a single table, so the other readers see all of them at once.
+func (s *ReftableStorage) UpdateReferences(updates []storer.ReferenceUpdate) error {
+	if err := storer.CheckReferenceUpdates(updates); err != nil {
+		return err
+	}
+
+	return s.update(func(t *reftable.Table, stack reftableStack) error {
+		for _, u := range updates {
+			r, err := stack.reference(u.Name.String())
+			if err != nil {
+				return err
+			}
+
+			var current *plumbing.Reference
+			if r != nil {
+				current = r.Reference()
+			}
+
+			if !u.Check(current) {
+				return storage.ErrReferenceHasChanged
+			}
+
+			switch u.Action {
+			case storer.CreateReference, storer.UpdateReference:
+				t.Refs = append(t.Refs, reftable.NewRefRecord(u.New, t.MinUpdateIndex))
+			case storer.DeleteReference:
+				if err := removeReference(t, stack, u.Name); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}, false)
+}
+
+func (s *ReftableStorage) Reflog(n plumbing.ReferenceName) ([]*reflog.Entry, error) {
+	stack, err := s.openStack()
+	if err != nil {
+		return nil, err
+	}
+
+	defer stack.close()
+	logs, err := stack.logs(n.String())
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(logs, func(i, j int) bool { return logs[i].UpdateIndex < logs[j].UpdateIndex })
+
+	var entries []*reflog.Entry
+	for _, l := range logs {
+		entries = append(entries, &reflog.Entry{
+			Old:       l.Old,
+			New:       l.New,
+			Committer: l.Committer,
+			Message:   strings.TrimSuffix(l.Message, "\n"),
+		})
+	}
+
+	return entries, nil
+}
+
+func (s *ReftableStorage) HasReflog(n plumbing.ReferenceName) (bool, error) {
+	stack, err := s.openStack()
+	if err != nil {
+		return false, err
+	}
+
+	defer stack.close()
+	logs, err := stack.logs(n.String())
+	return len(logs) > 0, err
+}
+
+func (s *ReftableStorage) AppendReflog(n plumbing.ReferenceName, e *reflog.Entry) error {
+	return s.update(func(t *reftable.Table, _ reftableStack) error {
+		addLog(t, n, e)
+		return nil
+	}, false)
+}
+
+func (s *ReftableStorage) SetReflog(n plumbing.ReferenceName, entries []*reflog.Entry) error {
+	return s.update(func(t *reftable.Table, stack reftableStack) error {
+		if err := deleteLogs(t, stack, n); err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			addLog(t, n, e)
+		}
+
+		return nil
+	}, false)
+}
+
+func (s *ReftableStorage) DeleteReflog(n plumbing.ReferenceName) error {
+	return s.update(func(t *reftable.Table, stack reftableStack) error {
+		return deleteLogs(t, stack, n)
+	}, false)
+}
+
+// deleteLogs adds to t the tombstones of the reflog entries of a reference.
+func deleteLogs(t *reftable.Table, stack reftableStack, n plumbing.ReferenceName) error {
+	logs, err := stack.logs(n.String())
+	if err != nil {
+		return err
+	}
+
+	for _, l := range logs {
+		t.Logs = append(t.Logs, &reftable.LogRecord{
+			RefName:     n.String(),
+			UpdateIndex: l.UpdateIndex,
+			Deleted:     true,
+		})
+	}
+
+	return nil
+}
+
+// addLog adds a reflog entry to t, with the first update index of the table
+// not used by the reference yet.
+func addLog(t *reftable.Table, n plumbing.ReferenceName, e *reflog.Entry) {
+	index := t.MinUpdateIndex
+	for _, l := range t.Logs {
+		if l.RefName == n.String() && !l.Deleted && l.UpdateIndex >= index {
+			index = l.UpdateIndex + 1
+		}
+	}
+
+	if index > t.MaxUpdateIndex {
+		t.MaxUpdateIndex = index
+	}
+
+	// as git, the messages are stored in a single line, ending with LF
+	msg := strings.TrimRight(e.Message, "\n")
+	msg = strings.Replace(msg, "\n", " ", -1) + "\n"
+
+	c := e.Committer
+	if c.When.Unix() < 0 {
+		c.When = time.Unix(0, 0).In(c.When.Location())
+	}
+
+	t.Logs = append(t.Logs, &reftable.LogRecord{
+		RefName:     n.String(),
+		UpdateIndex: index,
+		Old:         e.Old,
+		New:         e.New,
+		Committer:   c,
+		Message:     msg,
+	})
+}
+
+// update writes a new table, filled by fn, on top of the stack, and then
+// compacts the stack, all of it if full is true. Nothing is written if fn
+// returns an error, or adds no records.
+func (s *ReftableStorage) update(fn func(*reftable.Table, reftableStack) error, full bool) (err error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	lock, err := s.fs.OpenFile(tablesListLockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if os.IsExist(err) {
+		return ErrReftableLocked
+	}
+
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			_ = lock.Close()
+			_ = s.fs.Remove(tablesListLockPath)
+		}
+	}()
+
+	stack, err := s.openStack()
+	if err != nil {
+		return err
+	}
+
+	defer stack.close()
+
+	next := uint64(1)
+	if len(stack) != 0 {
+		next = stack[len(stack)-1].reader.MaxUpdateIndex() + 1
+	}
+
+	t := &reftable.Table{MinUpdateIndex: next, MaxUpdateIndex: next}
+	if err := fn(t, stack); err != nil {
+		return err
+	}
+
+	sizes := make([]int64, len(stack), len(stack)+1)
+	for i, t := range stack {
+		sizes[i] = t.size
+	}
+
+	names := make([]string, 0, len(stack)+1)
+	for _, t := range stack {
+		names = append(names, t.name)
+	}
+
+	tables := make([]*reftable.Table, len(stack), len(stack)+1)
+	added := len(t.Refs) != 0 || len(t.Logs) != 0
+	if added {
+		name, size, err := s.writeTable(t)
+		if err != nil {
+			return err
+		}
+
+		names = append(names, name)
+		sizes = append(sizes, size)
+		tables = append(tables, t)
+	}
+
+	start, end := 0, len(names)
+	if !full {
+		start, end = compactionSegment(sizes)
+	}
+
+	var compacted []string
+	if end-start > 1 {
+		for i := start; i < end; i++ {
+			if tables[i] != nil {
+				continue
+			}
+
+			if tables[i], err = stack[i].reader.Table(); err != nil {
+				return err
+			}
+		}
+
+		merged, _, err := s.writeTable(mergeTables(tables[start:end], start == 0))
+		if err != nil {
+			return err
+		}
+
+		compacted = names[start:end]
+		names = append(append(append([]string(nil), names[:start]...), merged), names[end:]...)
+	}
+
+	if !added && compacted == nil {
+		return nil
+	}
+
+	var list bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintln(&list, name)
+	}
+
+	if _, err := lock.Write(list.Bytes()); err != nil {
+		return err
+	}
+
+	if err := lock.Close(); err != nil {
+		return err
+	}
+
+	committed = true
+	if err := s.fs.Rename(tablesListLockPath, tablesListPath); err != nil {
+		_ = s.fs.Remove(tablesListLockPath)
+		return err
+	}
+
+	stack.close()
+	for _, name := range compacted {
+		_ = s.fs.Remove(s.fs.Join(reftablePath, name))
+	}
+
+	return nil
+}
+
+// writeTable writes a new table, not added to the stack yet, returning its
+// name and its size.
+func (s *ReftableStorage) writeTable(t *reftable.Table) (string, int64, error) {
+	t.Sort()
+
+	var buf bytes.Buffer
+	if err := reftable.NewEncoder(&buf).Encode(t); err != nil {
+		return "", 0, err
+	}
+
+	f, err := s.fs.TempFile(reftablePath, tmpTablePrefix)
+	if err != nil {
+		return "", 0, err
+	}
+
+	_, err = f.Write(buf.Bytes())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	name := fmt.Sprintf(tableNameFormat, t.MinUpdateIndex, t.MaxUpdateIndex, rand.Uint32())
+	if err == nil {
+		err = s.fs.Rename(f.Name(), s.fs.Join(reftablePath, name))
+	}
+
+	if err != nil {
+		_ = s.fs.Remove(f.Name())
+		return "", 0, err
+	}
+
+	return name, int64(buf.Len()), nil
+}
+
+// openStack opens the tables of the stack, read again when one of them is
+// removed, by a compaction, while opening them.
+func (s *ReftableStorage) openStack() (reftableStack, error) {
+	for i := 0; ; i++ {
+		names, err := s.readTablesList()
+		if err != nil {
+			return nil, err
+		}
+
+		stack, err := s.openTables(names)
+		if os.IsNotExist(err) && i < maxReloads {
+			continue
+		}
+
+		return stack, err
+	}
+}
+
+func (s *ReftableStorage) readTablesList() (names []string, err error) {
+	f, err := s.fs.Open(tablesListPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names, scanner.Err()
+}
+
+func (s *ReftableStorage) openTables(names []string) (reftableStack, error) {
+	stack := make(reftableStack, 0, len(names))
+	for _, name := range names {
+		t, err := s.openTable(name)
+		if err != nil {
+			stack.close()
+			return nil, err
+		}
+
+		stack = append(stack, t)
+	}
+
+	return stack, nil
+}
+
+func (s *ReftableStorage) openTable(name string) (*stackTable, error) {
+	path := s.fs.Join(reftablePath, name)
+	f, err := s.fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := s.fs.Stat(path)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	r, err := reftable.NewReader(f, fi.Size())
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return &stackTable{name: name, size: fi.Size(), file: f, reader: r}, nil
+}
+
+// close closes the files of the tables, it can be called more than once.
+func (s reftableStack) close() {
+	for _, t := range s {
+		if t.file != nil {
+			_ = t.file.Close()
+			t.file = nil
+		}
+	}
+}
+
+// reference returns the newest record of a reference, nil if the reference
+// doesn't exist or it was deleted.
+func (s reftableStack) reference(name string) (*reftable.RefRecord, error) {
+	for i := len(s) - 1; i >= 0; i-- {
+		r, err := s[i].reader.Ref(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if r == nil {
+			continue
+		}
+
+		if r.Deleted {
+			return nil, nil
+		}
+
+		return r, nil
+	}
+
+	return nil, nil
+}
+
+// logs returns the reflog entries of a reference, the newest record of
+// every update index, unless it is a deletion.
+func (s reftableStack) logs(name string) ([]*reftable.LogRecord, error) {
+	seen := make(map[uint64]bool)
+	var logs []*reftable.LogRecord
+	for i := len(s) - 1; i >= 0; i-- {
+		records, err := s[i].reader.Logs(name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, l := range records {
+			if seen[l.UpdateIndex] {
+				continue
+			}
+
+			seen[l.UpdateIndex] = true
+			if !l.Deleted {
+				logs = append(logs, l)
+			}
+		}
+	}
+
+	return logs, nil
+}
+
+// compactionSegment returns the range of tables to compact to keep the
+// sizes of the tables a geometric sequence, every table at least twice as
+// large as the next one, as git does on every update. The range is empty
+// when no compaction is needed.
+func compactionSegment(sizes []int64) (start, end int) {
+	i := len(sizes) - 1
+	var total int64
+	for ; i > 0; i-- {
+		if sizes[i-1] < sizes[i]*compactionFactor {
+			end, total = i+1, sizes[i]
+			break
+		}
+	}
+
+	for ; i > 0; i-- {
+		current := total
+		total += sizes[i-1]
+		if sizes[i-1] < current*compactionFactor {
+			start = i - 1
+		}
+	}
+
+	return start, end
+}
+
+// mergeTables merges the records of consecutive tables of the stack, the
+// newest record of every reference and reflog entry wins. The deletions are
+// only needed to hide the records of older tables, so they are dropped if
+// there are none.
+func mergeTables(tables []*reftable.Table, dropDeletions bool) *reftable.Table {
+	refs := make(map[string]*reftable.RefRecord)
+	type logKey struct {
+		name  string
+		index uint64
+	}
+
+	logs := make(map[logKey]*reftable.LogRecord)
+	for _, t := range tables {
+		for _, r := range t.Refs {
+			refs[r.RefName] = r
+		}
+
+		for _, l := range t.Logs {
+			logs[logKey{l.RefName, l.UpdateIndex}] = l
+		}
+	}
+
+	merged := &reftable.Table{
+		MinUpdateIndex: tables[0].MinUpdateIndex,
+		MaxUpdateIndex: tables[len(tables)-1].MaxUpdateIndex,
+	}
+
+	for _, r := range refs {
+		if !r.Deleted || !dropDeletions {
+			merged.Refs = append(merged.Refs, r)
+		}
+	}
+
+	for _, l := range logs {
+		if !l.Deleted || !dropDeletions {
+			merged.Logs = append(merged.Logs, l)
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,295 @@
+package filesystem
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/cache"
+	"github.com/goabstract/go-git/v5/plumbing/format/reflog"
+	"github.com/goabstract/go-git/v5/plumbing/storer"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	. "gopkg.in/check.v1"
+)
+
+type StorageReftableSuite struct {
+	StorageSuite
+}
+
+var _ = Suite(&StorageReftableSuite{})
+
+func (s *StorageReftableSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	storage := NewStorageWithOptions(
+		osfs.New(s.dir),
+		cache.NewObjectLRUDefault(),
+		Options{Reftable: true})
+
+	c.Assert(storage.Init(), IsNil)
+	setUpTest(&s.StorageSuite, c, storage)
+}
+
+func (s *StorageReftableSuite) TestNewStorageShouldNotAddAnyContentsToDir(c *C) {
+	c.Skip("the storage is initialized")
+}
+
+type ReftableSuite struct {
+	fs billy.Filesystem
+	s  *Storage
+}
+
+var _ = Suite(&ReftableSuite{})
+
+func (s *ReftableSuite) SetUpTest(c *C) {
+	s.fs = memfs.New()
+	s.s = NewStorageWithOptions(s.fs, cache.NewObjectLRUDefault(), Options{Reftable: true})
+	c.Assert(s.s.Init(), IsNil)
+}
+
+func (s *ReftableSuite) tables(c *C) []string {
+	names, err := NewReftableStorage(s.fs).readTablesList()
+	c.Assert(err, IsNil)
+	return names
+}
+
+func (s *ReftableSuite) TestInit(c *C) {
+	cfg, err := s.s.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Raw.Section("extensions").Option("refStorage"), Equals, "reftable")
+	c.Assert(cfg.Raw.Section("core").Option("repositoryformatversion"), Equals, "1")
+
+	f, err := s.fs.Open("HEAD")
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+	c.Assert(string(content), Equals, "ref: refs/heads/.invalid\n")
+
+	_, err = s.s.Reference(plumbing.HEAD)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+	c.Assert(s.tables(c), HasLen, 0)
+}
+
+func (s *ReftableSuite) TestInitExistingRepository(c *C) {
+	fs := memfs.New()
+	sto := NewStorage(fs, cache.NewObjectLRUDefault())
+	c.Assert(sto.Init(), IsNil)
+	c.Assert(sto.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Master)), IsNil)
+
+	sto = NewStorageWithOptions(fs, cache.NewObjectLRUDefault(), Options{Reftable: true})
+	c.Assert(sto.Init(), IsNil)
+	c.Assert(sto.ReferenceStorage.reftable, IsNil)
+
+	_, err := fs.Stat(reftablePath)
+	c.Assert(err, NotNil)
+}
+
+func (s *ReftableSuite) TestReopen(c *C) {
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Master)
+	master := plumbing.NewReferenceFromStrings("refs/heads/master", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	c.Assert(s.s.SetReference(head), IsNil)
+	c.Assert(s.s.SetReference(master), IsNil)
+
+	sto := NewStorage(s.fs, cache.NewObjectLRUDefault())
+	c.Assert(sto.ReferenceStorage.reftable, NotNil)
+
+	ref, err := sto.Reference(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(ref, DeepEquals, head)
+
+	iter, err := sto.IterReferences()
+	c.Assert(err, IsNil)
+
+	var refs []*plumbing.Reference
+	c.Assert(iter.ForEach(func(r *plumbing.Reference) error {
+		refs = append(refs, r)
+		return nil
+	}), IsNil)
+
+	c.Assert(refs, DeepEquals, []*plumbing.Reference{head, master})
+
+	// the loose references are ignored
+	_, err = s.fs.Stat("refs/heads/master")
+	c.Assert(err, NotNil)
+}
+
+func (s *ReftableSuite) TestUpdatesFromOtherStorage(c *C) {
+	other := NewStorage(s.fs, cache.NewObjectLRUDefault())
+
+	foo := plumbing.NewReferenceFromStrings("refs/heads/foo", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	c.Assert(s.s.SetReference(foo), IsNil)
+
+	ref, err := other.Reference(foo.Name())
+	c.Assert(err, IsNil)
+	c.Assert(ref, DeepEquals, foo)
+
+	c.Assert(other.RemoveReference(foo.Name()), IsNil)
+	_, err = s.s.Reference(foo.Name())
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *ReftableSuite) TestUpdateReferences(c *C) {
+	foo := plumbing.NewReferenceFromStrings("refs/heads/foo", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	bar := plumbing.NewReferenceFromStrings("refs/heads/bar", "918c48b83bd081e863dbe1b80f8998f058cd8294")
+	c.Assert(s.s.UpdateReferences([]storer.ReferenceUpdate{
+		{Action: storer.CreateReference, Name: foo.Name(), New: foo},
+		{Action: storer.CreateReference, Name: bar.Name(), New: bar},
+	}), IsNil)
+
+	// a single table is written
+	c.Assert(s.tables(c), HasLen, 1)
+}
+
+func (s *ReftableSuite) TestLocked(c *C) {
+	fs := osfs.New(c.MkDir())
+	sto := NewStorageWithOptions(fs, cache.NewObjectLRUDefault(), Options{Reftable: true})
+	c.Assert(sto.Init(), IsNil)
+	c.Assert(writeFile(fs, tablesListLockPath, nil), IsNil)
+
+	foo := plumbing.NewReferenceFromStrings("refs/heads/foo", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	c.Assert(sto.SetReference(foo), Equals, ErrReftableLocked)
+
+	c.Assert(fs.Remove(tablesListLockPath), IsNil)
+	c.Assert(sto.SetReference(foo), IsNil)
+
+	_, err := fs.Stat(tablesListLockPath)
+	c.Assert(err, NotNil)
+}
+
+func (s *ReftableSuite) TestAutoCompaction(c *C) {
+	for i := 0; i < 100; i++ {
+		ref := plumbing.NewHashReference(
+			plumbing.ReferenceName(fmt.Sprintf("refs/tags/v%d", i)),
+			plumbing.ComputeHash(plumbing.BlobObject, []byte(fmt.Sprint(i))),
+		)
+
+		c.Assert(s.s.SetReference(ref), IsNil)
+	}
+
+	tables := s.tables(c)
+	c.Assert(len(tables) < 10, Equals, true, Commentf("%d tables", len(tables)))
+
+	files, err := s.fs.ReadDir(reftablePath)
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, len(tables)+1)
+
+	c.Assert(s.s.RemoveReference("refs/tags/v5"), IsNil)
+	c.Assert(s.s.PackRefs(), IsNil)
+	c.Assert(s.tables(c), HasLen, 1)
+
+	// the deletions are dropped compacting the whole stack
+	stack, err := NewReftableStorage(s.fs).openStack()
+	c.Assert(err, IsNil)
+	defer stack.close()
+
+	t, err := stack[0].reader.Table()
+	c.Assert(err, IsNil)
+	c.Assert(t.Refs, HasLen, 99)
+	c.Assert(t.MinUpdateIndex, Equals, uint64(1))
+	c.Assert(t.MaxUpdateIndex, Equals, uint64(101))
+
+	ref, err := s.s.Reference("refs/tags/v99")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, plumbing.ComputeHash(plumbing.BlobObject, []byte("99")))
+}
+
+func (s *ReftableSuite) TestReflog(c *C) {
+	name := plumbing.ReferenceName("refs/heads/foo")
+	committer := reflog.Signature{
+		Name:  "foo",
+		Email: "foo@foo.foo",
+		When:  time.Unix(1494108223, 0).In(time.FixedZone("", 2*60*60)),
+	}
+
+	for i := 0; i < 40; i++ {
+		c.Assert(s.s.AppendReflog(name, &reflog.Entry{
+			New:       plumbing.ComputeHash(plumbing.BlobObject, []byte(fmt.Sprint(i))),
+			Committer: committer,
+			Message:   fmt.Sprintf("commit: %d\n", i),
+		}), IsNil)
+	}
+
+	entries, err := s.s.Reflog(name)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 40)
+	c.Assert(entries[0].Message, Equals, "commit: 0")
+	c.Assert(entries[39].Message, Equals, "commit: 39")
+	c.Assert(entries[39].Committer.When.Equal(committer.When), Equals, true)
+
+	c.Assert(s.s.SetReflog(name, entries[38:]), IsNil)
+	c.Assert(s.s.PackRefs(), IsNil)
+
+	entries, err = NewStorage(s.fs, cache.NewObjectLRUDefault()).Reflog(name)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Assert(entries[0].Message, Equals, "commit: 38")
+	c.Assert(entries[1].Message, Equals, "commit: 39")
+}
+
+func (s *ReftableSuite) TestDeletionsHideOlderTables(c *C) {
+	name := plumbing.ReferenceName("refs/heads/foo")
+	hash := plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47")
+
+	// a large first table, so the deletion isn't compacted with it
+	updates := []storer.ReferenceUpdate{{
+		Action: storer.CreateReference,
+		Name:   name,
+		New:    plumbing.NewHashReference(name, hash),
+	}}
+
+	for i := 0; i < 500; i++ {
+		ref := plumbing.NewHashReference(plumbing.ReferenceName(fmt.Sprintf("refs/tags/v%d", i)), hash)
+		updates = append(updates, storer.ReferenceUpdate{
+			Action: storer.CreateReference,
+			Name:   ref.Name(),
+			New:    ref,
+		})
+	}
+
+	c.Assert(s.s.UpdateReferences(updates), IsNil)
+	c.Assert(s.s.AppendReflog(name, &reflog.Entry{New: hash, Message: "foo"}), IsNil)
+	c.Assert(s.s.RemoveReference(name), IsNil)
+	c.Assert(len(s.tables(c)) > 1, Equals, true)
+
+	_, err := s.s.Reference(name)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	entries, err := s.s.Reflog(name)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+
+	iter, err := s.s.IterReferences()
+	c.Assert(err, IsNil)
+
+	var names []plumbing.ReferenceName
+	c.Assert(iter.ForEach(func(r *plumbing.Reference) error {
+		names = append(names, r.Name())
+		return nil
+	}), IsNil)
+
+	c.Assert(names, HasLen, 500)
+	c.Assert(names[0], Equals, plumbing.ReferenceName("refs/tags/v0"))
+}
+
+func (s *ReftableSuite) TestCompactionSegment(c *C) {
+	for _, t := range []struct {
+		sizes      []int64
+		start, end int
+	}{
+		{nil, 0, 0},
+		{[]int64{64}, 0, 0},
+		{[]int64{64, 32, 16, 8, 4, 2, 1}, 0, 0},
+		{[]int64{64, 32, 16, 8, 4, 3, 1}, 0, 6},
+		{[]int64{128, 32, 16, 8, 4, 3, 1}, 1, 6},
+		{[]int64{1000, 100, 10, 1, 1}, 3, 5},
+		{[]int64{1, 1}, 0, 2},
+	} {
+		start, end := compactionSegment(t.sizes)
+		c.Assert(start, Equals, t.start, Commentf("%v", t.sizes))
+		c.Assert(end, Equals, t.end, Commentf("%v", t.sizes))
+	}
+}
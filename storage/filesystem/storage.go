@@ -2,6 +2,7 @@
 package filesystem
 
 import (
+	"github.com/goabstract/go-git/v5/plumbing"
 	"github.com/goabstract/go-git/v5/plumbing/cache"
 	"github.com/goabstract/go-git/v5/storage/filesystem/dotgit"
 
@@ -12,8 +13,9 @@ import (
 // standard git format (this is, the .git directory). Zero values of this type
 // are not safe to use, see the NewStorage function below.
 type Storage struct {
-	fs  billy.Filesystem
-	dir *dotgit.DotGit
+	fs      billy.Filesystem
+	dir     *dotgit.DotGit
+	options Options
 
 	ObjectStorage
 	ReferenceStorage
@@ -34,6 +36,10 @@ type Options struct {
 	// MaxOpenDescriptors is the max number of file descriptors to keep
 	// open. If KeepDescriptors is true, all file descriptors will remain open.
 	MaxOpenDescriptors int
+	// Reftable makes Init create the new repositories with their references
+	// stored in reftables, as `git init --ref-format=reftable`. The existing
+	// repositories use the format set in their configuration.
+	Reftable bool
 }
 
 // NewStorage returns a new Storage backed by a given `fs.Filesystem` and cache.
@@ -49,9 +55,10 @@ func NewStorageWithOptions(fs billy.Filesystem, cache cache.Object, ops Options)
 	}
 	dir := dotgit.NewWithOptions(fs, dirOps)
 
-	return &Storage{
-		fs:  fs,
-		dir: dir,
+	s := &Storage{
+		fs:      fs,
+		dir:     dir,
+		options: ops,
 
 		ObjectStorage:    *NewObjectStorageWithOptions(dir, cache, ops),
 		ReferenceStorage: ReferenceStorage{dir: dir},
@@ -60,6 +67,12 @@ func NewStorageWithOptions(fs billy.Filesystem, cache cache.Object, ops Options)
 		ConfigStorage:    ConfigStorage{dir: dir},
 		ModuleStorage:    ModuleStorage{dir: dir},
 	}
+
+	if usesReftable(fs, &s.ConfigStorage) {
+		s.ReferenceStorage.reftable = NewReftableStorage(fs)
+	}
+
+	return s
 }
 
 // Filesystem returns the underlying filesystem
@@ -69,5 +82,24 @@ func (s *Storage) Filesystem() billy.Filesystem {
 
 // Init initializes .git directory
 func (s *Storage) Init() error {
-	return s.dir.Initialize()
+	if err := s.dir.Initialize(); err != nil {
+		return err
+	}
+
+	if !s.options.Reftable || s.ReferenceStorage.reftable != nil {
+		return nil
+	}
+
+	// an existing repository keeps its format
+	if _, err := s.fs.Stat(plumbing.HEAD.String()); err == nil {
+		return nil
+	}
+
+	rs := NewReftableStorage(s.fs)
+	if err := rs.Init(&s.ConfigStorage); err != nil {
+		return err
+	}
+
+	s.ReferenceStorage.reftable = rs
+	return nil
 }
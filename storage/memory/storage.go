@@ -3,11 +3,13 @@ package memory
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/goabstract/go-git/v5/config"
 	"github.com/goabstract/go-git/v5/plumbing"
 	"github.com/goabstract/go-git/v5/plumbing/format/index"
+	"github.com/goabstract/go-git/v5/plumbing/format/reflog"
 	"github.com/goabstract/go-git/v5/plumbing/storer"
 	"github.com/goabstract/go-git/v5/storage"
 )
@@ -24,6 +26,7 @@ type Storage struct {
 	ShallowStorage
 	IndexStorage
 	ReferenceStorage
+	ReflogStorage
 	ModuleStorage
 }
 
@@ -31,6 +34,7 @@ type Storage struct {
 func NewStorage() *Storage {
 	return &Storage{
 		ReferenceStorage: make(ReferenceStorage),
+		ReflogStorage:    make(ReflogStorage),
 		ConfigStorage:    ConfigStorage{},
 		ShallowStorage:   ShallowStorage{},
 		ObjectStorage: ObjectStorage{
@@ -186,6 +190,19 @@ func (o *ObjectStorage) ForEachObjectHash(fun func(plumbing.Hash) error) error {
 	return nil
 }
 
+// HashesWithPrefix returns the hashes of the stored objects starting with
+// the given hexadecimal prefix.
+func (o *ObjectStorage) HashesWithPrefix(prefix string) ([]plumbing.Hash, error) {
+	var hashes []plumbing.Hash
+	for h := range o.Objects {
+		if strings.HasPrefix(h.String(), prefix) {
+			hashes = append(hashes, h)
+		}
+	}
+
+	return hashes, nil
+}
+
 func (o *ObjectStorage) ObjectPacks() ([]plumbing.Hash, error) {
 	return nil, nil
 }
@@ -282,6 +299,31 @@ func (r ReferenceStorage) IterReferences() (storer.ReferenceIter, error) {
 	return storer.NewReferenceSliceIter(refs), nil
 }
 
+// UpdateReferences applies all the updates, or none of them if any of the
+// references hasn't the expected value.
+func (r ReferenceStorage) UpdateReferences(updates []storer.ReferenceUpdate) error {
+	if err := storer.CheckReferenceUpdates(updates); err != nil {
+		return err
+	}
+
+	for _, u := range updates {
+		if !u.Check(r[u.Name]) {
+			return storage.ErrReferenceHasChanged
+		}
+	}
+
+	for _, u := range updates {
+		switch u.Action {
+		case storer.CreateReference, storer.UpdateReference:
+			r[u.Name] = u.New
+		case storer.DeleteReference:
+			delete(r, u.Name)
+		}
+	}
+
+	return nil
+}
+
 func (r ReferenceStorage) CountLooseRefs() (int, error) {
 	return len(r), nil
 }
@@ -295,6 +337,60 @@ func (r ReferenceStorage) RemoveReference(n plumbing.ReferenceName) error {
 	return nil
 }
 
+// RemoveReference removes the reference and its reflog.
+func (s *Storage) RemoveReference(n plumbing.ReferenceName) error {
+	if err := s.ReflogStorage.DeleteReflog(n); err != nil {
+		return err
+	}
+
+	return s.ReferenceStorage.RemoveReference(n)
+}
+
+// UpdateReferences applies all the updates, or none of them, removing the
+// reflogs of the deleted references.
+func (s *Storage) UpdateReferences(updates []storer.ReferenceUpdate) error {
+	if err := s.ReferenceStorage.UpdateReferences(updates); err != nil {
+		return err
+	}
+
+	for _, u := range updates {
+		if u.Action != storer.DeleteReference {
+			continue
+		}
+
+		if err := s.ReflogStorage.DeleteReflog(u.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type ReflogStorage map[plumbing.ReferenceName][]*reflog.Entry
+
+func (r ReflogStorage) Reflog(n plumbing.ReferenceName) ([]*reflog.Entry, error) {
+	return append([]*reflog.Entry(nil), r[n]...), nil
+}
+
+func (r ReflogStorage) HasReflog(n plumbing.ReferenceName) (bool, error) {
+	return len(r[n]) > 0, nil
+}
+
+func (r ReflogStorage) AppendReflog(n plumbing.ReferenceName, e *reflog.Entry) error {
+	r[n] = append(r[n], e)
+	return nil
+}
+
+func (r ReflogStorage) SetReflog(n plumbing.ReferenceName, entries []*reflog.Entry) error {
+	r[n] = append([]*reflog.Entry(nil), entries...)
+	return nil
+}
+
+func (r ReflogStorage) DeleteReflog(n plumbing.ReferenceName) error {
+	delete(r, n)
+	return nil
+}
+
 type ShallowStorage []plumbing.Hash
 
 func (s *ShallowStorage) SetShallow(commits []plumbing.Hash) error {
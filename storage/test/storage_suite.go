@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"sort"
+	"time"
 
 	"github.com/goabstract/go-git/v5/config"
 	"github.com/goabstract/go-git/v5/plumbing"
 	"github.com/goabstract/go-git/v5/plumbing/format/index"
+	"github.com/goabstract/go-git/v5/plumbing/format/reflog"
 	"github.com/goabstract/go-git/v5/plumbing/storer"
 	"github.com/goabstract/go-git/v5/storage"
 
@@ -175,6 +178,28 @@ func (s *BaseStorageSuite) TestPackfileWriter(c *C) {
 	c.Assert(objects, Equals, 31)
 }
 
+func (s *BaseStorageSuite) TestHashesWithPrefix(c *C) {
+	hps, ok := s.Storer.(storer.HashPrefixObjectStorer)
+	if !ok {
+		c.Skip("not a storer.HashPrefixObjectStorer")
+	}
+
+	for _, to := range s.testObjects {
+		_, err := s.Storer.SetEncodedObject(to.Object)
+		c.Assert(err, IsNil)
+	}
+
+	for _, to := range s.testObjects {
+		hashes, err := hps.HashesWithPrefix(to.Hash[:7])
+		c.Assert(err, IsNil)
+		c.Assert(hashes, DeepEquals, []plumbing.Hash{plumbing.NewHash(to.Hash)})
+	}
+
+	hashes, err := hps.HashesWithPrefix("")
+	c.Assert(err, IsNil)
+	c.Assert(hashes, HasLen, len(s.testObjects))
+}
+
 func (s *BaseStorageSuite) TestObjectStorerTxSetEncodedObjectAndCommit(c *C) {
 	storer, ok := s.Storer.(storer.Transactioner)
 	if !ok {
@@ -354,6 +379,164 @@ func (s *BaseStorageSuite) TestRemoveReferenceNonExistent(c *C) {
 	c.Assert(e.Hash().String(), Equals, "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
 }
 
+func (s *BaseStorageSuite) TestReflog(c *C) {
+	rs, ok := s.Storer.(storer.ReflogStorer)
+	if !ok {
+		c.Skip("not a storer.ReflogStorer")
+	}
+
+	name := plumbing.ReferenceName("refs/heads/foo")
+	entries, err := rs.Reflog(name)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+
+	exists, err := rs.HasReflog(name)
+	c.Assert(err, IsNil)
+	c.Assert(exists, Equals, false)
+
+	committer := reflog.Signature{Name: "foo", Email: "foo@foo.foo", When: time.Unix(1494108223, 0).UTC()}
+	first := &reflog.Entry{
+		New:       plumbing.NewHash("482e0eada5de4039e6f216b45b3c9b683b83bfa0"),
+		Committer: committer,
+		Message:   "branch: Created from HEAD",
+	}
+
+	second := &reflog.Entry{
+		Old:       first.New,
+		New:       plumbing.NewHash("bc9968d75e48de59f0870ffb71f5e160bbbdcf52"),
+		Committer: committer,
+		Message:   "commit: foo",
+	}
+
+	c.Assert(rs.AppendReflog(name, first), IsNil)
+	c.Assert(rs.AppendReflog(name, second), IsNil)
+
+	exists, err = rs.HasReflog(name)
+	c.Assert(err, IsNil)
+	c.Assert(exists, Equals, true)
+
+	entries, err = rs.Reflog(name)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Assert(entries[0].New, Equals, first.New)
+	c.Assert(entries[1].Old, Equals, first.New)
+	c.Assert(entries[1].Message, Equals, "commit: foo")
+	c.Assert(entries[1].Committer.Email, Equals, "foo@foo.foo")
+	c.Assert(entries[1].Committer.When.Unix(), Equals, int64(1494108223))
+
+	c.Assert(rs.SetReflog(name, []*reflog.Entry{second}), IsNil)
+	entries, err = rs.Reflog(name)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].Message, Equals, "commit: foo")
+
+	c.Assert(rs.DeleteReflog(name), IsNil)
+	c.Assert(rs.DeleteReflog(name), IsNil)
+	entries, err = rs.Reflog(name)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+
+	exists, err = rs.HasReflog(name)
+	c.Assert(err, IsNil)
+	c.Assert(exists, Equals, false)
+}
+
+func (s *BaseStorageSuite) TestRemoveReferenceDeletesReflog(c *C) {
+	rs, ok := s.Storer.(storer.ReflogStorer)
+	if !ok {
+		c.Skip("not a storer.ReflogStorer")
+	}
+
+	ref := plumbing.NewReferenceFromStrings("refs/heads/foo", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+	c.Assert(s.Storer.SetReference(ref), IsNil)
+	c.Assert(rs.AppendReflog(ref.Name(), &reflog.Entry{New: ref.Hash(), Message: "foo"}), IsNil)
+
+	c.Assert(s.Storer.RemoveReference(ref.Name()), IsNil)
+
+	entries, err := rs.Reflog(ref.Name())
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+}
+
+func (s *BaseStorageSuite) TestUpdateReferences(c *C) {
+	ts, ok := s.Storer.(storer.ReferenceTransactionStorer)
+	if !ok {
+		c.Skip("not a storer.ReferenceTransactionStorer")
+	}
+
+	foo := plumbing.NewReferenceFromStrings("refs/heads/foo", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+	bar := plumbing.NewReferenceFromStrings("refs/heads/bar", "c3f4688a08fd86f1bf8e055724c84b7a40a09733")
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, foo.Name())
+	c.Assert(ts.UpdateReferences([]storer.ReferenceUpdate{
+		{Action: storer.CreateReference, Name: foo.Name(), New: foo},
+		{Action: storer.CreateReference, Name: bar.Name(), New: bar},
+		{Action: storer.UpdateReference, Name: head.Name(), New: head},
+	}), IsNil)
+
+	moved := plumbing.NewHashReference(foo.Name(), bar.Hash())
+	for _, updates := range [][]storer.ReferenceUpdate{
+		{
+			{Action: storer.UpdateReference, Name: foo.Name(), New: moved, Old: foo},
+			{Action: storer.CreateReference, Name: bar.Name(), New: bar},
+		},
+		{
+			{Action: storer.UpdateReference, Name: foo.Name(), New: moved, Old: foo},
+			{Action: storer.DeleteReference, Name: bar.Name(), Old: foo},
+		},
+		{
+			{Action: storer.UpdateReference, Name: foo.Name(), New: moved, Old: foo},
+			{Action: storer.VerifyReference, Name: "refs/heads/qux", Old: foo},
+		},
+		{
+			{Action: storer.UpdateReference, Name: foo.Name(), New: moved},
+			{Action: storer.VerifyReference, Name: head.Name(), Old: plumbing.NewSymbolicReference(plumbing.HEAD, bar.Name())},
+		},
+	} {
+		c.Assert(ts.UpdateReferences(updates), Equals, storage.ErrReferenceHasChanged)
+
+		ref, err := s.Storer.Reference(foo.Name())
+		c.Assert(err, IsNil)
+		c.Assert(ref, DeepEquals, foo)
+	}
+
+	err := ts.UpdateReferences([]storer.ReferenceUpdate{
+		{Action: storer.UpdateReference, Name: foo.Name(), New: moved},
+		{Action: storer.DeleteReference, Name: foo.Name()},
+	})
+	c.Assert(err, Equals, storer.ErrDuplicatedReferenceUpdate)
+
+	err = ts.UpdateReferences([]storer.ReferenceUpdate{
+		{Action: storer.UpdateReference, Name: bar.Name(), New: moved},
+	})
+	c.Assert(err, Equals, storer.ErrInvalidReferenceUpdate)
+
+	c.Assert(ts.UpdateReferences([]storer.ReferenceUpdate{
+		{Action: storer.UpdateReference, Name: foo.Name(), New: moved, Old: foo},
+		{Action: storer.DeleteReference, Name: bar.Name(), Old: bar},
+		{Action: storer.VerifyReference, Name: head.Name(), Old: head},
+		{Action: storer.VerifyReference, Name: "refs/heads/qux"},
+	}), IsNil)
+
+	ref, err := s.Storer.Reference(foo.Name())
+	c.Assert(err, IsNil)
+	c.Assert(ref, DeepEquals, moved)
+
+	_, err = s.Storer.Reference(bar.Name())
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	iter, err := s.Storer.IterReferences()
+	c.Assert(err, IsNil)
+
+	var names []string
+	c.Assert(iter.ForEach(func(r *plumbing.Reference) error {
+		names = append(names, r.Name().String())
+		return nil
+	}), IsNil)
+
+	sort.Strings(names)
+	c.Assert(names, DeepEquals, []string{"HEAD", "refs/heads/foo"})
+}
+
 func (s *BaseStorageSuite) TestGetReferenceNotFound(c *C) {
 	r, err := s.Storer.Reference(plumbing.ReferenceName("bar"))
 	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
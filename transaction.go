@@ -0,0 +1,119 @@
+package git
+
+import (
+	"errors"
+
+	"github.com/goabstract/go-git/v5/config"
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/plumbing/format/reflog"
+	"github.com/goabstract/go-git/v5/plumbing/storer"
+)
+
+var (
+	// ErrReferenceTransactionNotSupported is returned when the storer of the
+	// repository can't update many references at once.
+	ErrReferenceTransactionNotSupported = errors.New("storer doesn't support reference transactions")
+)
+
+// ReferenceTransaction is a set of updates of references applied all at
+// once, as `git update-ref --stdin` does: either every update is applied, or
+// none of them. The updates are queued with Create, Update, Delete and
+// Verify, and applied by Commit.
+type ReferenceTransaction struct {
+	r       *Repository
+	updates []storer.ReferenceUpdate
+}
+
+// ReferenceTransaction returns a new empty transaction of the references of
+// the repository.
+func (r *Repository) ReferenceTransaction() *ReferenceTransaction {
+	return &ReferenceTransaction{r: r}
+}
+
+// Create queues the creation of the given reference, which must not exist.
+func (t *ReferenceTransaction) Create(ref *plumbing.Reference) {
+	t.queue(storer.CreateReference, ref.Name(), ref, nil)
+}
+
+// Update queues setting the given reference. If old is not nil, the current
+// value of the reference must be old.
+func (t *ReferenceTransaction) Update(ref, old *plumbing.Reference) {
+	t.queue(storer.UpdateReference, ref.Name(), ref, old)
+}
+
+// Delete queues the removal of the given reference, and its reflog. If old
+// is not nil, the current value of the reference must be old.
+func (t *ReferenceTransaction) Delete(name plumbing.ReferenceName, old *plumbing.Reference) {
+	t.queue(storer.DeleteReference, name, nil, old)
+}
+
+// Verify queues a check of the current value of the given reference, it
+// must be old, or the reference must not exist if old is nil.
+func (t *ReferenceTransaction) Verify(name plumbing.ReferenceName, old *plumbing.Reference) {
+	t.queue(storer.VerifyReference, name, nil, old)
+}
+
+func (t *ReferenceTransaction) queue(action storer.ReferenceUpdateAction,
+	name plumbing.ReferenceName, ref, old *plumbing.Reference) {
+
+	t.updates = append(t.updates, storer.ReferenceUpdate{
+		Action: action,
+		Name:   name,
+		New:    ref,
+		Old:    old,
+	})
+}
+
+// Commit applies all the queued updates, or none of them, returning
+// storage.ErrReferenceHasChanged, if any reference hasn't the expected
+// value. The changes are recorded in the reflogs with the given message,
+// unless it is empty. The transaction is empty after a successful commit.
+func (t *ReferenceTransaction) Commit(msg string) error {
+	s := t.r.Storer
+	ts, ok := s.(storer.ReferenceTransactionStorer)
+	if !ok {
+		return ErrReferenceTransactionNotSupported
+	}
+
+	before := make([]plumbing.Hash, len(t.updates))
+	for i, u := range t.updates {
+		before[i] = resolvedReferenceHash(s, u.Name)
+	}
+
+	var cfg *config.Config
+	if msg != "" {
+		var err error
+		if cfg, err = reflogConfig(s); err != nil {
+			return err
+		}
+	}
+
+	head, _ := s.Reference(plumbing.HEAD)
+	if err := ts.UpdateReferences(t.updates); err != nil {
+		return err
+	}
+
+	updates := t.updates
+	t.updates = nil
+
+	rs, ok := s.(storer.ReflogStorer)
+	if !ok || msg == "" {
+		return nil
+	}
+
+	committer := reflogSignature(cfg, nil)
+	for i, u := range updates {
+		if u.Action != storer.CreateReference && u.Action != storer.UpdateReference {
+			continue
+		}
+
+		logReferenceUpdate(cfg, rs, head, u.Name, &reflog.Entry{
+			Old:       before[i],
+			New:       resolvedReferenceHash(s, u.Name),
+			Committer: committer,
+			Message:   msg,
+		})
+	}
+
+	return nil
+}
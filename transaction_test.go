@@ -0,0 +1,82 @@
+package git
+
+import (
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/storage"
+
+	fixtures "github.com/go-git/go-git-fixtures/v4"
+	. "gopkg.in/check.v1"
+)
+
+type ReferenceTransactionSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&ReferenceTransactionSuite{})
+
+func (s *ReferenceTransactionSuite) TestCommit(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	master, err := r.Reference(plumbing.Master, false)
+	c.Assert(err, IsNil)
+	branch, err := r.Reference("refs/heads/branch", false)
+	c.Assert(err, IsNil)
+
+	release := plumbing.NewHashReference("refs/heads/release", master.Hash())
+	tag := plumbing.NewHashReference("refs/tags/v2.0.0", master.Hash())
+	moved := plumbing.NewHashReference(plumbing.Master, branch.Hash())
+
+	t := r.ReferenceTransaction()
+	t.Create(release)
+	t.Create(tag)
+	t.Update(moved, master)
+	t.Delete(branch.Name(), branch)
+	c.Assert(t.Commit("release: v2.0.0"), IsNil)
+
+	for _, ref := range []*plumbing.Reference{release, tag, moved} {
+		obtained, err := r.Reference(ref.Name(), false)
+		c.Assert(err, IsNil)
+		c.Assert(obtained, DeepEquals, ref)
+	}
+
+	_, err = r.Reference(branch.Name(), false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	entries, err := r.Reflog(plumbing.Master)
+	c.Assert(err, IsNil)
+	c.Assert(entries[0].Message, Equals, "release: v2.0.0")
+	c.Assert(entries[0].Old, Equals, master.Hash())
+	c.Assert(entries[0].New, Equals, branch.Hash())
+
+	entries, err = r.Reflog(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(entries[0].Message, Equals, "release: v2.0.0")
+
+	entries, err = r.Reflog(release.Name())
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].Old, Equals, plumbing.ZeroHash)
+}
+
+func (s *ReferenceTransactionSuite) TestCommitConflict(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	master, err := r.Reference(plumbing.Master, false)
+	c.Assert(err, IsNil)
+	branch, err := r.Reference("refs/heads/branch", false)
+	c.Assert(err, IsNil)
+
+	tag := plumbing.NewHashReference("refs/tags/v2.0.0", master.Hash())
+
+	t := r.ReferenceTransaction()
+	t.Create(tag)
+	t.Update(plumbing.NewHashReference(plumbing.Master, branch.Hash()), branch)
+	c.Assert(t.Commit("release: v2.0.0"), Equals, storage.ErrReferenceHasChanged)
+
+	_, err = r.Reference(tag.Name(), false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	obtained, err := r.Reference(plumbing.Master, false)
+	c.Assert(err, IsNil)
+	c.Assert(obtained, DeepEquals, master)
+}
@@ -0,0 +1,283 @@
+package git
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/goabstract/go-git/v5/config"
+	"github.com/goabstract/go-git/v5/plumbing"
+)
+
+var (
+	// ErrInvalidUpstream is returned by SetUpstream when the upstream is
+	// neither a local branch nor a remote-tracking branch of a remote.
+	ErrInvalidUpstream = errors.New("upstream is not a branch nor a remote-tracking branch")
+	// ErrUpstreamNameMismatch is returned pushing with push.default=simple
+	// to the upstream of the current branch when it has a different name.
+	ErrUpstreamNameMismatch = errors.New("upstream branch doesn't match the name of the current branch")
+	// ErrPushNothing is returned pushing without refspecs when push.default
+	// is nothing.
+	ErrPushNothing = errors.New("no refspec given and push.default is nothing")
+	// ErrPushDetachedHead is returned pushing without refspecs the current
+	// branch when HEAD is detached.
+	ErrPushDetachedHead = errors.New("HEAD is detached, there is no current branch to push")
+)
+
+const (
+	branchSection     = "branch"
+	branchRemoteKey   = "remote"
+	branchMergeKey    = "merge"
+	autoSetupMergeKey = "autoSetupMerge"
+	remoteSection     = "remote"
+	remotePushKey     = "push"
+	pushSection       = "push"
+	pushDefaultKey    = "default"
+)
+
+// SetUpstream sets the upstream of the given local branch, the branch merged
+// by `git pull` and compared by `git status`, as
+// `git branch --set-upstream-to` does. The upstream is a remote-tracking
+// branch, as "refs/remotes/origin/master", or a local branch.
+func (r *Repository) SetUpstream(branch string, upstream plumbing.ReferenceName) error {
+	if _, err := r.Storer.Reference(plumbing.NewBranchReferenceName(branch)); err != nil {
+		return err
+	}
+
+	if _, err := r.Storer.Reference(upstream); err != nil {
+		return err
+	}
+
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return err
+	}
+
+	remote, merge, err := upstreamBranch(cfg, upstream)
+	if err != nil {
+		return err
+	}
+
+	setBranchUpstream(cfg, branch, remote, merge)
+	return r.Storer.SetConfig(cfg)
+}
+
+// UnsetUpstream removes the upstream of the given local branch, as
+// `git branch --unset-upstream` does. ErrNoUpstream is returned if the branch
+// has no upstream.
+func (r *Repository) UnsetUpstream(branch string) error {
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return err
+	}
+
+	b, ok := cfg.Branches[branch]
+	if !ok || b.Remote == "" && b.Merge == "" {
+		return ErrNoUpstream
+	}
+
+	b.Remote, b.Merge = "", ""
+	if isEmptyBranchConfig(cfg, b) {
+		delete(cfg.Branches, branch)
+	}
+
+	return r.Storer.SetConfig(cfg)
+}
+
+// isEmptyBranchConfig returns true if the branch has no other option than
+// its upstream.
+func isEmptyBranchConfig(cfg *config.Config, b *config.Branch) bool {
+	if b.Remote != "" || b.Merge != "" || b.Rebase != "" {
+		return false
+	}
+
+	for _, o := range cfg.Raw.Section(branchSection).Subsection(b.Name).Options {
+		if !o.IsKey(branchRemoteKey) && !o.IsKey(branchMergeKey) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// upstreamBranch returns the remote and the branch of the remote of the given
+// upstream, mapping the remote-tracking branches back with the fetch refspecs
+// of the remotes. A local branch is in the "." remote.
+func upstreamBranch(cfg *config.Config, upstream plumbing.ReferenceName) (string, plumbing.ReferenceName, error) {
+	if upstream.IsBranch() {
+		return ".", upstream, nil
+	}
+
+	names := make([]string, 0, len(cfg.Remotes))
+	for name := range cfg.Remotes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		for _, spec := range cfg.Remotes[name].Fetch {
+			reverse := config.RefSpec(strings.TrimPrefix(spec.String(), "+")).Reverse()
+			if !reverse.Match(upstream) {
+				continue
+			}
+
+			if merge := reverse.Dst(upstream); merge.IsBranch() {
+				return name, merge, nil
+			}
+		}
+	}
+
+	return "", "", ErrInvalidUpstream
+}
+
+func setBranchUpstream(cfg *config.Config, branch, remote string, merge plumbing.ReferenceName) {
+	b, ok := cfg.Branches[branch]
+	if !ok {
+		b = &config.Branch{Name: branch}
+		cfg.Branches[branch] = b
+	}
+
+	b.Remote, b.Merge = remote, merge
+}
+
+// autoSetupUpstream sets the upstream of a branch created from the given
+// start point, following branch.autoSetupMerge: by default only the branches
+// created from a remote-tracking branch track it.
+func (r *Repository) autoSetupUpstream(branch string, start plumbing.ReferenceName) error {
+	merged, err := r.MergedConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return err
+	}
+
+	var remote string
+	var merge plumbing.ReferenceName
+	switch strings.ToLower(merged.Raw.Section(branchSection).Option(autoSetupMergeKey)) {
+	case "false":
+		return nil
+	case "inherit":
+		if b, ok := cfg.Branches[start.Short()]; ok && start.IsBranch() {
+			remote, merge = b.Remote, b.Merge
+			break
+		}
+
+		fallthrough
+	case "always":
+		if start.IsBranch() || start.IsRemote() {
+			remote, merge, err = upstreamBranch(cfg, start)
+		}
+	case "simple":
+		if start.IsRemote() {
+			remote, merge, err = upstreamBranch(cfg, start)
+			if err == nil && merge.Short() != branch {
+				return nil
+			}
+		}
+	default:
+		if start.IsRemote() {
+			remote, merge, err = upstreamBranch(cfg, start)
+		}
+	}
+
+	if err == ErrInvalidUpstream || remote == "" || merge == "" {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	setBranchUpstream(cfg, branch, remote, merge)
+	return r.Storer.SetConfig(cfg)
+}
+
+// defaultPushOptions returns a copy of the options of a push without
+// refspecs, with the remote and the refspecs set as git does: the
+// remote.<name>.push refspecs of the remote, if any, or the ones following
+// push.default, pushing by default the current branch to its upstream.
+func (r *Repository) defaultPushOptions(opts *PushOptions) (*PushOptions, error) {
+	cfg, err := r.MergedConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var branch string
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return nil, err
+	}
+
+	o := *opts
+	o.RefSpecs = nil
+
+	if head != nil && head.Type() == plumbing.SymbolicReference && head.Target().IsBranch() {
+		branch = head.Target().Short()
+	}
+
+	if o.RemoteName == "" && branch != "" {
+		o.RemoteName = pushRemoteName(cfg, branch)
+	}
+
+	if o.RemoteName == "" {
+		o.RemoteName = DefaultRemoteName
+	}
+
+	for _, spec := range cfg.Raw.Section(remoteSection).Subsection(o.RemoteName).Options.GetAll(remotePushKey) {
+		o.RefSpecs = append(o.RefSpecs, config.RefSpec(spec))
+	}
+
+	if len(o.RefSpecs) != 0 {
+		return &o, nil
+	}
+
+	mode := strings.ToLower(cfg.Raw.Section(pushSection).Option(pushDefaultKey))
+	switch mode {
+	case "nothing":
+		return nil, ErrPushNothing
+	case "matching":
+		o.RefSpecs = []config.RefSpec{":"}
+		return &o, nil
+	}
+
+	if branch == "" {
+		return nil, ErrPushDetachedHead
+	}
+
+	src := plumbing.NewBranchReferenceName(branch)
+	dst := src
+	b := cfg.Branches[branch]
+	fetchRemote := DefaultRemoteName
+	if b != nil && b.Remote != "" {
+		fetchRemote = b.Remote
+	}
+
+	switch {
+	case mode == "current":
+	case mode == "upstream" || mode == "tracking":
+		if b == nil || b.Merge == "" || b.Remote != o.RemoteName {
+			return nil, ErrNoUpstream
+		}
+
+		dst = b.Merge
+	case mode == "" || mode == "simple":
+		// pushing to another remote than the one fetched, as `current`
+		if o.RemoteName != fetchRemote {
+			break
+		}
+
+		if b == nil || b.Merge == "" {
+			return nil, ErrNoUpstream
+		}
+
+		if b.Merge != src {
+			return nil, ErrUpstreamNameMismatch
+		}
+	}
+
+	o.RefSpecs = []config.RefSpec{config.RefSpec(src + ":" + dst)}
+	return &o, nil
+}
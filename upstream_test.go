@@ -0,0 +1,243 @@
+package git
+
+import (
+	"github.com/goabstract/go-git/v5/config"
+	"github.com/goabstract/go-git/v5/plumbing"
+	"github.com/goabstract/go-git/v5/storage/memory"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	fixtures "github.com/go-git/go-git-fixtures/v4"
+	. "gopkg.in/check.v1"
+)
+
+type UpstreamSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&UpstreamSuite{})
+
+func (s *UpstreamSuite) TestSetUpstream(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	c.Assert(r.SetUpstream("branch", "refs/remotes/origin/master"), IsNil)
+	b, err := r.Branch("branch")
+	c.Assert(err, IsNil)
+	c.Assert(b.Remote, Equals, "origin")
+	c.Assert(b.Merge, Equals, plumbing.Master)
+
+	c.Assert(r.SetUpstream("branch", plumbing.Master), IsNil)
+	b, err = r.Branch("branch")
+	c.Assert(err, IsNil)
+	c.Assert(b.Remote, Equals, ".")
+	c.Assert(b.Merge, Equals, plumbing.Master)
+
+	ahead, behind, err := r.AheadBehind("branch")
+	c.Assert(err, IsNil)
+	c.Assert([]int{ahead, behind}, DeepEquals, []int{1, 1})
+
+	c.Assert(r.SetUpstream("branch", "refs/tags/v1.0.0"), Equals, ErrInvalidUpstream)
+	c.Assert(r.SetUpstream("branch", "refs/remotes/origin/foo"), Equals, plumbing.ErrReferenceNotFound)
+	c.Assert(r.SetUpstream("foo", plumbing.Master), Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *UpstreamSuite) TestUnsetUpstream(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+
+	c.Assert(r.UnsetUpstream("master"), IsNil)
+	_, err := r.Branch("master")
+	c.Assert(err, Equals, ErrBranchNotFound)
+	c.Assert(r.UnsetUpstream("master"), Equals, ErrNoUpstream)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Branches["branch"].Rebase = "true"
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	c.Assert(r.UnsetUpstream("branch"), IsNil)
+	b, err := r.Branch("branch")
+	c.Assert(err, IsNil)
+	c.Assert(b.Remote, Equals, "")
+	c.Assert(b.Merge, Equals, plumbing.ReferenceName(""))
+	c.Assert(b.Rebase, Equals, "true")
+}
+
+func (s *UpstreamSuite) TestCheckoutCreateTracks(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(w.Checkout(&CheckoutOptions{
+		Branch:     "refs/heads/foo",
+		Create:     true,
+		StartPoint: "refs/remotes/origin/branch",
+	}), IsNil)
+
+	b, err := r.Branch("foo")
+	c.Assert(err, IsNil)
+	c.Assert(b.Remote, Equals, "origin")
+	c.Assert(b.Merge, Equals, plumbing.ReferenceName("refs/heads/branch"))
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.ReferenceName("refs/heads/foo"))
+	c.Assert(head.Hash().String(), Equals, "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+
+	for _, opts := range []*CheckoutOptions{
+		{Branch: "refs/heads/bar", Create: true, StartPoint: "refs/remotes/origin/branch", NoTrack: true},
+		{Branch: "refs/heads/qux", Create: true, StartPoint: plumbing.Master},
+		{Branch: "refs/heads/baz", Create: true},
+	} {
+		c.Assert(w.Checkout(opts), IsNil)
+		_, err = r.Branch(opts.Branch.Short())
+		c.Assert(err, Equals, ErrBranchNotFound)
+	}
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("branch").SetOption("autoSetupMerge", "always")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	c.Assert(w.Checkout(&CheckoutOptions{
+		Branch:     "refs/heads/quux",
+		Create:     true,
+		StartPoint: plumbing.Master,
+	}), IsNil)
+
+	b, err = r.Branch("quux")
+	c.Assert(err, IsNil)
+	c.Assert(b.Remote, Equals, ".")
+	c.Assert(b.Merge, Equals, plumbing.Master)
+}
+
+func (s *UpstreamSuite) TestCheckoutOptionsStartPoint(c *C) {
+	o := &CheckoutOptions{Branch: "refs/heads/foo", StartPoint: plumbing.Master}
+	c.Assert(o.Validate(), Equals, ErrStartPointRequiresCreate)
+
+	o = &CheckoutOptions{
+		Branch:     "refs/heads/foo",
+		Create:     true,
+		StartPoint: plumbing.Master,
+		Hash:       plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+	}
+	c.Assert(o.Validate(), Equals, ErrStartPointHashExclusive)
+}
+
+// pushSetup returns a bare server cloned from the basic fixture, with the
+// master and branch branches, and a clone of it with HEAD at master tracking
+// origin/master.
+func (s *UpstreamSuite) pushSetup(c *C) (server, r *Repository) {
+	url := c.MkDir()
+	server, err := PlainClone(url, true, &CloneOptions{
+		URL: fixtures.Basic().One().DotGit().Root(),
+	})
+	c.Assert(err, IsNil)
+
+	branch := plumbing.NewReferenceFromStrings("refs/heads/branch", "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	c.Assert(server.Storer.SetReference(branch), IsNil)
+
+	r, err = Clone(memory.NewStorage(), memfs.New(), &CloneOptions{URL: url})
+	c.Assert(err, IsNil)
+	return server, r
+}
+
+func (s *UpstreamSuite) setPushDefault(c *C, r *Repository, mode string) {
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("push").SetOption("default", mode)
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+}
+
+func (s *UpstreamSuite) TestPushDefaultSimple(c *C) {
+	server, r := s.pushSetup(c)
+	parent := plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+	c.Assert(server.Storer.SetReference(plumbing.NewHashReference(plumbing.Master, parent)), IsNil)
+
+	o := &PushOptions{}
+	c.Assert(r.Push(o), IsNil)
+	AssertReferences(c, server, map[string]string{
+		"refs/heads/master": "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+	})
+
+	// the options of the caller are left untouched
+	c.Assert(o.RemoteName, Equals, "")
+	c.Assert(o.RefSpecs, HasLen, 0)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	c.Assert(w.Checkout(&CheckoutOptions{Branch: "refs/heads/feature", Create: true}), IsNil)
+	c.Assert(r.Push(&PushOptions{}), Equals, ErrNoUpstream)
+
+	c.Assert(r.SetUpstream("feature", "refs/remotes/origin/master"), IsNil)
+	c.Assert(r.Push(&PushOptions{}), Equals, ErrUpstreamNameMismatch)
+
+	// pushing to another remote than the upstream pushes the current branch
+	url := c.MkDir()
+	other, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
+	_, err = r.CreateRemote(&config.RemoteConfig{Name: "other", URLs: []string{url}})
+	c.Assert(err, IsNil)
+
+	c.Assert(r.Push(&PushOptions{RemoteName: "other"}), IsNil)
+	AssertReferences(c, other, map[string]string{
+		"refs/heads/feature": "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+	})
+	_, err = other.Reference(plumbing.Master, false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *UpstreamSuite) TestPushDefaultModes(c *C) {
+	server, r := s.pushSetup(c)
+	master := "6ecf0ef2c2dffb796033e5a02219af86ec6584e5"
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	c.Assert(w.Checkout(&CheckoutOptions{Branch: "refs/heads/feature", Create: true}), IsNil)
+	c.Assert(r.SetUpstream("feature", "refs/remotes/origin/branch"), IsNil)
+
+	s.setPushDefault(c, r, "current")
+	c.Assert(r.Push(&PushOptions{}), IsNil)
+	AssertReferences(c, server, map[string]string{"refs/heads/feature": master})
+
+	s.setPushDefault(c, r, "upstream")
+	err = r.Push(&PushOptions{})
+	c.Assert(err, ErrorMatches, ".*non-fast-forward.*")
+
+	c.Assert(server.Storer.RemoveReference("refs/heads/feature"), IsNil)
+	c.Assert(r.SetUpstream("feature", "refs/remotes/origin/master"), IsNil)
+	c.Assert(r.Push(&PushOptions{}), Equals, NoErrAlreadyUpToDate)
+	_, err = server.Reference("refs/heads/feature", false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	s.setPushDefault(c, r, "nothing")
+	c.Assert(r.Push(&PushOptions{}), Equals, ErrPushNothing)
+
+	// matching only pushes the branches existing on both sides
+	s.setPushDefault(c, r, "matching")
+	parent := plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+	c.Assert(server.Storer.SetReference(plumbing.NewHashReference("refs/heads/branch", parent)), IsNil)
+	c.Assert(r.Storer.SetReference(plumbing.NewHashReference("refs/heads/branch", plumbing.NewHash(master))), IsNil)
+	c.Assert(r.Push(&PushOptions{}), IsNil)
+	AssertReferences(c, server, map[string]string{"refs/heads/branch": master})
+	_, err = server.Reference("refs/heads/feature", false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	// the push refspecs of the remote are used first
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("remote").Subsection("origin").SetOption("push", "refs/heads/feature:refs/heads/pushed")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+	c.Assert(r.Push(&PushOptions{}), IsNil)
+	AssertReferences(c, server, map[string]string{"refs/heads/pushed": master})
+}
+
+func (s *UpstreamSuite) TestPushDefaultDetached(c *C) {
+	_, r := s.pushSetup(c)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	c.Assert(w.Checkout(&CheckoutOptions{
+		Hash: plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294"),
+	}), IsNil)
+
+	c.Assert(r.Push(&PushOptions{}), Equals, ErrPushDetachedHead)
+}
@@ -75,9 +75,10 @@ func (w *Worktree) PullContext(ctx context.Context, o *PullOptions) error {
 		RemoteName: o.RemoteName,
 		Depth:      o.Depth,
 		Auth:       o.Auth,
+		HTTP:       o.HTTP,
 		Progress:   o.Progress,
 		Force:      o.Force,
-	})
+	}, reflogAction("pull"))
 
 	updated := true
 	if err == NoErrAlreadyUpToDate {
@@ -111,14 +112,14 @@ func (w *Worktree) PullContext(ctx context.Context, o *PullOptions) error {
 		return err
 	}
 
-	if err := w.updateHEAD(ref.Hash()); err != nil {
+	if err := w.updateHEAD(ref.Hash(), nil, "pull: Fast-forward"); err != nil {
 		return err
 	}
 
-	if err := w.Reset(&ResetOptions{
+	if err := w.reset(&ResetOptions{
 		Mode:   MergeReset,
 		Commit: ref.Hash(),
-	}); err != nil {
+	}, ""); err != nil {
 		return err
 	}
 
@@ -165,18 +166,39 @@ func (w *Worktree) Checkout(opts *CheckoutOptions) error {
 		ro.Mode = SoftReset
 	}
 
+	from, err := w.checkoutReflogName()
+	if err != nil {
+		return err
+	}
+
 	if !opts.Hash.IsZero() && !opts.Create {
-		err = w.setHEADToCommit(opts.Hash)
+		err = w.setHEADToCommit(opts.Hash, checkoutReflogPrefix+from+" to "+opts.Hash.String())
 	} else {
-		err = w.setHEADToBranch(opts.Branch, c)
+		err = w.setHEADToBranch(opts.Branch, c, checkoutReflogPrefix+from+" to "+opts.Branch.Short())
 	}
 
 	if err != nil {
 		return err
 	}
 
-	return w.Reset(ro)
+	return w.reset(ro, "")
+}
+
+// checkoutReflogName returns the name of the current branch, or the hash of
+// HEAD when detached, as written in the checkout messages of the reflog.
+func (w *Worktree) checkoutReflogName() (string, error) {
+	head, err := w.r.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return "", err
+	}
+
+	if head.Type() == plumbing.SymbolicReference {
+		return head.Target().Short(), nil
+	}
+
+	return head.Hash().String(), nil
 }
+
 func (w *Worktree) createBranch(opts *CheckoutOptions) error {
 	_, err := w.r.Storer.Reference(opts.Branch)
 	if err == nil {
@@ -187,18 +209,41 @@ func (w *Worktree) createBranch(opts *CheckoutOptions) error {
 		return err
 	}
 
-	if opts.Hash.IsZero() {
+	from := opts.Hash.String()
+	switch {
+	case opts.StartPoint != "":
+		ref, err := storer.ResolveReference(w.r.Storer, opts.StartPoint)
+		if err != nil {
+			return err
+		}
+
+		if opts.Hash, err = w.r.resolveToCommitHash(ref.Hash()); err != nil {
+			return err
+		}
+
+		from = opts.StartPoint.Short()
+	case opts.Hash.IsZero():
 		ref, err := w.r.Head()
 		if err != nil {
 			return err
 		}
 
 		opts.Hash = ref.Hash()
+		from = plumbing.HEAD.String()
 	}
 
-	return w.r.Storer.SetReference(
+	if err := updateReference(w.r.Storer, nil,
 		plumbing.NewHashReference(opts.Branch, opts.Hash),
-	)
+		nil, nil, "branch: Created from "+from,
+	); err != nil {
+		return err
+	}
+
+	if opts.StartPoint == "" || opts.NoTrack {
+		return nil
+	}
+
+	return w.r.autoSetupUpstream(opts.Branch.Short(), opts.StartPoint)
 }
 
 func (w *Worktree) getCommitFromCheckoutOptions(opts *CheckoutOptions) (plumbing.Hash, error) {
@@ -234,12 +279,12 @@ func (w *Worktree) getCommitFromCheckoutOptions(opts *CheckoutOptions) (plumbing
 	return plumbing.ZeroHash, fmt.Errorf("unsupported tag target %q", o.Type())
 }
 
-func (w *Worktree) setHEADToCommit(commit plumbing.Hash) error {
+func (w *Worktree) setHEADToCommit(commit plumbing.Hash, msg string) error {
 	head := plumbing.NewHashReference(plumbing.HEAD, commit)
-	return w.r.Storer.SetReference(head)
+	return updateReference(w.r.Storer, nil, head, nil, nil, msg)
 }
 
-func (w *Worktree) setHEADToBranch(branch plumbing.ReferenceName, commit plumbing.Hash) error {
+func (w *Worktree) setHEADToBranch(branch plumbing.ReferenceName, commit plumbing.Hash, msg string) error {
 	target, err := w.r.Storer.Reference(branch)
 	if err != nil {
 		return err
@@ -252,7 +297,7 @@ func (w *Worktree) setHEADToBranch(branch plumbing.ReferenceName, commit plumbin
 		head = plumbing.NewHashReference(plumbing.HEAD, commit)
 	}
 
-	return w.r.Storer.SetReference(head)
+	return updateReference(w.r.Storer, nil, head, nil, nil, msg)
 }
 
 // Reset the worktree to a specified state.
@@ -261,6 +306,16 @@ func (w *Worktree) Reset(opts *ResetOptions) error {
 		return err
 	}
 
+	return w.reset(opts, "reset: moving to "+opts.Commit.String())
+}
+
+// reset resets the worktree, recording the update of HEAD in the reflog with
+// the given message, if any.
+func (w *Worktree) reset(opts *ResetOptions, msg string) error {
+	if err := opts.Validate(w.r); err != nil {
+		return err
+	}
+
 	if opts.Mode == MergeReset {
 		unstaged, err := w.containsUnstagedChanges()
 		if err != nil {
@@ -272,7 +327,7 @@ func (w *Worktree) Reset(opts *ResetOptions) error {
 		}
 	}
 
-	if err := w.setHEADCommit(opts.Commit); err != nil {
+	if err := w.setHEADCommit(opts.Commit, msg); err != nil {
 		return err
 	}
 
@@ -423,7 +478,7 @@ func (w *Worktree) containsUnstagedChanges() (bool, error) {
 	return false, nil
 }
 
-func (w *Worktree) setHEADCommit(commit plumbing.Hash) error {
+func (w *Worktree) setHEADCommit(commit plumbing.Hash, msg string) error {
 	head, err := w.r.Reference(plumbing.HEAD, false)
 	if err != nil {
 		return err
@@ -431,7 +486,7 @@ func (w *Worktree) setHEADCommit(commit plumbing.Hash) error {
 
 	if head.Type() == plumbing.HashReference {
 		head = plumbing.NewHashReference(plumbing.HEAD, commit)
-		return w.r.Storer.SetReference(head)
+		return updateReference(w.r.Storer, nil, head, nil, nil, msg)
 	}
 
 	branch, err := w.r.Reference(head.Target(), false)
@@ -444,7 +499,7 @@ func (w *Worktree) setHEADCommit(commit plumbing.Hash) error {
 	}
 
 	branch = plumbing.NewHashReference(branch.Name(), commit)
-	return w.r.Storer.SetReference(branch)
+	return updateReference(w.r.Storer, nil, branch, nil, nil, msg)
 }
 
 func (w *Worktree) checkoutChangeSubmodule(name string,
@@ -2,7 +2,10 @@ package git
 
 import (
 	"bytes"
+	"io/ioutil"
+	"os"
 	"path"
+	"path/filepath"
 	"sort"
 	"strings"
 
@@ -17,12 +20,19 @@ import (
 )
 
 // Commit stores the current contents of the index in a new commit along with
-// a log message from the user describing the changes.
+// a log message from the user describing the changes. If the message is empty,
+// the commit.template file is used, and the message is cleaned up following
+// commit.cleanup.
 func (w *Worktree) Commit(msg string, opts *CommitOptions) (plumbing.Hash, error) {
 	if err := opts.Validate(w.r); err != nil {
 		return plumbing.ZeroHash, err
 	}
 
+	msg, err := w.commitMessage(msg)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
 	if opts.All {
 		if err := w.autoAddModifiedAndDeleted(); err != nil {
 			return plumbing.ZeroHash, err
@@ -49,7 +59,7 @@ func (w *Worktree) Commit(msg string, opts *CommitOptions) (plumbing.Hash, error
 		return plumbing.ZeroHash, err
 	}
 
-	return commit, w.updateHEAD(commit)
+	return commit, w.updateHEAD(commit, opts.Committer, commitReflogMessage(msg, opts.Parents))
 }
 
 func (w *Worktree) autoAddModifiedAndDeleted() error {
@@ -71,7 +81,7 @@ func (w *Worktree) autoAddModifiedAndDeleted() error {
 	return nil
 }
 
-func (w *Worktree) updateHEAD(commit plumbing.Hash) error {
+func (w *Worktree) updateHEAD(commit plumbing.Hash, committer *object.Signature, msg string) error {
 	head, err := w.r.Storer.Reference(plumbing.HEAD)
 	if err != nil {
 		return err
@@ -83,7 +93,63 @@ func (w *Worktree) updateHEAD(commit plumbing.Hash) error {
 	}
 
 	ref := plumbing.NewHashReference(name, commit)
-	return w.r.Storer.SetReference(ref)
+	return updateReference(w.r.Storer, nil, ref, nil, committer, msg)
+}
+
+// commitMessage returns the message of a commit: the given one, or the
+// content of the commit.template file if empty, cleaned up following
+// commit.cleanup. Without commit.cleanup the given message is kept verbatim,
+// and the template is stripped of its comments.
+func (w *Worktree) commitMessage(msg string) (string, error) {
+	cfg, err := w.r.MergedConfig()
+	if err != nil {
+		return "", err
+	}
+
+	mode := cfg.Commit.Cleanup
+	edited := msg == "" && cfg.Commit.Template != ""
+	if edited {
+		b, err := ioutil.ReadFile(expandHomePath(cfg.Commit.Template))
+		if err != nil {
+			return "", err
+		}
+
+		msg = string(b)
+		if mode == "" {
+			mode = cleanupDefault
+		}
+	}
+
+	return cleanupMessage(msg, mode, cfg.Core.CommentChar, edited)
+}
+
+// expandHomePath expands a leading "~/" of the path to the home directory of
+// the user.
+func expandHomePath(p string) string {
+	if !strings.HasPrefix(p, "~/") {
+		return p
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+
+	return filepath.Join(home, p[2:])
+}
+
+// commitReflogMessage returns the reflog message of a commit, the same as
+// `git commit` writes.
+func commitReflogMessage(msg string, parents []plumbing.Hash) string {
+	action := "commit"
+	switch {
+	case len(parents) == 0:
+		action = "commit (initial)"
+	case len(parents) > 1:
+		action = "commit (merge)"
+	}
+
+	return action + ": " + commitSubject(msg)
 }
 
 func (w *Worktree) buildCommitObject(msg string, opts *CommitOptions, tree plumbing.Hash) (plumbing.Hash, error) {
@@ -5,9 +5,11 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/goabstract/go-git/v5/config"
 	"github.com/goabstract/go-git/v5/plumbing"
 	"github.com/goabstract/go-git/v5/plumbing/cache"
 	"github.com/goabstract/go-git/v5/plumbing/object"
@@ -202,6 +204,161 @@ func (s *WorktreeSuite) TestCommitSignBadKey(c *C) {
 	c.Assert(err, Equals, errors.InvalidArgumentError("signing key is encrypted"))
 }
 
+// commitSetup returns a repository with a staged file and the given user,
+// commit and tag settings in its config.
+func commitSetup(c *C, setup func(cfg *config.Config)) (*Repository, *Worktree) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Storer.Config()
+	c.Assert(err, IsNil)
+	setup(cfg)
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(fs, "foo", []byte("foo"), 0644), IsNil)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	return r, w
+}
+
+func (s *WorktreeSuite) TestCommitDefaultsFromConfig(c *C) {
+	key := commitSignKey(c, true)
+	r, w := commitSetup(c, func(cfg *config.Config) {
+		cfg.User.Name = "foo bar"
+		cfg.User.Email = "foo@foo.foo"
+		cfg.Commit.GpgSign = true
+	})
+
+	// without a key ring commit.gpgSign can't be honoured
+	hash, err := w.Commit("foo\n", &CommitOptions{})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.PGPSignature, Equals, "")
+
+	hash, err = w.Commit("foo\n", &CommitOptions{KeyRing: openpgp.EntityList{key}})
+	c.Assert(err, IsNil)
+
+	commit, err = r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Author.Name, Equals, "foo bar")
+	c.Assert(commit.Author.Email, Equals, "foo@foo.foo")
+	c.Assert(commit.Committer, DeepEquals, commit.Author)
+	c.Assert(commit.PGPSignature, Not(Equals), "")
+
+	cfg, err := r.Storer.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("gpg").SetOption("format", "ssh")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	hash, err = w.Commit("foo\n", &CommitOptions{KeyRing: openpgp.EntityList{key}})
+	c.Assert(err, IsNil)
+
+	commit, err = r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.PGPSignature, Equals, "")
+
+	cfg.Raw.RemoveSection("gpg")
+	cfg.User.SigningKey = "0xDEADBEEF"
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	_, err = w.Commit("foo\n", &CommitOptions{KeyRing: openpgp.EntityList{key}})
+	c.Assert(err, Equals, ErrMissingSignKey)
+}
+
+func (s *WorktreeSuite) TestCommitIdentityFromEnv(c *C) {
+	env := map[string]string{
+		"GIT_AUTHOR_NAME":     "author",
+		"GIT_AUTHOR_EMAIL":    "author@example.com",
+		"GIT_AUTHOR_DATE":     "@1234567890 +0200",
+		"GIT_COMMITTER_EMAIL": "committer@example.com",
+		"GIT_COMMITTER_DATE":  "2009-02-14 01:31:30 +0200",
+	}
+
+	for name, value := range env {
+		os.Setenv(name, value)
+		defer os.Unsetenv(name)
+	}
+
+	r, w := commitSetup(c, func(cfg *config.Config) {
+		cfg.User.Name = "foo"
+		cfg.User.Email = "foo@foo.foo"
+	})
+
+	hash, err := w.Commit("foo\n", &CommitOptions{})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Author.Name, Equals, "foo")
+	c.Assert(commit.Committer.Email, Equals, "foo@foo.foo")
+
+	hash, err = w.Commit("bar\n", &CommitOptions{IdentityFromEnv: true})
+	c.Assert(err, IsNil)
+
+	commit, err = r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Author.Name, Equals, "author")
+	c.Assert(commit.Author.Email, Equals, "author@example.com")
+	c.Assert(commit.Author.When.Unix(), Equals, int64(1234567890))
+	c.Assert(commit.Author.When.Format("-0700"), Equals, "+0200")
+	c.Assert(commit.Committer.Name, Equals, "foo")
+	c.Assert(commit.Committer.Email, Equals, "committer@example.com")
+	c.Assert(commit.Committer.When.Unix(), Equals, int64(1234567890))
+
+	os.Setenv("GIT_AUTHOR_DATE", "yesterday")
+	_, err = w.Commit("baz\n", &CommitOptions{IdentityFromEnv: true})
+	c.Assert(err, Equals, ErrInvalidDate)
+}
+
+func (s *WorktreeSuite) TestCommitTemplateAndCleanup(c *C) {
+	template := filepath.Join(c.MkDir(), "template")
+	err := ioutil.WriteFile(template, []byte("\n# comment\nfoo  \n\n\nbar\n\n"), 0644)
+	c.Assert(err, IsNil)
+
+	r, w := commitSetup(c, func(cfg *config.Config) {
+		cfg.Commit.Template = template
+	})
+
+	for _, t := range []struct {
+		cleanup, msg, expected string
+	}{
+		{"", "", "foo\n\nbar\n"},
+		{"", " foo  \n", " foo  \n"},
+		{"verbatim", "", "\n# comment\nfoo  \n\n\nbar\n\n"},
+		{"whitespace", "", "# comment\nfoo\n\nbar\n"},
+		{"default", "# foo\n\n", "# foo\n"},
+		{"strip", "# foo\nbar", "bar\n"},
+		{"scissors", "", "# comment\nfoo\n\nbar\n"},
+	} {
+		cfg, err := r.Storer.Config()
+		c.Assert(err, IsNil)
+		cfg.Raw.Section("commit").SetOption("cleanup", t.cleanup)
+		c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+		hash, err := w.Commit(t.msg, &CommitOptions{Author: defaultSignature()})
+		c.Assert(err, IsNil)
+
+		commit, err := r.CommitObject(hash)
+		c.Assert(err, IsNil)
+		c.Assert(commit.Message, Equals, t.expected, Commentf("cleanup %q", t.cleanup))
+	}
+
+	cfg, err := r.Storer.Config()
+	c.Assert(err, IsNil)
+	cfg.Commit.Cleanup = "foo"
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	_, err = w.Commit("foo", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, Equals, ErrInvalidCleanupMode)
+}
+
 func (s *WorktreeSuite) TestCommitTreeSort(c *C) {
 	path, err := ioutil.TempDir(os.TempDir(), "test-commit-tree-sort")
 	c.Assert(err, IsNil)
@@ -234,7 +391,9 @@ func (s *WorktreeSuite) TestCommitTreeSort(c *C) {
 	})
 	c.Assert(err, IsNil)
 
-	err = r.Push(&PushOptions{})
+	err = r.Push(&PushOptions{
+		RefSpecs: []config.RefSpec{"refs/heads/master:refs/heads/master"},
+	})
 	c.Assert(err, IsNil)
 
 	cmd := exec.Command("git", "fsck")